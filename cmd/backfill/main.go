@@ -0,0 +1,53 @@
+// Backfill copies the messages of the legacy `messages` table into the monthly bucketed `user_messages` table.
+// Run it once the bucketed tables are migrated & the service writes to them.
+package main
+
+import (
+	"chat-system/internal/config"
+	dbmanager "chat-system/internal/db_manager"
+	"chat-system/internal/logging"
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+func main() {
+	cfg, _, err := config.Load("backfill", os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err == nil {
+		// Only the logs & the DB are relied on, e.g there's no need for the JWT secret
+		err = cfg.Validate(config.SECTION_LOG, config.SECTION_DB)
+	}
+	if err != nil {
+		log.Fatalf("Invalid config:\n%v", err)
+	}
+
+	logging.Init(cfg.Log)
+
+	// Only Cassandra ever had the legacy table
+	if cfg.DB.Storage != dbmanager.STORAGE_CASSANDRA {
+		logging.Fatal("Nothing to backfill", "storage", cfg.DB.Storage)
+	}
+
+	csSession := dbmanager.InitCassandra(cfg.DB.Cassandra)
+
+	// Interrupting stops copying right away, while running it again picks up safely
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	copied, err := dbmanager.BackfillMessageBuckets(ctx, csSession)
+	// Closed before exiting on failure, which would skip any deferred call
+	csSession.Close()
+	if err != nil {
+		logging.Fatal("Backfill stopped", "copied", copied, "error", err)
+	}
+
+	slog.Info("Backfilled messages", "copied", copied)
+}
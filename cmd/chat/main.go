@@ -1,32 +1,167 @@
 package main
 
 import (
+	appconfig "chat-system/internal/api/app_config"
+	"chat-system/internal/api/auth"
 	"chat-system/internal/api/cache"
+	"chat-system/internal/api/realtime"
 	"chat-system/internal/api/routes"
+	"chat-system/internal/config"
 	dbmanager "chat-system/internal/db_manager"
+	"chat-system/internal/logging"
+	"chat-system/internal/metrics"
+	"chat-system/internal/tracing"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
-
-	"github.com/joho/godotenv"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 )
 
 func main() {
-	loadEnv()
+	cfg, args := loadConfig()
+
+	// Migrating only relies on the logs & the DB, not on the secrets & the dependencies of the API
+	if len(args) > 0 && args[0] == "migrate" {
+		validateConfig(cfg, config.SECTION_LOG, config.SECTION_DB)
+		logging.Init(cfg.Log)
+		migrate(cfg, args[1:])
+		return
+	}
+
+	validateConfig(cfg, config.ALL_SECTIONS...)
+	logging.Init(cfg.Log)
+	// Exiting only once serve returned, so that every connection it opened got closed
+	if err := serve(cfg); err != nil {
+		logging.Fatal("Server stopped", "error", err)
+	}
+}
+
+// Time allowed to read the headers of a request, so idle clients can't hold connections forever
+const READ_HEADER_TIMEOUT = 10 * time.Second
 
-	csSession := dbmanager.InitDB()
-	defer csSession.Close()
+// serve runs the API until SIGINT or SIGTERM, then drains it before closing its connections in order:
+// HTTP requests & live sessions first, then the live events, the cache & the DB they rely on, then the spans left.
+// A server failing to listen shuts the other one down the same way.
+func serve(cfg *config.Config) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	cache.Init()
+	if err := tracing.Init(cfg.Tracing); err != nil {
+		return fmt.Errorf("failed to set tracing up: %w", err)
+	}
+	defer tracing.Close()
 
-	r := routes.InitRoutes()
+	db := dbmanager.Open(ctx, cfg.DB)
+	defer db.Close()
 
-	log.Fatal(http.ListenAndServe(":"+os.Getenv("APP_PORT"), r))
+	cacheStore := cache.Open(cfg.Cache)
+	defer cacheStore.Close()
+
+	tokens, err := auth.NewTokens(cfg.Auth, newRevocationList(cacheStore))
+	if err != nil {
+		return fmt.Errorf("failed to set the tokens up: %w", err)
+	}
+
+	live := realtime.Start(cacheStore.Client)
+	defer live.Close()
+
+	server := &http.Server{
+		Addr:              ":" + strconv.Itoa(cfg.Port),
+		Handler:           tracing.Handler(routes.InitRoutes(cfg, appconfig.Deps{DB: db, Cache: cacheStore, Tokens: tokens, Live: live})),
+		ReadHeaderTimeout: READ_HEADER_TIMEOUT,
+		ConnState:         countConnections,
+	}
+	// WebSockets are hijacked & SSE streams never go idle, so the server can't drain them on its own.
+	// Closing the hub ends their sessions instead.
+	server.RegisterOnShutdown(live.Hub.Close)
+
+	// Metrics are served apart from the API, for Prometheus only
+	metricsServer := &http.Server{
+		Addr:              ":" + strconv.Itoa(cfg.MetricsPort),
+		Handler:           routes.InitMetricsRoutes(),
+		ReadHeaderTimeout: READ_HEADER_TIMEOUT,
+	}
+
+	serveErr := make(chan error, 2)
+	go func() {
+		serveErr <- server.ListenAndServe()
+	}()
+	go func() {
+		serveErr <- metricsServer.ListenAndServe()
+	}()
+
+	var failure error
+	select {
+	case failure = <-serveErr:
+	case <-ctx.Done():
+	}
+	// A second signal kills the process right away
+	stop()
+
+	slog.Info("Shutting down, waiting for in-flight requests & live sessions", "timeout", cfg.ShutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		// Closing the connections left cancels the contexts of their requests, down to their queries
+		slog.Error("Failed to drain in-flight requests", "error", err)
+		server.Close()
+	}
+	if err := live.Hub.Wait(shutdownCtx); err != nil {
+		slog.Error("Failed to drain live sessions", "error", err)
+	}
+	// Scraped until the API is drained
+	if err := metricsServer.Shutdown(shutdownCtx); err != nil {
+		metricsServer.Close()
+	}
+
+	return failure
+}
+
+// newRevocationList shares the revoked tokens through Redis with the other replicas, if caching in it
+func newRevocationList(cacheStore *cache.Store) auth.RevocationList {
+	if cacheStore.Client == nil {
+		return auth.NewMemoryRevocationList()
+	}
+
+	return auth.NewRedisRevocationList(cacheStore.Client)
 }
 
-func loadEnv() {
-	err := godotenv.Load()
+// countConnections keeps track of the open connections, which stop counting once hijacked by WebSockets
+func countConnections(_ net.Conn, state http.ConnState) {
+	switch state {
+	case http.StateNew:
+		metrics.HTTPConnections.Inc()
+	case http.StateHijacked, http.StateClosed:
+		metrics.HTTPConnections.Dec()
+	}
+}
+
+func loadConfig() (*config.Config, []string) {
+	cfg, args, err := config.Load("chat", os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
 	if err != nil {
-		log.Fatalf("Error loading env file: %v", err)
+		log.Fatalf("Invalid config:\n%v", err)
+	}
+
+	return cfg, args
+}
+
+// validateConfig exits unless the sections are valid. Logs aren't set up until the config is validated, so its
+// errors are printed as they are, a line each.
+func validateConfig(cfg *config.Config, sections ...config.Section) {
+	if err := cfg.Validate(sections...); err != nil {
+		log.Fatalf("Invalid config:\n%v", err)
 	}
 }
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"chat-system/internal/cassandra"
+	"chat-system/internal/config"
+	dbmanager "chat-system/internal/db_manager"
+	"chat-system/internal/logging"
+	"chat-system/internal/migrations"
+	"chat-system/internal/postgres"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+)
+
+const migrateUsage = `Usage: chat migrate <command>
+
+Commands:
+  up [N]         Apply all or the next N pending migrations
+  down [N]       Revert all or the last N applied migrations
+  status         Print the current version & the pending migrations
+  force VERSION  Set the version without running any migration, e.g. to recover from a dirty state (-1 for none)`
+
+// migrate runs the migrations embedded within the binary against the app's storage, until SIGINT or SIGTERM
+// interrupt them: the migration running then fails, leaving the schema dirty, while the lock gets released
+func migrate(cfg *config.Config, args []string) {
+	if len(args) == 0 {
+		fmt.Println(migrateUsage)
+		os.Exit(2)
+	}
+
+	var run func(ctx context.Context, migrator *migrations.Migrator) error
+	switch args[0] {
+	case "up":
+		steps := stepsArg(args)
+		run = func(ctx context.Context, migrator *migrations.Migrator) error { return migrator.Up(ctx, steps) }
+	case "down":
+		steps := stepsArg(args)
+		run = func(ctx context.Context, migrator *migrations.Migrator) error { return migrator.Down(ctx, steps) }
+	case "status":
+		run = printStatus
+	case "force":
+		if len(args) < 2 {
+			logging.Fatal("force requires the version to set")
+		}
+		version, convErr := strconv.Atoi(args[1])
+		if convErr != nil {
+			logging.Fatal("Invalid version", "version", args[1], "error", convErr)
+		}
+		run = func(ctx context.Context, migrator *migrations.Migrator) error { return migrator.Force(ctx, version) }
+	default:
+		fmt.Println(migrateUsage)
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	db := dbmanager.Open(ctx, cfg.DB)
+	err := runMigrations(ctx, db, run)
+	// Closed before exiting on failure, which would skip any deferred call
+	db.Close()
+
+	if err != nil {
+		logging.Fatal("Migrate failed", "command", args[0], "error", err)
+	}
+}
+
+// runMigrations runs the command against the migrations of the storage, if it keeps any schema
+func runMigrations(ctx context.Context, db *dbmanager.DB, run func(ctx context.Context, migrator *migrations.Migrator) error) error {
+	if db.Storage == dbmanager.STORAGE_MEMORY {
+		slog.Info("Nothing to migrate, the app data is kept in memory")
+		return nil
+	}
+
+	migrator, err := newMigrator(ctx, db)
+	if err != nil {
+		return fmt.Errorf("failed to set up migrations: %w", err)
+	}
+
+	return run(ctx, migrator)
+}
+
+// newMigrator picks the migrations of the storage in use
+func newMigrator(ctx context.Context, db *dbmanager.DB) (*migrations.Migrator, error) {
+	if db.Storage == dbmanager.STORAGE_POSTGRES {
+		return postgres.NewMigrator(ctx, db.PostgresPool)
+	}
+
+	return cassandra.NewMigrator(ctx, db.CassandraSession, dbmanager.CASSANDRA_KEYSPACE)
+}
+
+// stepsArg reads the optional number of migrations to run, 0 meaning all
+func stepsArg(args []string) int {
+	if len(args) < 2 {
+		return 0
+	}
+
+	steps, err := strconv.Atoi(args[1])
+	if err != nil || steps <= 0 {
+		logging.Fatal("Invalid number of migrations", "steps", args[1])
+	}
+	return steps
+}
+
+func printStatus(ctx context.Context, migrator *migrations.Migrator) error {
+	version, dirty, err := migrator.Version(ctx)
+	if err != nil {
+		return err
+	}
+
+	state := "clean"
+	if dirty {
+		state = "dirty"
+	}
+	fmt.Printf("Version: %d (%s)\n", version, state)
+
+	pending := 0
+	for _, migration := range migrator.Migrations() {
+		if migration.Version > version {
+			fmt.Printf("Pending: %d_%s\n", migration.Version, migration.Name)
+			pending++
+		}
+	}
+	if pending == 0 {
+		fmt.Println("No pending migrations")
+	}
+
+	return nil
+}
@@ -1,44 +1,134 @@
 package appconfig
 
 import (
+	"chat-system/internal/api/auth"
+	"chat-system/internal/api/cache"
 	"chat-system/internal/api/handlers"
+	"chat-system/internal/api/realtime"
+	"chat-system/internal/config"
 	dbmanager "chat-system/internal/db_manager"
+	"chat-system/internal/repositories"
 	"chat-system/internal/services"
 )
 
 type AppConfig interface {
 	GetUserHandler() handlers.AuthHandler
 	GetMsgHandler() handlers.MsgHandler
+	GetLiveHandler() handlers.LiveHandler
+	GetConversationHandler() handlers.ConversationHandler
+	GetGroupHandler() handlers.GroupHandler
+	GetHealthHandler() handlers.HealthHandler
+}
+
+// Deps are the connections & services set up at startup, which get injected into the handlers
+type Deps struct {
+	DB     *dbmanager.DB
+	Cache  *cache.Store
+	Tokens *auth.Tokens
+	Live   *realtime.Live
 }
 
 type appConfig struct {
+	cfg  *config.Config
+	deps Deps
 }
 
-func NewAppConfig() *appConfig {
-	return &appConfig{}
+func NewAppConfig(cfg *config.Config, deps Deps) *appConfig {
+	return &appConfig{cfg: cfg, deps: deps}
 }
 
 func (a *appConfig) GetUserHandler() handlers.AuthHandler {
-	return handlers.NewUserHandler(
-		services.NewUserService(
-			dbmanager.CassandraSession,
-			dbmanager.CASSANDRA_KEYSPACE,
-			dbmanager.USERS_TABLE,
-		),
-	)
+	return handlers.NewUserHandler(a.newUserService(), a.deps.Tokens)
 }
 
 func (a *appConfig) GetMsgHandler() handlers.MsgHandler {
 	return handlers.NewMsgHandler(
-		services.NewMessageService(
-			dbmanager.CassandraSession,
-			dbmanager.CASSANDRA_KEYSPACE,
-			dbmanager.MSGS_TABLE,
-		),
-		services.NewUserService(
-			dbmanager.CassandraSession,
-			dbmanager.CASSANDRA_KEYSPACE,
-			dbmanager.USERS_TABLE,
-		),
+		a.newMessageService(),
+		a.newUserService(),
+		a.newGroupService(),
+		a.newConversationService(),
+		a.deps.Live.Broker,
+	)
+}
+
+func (a *appConfig) GetLiveHandler() handlers.LiveHandler {
+	return handlers.NewLiveHandler(a.deps.Live.Hub, a.newMessageService())
+}
+
+func (a *appConfig) GetConversationHandler() handlers.ConversationHandler {
+	return handlers.NewConversationHandler(
+		a.newConversationService(),
+		a.newUserService(),
+		a.deps.Live.Broker,
 	)
 }
+
+func (a *appConfig) GetGroupHandler() handlers.GroupHandler {
+	return handlers.NewGroupHandler(a.newGroupService(), a.newUserService())
+}
+
+// GetHealthHandler checks the storage along with Redis, when the replicas share it
+func (a *appConfig) GetHealthHandler() handlers.HealthHandler {
+	checks := append(a.deps.DB.HealthChecks(), a.deps.Cache.HealthChecks()...)
+	return handlers.NewHealthHandler(checks)
+}
+
+func (a *appConfig) newUserService() services.UserService {
+	return services.NewUserService(a.newUserRepository(), a.cfg.Auth.BcryptCost)
+}
+
+func (a *appConfig) newMessageService() services.MessageService {
+	return services.NewMessageService(a.newMessageRepository(), a.deps.Cache.Cache)
+}
+
+func (a *appConfig) newConversationService() services.ConversationService {
+	return services.NewConversationService(a.newConversationRepository(), a.deps.Cache.Cache)
+}
+
+func (a *appConfig) newGroupService() services.GroupService {
+	return services.NewGroupService(a.newGroupRepository())
+}
+
+func (a *appConfig) newUserRepository() repositories.UserRepository {
+	switch a.deps.DB.Storage {
+	case dbmanager.STORAGE_POSTGRES:
+		return repositories.NewPostgresUserRepository(a.deps.DB.PostgresPool)
+	case dbmanager.STORAGE_MEMORY:
+		return repositories.NewMemoryUserRepository(a.deps.DB.MemoryStore)
+	}
+
+	return repositories.NewCassandraUserRepository(a.deps.DB.CassandraQueries)
+}
+
+func (a *appConfig) newMessageRepository() repositories.MessageRepository {
+	switch a.deps.DB.Storage {
+	case dbmanager.STORAGE_POSTGRES:
+		return repositories.NewPostgresMessageRepository(a.deps.DB.PostgresPool)
+	case dbmanager.STORAGE_MEMORY:
+		return repositories.NewMemoryMessageRepository(a.deps.DB.MemoryStore)
+	}
+
+	return repositories.NewCassandraMessageRepository(a.deps.DB.CassandraQueries)
+}
+
+func (a *appConfig) newConversationRepository() repositories.ConversationRepository {
+	switch a.deps.DB.Storage {
+	case dbmanager.STORAGE_POSTGRES:
+		return repositories.NewPostgresConversationRepository(a.deps.DB.PostgresPool)
+	case dbmanager.STORAGE_MEMORY:
+		return repositories.NewMemoryConversationRepository(a.deps.DB.MemoryStore)
+	}
+
+	return repositories.NewCassandraConversationRepository(a.deps.DB.CassandraQueries)
+}
+
+func (a *appConfig) newGroupRepository() repositories.GroupRepository {
+	switch a.deps.DB.Storage {
+	case dbmanager.STORAGE_POSTGRES:
+		return repositories.NewPostgresGroupRepository(a.deps.DB.PostgresPool)
+	case dbmanager.STORAGE_MEMORY:
+		return repositories.NewMemoryGroupRepository(a.deps.DB.MemoryStore)
+	}
+
+	return repositories.NewCassandraGroupRepository(a.deps.DB.CassandraQueries)
+}
@@ -1,49 +1,288 @@
 package auth
 
 import (
-	"os"
+	"chat-system/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
 	"time"
 
-	"github.com/dgrijalva/jwt-go"
+	"github.com/gocql/gocql"
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/crypto/bcrypt"
 )
 
-const TokenExpiresAt = 24 * time.Hour
+const (
+	// Access tokens are short-lived since they are trusted as-is by every request,
+	// while the session is kept alive by rotating the long-lived refresh token
+	ACCESS_TOKEN_EXPIRES_IN  = 15 * time.Minute
+	REFRESH_TOKEN_EXPIRES_IN = 7 * 24 * time.Hour
+)
+
+// Default `iss` & `aud` of the tokens, overridden by `JWT_ISSUER` & `JWT_AUDIENCE`.
+// Downstream services verifying the tokens must be configured with the same audience.
+const (
+	DEFAULT_ISSUER   = "chat-system"
+	DEFAULT_AUDIENCE = "chat-system"
+)
+
+// Tolerated clock difference between the replicas issuing & the services verifying the tokens
+const CLOCK_SKEW_LEEWAY = 30 * time.Second
+
+// Passwords are hashed by bcrypt at this cost unless `BCRYPT_COST` says otherwise
+const DEFAULT_BCRYPT_COST = 14
+
+// Scheme of the `Authorization` header unless `AUTH_HEADER_PREFIX` says otherwise
+const DEFAULT_HEADER_PREFIX = "Bearer"
+
+// Types of the issued tokens, so a refresh token can't be used to access the API & vice versa
+const (
+	TOKEN_TYPE_ACCESS  = "access"
+	TOKEN_TYPE_REFRESH = "refresh"
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid token")
+	ErrRevokedToken = errors.New("token has been revoked")
+)
+
+type Config struct {
+	// Signs the tokens with HS256 unless KeysDir is set
+	SecretKey []byte
+	// Directory of the asymmetric keys named `<kid>.pem`, along with the ID of the one signing the tokens
+	KeysDir      string
+	SigningKeyID string
+	Issuer       string
+	Audience     string
+	// Scheme of the `Authorization` header, e.g `Bearer <token>`
+	HeaderPrefix string
+	BcryptCost   int
+}
+
+// ParseConfig reads the config of the tokens out of the settings, i.e. `JWT_SECRET_KEY` or `JWT_KEYS_DIR`
+// & `JWT_SIGNING_KEY_ID`, along with the optional `JWT_ISSUER`, `JWT_AUDIENCE`, `AUTH_HEADER_PREFIX` & `BCRYPT_COST`
+func ParseConfig(get func(name string) string) (Config, error) {
+	config := Config{
+		SecretKey:    []byte(get("JWT_SECRET_KEY")),
+		KeysDir:      get("JWT_KEYS_DIR"),
+		SigningKeyID: get("JWT_SIGNING_KEY_ID"),
+		Issuer:       getOr(get, "JWT_ISSUER", DEFAULT_ISSUER),
+		Audience:     getOr(get, "JWT_AUDIENCE", DEFAULT_AUDIENCE),
+		HeaderPrefix: getOr(get, "AUTH_HEADER_PREFIX", DEFAULT_HEADER_PREFIX),
+		BcryptCost:   DEFAULT_BCRYPT_COST,
+	}
 
-var jwtKey = []byte(os.Getenv("JWT_SECRET_KEY"))
+	var errs []error
+
+	if config.KeysDir == "" && len(config.SecretKey) == 0 {
+		errs = append(errs, errors.New("JWT_SECRET_KEY is required unless JWT_KEYS_DIR is set"))
+	}
+	if config.KeysDir != "" && config.SigningKeyID == "" {
+		errs = append(errs, errors.New("JWT_SIGNING_KEY_ID is required along with JWT_KEYS_DIR"))
+	}
 
+	if value := get("BCRYPT_COST"); value != "" {
+		cost, err := strconv.Atoi(value)
+		if err != nil || cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+			errs = append(errs, fmt.Errorf("invalid BCRYPT_COST: %q, expected %d to %d", value, bcrypt.MinCost, bcrypt.MaxCost))
+		}
+		config.BcryptCost = cost
+	}
+
+	return config, errors.Join(errs...)
+}
+
+// Tokens issues & verifies the tokens of the app, along with their revocations
+type Tokens struct {
+	issuer      string
+	audience    string
+	keys        *keySet
+	revocations RevocationList
+}
+
+// NewTokens signs the tokens by the asymmetric keys of the config if any, by its secret key otherwise.
+// Revocations must be shared by all replicas (e.g kept in Redis), unless a single one runs.
+func NewTokens(config Config, revocations RevocationList) (*Tokens, error) {
+	tokens := &Tokens{
+		issuer:      config.Issuer,
+		audience:    config.Audience,
+		keys:        newSecretKeySet(config.SecretKey),
+		revocations: revocations,
+	}
+
+	if config.KeysDir != "" {
+		loaded, err := loadKeySet(config.KeysDir, config.SigningKeyID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load the keys of %s: %w", config.KeysDir, err)
+		}
+		tokens.keys = loaded
+	}
+
+	return tokens, nil
+}
+
+// Claims identify the user by the `sub` (their ID), along with the standard claims checked by ValidateToken
 type Claims struct {
-	UserID   string `json:"id"`
-	Username string `json:"username"`
-	jwt.StandardClaims
+	UserID    string `json:"id"`
+	Username  string `json:"username"`
+	Type      string `json:"type"`
+	SessionID string `json:"sid"`
+	jwt.RegisteredClaims
+}
+
+// Validate requires the claims identifying the user, the token & its session, on top of the registered ones
+func (c *Claims) Validate() error {
+	if c.Subject == "" || c.ID == "" || c.SessionID == "" {
+		return ErrInvalidToken
+	}
+
+	return nil
+}
+
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	// Lifetime of the access token in seconds
+	ExpiresIn int64
+}
+
+// GenerateToken issues an access token of a brand new session of the user
+func (t *Tokens) GenerateToken(user *models.User) (string, error) {
+	return t.generateToken(user.ID.String(), user.Username, newID(), TOKEN_TYPE_ACCESS, ACCESS_TOKEN_EXPIRES_IN)
+}
+
+// GenerateTokenPair starts a new session of the user
+func (t *Tokens) GenerateTokenPair(user *models.User) (*TokenPair, error) {
+	return t.generateTokenPair(user.ID.String(), user.Username, newID())
+}
+
+// RefreshTokenPair rotates the refresh token, i.e. revokes it in favor of a new pair within the same session.
+// An already rotated refresh token showing up again means it leaked, so the whole session gets revoked.
+func (t *Tokens) RefreshTokenPair(ctx context.Context, refreshToken string) (*TokenPair, error) {
+	claims, err := t.parse(refreshToken, TOKEN_TYPE_REFRESH)
+	if err != nil {
+		return nil, err
+	}
+
+	revoked, err := t.revocations.IsRevoked(ctx, claims.SessionID)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, ErrRevokedToken
+	}
+
+	rotated, err := t.revocations.Revoke(ctx, claims.ID, claims.ExpiresAt.Time)
+	if err != nil {
+		return nil, err
+	}
+	if !rotated {
+		if err := t.RevokeSession(ctx, claims); err != nil {
+			return nil, err
+		}
+		return nil, ErrRevokedToken
+	}
+
+	return t.generateTokenPair(claims.Subject, claims.Username, claims.SessionID)
+}
+
+// RevokeSession revokes all the tokens ever issued within the session of the claims
+func (t *Tokens) RevokeSession(ctx context.Context, claims *Claims) error {
+	// No token of the session outlives its latest refresh token
+	_, err := t.revocations.Revoke(ctx, claims.SessionID, time.Now().Add(REFRESH_TOKEN_EXPIRES_IN))
+	return err
 }
 
-func GenerateToken(username string) (string, error) {
-	expirationTime := time.Now().Add(TokenExpiresAt)
+// ValidateToken validates an access token, which must not be revoked either on its own or along with its session
+func (t *Tokens) ValidateToken(ctx context.Context, tokenString string) (*Claims, error) {
+	claims, err := t.parse(tokenString, TOKEN_TYPE_ACCESS)
+	if err != nil {
+		return nil, err
+	}
+
+	revoked, err := t.revocations.IsRevoked(ctx, claims.ID, claims.SessionID)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, ErrRevokedToken
+	}
+
+	return claims, nil
+}
+
+func (t *Tokens) generateTokenPair(userID, username, sessionID string) (*TokenPair, error) {
+	accessToken, err := t.generateToken(userID, username, sessionID, TOKEN_TYPE_ACCESS, ACCESS_TOKEN_EXPIRES_IN)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := t.generateToken(userID, username, sessionID, TOKEN_TYPE_REFRESH, REFRESH_TOKEN_EXPIRES_IN)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(ACCESS_TOKEN_EXPIRES_IN.Seconds()),
+	}, nil
+}
+
+func (t *Tokens) generateToken(userID, username, sessionID, tokenType string, expiresIn time.Duration) (string, error) {
+	now := time.Now()
 	claims := &Claims{
-		Username: username,
-		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: expirationTime.Unix(),
+		UserID:    userID,
+		Username:  username,
+		Type:      tokenType,
+		SessionID: sessionID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        newID(),
+			Subject:   userID,
+			Issuer:    t.issuer,
+			Audience:  jwt.ClaimStrings{t.audience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(expiresIn)),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtKey)
+	return t.keys.sign(claims)
 }
 
-func ValidateToken(tokenString string) (*Claims, error) {
+func (t *Tokens) parse(tokenString, tokenType string) (*Claims, error) {
 	claims := &Claims{}
 
-	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		return jwtKey, nil
-	})
+	parser := jwt.NewParser(
+		jwt.WithValidMethods(t.keys.methods()),
+		jwt.WithIssuer(t.issuer),
+		jwt.WithAudience(t.audience),
+		jwt.WithLeeway(CLOCK_SKEW_LEEWAY),
+		jwt.WithIssuedAt(),
+		jwt.WithExpirationRequired(),
+	)
 
+	token, err := parser.ParseWithClaims(tokenString, claims, t.keys.keyFunc)
 	if err != nil {
 		return nil, err
 	}
 
-	if !token.Valid {
-		return nil, err
+	if !token.Valid || claims.Type != tokenType {
+		return nil, ErrInvalidToken
 	}
 
 	return claims, nil
 }
+
+func getOr(get func(name string) string, name, fallback string) string {
+	if value := get(name); value != "" {
+		return value
+	}
+
+	return fallback
+}
+
+func newID() string {
+	return gocql.MustRandomUUID().String()
+}
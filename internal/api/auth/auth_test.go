@@ -0,0 +1,123 @@
+package auth
+
+import (
+	"chat-system/internal/models"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/suite"
+)
+
+type AuthTestSuite struct {
+	suite.Suite
+	tokens *Tokens
+}
+
+func TestAuthTestSuite(t *testing.T) {
+	suite.Run(t, new(AuthTestSuite))
+}
+
+func (ats *AuthTestSuite) SetupTest() {
+	ats.tokens = newTestTokens(newSecretKeySet([]byte("secret")))
+}
+
+// newTestTokens issues tokens signed by the keys, revoking them in memory
+func newTestTokens(keys *keySet) *Tokens {
+	return &Tokens{
+		issuer:      DEFAULT_ISSUER,
+		audience:    DEFAULT_AUDIENCE,
+		keys:        keys,
+		revocations: NewMemoryRevocationList(),
+	}
+}
+
+// validClaims returns the claims of an access token issued at the given time
+func validClaims(issuedAt time.Time) *Claims {
+	userID := gocql.TimeUUID().String()
+
+	return &Claims{
+		UserID:    userID,
+		Username:  "user1",
+		Type:      TOKEN_TYPE_ACCESS,
+		SessionID: newID(),
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        newID(),
+			Subject:   userID,
+			Issuer:    DEFAULT_ISSUER,
+			Audience:  jwt.ClaimStrings{DEFAULT_AUDIENCE},
+			IssuedAt:  jwt.NewNumericDate(issuedAt),
+			NotBefore: jwt.NewNumericDate(issuedAt),
+			ExpiresAt: jwt.NewNumericDate(issuedAt.Add(ACCESS_TOKEN_EXPIRES_IN)),
+		},
+	}
+}
+
+func (ats *AuthTestSuite) validate(claims *Claims) error {
+	token, err := ats.tokens.keys.sign(claims)
+	ats.Require().NoError(err)
+
+	_, err = ats.tokens.ValidateToken(context.Background(), token)
+	return err
+}
+
+func (ats *AuthTestSuite) TestGenerateToken_Full_Claims() {
+	user := &models.User{ID: gocql.TimeUUID(), Username: "user1"}
+
+	token, err := ats.tokens.GenerateToken(user)
+	ats.Require().NoError(err)
+
+	claims, err := ats.tokens.ValidateToken(context.Background(), token)
+	ats.Require().NoError(err)
+
+	ats.Equal(user.ID.String(), claims.Subject)
+	ats.Equal(user.ID.String(), claims.UserID)
+	ats.Equal(user.Username, claims.Username)
+	ats.Equal(DEFAULT_ISSUER, claims.Issuer)
+	ats.Equal(jwt.ClaimStrings{DEFAULT_AUDIENCE}, claims.Audience)
+	ats.NotNil(claims.IssuedAt)
+	ats.NotNil(claims.NotBefore)
+	ats.NotEmpty(claims.ID)
+}
+
+func (ats *AuthTestSuite) TestValidateToken_Issuer_And_Audience() {
+	claims := validClaims(time.Now())
+	claims.Issuer = "someone-else"
+	ats.Error(ats.validate(claims))
+
+	claims = validClaims(time.Now())
+	claims.Audience = jwt.ClaimStrings{"another-service"}
+	ats.Error(ats.validate(claims))
+}
+
+func (ats *AuthTestSuite) TestValidateToken_Requires_Subject() {
+	claims := validClaims(time.Now())
+	claims.Subject = ""
+
+	ats.Error(ats.validate(claims))
+}
+
+func (ats *AuthTestSuite) TestValidateToken_Clock_Skew() {
+	// Issued by a replica whose clock is slightly ahead
+	ats.NoError(ats.validate(validClaims(time.Now().Add(CLOCK_SKEW_LEEWAY / 2))))
+	// Way too far in the future
+	ats.Error(ats.validate(validClaims(time.Now().Add(2 * CLOCK_SKEW_LEEWAY))))
+
+	// Expired a moment ago
+	expired := validClaims(time.Now().Add(-ACCESS_TOKEN_EXPIRES_IN - CLOCK_SKEW_LEEWAY/2))
+	ats.NoError(ats.validate(expired))
+
+	expired = validClaims(time.Now().Add(-ACCESS_TOKEN_EXPIRES_IN - 2*CLOCK_SKEW_LEEWAY))
+	ats.Error(ats.validate(expired))
+}
+
+func (ats *AuthTestSuite) TestValidateToken_Pins_Algorithm() {
+	token := jwt.NewWithClaims(jwt.SigningMethodNone, validClaims(time.Now()))
+	signed, err := token.SignedString(jwt.UnsafeAllowNoneSignatureType)
+	ats.Require().NoError(err)
+
+	_, err = ats.tokens.ValidateToken(context.Background(), signed)
+	ats.Error(err)
+}
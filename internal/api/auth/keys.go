@@ -0,0 +1,212 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const KEY_FILE_EXT = ".pem"
+
+// key is either a key pair, a public key only (verification of tokens signed elsewhere/before a rotation)
+// or a shared secret
+type key struct {
+	id     string
+	method jwt.SigningMethod
+	// Private key or secret, nil for verification only keys
+	signing interface{}
+	// Public key or secret
+	verification interface{}
+}
+
+type keySet struct {
+	signing      *key
+	verification map[string]*key
+}
+
+// Tokens are signed by the HS256 secret of the config, unless asymmetric keys are configured
+func newSecretKeySet(secret []byte) *keySet {
+	secretKey := &key{
+		method:       jwt.SigningMethodHS256,
+		signing:      secret,
+		verification: secret,
+	}
+
+	return &keySet{
+		signing:      secretKey,
+		verification: map[string]*key{secretKey.id: secretKey},
+	}
+}
+
+// loadKeySet loads every PEM encoded key of the directory, named after its key ID (`<kid>.pem`).
+// All of them verify tokens, while the one of the signing key ID signs the new ones & must be a private key.
+// Keys are either RSA (RS256) or Ed25519 (EdDSA), in PKCS#8/PKIX or PKCS#1 encoding.
+func loadKeySet(dir, signingKeyID string) (*keySet, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*"+KEY_FILE_EXT))
+	if err != nil {
+		return nil, err
+	}
+
+	set := &keySet{verification: make(map[string]*key, len(files))}
+	for _, file := range files {
+		k, err := loadKey(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load key %s: %w", file, err)
+		}
+		set.verification[k.id] = k
+	}
+
+	signingKey, ok := set.verification[signingKeyID]
+	if !ok || signingKey.signing == nil {
+		return nil, fmt.Errorf("no private key found for signing key ID %q", signingKeyID)
+	}
+	set.signing = signingKey
+
+	return set, nil
+}
+
+func loadKey(file string) (*key, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+
+	k := &key{id: strings.TrimSuffix(filepath.Base(file), KEY_FILE_EXT)}
+
+	var parsed interface{}
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		parsed, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block type %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch typed := parsed.(type) {
+	case *rsa.PrivateKey:
+		k.method, k.signing, k.verification = jwt.SigningMethodRS256, typed, &typed.PublicKey
+	case *rsa.PublicKey:
+		k.method, k.verification = jwt.SigningMethodRS256, typed
+	case ed25519.PrivateKey:
+		k.method, k.signing, k.verification = jwt.SigningMethodEdDSA, typed, typed.Public()
+	case ed25519.PublicKey:
+		k.method, k.verification = jwt.SigningMethodEdDSA, typed
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", parsed)
+	}
+
+	return k, nil
+}
+
+// sign signs the claims with the signing key, whose ID is set as the `kid` header
+func (s *keySet) sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(s.signing.method, claims)
+	if s.signing.id != "" {
+		token.Header["kid"] = s.signing.id
+	}
+
+	return token.SignedString(s.signing.signing)
+}
+
+// keyFunc picks the verification key of the token's `kid`, as long as the token is signed by the key's algorithm
+func (s *keySet) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	k, ok := s.verification[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key ID %q", kid)
+	}
+
+	if token.Method.Alg() != k.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+	}
+
+	return k.verification, nil
+}
+
+// methods returns the algorithms of the verification keys, no token signed by any other is accepted
+func (s *keySet) methods() []string {
+	var methods []string
+	seen := make(map[string]bool)
+	for _, k := range s.verification {
+		if alg := k.method.Alg(); !seen[alg] {
+			seen[alg] = true
+			methods = append(methods, alg)
+		}
+	}
+
+	return methods
+}
+
+// JWK is the public part of a verification key, as defined by RFC 7517
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	// RSA
+	Modulus  string `json:"n,omitempty"`
+	Exponent string `json:"e,omitempty"`
+	// Ed25519
+	Curve string `json:"crv,omitempty"`
+	X     string `json:"x,omitempty"`
+}
+
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public keys verifying the tokens, so other services don't need any shared secret.
+// Secrets are never published, so the set is empty while signing with HS256.
+func (t *Tokens) JWKS() *JWKSet {
+	set := &JWKSet{Keys: make([]JWK, 0, len(t.keys.verification))}
+
+	for _, k := range t.keys.verification {
+		jwk := JWK{KeyID: k.id, Use: "sig", Algorithm: k.method.Alg()}
+
+		switch public := k.verification.(type) {
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.Modulus = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.Exponent = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		default:
+			continue
+		}
+
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	sort.Slice(set.Keys, func(i, j int) bool {
+		return set.Keys[i].KeyID < set.Keys[j].KeyID
+	})
+
+	return set
+}
@@ -0,0 +1,172 @@
+package auth
+
+import (
+	"chat-system/internal/models"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/suite"
+)
+
+type KeysTestSuite struct {
+	suite.Suite
+	dir    string
+	tokens *Tokens
+}
+
+func TestKeysTestSuite(t *testing.T) {
+	suite.Run(t, new(KeysTestSuite))
+}
+
+func (kts *KeysTestSuite) SetupTest() {
+	kts.dir = kts.T().TempDir()
+	kts.tokens = newTestTokens(newSecretKeySet([]byte("secret")))
+}
+
+func (kts *KeysTestSuite) writeKey(name, blockType string, der []byte) {
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	err := os.WriteFile(filepath.Join(kts.dir, name+KEY_FILE_EXT), data, 0600)
+	kts.Require().NoError(err, "Failed to write key")
+}
+
+func (kts *KeysTestSuite) writeRSAKey(name string) {
+	private, err := rsa.GenerateKey(rand.Reader, 2048)
+	kts.Require().NoError(err, "Failed to generate key")
+
+	kts.writeKey(name, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(private))
+}
+
+func (kts *KeysTestSuite) writeEd25519Key(name string) {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	kts.Require().NoError(err, "Failed to generate key")
+
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	kts.Require().NoError(err, "Failed to marshal key")
+
+	kts.writeKey(name, "PRIVATE KEY", der)
+}
+
+func (kts *KeysTestSuite) assertSignsWith(signingKeyID string, method jwt.SigningMethod) {
+	loaded, err := loadKeySet(kts.dir, signingKeyID)
+	kts.Require().NoError(err)
+	kts.tokens.keys = loaded
+
+	token, err := kts.tokens.GenerateToken(&models.User{ID: gocql.TimeUUID(), Username: "user1"})
+	kts.NoError(err)
+
+	parsed, _, err := new(jwt.Parser).ParseUnverified(token, &Claims{})
+	kts.NoError(err)
+	kts.Equal(signingKeyID, parsed.Header["kid"])
+	kts.Equal(method.Alg(), parsed.Method.Alg())
+
+	claims, err := kts.tokens.ValidateToken(context.Background(), token)
+	kts.NoError(err)
+	kts.Equal("user1", claims.Username)
+}
+
+func (kts *KeysTestSuite) TestLoadKeySet_RS256() {
+	kts.writeRSAKey("rsa-key")
+
+	kts.assertSignsWith("rsa-key", jwt.SigningMethodRS256)
+}
+
+func (kts *KeysTestSuite) TestLoadKeySet_EdDSA() {
+	kts.writeEd25519Key("ed-key")
+
+	kts.assertSignsWith("ed-key", jwt.SigningMethodEdDSA)
+}
+
+func (kts *KeysTestSuite) TestLoadKeySet_Signing_Key_Must_Be_Private() {
+	private, err := rsa.GenerateKey(rand.Reader, 2048)
+	kts.Require().NoError(err, "Failed to generate key")
+
+	der, err := x509.MarshalPKIXPublicKey(&private.PublicKey)
+	kts.Require().NoError(err, "Failed to marshal key")
+	kts.writeKey("public-only", "PUBLIC KEY", der)
+
+	_, err = loadKeySet(kts.dir, "public-only")
+	kts.Error(err)
+
+	_, err = loadKeySet(kts.dir, "missing")
+	kts.Error(err)
+}
+
+func (kts *KeysTestSuite) TestRotation_Keeps_Verifying_Old_Tokens() {
+	kts.writeRSAKey("old")
+
+	loaded, err := loadKeySet(kts.dir, "old")
+	kts.Require().NoError(err)
+	kts.tokens.keys = loaded
+
+	oldToken, err := kts.tokens.GenerateToken(&models.User{ID: gocql.TimeUUID(), Username: "user1"})
+	kts.Require().NoError(err)
+
+	// The new key is rolled out & becomes the signing one, while the old one is still around for verification
+	kts.writeEd25519Key("new")
+	loaded, err = loadKeySet(kts.dir, "new")
+	kts.Require().NoError(err)
+	kts.tokens.keys = loaded
+
+	_, err = kts.tokens.ValidateToken(context.Background(), oldToken)
+	kts.NoError(err)
+
+	jwks := kts.tokens.JWKS()
+	kts.Len(jwks.Keys, 2)
+	kts.Equal("new", jwks.Keys[0].KeyID)
+	kts.Equal("OKP", jwks.Keys[0].KeyType)
+	kts.NotEmpty(jwks.Keys[0].X)
+	kts.Equal("old", jwks.Keys[1].KeyID)
+	kts.Equal("RSA", jwks.Keys[1].KeyType)
+	kts.Equal("AQAB", jwks.Keys[1].Exponent)
+
+	// Once removed, tokens signed by the old key are rejected
+	kts.Require().NoError(os.Remove(filepath.Join(kts.dir, "old"+KEY_FILE_EXT)))
+	loaded, err = loadKeySet(kts.dir, "new")
+	kts.Require().NoError(err)
+	kts.tokens.keys = loaded
+
+	_, err = kts.tokens.ValidateToken(context.Background(), oldToken)
+	kts.Error(err)
+}
+
+func (kts *KeysTestSuite) TestKeyFunc_Pins_Algorithm() {
+	kts.writeRSAKey("rsa-key")
+
+	loaded, err := loadKeySet(kts.dir, "rsa-key")
+	kts.Require().NoError(err)
+	kts.tokens.keys = loaded
+
+	// Signed by HS256 claiming the RSA key ID, e.g. using the public key as the HMAC secret
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, validClaims(time.Now()))
+	token.Header["kid"] = "rsa-key"
+	signed, err := token.SignedString([]byte("secret"))
+	kts.Require().NoError(err)
+
+	_, err = kts.tokens.ValidateToken(context.Background(), signed)
+	kts.Error(err)
+}
+
+func (kts *KeysTestSuite) TestJWKS_Hides_Secrets() {
+	kts.Empty(kts.tokens.JWKS().Keys)
+}
+
+func (kts *KeysTestSuite) TestNewTokens_Signs_With_Keys_Dir() {
+	kts.writeEd25519Key("ed-key")
+
+	tokens, err := NewTokens(Config{KeysDir: kts.dir, SigningKeyID: "ed-key"}, NewMemoryRevocationList())
+	kts.Require().NoError(err)
+	kts.Len(tokens.JWKS().Keys, 1)
+
+	_, err = NewTokens(Config{KeysDir: kts.dir, SigningKeyID: "missing"}, NewMemoryRevocationList())
+	kts.Error(err)
+}
@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const REVOKED_KEY_PREFIX = "revoked-"
+
+// RevocationList keeps track of the revoked token & session IDs until the tokens would expire anyway
+type RevocationList interface {
+	// Revoke reports whether the ID got revoked by this very call, i.e. it was not revoked already
+	Revoke(ctx context.Context, id string, expiresAt time.Time) (bool, error)
+	// IsRevoked reports whether any of the IDs is revoked
+	IsRevoked(ctx context.Context, ids ...string) (bool, error)
+}
+
+type redisRevocationList struct {
+	client *redis.Client
+}
+
+func NewRedisRevocationList(client *redis.Client) *redisRevocationList {
+	return &redisRevocationList{
+		client: client,
+	}
+}
+
+func (l *redisRevocationList) Revoke(ctx context.Context, id string, expiresAt time.Time) (bool, error) {
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		// Expired already, nothing to keep track of
+		return true, nil
+	}
+
+	return l.client.SetNX(ctx, REVOKED_KEY_PREFIX+id, 1, ttl).Result()
+}
+
+func (l *redisRevocationList) IsRevoked(ctx context.Context, ids ...string) (bool, error) {
+	keys := make([]string, 0, len(ids))
+	for _, id := range ids {
+		keys = append(keys, REVOKED_KEY_PREFIX+id)
+	}
+
+	count, err := l.client.Exists(ctx, keys...).Result()
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+type memoryRevocationList struct {
+	mu      sync.Mutex
+	revoked map[string]time.Time
+}
+
+func NewMemoryRevocationList() *memoryRevocationList {
+	return &memoryRevocationList{
+		revoked: make(map[string]time.Time),
+	}
+}
+
+func (l *memoryRevocationList) Revoke(_ context.Context, id string, expiresAt time.Time) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	for revokedID, expiry := range l.revoked {
+		if now.After(expiry) {
+			delete(l.revoked, revokedID)
+		}
+	}
+
+	if _, ok := l.revoked[id]; ok {
+		return false, nil
+	}
+	l.revoked[id] = expiresAt
+
+	return true, nil
+}
+
+func (l *memoryRevocationList) IsRevoked(_ context.Context, ids ...string) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	for _, id := range ids {
+		if expiry, ok := l.revoked[id]; ok && now.Before(expiry) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
@@ -1,42 +1,214 @@
 package cache
 
 import (
+	"chat-system/internal/health"
 	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
 	"fmt"
-	"log"
+	"log/slog"
+	"net"
+	"strconv"
+	"time"
 
 	"github.com/go-redis/redis/v8"
 )
 
 const (
-	DURATION         = 0 //30 * time.Second
-	DB               = 1
-	CACHE_KEY_SUFFIX = "-messages"
+	DRIVER_REDIS  = "redis"
+	DRIVER_MEMORY = "memory"
+
+	DEFAULT_REDIS_ADDR  = "redis:6379"
+	DEFAULT_REDIS_DB    = 1
+	DEFAULT_TTL         = 24 * time.Hour
+	DEFAULT_MAX_ENTRIES = 10000
+
+	CACHE_KEY_SUFFIX  = "-messages"
+	UNREAD_KEY_SUFFIX = "-unread"
+	LEASE_KEY_SUFFIX  = "-lease"
+	// Version of the cached messages of a user, bumped on each invalidation
+	VERSION_KEY_SUFFIX = "-version"
+	// Read marks of the peers of a user, next to their unread counts
+	READ_MARKS_KEY_SUFFIX = "-read-marks"
 )
 
-var (
-	Ctx    = context.Background()
+// Cache holds the cached messages (sorted sets), cached fields & counters (hashes) of users.
+// Cached messages & fields expire after the TTL of the cache since their last write, while counters are kept.
+type Cache interface {
+	Delete(ctx context.Context, key string) error
+	IncrField(ctx context.Context, key, field string) error
+	SetField(ctx context.Context, key, field string, value int64) error
+	// CompareAndSetField sets the counter to the value only if it's still at old (0 if missing), telling whether it did
+	CompareAndSetField(ctx context.Context, key, field string, old, value int64) (bool, error)
+	GetFields(ctx context.Context, key string) (map[string]string, error)
+	// SetCachedField sets a field of the hash, which expires like the cached messages
+	SetCachedField(ctx context.Context, key, field, value string) error
+	// AddCachedFields adds the fields missing from the hash at once, keeping the ones set meanwhile,
+	// & expires it like the cached messages
+	AddCachedFields(ctx context.Context, key string, fields map[string]string) error
+	// AddToSortedSet adds the members & trims the set down to its `limit` highest scored members at once,
+	// so concurrent writers can't lose each other's members nor grow the set past the limit
+	AddToSortedSet(ctx context.Context, key string, members []ScoredMember, limit int64) error
+	// GetSortedRanges reads several ranges of the sorted set at once
+	GetSortedRanges(ctx context.Context, key string, ranges ...ScoreRange) ([][]ScoredMember, error)
+	// AcquireLease takes the key for the TTL at most, unless someone else holds it already (false).
+	// The returned token releases the lease.
+	AcquireLease(ctx context.Context, key string, ttl time.Duration) (string, bool, error)
+	ReleaseLease(ctx context.Context, key, token string) error
+}
+
+// ScoredMember is a member of a sorted set along with its score
+type ScoredMember struct {
+	Member string
+	Score  float64
+}
+
+// ScoreRange selects the members of a sorted set scored within [Min, Max], following the Redis syntax
+// (e.g "-inf", "(42" for an exclusive bound), walked from the highest score when Reverse. A zero Limit means all.
+type ScoreRange struct {
+	Min     string
+	Max     string
+	Reverse bool
+	Limit   int64
+}
+
+type Config struct {
+	Driver   string
+	Addr     string
+	DB       int
+	Password string
+	TLS      bool
+	TTL      time.Duration
+	// Max number of cached keys held by the in-memory cache before evicting the least recently used ones, counters aside
+	MaxEntries int
+}
+
+// ParseConfig reads the config of the cache out of the settings, i.e. `CACHE_DRIVER` (redis or memory), `REDIS_ADDR`, `REDIS_DB`,
+// `REDIS_PASSWORD`, `REDIS_TLS`, `CACHE_TTL` & `CACHE_MAX_ENTRIES`, falling back to the defaults
+func ParseConfig(get func(name string) string) (Config, error) {
+	config := Config{
+		Driver:     DRIVER_REDIS,
+		Addr:       DEFAULT_REDIS_ADDR,
+		DB:         DEFAULT_REDIS_DB,
+		Password:   get("REDIS_PASSWORD"),
+		TTL:        DEFAULT_TTL,
+		MaxEntries: DEFAULT_MAX_ENTRIES,
+	}
+
+	if driver := get("CACHE_DRIVER"); driver != "" {
+		if driver != DRIVER_REDIS && driver != DRIVER_MEMORY {
+			return config, fmt.Errorf("unknown cache driver %q", driver)
+		}
+		config.Driver = driver
+	}
+
+	if addr := get("REDIS_ADDR"); addr != "" {
+		config.Addr = addr
+	}
+
+	var err error
+	if value := get("REDIS_DB"); value != "" {
+		if config.DB, err = strconv.Atoi(value); err != nil {
+			return config, fmt.Errorf("invalid REDIS_DB: %w", err)
+		}
+	}
+
+	if value := get("REDIS_TLS"); value != "" {
+		if config.TLS, err = strconv.ParseBool(value); err != nil {
+			return config, fmt.Errorf("invalid REDIS_TLS: %w", err)
+		}
+	}
+
+	if value := get("CACHE_TTL"); value != "" {
+		if config.TTL, err = time.ParseDuration(value); err != nil {
+			return config, fmt.Errorf("invalid CACHE_TTL: %w", err)
+		}
+	}
+
+	if value := get("CACHE_MAX_ENTRIES"); value != "" {
+		if config.MaxEntries, err = strconv.Atoi(value); err != nil || config.MaxEntries <= 0 {
+			return config, fmt.Errorf("invalid CACHE_MAX_ENTRIES: %q", value)
+		}
+	}
+
+	return config, nil
+}
+
+func (c Config) redisOptions() *redis.Options {
+	options := &redis.Options{
+		Addr:     c.Addr,
+		DB:       c.DB,
+		Password: c.Password,
+	}
+
+	if c.TLS {
+		host, _, err := net.SplitHostPort(c.Addr)
+		if err != nil {
+			host = c.Addr
+		}
+		options.TLSConfig = &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}
+	}
+
+	return options
+}
+
+// Store is the cache of the app, along with the Redis client it's kept in if any, which the replicas also rely on
+// to share live events & revocations
+type Store struct {
+	Cache  Cache
 	Client *redis.Client
-)
+}
 
-func Init() {
-	Client = redis.NewClient(&redis.Options{
-		Addr: "redis:6379",
-		DB:   DB,
-	})
-	pong, err := TestConn(Client, Ctx)
+// Open sets the cache of the config up, in memory or in Redis
+func Open(config Config) *Store {
+	if config.Driver == DRIVER_MEMORY {
+		slog.Warn("Cache is kept in memory, which only fits a single replica")
+		return &Store{Cache: NewMemoryCache(config.MaxEntries, config.TTL)}
+	}
+
+	client := redis.NewClient(config.redisOptions())
+	client.AddHook(tracingHook{})
+	store := &Store{Cache: NewRedisCache(client, config.TTL), Client: client}
+
+	pong, err := TestConn(client, context.Background())
 	if err != nil {
-		log.Printf("cache is not healthy. returned error: %v", err)
+		slog.Error("Cache is not healthy", "error", err)
+		return store
 	}
-	fmt.Printf("cache is healthy and responds with '%s'", pong)
+	slog.Info("Cache is healthy", "addr", config.Addr, "ping", pong)
+
+	return store
 }
 
-func Get(key string) (string, error) {
-	return Client.Get(Ctx, key).Result()
+// Close closes the Redis client if any, once nothing uses the cache anymore
+func (s *Store) Close() {
+	if s.Client != nil {
+		if err := s.Client.Close(); err != nil {
+			slog.Error("Failed to close the cache client", "error", err)
+		}
+	}
 }
 
-func Set(key string, value interface{}) error {
-	return Client.Set(Ctx, key, value, DURATION).Err()
+func newLeaseToken() (string, error) {
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(token), nil
+}
+
+// HealthChecks check Redis answers when caching in it, which the replicas also share live events through
+func (s *Store) HealthChecks() []health.Check {
+	if s.Client == nil {
+		return nil
+	}
+
+	return []health.Check{{Name: DRIVER_REDIS, Run: func(ctx context.Context) error {
+		_, err := TestConn(s.Client, ctx)
+		return err
+	}}}
 }
 
 func TestConn(client *redis.Client, ctx context.Context) (string, error) {
@@ -0,0 +1,377 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrWrongType = errors.New("operation against a key holding the wrong kind of value")
+
+// memoryCache is an in-process cache bounded to its max number of cached keys, evicting the least recently used ones.
+// Counters are kept rather than cached, so they're never evicted.
+// It fits tests & single replica deployments running without Redis.
+type memoryCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	ttl        time.Duration
+	// Elements of the counters aren't linked to the LRU list, which ignores them
+	entries map[string]*list.Element
+	// Most recently used cached entries first
+	lru *list.List
+	now func() time.Time
+}
+
+// Kinds of values held by the keys
+const (
+	kindString = iota
+	kindHash
+	kindSortedSet
+)
+
+type memoryEntry struct {
+	key  string
+	kind int
+	// The value of a string, the fields of a hash or the members of a sorted set
+	// (sorted by score then member like Redis does) depending on the kind
+	value     string
+	fields    map[string]string
+	members   []ScoredMember
+	expiresAt time.Time
+}
+
+func NewMemoryCache(maxEntries int, ttl time.Duration) *memoryCache {
+	return &memoryCache{
+		maxEntries: maxEntries,
+		ttl:        ttl,
+		entries:    make(map[string]*list.Element),
+		lru:        list.New(),
+		now:        time.Now,
+	}
+}
+
+func (c *memoryCache) Delete(_ context.Context, key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[key]; ok {
+		c.remove(element)
+	}
+
+	return nil
+}
+
+func (c *memoryCache) IncrField(_ context.Context, key, field string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, err := c.hash(key)
+	if err != nil {
+		return err
+	}
+
+	value := int64(0)
+	if current, ok := entry.fields[field]; ok {
+		if value, err = strconv.ParseInt(current, 10, 64); err != nil {
+			return err
+		}
+	}
+	entry.fields[field] = strconv.FormatInt(value+1, 10)
+
+	return nil
+}
+
+func (c *memoryCache) SetField(_ context.Context, key, field string, value int64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, err := c.hash(key)
+	if err != nil {
+		return err
+	}
+	entry.fields[field] = strconv.FormatInt(value, 10)
+
+	return nil
+}
+
+func (c *memoryCache) CompareAndSetField(_ context.Context, key, field string, old, value int64) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, err := c.hash(key)
+	if err != nil {
+		return false, err
+	}
+
+	current, ok := entry.fields[field]
+	if !ok {
+		current = "0"
+	}
+	if current != strconv.FormatInt(old, 10) {
+		return false, nil
+	}
+	entry.fields[field] = strconv.FormatInt(value, 10)
+
+	return true, nil
+}
+
+func (c *memoryCache) GetFields(_ context.Context, key string) (map[string]string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	fields := make(map[string]string)
+	entry := c.get(key)
+	if entry == nil {
+		return fields, nil
+	}
+	if entry.kind != kindHash {
+		return nil, ErrWrongType
+	}
+
+	for field, value := range entry.fields {
+		fields[field] = value
+	}
+
+	return fields, nil
+}
+
+func (c *memoryCache) SetCachedField(_ context.Context, key, field, value string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, err := c.cachedHash(key)
+	if err != nil {
+		return err
+	}
+	entry.fields[field] = value
+
+	return nil
+}
+
+func (c *memoryCache) AddCachedFields(_ context.Context, key string, fields map[string]string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, err := c.cachedHash(key)
+	if err != nil {
+		return err
+	}
+	for field, value := range fields {
+		if _, ok := entry.fields[field]; !ok {
+			entry.fields[field] = value
+		}
+	}
+
+	return nil
+}
+
+func (c *memoryCache) AddToSortedSet(_ context.Context, key string, members []ScoredMember, limit int64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := c.get(key)
+	if entry == nil {
+		entry = c.put(&memoryEntry{key: key, kind: kindSortedSet})
+	}
+	if entry.kind != kindSortedSet {
+		return ErrWrongType
+	}
+
+	for _, member := range members {
+		entry.members = addMember(entry.members, member)
+	}
+
+	if excess := int64(len(entry.members)) - limit; excess > 0 {
+		entry.members = append(entry.members[:0:0], entry.members[excess:]...)
+	}
+
+	if c.ttl > 0 {
+		entry.expiresAt = c.now().Add(c.ttl)
+	}
+
+	return nil
+}
+
+func (c *memoryCache) GetSortedRanges(_ context.Context, key string, ranges ...ScoreRange) ([][]ScoredMember, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	results := make([][]ScoredMember, len(ranges))
+	entry := c.get(key)
+	if entry == nil {
+		return results, nil
+	}
+	if entry.kind != kindSortedSet {
+		return nil, ErrWrongType
+	}
+
+	for i, r := range ranges {
+		min, minExclusive, err := parseScoreBound(r.Min)
+		if err != nil {
+			return nil, err
+		}
+		max, maxExclusive, err := parseScoreBound(r.Max)
+		if err != nil {
+			return nil, err
+		}
+
+		inRange := func(score float64) bool {
+			return (score > min || (!minExclusive && score == min)) && (score < max || (!maxExclusive && score == max))
+		}
+
+		for j := range entry.members {
+			member := entry.members[j]
+			if r.Reverse {
+				member = entry.members[len(entry.members)-1-j]
+			}
+			if !inRange(member.Score) {
+				continue
+			}
+
+			results[i] = append(results[i], member)
+			if r.Limit > 0 && int64(len(results[i])) == r.Limit {
+				break
+			}
+		}
+	}
+
+	return results, nil
+}
+
+func (c *memoryCache) AcquireLease(_ context.Context, key string, ttl time.Duration) (string, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.get(key) != nil {
+		return "", false, nil
+	}
+
+	token, err := newLeaseToken()
+	if err != nil {
+		return "", false, err
+	}
+	c.put(&memoryEntry{key: key, kind: kindString, value: token, expiresAt: c.now().Add(ttl)})
+
+	return token, true, nil
+}
+
+func (c *memoryCache) ReleaseLease(_ context.Context, key, token string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if entry := c.get(key); entry != nil && entry.kind == kindString && entry.value == token {
+		c.remove(c.entries[key])
+	}
+
+	return nil
+}
+
+// hash returns the hash of counters held by the key, creating it if missing
+func (c *memoryCache) hash(key string) (*memoryEntry, error) {
+	entry := c.get(key)
+	if entry == nil {
+		entry = &memoryEntry{key: key, kind: kindHash, fields: make(map[string]string)}
+		c.entries[key] = &list.Element{Value: entry}
+	}
+	if entry.kind != kindHash {
+		return nil, ErrWrongType
+	}
+
+	return entry, nil
+}
+
+// cachedHash returns the cached hash held by the key, creating it if missing, & pushes back its expiry
+func (c *memoryCache) cachedHash(key string) (*memoryEntry, error) {
+	entry := c.get(key)
+	if entry == nil {
+		entry = c.put(&memoryEntry{key: key, kind: kindHash, fields: make(map[string]string)})
+	}
+	if entry.kind != kindHash {
+		return nil, ErrWrongType
+	}
+	if c.ttl > 0 {
+		entry.expiresAt = c.now().Add(c.ttl)
+	}
+
+	return entry, nil
+}
+
+// get returns the live entry of the key marking it as the most recently used, nil if missing or expired
+func (c *memoryCache) get(key string) *memoryEntry {
+	element, ok := c.entries[key]
+	if !ok {
+		return nil
+	}
+
+	entry := element.Value.(*memoryEntry)
+	if !entry.expiresAt.IsZero() && !c.now().Before(entry.expiresAt) {
+		c.remove(element)
+		return nil
+	}
+
+	// A no-op for counters as well
+	c.lru.MoveToFront(element)
+	return entry
+}
+
+// put adds the cached entry as the most recently used, evicting the least recently used ones past the max
+func (c *memoryCache) put(entry *memoryEntry) *memoryEntry {
+	c.entries[entry.key] = c.lru.PushFront(entry)
+
+	for c.lru.Len() > c.maxEntries {
+		c.remove(c.lru.Back())
+	}
+
+	return entry
+}
+
+// remove deletes the entry, which is a no-op on the LRU list for counters as they aren't linked to it
+func (c *memoryCache) remove(element *list.Element) {
+	c.lru.Remove(element)
+	delete(c.entries, element.Value.(*memoryEntry).key)
+}
+
+// addMember adds or moves the member to its place within the members sorted by score then member
+func addMember(members []ScoredMember, member ScoredMember) []ScoredMember {
+	for i := range members {
+		if members[i].Member == member.Member {
+			members = append(members[:i], members[i+1:]...)
+			break
+		}
+	}
+
+	i := sort.Search(len(members), func(i int) bool {
+		if members[i].Score != member.Score {
+			return members[i].Score > member.Score
+		}
+		return members[i].Member > member.Member
+	})
+
+	members = append(members, ScoredMember{})
+	copy(members[i+1:], members[i:])
+	members[i] = member
+
+	return members
+}
+
+// parseScoreBound parses a bound of a score range following the Redis syntax
+func parseScoreBound(bound string) (float64, bool, error) {
+	exclusive := strings.HasPrefix(bound, "(")
+	bound = strings.TrimPrefix(bound, "(")
+
+	switch bound {
+	case "-inf":
+		return math.Inf(-1), exclusive, nil
+	case "+inf", "inf":
+		return math.Inf(1), exclusive, nil
+	}
+
+	score, err := strconv.ParseFloat(bound, 64)
+	return score, exclusive, err
+}
@@ -0,0 +1,193 @@
+package cache
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+var ctx = context.Background()
+
+type MemoryCacheTestSuite struct {
+	suite.Suite
+	cache *memoryCache
+	now   time.Time
+}
+
+func TestMemoryCacheTestSuite(t *testing.T) {
+	suite.Run(t, new(MemoryCacheTestSuite))
+}
+
+func (mts *MemoryCacheTestSuite) SetupTest() {
+	mts.now = time.Now()
+	mts.cache = NewMemoryCache(2, time.Minute)
+	mts.cache.now = func() time.Time { return mts.now }
+}
+
+func (mts *MemoryCacheTestSuite) members(key string, r ScoreRange) []string {
+	ranges, err := mts.cache.GetSortedRanges(ctx, key, r)
+	mts.Require().NoError(err)
+
+	var members []string
+	for _, m := range ranges[0] {
+		members = append(members, m.Member)
+	}
+	return members
+}
+
+func (mts *MemoryCacheTestSuite) Test_Sorted_Set_Trimmed_To_Highest_Scores() {
+	err := mts.cache.AddToSortedSet(ctx, "key", []ScoredMember{{"a", 1}, {"c", 3}, {"b", 2}}, 2)
+	mts.NoError(err)
+
+	all := ScoreRange{Min: "-inf", Max: "+inf"}
+	mts.Equal([]string{"b", "c"}, mts.members("key", all))
+
+	// Re-adding a member moves it rather than duplicating it
+	err = mts.cache.AddToSortedSet(ctx, "key", []ScoredMember{{"b", 4}}, 2)
+	mts.NoError(err)
+	mts.Equal([]string{"c", "b"}, mts.members("key", all))
+}
+
+func (mts *MemoryCacheTestSuite) Test_Sorted_Ranges() {
+	err := mts.cache.AddToSortedSet(ctx, "key", []ScoredMember{{"a", 1}, {"b", 2}, {"c", 2}, {"d", 3}}, 10)
+	mts.NoError(err)
+
+	mts.Equal([]string{"b", "c"}, mts.members("key", ScoreRange{Min: "2", Max: "2"}))
+	mts.Equal([]string{"d", "c"}, mts.members("key", ScoreRange{Min: "(1", Max: "+inf", Reverse: true, Limit: 2}))
+	mts.Equal([]string{"a"}, mts.members("key", ScoreRange{Min: "-inf", Max: "(2"}))
+	mts.Empty(mts.members("missing", ScoreRange{Min: "-inf", Max: "+inf"}))
+}
+
+func (mts *MemoryCacheTestSuite) Test_Sorted_Set_Expires_After_TTL() {
+	err := mts.cache.AddToSortedSet(ctx, "key", []ScoredMember{{"a", 1}}, 10)
+	mts.NoError(err)
+
+	mts.now = mts.now.Add(time.Minute)
+	mts.Empty(mts.members("key", ScoreRange{Min: "-inf", Max: "+inf"}))
+}
+
+func (mts *MemoryCacheTestSuite) Test_Fields_Are_Kept_Past_TTL() {
+	mts.NoError(mts.cache.IncrField(ctx, "key", "peer"))
+	mts.NoError(mts.cache.IncrField(ctx, "key", "peer"))
+	mts.NoError(mts.cache.SetField(ctx, "key", "other", 5))
+
+	mts.now = mts.now.Add(time.Hour)
+
+	fields, err := mts.cache.GetFields(ctx, "key")
+	mts.NoError(err)
+	mts.Equal(map[string]string{"peer": "2", "other": "5"}, fields)
+}
+
+func (mts *MemoryCacheTestSuite) Test_Cached_Fields_Added_Until_TTL() {
+	mts.NoError(mts.cache.SetCachedField(ctx, "key", "a", "new"))
+	mts.NoError(mts.cache.AddCachedFields(ctx, "key", map[string]string{"a": "old", "b": "2"}))
+
+	fields, err := mts.cache.GetFields(ctx, "key")
+	mts.NoError(err)
+	mts.Equal(map[string]string{"a": "new", "b": "2"}, fields, "Fields set meanwhile kept")
+
+	mts.now = mts.now.Add(time.Minute)
+
+	fields, err = mts.cache.GetFields(ctx, "key")
+	mts.NoError(err)
+	mts.Empty(fields)
+}
+
+func (mts *MemoryCacheTestSuite) Test_Compare_And_Set_Field() {
+	set, err := mts.cache.CompareAndSetField(ctx, "key", "peer", 0, 3)
+	mts.NoError(err)
+	mts.True(set, "A missing counter is at 0")
+
+	mts.NoError(mts.cache.IncrField(ctx, "key", "peer"))
+
+	set, err = mts.cache.CompareAndSetField(ctx, "key", "peer", 3, 1)
+	mts.NoError(err)
+	mts.False(set, "Counted meanwhile")
+
+	set, err = mts.cache.CompareAndSetField(ctx, "key", "peer", 4, 1)
+	mts.NoError(err)
+	mts.True(set)
+
+	fields, err := mts.cache.GetFields(ctx, "key")
+	mts.NoError(err)
+	mts.Equal(map[string]string{"peer": "1"}, fields)
+}
+
+func (mts *MemoryCacheTestSuite) Test_Evicts_Least_Recently_Used() {
+	mts.NoError(mts.cache.AddToSortedSet(ctx, "first", []ScoredMember{{"a", 1}}, 10))
+	mts.NoError(mts.cache.AddToSortedSet(ctx, "second", []ScoredMember{{"a", 1}}, 10))
+
+	// Using the first key makes the second one the least recently used
+	mts.NotEmpty(mts.members("first", ScoreRange{Min: "-inf", Max: "+inf"}))
+	mts.NoError(mts.cache.AddCachedFields(ctx, "third", map[string]string{"f": "1"}))
+
+	mts.Empty(mts.members("second", ScoreRange{Min: "-inf", Max: "+inf"}))
+	mts.NotEmpty(mts.members("first", ScoreRange{Min: "-inf", Max: "+inf"}))
+}
+
+func (mts *MemoryCacheTestSuite) Test_Counters_Never_Evicted() {
+	mts.NoError(mts.cache.IncrField(ctx, "user1-unread", "peer"))
+	mts.NoError(mts.cache.SetField(ctx, "user2-unread", "peer", 3))
+
+	// Way past the max entries
+	for i := 0; i < 10*mts.cache.maxEntries; i++ {
+		key := strconv.Itoa(i)
+		mts.NoError(mts.cache.AddToSortedSet(ctx, key+"-messages", []ScoredMember{{"a", 1}}, 10))
+		mts.NoError(mts.cache.AddCachedFields(ctx, key+"-read-marks", map[string]string{"f": "1"}))
+	}
+	mts.Equal(mts.cache.maxEntries, mts.cache.lru.Len())
+
+	fields, err := mts.cache.GetFields(ctx, "user1-unread")
+	mts.NoError(err)
+	mts.Equal(map[string]string{"peer": "1"}, fields)
+
+	fields, err = mts.cache.GetFields(ctx, "user2-unread")
+	mts.NoError(err)
+	mts.Equal(map[string]string{"peer": "3"}, fields)
+
+	// Deleting a counter still works
+	mts.NoError(mts.cache.Delete(ctx, "user1-unread"))
+	fields, err = mts.cache.GetFields(ctx, "user1-unread")
+	mts.NoError(err)
+	mts.Empty(fields)
+}
+
+func (mts *MemoryCacheTestSuite) Test_Wrong_Type() {
+	mts.NoError(mts.cache.SetField(ctx, "key", "f", 1))
+
+	err := mts.cache.AddToSortedSet(ctx, "key", []ScoredMember{{"a", 1}}, 10)
+	mts.ErrorIs(err, ErrWrongType)
+
+	mts.NoError(mts.cache.Delete(ctx, "key"))
+	mts.NoError(mts.cache.AddToSortedSet(ctx, "key", []ScoredMember{{"a", 1}}, 10))
+}
+
+func (mts *MemoryCacheTestSuite) Test_Lease() {
+	token, acquired, err := mts.cache.AcquireLease(ctx, "lease", time.Second)
+	mts.NoError(err)
+	mts.True(acquired)
+
+	_, acquired, err = mts.cache.AcquireLease(ctx, "lease", time.Second)
+	mts.NoError(err)
+	mts.False(acquired)
+
+	// Only the holder releases the lease
+	mts.NoError(mts.cache.ReleaseLease(ctx, "lease", "other"))
+	_, acquired, err = mts.cache.AcquireLease(ctx, "lease", time.Second)
+	mts.NoError(err)
+	mts.False(acquired)
+
+	mts.NoError(mts.cache.ReleaseLease(ctx, "lease", token))
+	_, acquired, err = mts.cache.AcquireLease(ctx, "lease", time.Second)
+	mts.NoError(err)
+	mts.True(acquired)
+
+	// Expires past its TTL
+	mts.now = mts.now.Add(time.Second)
+	_, acquired, err = mts.cache.AcquireLease(ctx, "lease", time.Second)
+	mts.NoError(err)
+	mts.True(acquired)
+}
@@ -0,0 +1,146 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Deletes the lease only if it's still held by the token, rather than the next holder once it expired
+var releaseLeaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// Sets the counter only if it's still at the expected value, a missing one being 0
+var compareAndSetFieldScript = redis.NewScript(`
+if (redis.call("HGET", KEYS[1], ARGV[1]) or "0") == ARGV[2] then
+	redis.call("HSET", KEYS[1], ARGV[1], ARGV[3])
+	return 1
+end
+return 0
+`)
+
+type redisCache struct {
+	client *redis.Client
+	ttl    time.Duration
+}
+
+func NewRedisCache(client *redis.Client, ttl time.Duration) *redisCache {
+	return &redisCache{
+		client: client,
+		ttl:    ttl,
+	}
+}
+
+func (c *redisCache) Delete(ctx context.Context, key string) error {
+	return c.client.Del(ctx, key).Err()
+}
+
+func (c *redisCache) IncrField(ctx context.Context, key, field string) error {
+	return c.client.HIncrBy(ctx, key, field, 1).Err()
+}
+
+func (c *redisCache) SetField(ctx context.Context, key, field string, value int64) error {
+	return c.client.HSet(ctx, key, field, value).Err()
+}
+
+func (c *redisCache) CompareAndSetField(ctx context.Context, key, field string, old, value int64) (bool, error) {
+	set, err := compareAndSetFieldScript.Run(ctx, c.client, []string{key}, field, old, value).Int()
+	return set == 1, err
+}
+
+func (c *redisCache) GetFields(ctx context.Context, key string) (map[string]string, error) {
+	return c.client.HGetAll(ctx, key).Result()
+}
+
+func (c *redisCache) SetCachedField(ctx context.Context, key, field, value string) error {
+	_, err := c.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, key, field, value)
+		if c.ttl > 0 {
+			pipe.Expire(ctx, key, c.ttl)
+		}
+		return nil
+	})
+	return err
+}
+
+func (c *redisCache) AddCachedFields(ctx context.Context, key string, fields map[string]string) error {
+	_, err := c.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for field, value := range fields {
+			pipe.HSetNX(ctx, key, field, value)
+		}
+		if c.ttl > 0 {
+			pipe.Expire(ctx, key, c.ttl)
+		}
+		return nil
+	})
+	return err
+}
+
+func (c *redisCache) AddToSortedSet(ctx context.Context, key string, members []ScoredMember, limit int64) error {
+	zs := make([]*redis.Z, len(members))
+	for i, m := range members {
+		zs[i] = &redis.Z{Score: m.Score, Member: m.Member}
+	}
+
+	_, err := c.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZAdd(ctx, key, zs...)
+		pipe.ZRemRangeByRank(ctx, key, 0, -(limit + 1))
+		if c.ttl > 0 {
+			pipe.Expire(ctx, key, c.ttl)
+		}
+		return nil
+	})
+	return err
+}
+
+// GetSortedRanges reads the ranges within a single round trip
+func (c *redisCache) GetSortedRanges(ctx context.Context, key string, ranges ...ScoreRange) ([][]ScoredMember, error) {
+	cmds := make([]*redis.ZSliceCmd, len(ranges))
+	_, err := c.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, r := range ranges {
+			by := &redis.ZRangeBy{Min: r.Min, Max: r.Max, Count: r.Limit}
+			if r.Reverse {
+				cmds[i] = pipe.ZRevRangeByScoreWithScores(ctx, key, by)
+			} else {
+				cmds[i] = pipe.ZRangeByScoreWithScores(ctx, key, by)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([][]ScoredMember, len(cmds))
+	for i, cmd := range cmds {
+		for _, z := range cmd.Val() {
+			member, _ := z.Member.(string)
+			results[i] = append(results[i], ScoredMember{Member: member, Score: z.Score})
+		}
+	}
+
+	return results, nil
+}
+
+func (c *redisCache) AcquireLease(ctx context.Context, key string, ttl time.Duration) (string, bool, error) {
+	token, err := newLeaseToken()
+	if err != nil {
+		return "", false, err
+	}
+
+	acquired, err := c.client.SetNX(ctx, key, token, ttl).Result()
+	if err != nil || !acquired {
+		return "", false, err
+	}
+
+	return token, true, nil
+}
+
+func (c *redisCache) ReleaseLease(ctx context.Context, key, token string) error {
+	return releaseLeaseScript.Run(ctx, c.client, []string{key}, token).Err()
+}
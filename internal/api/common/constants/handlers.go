@@ -7,5 +7,13 @@ const (
 	INTERNAL_SERVER_ERROR     = "our bad. something went wrong .. please try again later"
 	BAD_REQUEST               = "invalid payLoad"
 	INVALID_LOGIN             = "invalid login"
+	INVALID_REFRESH_TOKEN     = "invalid refresh token"
 	SEND_MESSAGE_NO_RECIPIENT = "recipient does not exist"
+	MESSAGE_NOT_FOUND         = "message does not exist"
+	MESSAGE_NOT_SENDER        = "only the sender can change this message"
+	CONVERSATION_NO_PEER      = "peer does not exist"
+	GROUP_NOT_MEMBER          = "you are not a member of this group"
+	GROUP_FORBIDDEN           = "you are not allowed to manage this member"
+	GROUP_MEMBER_NOT_FOUND    = "member does not exist"
+	GROUP_FULL                = "group has reached the max number of members"
 )
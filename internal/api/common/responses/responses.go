@@ -10,6 +10,7 @@ type MessageResponse struct {
 	Recipient string `json:"recipient"`
 	Timestamp string `json:"timestamp"`
 	Content   string `json:"content"`
+	Status    string `json:"status"`
 }
 
 type MessagesResponse struct {
@@ -18,8 +19,21 @@ type MessagesResponse struct {
 }
 
 type Pagination struct {
-	CurrentPage   int `json:"currentPage"`
-	PageSize      int `json:"pageSize"`
-	TotalMessages int `json:"totalMessages"`
-	TotalPages    int `json:"totalPages"`
+	PageSize   int     `json:"pageSize"`
+	NextCursor *string `json:"nextCursor"`
+}
+
+type ConversationResponse struct {
+	Peer               string `json:"peer"`
+	LastMessageId      string `json:"lastMessageId"`
+	LastMessageSender  string `json:"lastMessageSender"`
+	LastMessagePreview string `json:"lastMessagePreview"`
+	LastMessageAt      string `json:"lastMessageAt"`
+	LastReadId         string `json:"lastReadId"`
+	PeerLastReadId     string `json:"peerLastReadId"`
+	UnreadCount        int64  `json:"unreadCount"`
+}
+
+type ConversationsResponse struct {
+	Conversations []ConversationResponse `json:"conversations"`
 }
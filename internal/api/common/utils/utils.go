@@ -1,20 +1,135 @@
 package utils
 
 import (
+	"chat-system/internal/models"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
+	"time"
+
+	"github.com/gocql/gocql"
 )
 
-func GetPaginationParams(r *http.Request) (int, int) {
-	page, err := strconv.Atoi(r.URL.Query().Get("page"))
-	if err != nil || page < 1 {
-		page = 1
-	}
+// Directions a cursor walks the messages in
+const (
+	CURSOR_BEFORE = "before"
+	CURSOR_AFTER  = "after"
+)
+
+// cursor is what the opaque `cursor` param & `nextCursor` field carry
+type cursor struct {
+	Direction string     `json:"d"`
+	Timestamp int64      `json:"t"`
+	ID        gocql.UUID `json:"id"`
+}
 
-	pageSize, err := strconv.Atoi(r.URL.Query().Get("pageSize"))
+var (
+	ErrInvalidCursor = errors.New("invalid cursor")
+	ErrNotTimeUUID   = errors.New("not a TimeUUID")
+)
+
+// GetCursorParams reads the page bounds from either the opaque `cursor`, or the `before`/`after` message IDs.
+// The returned page asks for one more message than the page size, so we can tell whether there's a next page.
+func GetCursorParams(r *http.Request) (models.MessagesPage, int, error) {
+	var page models.MessagesPage
+	query := r.URL.Query()
+
+	pageSize, err := strconv.Atoi(query.Get("pageSize"))
 	if err != nil || pageSize < 1 {
-		pageSize = 10
+		pageSize = models.DEFAULT_PAGE_SIZE
+	}
+	if pageSize > models.MAX_PAGE_SIZE {
+		pageSize = models.MAX_PAGE_SIZE
+	}
+	page.Limit = pageSize + 1
+
+	if raw := query.Get("cursor"); raw != "" {
+		direction, key, err := DecodeCursor(raw)
+		if err != nil {
+			return page, pageSize, err
+		}
+
+		if direction == CURSOR_AFTER {
+			page.After = &key
+		} else {
+			page.Before = &key
+		}
+
+		return page, pageSize, nil
+	}
+
+	if raw := query.Get("before"); raw != "" {
+		key, err := keyFromParam(raw)
+		if err != nil {
+			return page, pageSize, err
+		}
+		page.Before = &key
+	}
+
+	if raw := query.Get("after"); raw != "" {
+		if page.Before != nil {
+			return page, pageSize, ErrInvalidCursor
+		}
+
+		key, err := keyFromParam(raw)
+		if err != nil {
+			return page, pageSize, err
+		}
+		page.After = &key
+	}
+
+	return page, pageSize, nil
+}
+
+func EncodeCursor(direction string, key models.MessageKey) string {
+	encoded, _ := json.Marshal(cursor{
+		Direction: direction,
+		Timestamp: key.Timestamp.UnixMilli(),
+		ID:        key.ID,
+	})
+
+	return base64.RawURLEncoding.EncodeToString(encoded)
+}
+
+func DecodeCursor(raw string) (string, models.MessageKey, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return "", models.MessageKey{}, ErrInvalidCursor
+	}
+
+	var c cursor
+	if err := json.Unmarshal(decoded, &c); err != nil {
+		return "", models.MessageKey{}, ErrInvalidCursor
+	}
+
+	if c.Direction != CURSOR_BEFORE && c.Direction != CURSOR_AFTER {
+		return "", models.MessageKey{}, ErrInvalidCursor
+	}
+
+	return c.Direction, models.MessageKey{Timestamp: time.UnixMilli(c.Timestamp).UTC(), ID: c.ID}, nil
+}
+
+// keyFromParam accepts the ID of a message, which must be a TimeUUID
+func keyFromParam(raw string) (models.MessageKey, error) {
+	id, err := ParseTimeUUID(raw)
+	if err != nil {
+		return models.MessageKey{}, ErrInvalidCursor
+	}
+
+	return models.KeyFromID(id), nil
+}
+
+// ParseTimeUUID parses the ID of a message, which must be a TimeUUID
+func ParseTimeUUID(raw string) (gocql.UUID, error) {
+	id, err := gocql.ParseUUID(raw)
+	if err != nil {
+		return gocql.UUID{}, err
+	}
+	if id.Version() != 1 {
+		return gocql.UUID{}, ErrNotTimeUUID
 	}
 
-	return page, pageSize
+	return id, nil
 }
@@ -1,20 +1,48 @@
 package handlers
 
 import (
+	"chat-system/internal/health"
+	"chat-system/internal/logging"
 	"encoding/json"
 	"net/http"
 )
 
-func HowAmI(w http.ResponseWriter, r *http.Request) {
+const STATUS_ALIVE = "alive"
+
+type HealthHandler interface {
+	Live(w http.ResponseWriter, r *http.Request)
+	Ready(w http.ResponseWriter, r *http.Request)
+}
+
+type healthHandler struct {
+	checks []health.Check
+}
+
+func NewHealthHandler(checks []health.Check) *healthHandler {
+	return &healthHandler{checks: checks}
+}
+
+// Live tells the process is up & serving, without checking its dependencies: restarting it wouldn't bring them back
+func (h *healthHandler) Live(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
 	w.WriteHeader(http.StatusOK)
-	res := struct {
-		Status, HeartBeat, Message string
-	}{
-		Status:    "ALIVE",
-		HeartBeat: "HeartBeating & Functioning",
-		Message:   "How Are you?!",
+	json.NewEncoder(w).Encode(map[string]string{"status": STATUS_ALIVE})
+}
+
+// Ready checks each dependency the replica relies on, answering 503 along with their statuses unless all of them
+// are up, so that no traffic gets routed to the replica meanwhile
+func (h *healthHandler) Ready(w http.ResponseWriter, r *http.Request) {
+	report := health.Run(r.Context(), h.checks, health.CHECK_TIMEOUT)
+
+	status := http.StatusOK
+	if !report.Ready() {
+		status = http.StatusServiceUnavailable
+		logging.FromContext(r.Context()).Warn("Not ready", "checks", report.Checks)
 	}
 
-	json.NewEncoder(w).Encode(res)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(report)
 }
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"chat-system/internal/health"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type HealthTestSuite struct {
+	suite.Suite
+	redisErr error
+	handler  *healthHandler
+}
+
+func TestHealthTestSuite(t *testing.T) {
+	suite.Run(t, new(HealthTestSuite))
+}
+
+func (hts *HealthTestSuite) SetupTest() {
+	hts.redisErr = nil
+	hts.handler = NewHealthHandler([]health.Check{
+		{Name: "cassandra", Run: func(ctx context.Context) error { return nil }},
+		{Name: "redis", Run: func(ctx context.Context) error { return hts.redisErr }},
+	})
+}
+
+func (hts *HealthTestSuite) ready() (*httptest.ResponseRecorder, health.Report) {
+	rr := httptest.NewRecorder()
+	hts.handler.Ready(rr, httptest.NewRequest("GET", "/api/v1/health/ready", nil))
+
+	var report health.Report
+	hts.NoError(json.NewDecoder(rr.Body).Decode(&report))
+	return rr, report
+}
+
+func (hts *HealthTestSuite) Test_Live() {
+	hts.redisErr = errors.New("connection refused")
+	rr := httptest.NewRecorder()
+
+	hts.handler.Live(rr, httptest.NewRequest("GET", "/api/v1/health/live", nil))
+
+	hts.Equal(http.StatusOK, rr.Code)
+	hts.JSONEq(`{"status":"alive"}`, rr.Body.String())
+}
+
+func (hts *HealthTestSuite) Test_Ready() {
+	rr, report := hts.ready()
+
+	hts.Equal(http.StatusOK, rr.Code)
+	hts.Equal(health.STATUS_READY, report.Status)
+	hts.Equal(health.STATUS_UP, report.Checks["cassandra"].Status)
+	hts.Equal(health.STATUS_UP, report.Checks["redis"].Status)
+}
+
+func (hts *HealthTestSuite) Test_Not_Ready() {
+	hts.redisErr = errors.New("connection refused")
+
+	rr, report := hts.ready()
+
+	hts.Equal(http.StatusServiceUnavailable, rr.Code)
+	hts.Equal(health.STATUS_NOT_READY, report.Status)
+	hts.Equal(health.STATUS_UP, report.Checks["cassandra"].Status)
+	hts.Equal(health.STATUS_DOWN, report.Checks["redis"].Status)
+	hts.Equal("connection refused", report.Checks["redis"].Error)
+}
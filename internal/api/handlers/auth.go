@@ -6,26 +6,31 @@ import (
 	"chat-system/internal/api/middlewares"
 	"chat-system/internal/api/transformers"
 	"chat-system/internal/api/validators"
+	"chat-system/internal/logging"
 	"chat-system/internal/models"
 	"chat-system/internal/services"
 	"encoding/json"
 	"errors"
-	"log"
 	"net/http"
 )
 
 type AuthHandler interface {
 	Register(w http.ResponseWriter, r *http.Request)
 	Login(w http.ResponseWriter, r *http.Request)
+	Refresh(w http.ResponseWriter, r *http.Request)
+	Logout(w http.ResponseWriter, r *http.Request)
+	JWKS(w http.ResponseWriter, r *http.Request)
 }
 
 type userHandler struct {
 	service services.UserService
+	tokens  *auth.Tokens
 }
 
-func NewUserHandler(userService services.UserService) *userHandler {
+func NewUserHandler(userService services.UserService, tokens *auth.Tokens) *userHandler {
 	return &userHandler{
 		service: userService,
+		tokens:  tokens,
 	}
 }
 
@@ -39,7 +44,7 @@ func (uh *userHandler) Register(w http.ResponseWriter, r *http.Request) {
 		panic(middlewares.NewHTTPError(http.StatusBadRequest, errors.New(common.BAD_REQUEST)))
 	}
 
-	exists, err := uh.service.UserExists(input.Username)
+	exists, err := uh.service.UserExists(r.Context(), input.Username)
 	if err != nil {
 		panic(err)
 	}
@@ -47,7 +52,7 @@ func (uh *userHandler) Register(w http.ResponseWriter, r *http.Request) {
 		panic(middlewares.NewHTTPError(http.StatusConflict, errors.New(common.REGISTER_USER_EXISTS)))
 	}
 
-	user, err := uh.service.CreateUser(&input)
+	user, err := uh.service.CreateUser(r.Context(), &input)
 	if err != nil {
 		panic(err)
 	}
@@ -68,15 +73,15 @@ func (uh *userHandler) Login(w http.ResponseWriter, r *http.Request) {
 		panic(middlewares.NewHTTPError(http.StatusBadRequest, errors.New(common.BAD_REQUEST)))
 	}
 
-	user, err := uh.service.GetUserByCreds(credentials)
+	user, err := uh.service.GetUserByCreds(r.Context(), credentials)
 
 	if err != nil {
-		log.Printf("An unexpected error occurred: %v", err)
+		logging.FromContext(r.Context()).Info("Failed to log in", "user", credentials.Username, "error", err)
 		panic(middlewares.NewHTTPError(http.StatusUnauthorized, errors.New(common.INVALID_LOGIN)))
 	}
 
-	// Generate JWT token
-	token, err := auth.GenerateToken(user.Username)
+	// Generate JWT tokens of a new session
+	tokens, err := uh.tokens.GenerateTokenPair(user)
 	if err != nil {
 		panic(err)
 	}
@@ -84,24 +89,63 @@ func (uh *userHandler) Login(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	res := struct {
-		Token string                     `json:"token"`
-		Data  *transformers.UserResponse `json:"data"`
+		*transformers.TokensResponse
+		Data *transformers.UserResponse `json:"data"`
 	}{
-		Token: token,
-		Data:  transformers.TransUserToUserResponse(user),
+		TokensResponse: transformers.TransTokenPairToTokensResponse(tokens),
+		Data:           transformers.TransUserToUserResponse(user),
 	}
 	json.NewEncoder(w).Encode(res)
 }
 
+// Refresh exchanges a refresh token for a new pair of tokens. The refresh token is single-use.
+func (uh *userHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	var input models.RefreshTokenInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		panic(middlewares.NewHTTPError(http.StatusBadRequest, errors.New(common.BAD_REQUEST)))
+	}
+
+	if err := validators.ValidateRefreshTokenInput(input); err != nil {
+		panic(middlewares.NewHTTPError(http.StatusBadRequest, errors.New(common.BAD_REQUEST)))
+	}
+
+	tokens, err := uh.tokens.RefreshTokenPair(r.Context(), input.RefreshToken)
+	if err != nil {
+		logging.FromContext(r.Context()).Info("Failed to refresh tokens", "error", err)
+		panic(middlewares.NewHTTPError(http.StatusUnauthorized, errors.New(common.INVALID_REFRESH_TOKEN)))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(transformers.TransTokenPairToTokensResponse(tokens))
+}
+
+// Logout revokes the session of the authenticated user, i.e. both its access & refresh tokens
+func (uh *userHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	userClaims := middlewares.GetUserFromContext(r.Context())
+
+	if err := uh.tokens.RevokeSession(r.Context(), userClaims); err != nil {
+		panic(err)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// JWKS publishes the public keys verifying the issued tokens, so other services can verify them on their own
+func (uh *userHandler) JWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	// Keys only change on rotation, which keeps the old key around long enough for the caches to expire
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(uh.tokens.JWKS())
+}
+
 /*
 TODO:: A list of events/actions that must trigger cache invalidation:
 - User Profile Update: When a user updates their profile information (especially username).
 
 - User Deactivation or Suspension: If a user is deactivated or suspended by admin e.g.
 
-- User Logout: When a user logs out, any cached data related to that user should be invalidated to ensure no unauthorized access.
-
 - User Account Deletion: If a user account is deleted.
-- Token Refresh Mechanism: If we implement such a mechanism, it's obvious to consider invalidating the respective user cache.
 etc...
 */
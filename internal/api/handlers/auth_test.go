@@ -2,17 +2,20 @@ package handlers
 
 import (
 	"bytes"
+	"chat-system/internal/api/auth"
 	common "chat-system/internal/api/common/constants"
 	"chat-system/internal/api/middlewares"
 	"chat-system/internal/api/transformers"
 	"chat-system/internal/models"
 	"chat-system/mocks"
+	"context"
 	"encoding/json"
 	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
+	"github.com/gocql/gocql"
 	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
@@ -22,6 +25,7 @@ type AuthTestSuite struct {
 	suite.Suite
 	handler *userHandler
 	service *mocks.UserService
+	tokens  *auth.Tokens
 	server  *httptest.Server
 }
 
@@ -29,15 +33,32 @@ func TestAuthTestSuite(t *testing.T) {
 	suite.Run(t, new(AuthTestSuite))
 }
 
+// newTestTokens signs the tokens of the tests with a secret key, revoking them in memory
+func newTestTokens() *auth.Tokens {
+	tokens, err := auth.NewTokens(auth.Config{
+		SecretKey: []byte("secret"),
+		Issuer:    auth.DEFAULT_ISSUER,
+		Audience:  auth.DEFAULT_AUDIENCE,
+	}, auth.NewMemoryRevocationList())
+	if err != nil {
+		panic(err)
+	}
+
+	return tokens
+}
+
 func (ats *AuthTestSuite) SetupTest() {
 	r := mux.NewRouter()
 	r.Use(middlewares.HandleErrors)
 
 	ats.service = &mocks.UserService{}
-	ats.handler = NewUserHandler(ats.service)
+	ats.tokens = newTestTokens()
+	ats.handler = NewUserHandler(ats.service, ats.tokens)
 
 	r.HandleFunc("/register", ats.handler.Register).Methods("POST")
 	r.HandleFunc("/login", ats.handler.Login).Methods("POST")
+	r.HandleFunc("/refresh", ats.handler.Refresh).Methods("POST")
+	r.Handle("/logout", middlewares.IsAuth(ats.tokens, "Bearer")(http.HandlerFunc(ats.handler.Logout))).Methods("POST")
 
 	ats.server = httptest.NewServer(r)
 }
@@ -53,8 +74,8 @@ func (ats *AuthTestSuite) TestRegister_Invalid_Input() {
 	body, err := json.Marshal(invalidBody)
 	ats.NoError(err, "Failed to marshal registerInput")
 	expectedUser := &models.User{Username: "user1", Password: "123456"}
-	ats.service.On("UserExists", mock.Anything).Return(false, nil).Once()
-	ats.service.On("CreateUser", mock.Anything).Return(expectedUser, nil).Once()
+	ats.service.On("UserExists", mock.Anything, mock.Anything).Return(false, nil).Once()
+	ats.service.On("CreateUser", mock.Anything, mock.Anything).Return(expectedUser, nil).Once()
 	resp, err := http.Post(ats.server.URL+"/register", "application/json", bytes.NewBuffer(body))
 	ats.NoError(err, "Failed to make POST request")
 
@@ -66,8 +87,8 @@ func (ats *AuthTestSuite) TestRegister_Invalid_Input() {
 	body, err = json.Marshal(invalidBody)
 	ats.NoError(err, "Failed to marshal registerInput")
 	expectedUser = &models.User{Username: "user1", Password: "123456"}
-	ats.service.On("UserExists", mock.Anything).Return(false, nil).Once()
-	ats.service.On("CreateUser", mock.Anything).Return(expectedUser, nil).Once()
+	ats.service.On("UserExists", mock.Anything, mock.Anything).Return(false, nil).Once()
+	ats.service.On("CreateUser", mock.Anything, mock.Anything).Return(expectedUser, nil).Once()
 	resp, err = http.Post(ats.server.URL+"/register", "application/json", bytes.NewBuffer(body))
 	ats.NoError(err, "Failed to make POST request")
 
@@ -152,7 +173,7 @@ func (ats *AuthTestSuite) TestRegister_Invalid_Input() {
 }
 
 func (ats *AuthTestSuite) TestRegister_Username_Taken() {
-	ats.service.On("UserExists", mock.Anything).Return(true, nil).Once()
+	ats.service.On("UserExists", mock.Anything, mock.Anything).Return(true, nil).Once()
 
 	testUserName := "user1"
 	testPassword := "123456"
@@ -177,7 +198,7 @@ func (ats *AuthTestSuite) TestRegister_Username_Taken() {
 
 func (ats *AuthTestSuite) TestRegister_Unexpected_Err() {
 	expectedErr := errors.New(common.INTERNAL_SERVER_ERROR)
-	ats.service.On("UserExists", mock.Anything).Return(false, expectedErr).Once()
+	ats.service.On("UserExists", mock.Anything, mock.Anything).Return(false, expectedErr).Once()
 
 	testUserName := "user1"
 	testPassword := "123456"
@@ -201,12 +222,12 @@ func (ats *AuthTestSuite) TestRegister_Unexpected_Err() {
 }
 
 func (ats *AuthTestSuite) TestRegister_Success() {
-	ats.service.On("UserExists", mock.Anything).Return(false, nil).Once()
+	ats.service.On("UserExists", mock.Anything, mock.Anything).Return(false, nil).Once()
 
 	testUsername := "user1"
 	testPassword := "123456"
 	expectedUser := &models.User{Username: testUsername, Password: testPassword}
-	ats.service.On("CreateUser", mock.Anything).Return(expectedUser, nil).Once()
+	ats.service.On("CreateUser", mock.Anything, mock.Anything).Return(expectedUser, nil).Once()
 
 	registerInput := models.RegisterInput{Username: testUsername, Password: testPassword}
 	body, err := json.Marshal(registerInput)
@@ -257,7 +278,7 @@ func (ats *AuthTestSuite) TestLogin_Invalid_Input() {
 
 func (ats *AuthTestSuite) TestLogin_Invalid_Login() {
 	expectedErr := errors.New(common.INVALID_LOGIN)
-	ats.service.On("GetUserByCreds", mock.Anything).Return(nil, expectedErr).Once()
+	ats.service.On("GetUserByCreds", mock.Anything, mock.Anything).Return(nil, expectedErr).Once()
 
 	testUserName := "user1"
 	testPassword := "123456"
@@ -284,7 +305,7 @@ func (ats *AuthTestSuite) TestLogin_Success() {
 	testUsername := "user1"
 	testPassword := "123456"
 	expectedUser := &models.User{Username: testUsername, Password: testPassword}
-	ats.service.On("GetUserByCreds", mock.Anything).Return(expectedUser, nil).Once()
+	ats.service.On("GetUserByCreds", mock.Anything, mock.Anything).Return(expectedUser, nil).Once()
 
 	loginInput := models.LoginInput{Username: testUsername, Password: testPassword}
 	body, err := json.Marshal(loginInput)
@@ -297,11 +318,84 @@ func (ats *AuthTestSuite) TestLogin_Success() {
 	ats.Equal(http.StatusOK, resp.StatusCode)
 
 	var res struct {
-		Token string                     `json:"token"`
-		Data  *transformers.UserResponse `json:"data"`
+		transformers.TokensResponse
+		Data *transformers.UserResponse `json:"data"`
 	}
 	err = json.NewDecoder(resp.Body).Decode(&res)
 	ats.NoError(err, "Failed to decode response body")
 
 	ats.Equal(testUsername, res.Data.Username)
+	ats.NotEmpty(res.Token)
+	ats.NotEmpty(res.RefreshToken)
+	ats.Equal(int64(auth.ACCESS_TOKEN_EXPIRES_IN.Seconds()), res.ExpiresIn)
+}
+
+func (ats *AuthTestSuite) refresh(refreshToken string) (*http.Response, *transformers.TokensResponse) {
+	body, err := json.Marshal(models.RefreshTokenInput{RefreshToken: refreshToken})
+	ats.NoError(err, "Failed to marshal refreshTokenInput")
+
+	resp, err := http.Post(ats.server.URL+"/refresh", "application/json", bytes.NewBuffer(body))
+	ats.NoError(err, "Failed to make POST request")
+	defer resp.Body.Close()
+
+	var tokens transformers.TokensResponse
+	json.NewDecoder(resp.Body).Decode(&tokens)
+
+	return resp, &tokens
+}
+
+func (ats *AuthTestSuite) TestRefresh_Rotates_Refresh_Token() {
+	tokens, err := ats.tokens.GenerateTokenPair(&models.User{ID: gocql.TimeUUID(), Username: "user1"})
+	ats.NoError(err, "Failed to generate tokens")
+
+	resp, rotated := ats.refresh(tokens.RefreshToken)
+
+	ats.Equal(http.StatusOK, resp.StatusCode)
+	ats.NotEmpty(rotated.Token)
+	ats.NotEqual(tokens.RefreshToken, rotated.RefreshToken)
+
+	// The refresh token is single-use
+	resp, _ = ats.refresh(tokens.RefreshToken)
+	ats.Equal(http.StatusUnauthorized, resp.StatusCode)
+
+	// Reusing it revokes the whole session, including the tokens it was rotated into
+	resp, _ = ats.refresh(rotated.RefreshToken)
+	ats.Equal(http.StatusUnauthorized, resp.StatusCode)
+
+	_, err = ats.tokens.ValidateToken(context.Background(), rotated.Token)
+	ats.ErrorIs(err, auth.ErrRevokedToken)
+}
+
+func (ats *AuthTestSuite) TestRefresh_Access_Token_Rejected() {
+	token, err := ats.tokens.GenerateToken(&models.User{ID: gocql.TimeUUID(), Username: "user1"})
+	ats.NoError(err, "Failed to generate token")
+
+	resp, _ := ats.refresh(token)
+
+	ats.Equal(http.StatusUnauthorized, resp.StatusCode)
+}
+
+func (ats *AuthTestSuite) TestLogout_Revokes_Session() {
+	tokens, err := ats.tokens.GenerateTokenPair(&models.User{ID: gocql.TimeUUID(), Username: "user1"})
+	ats.NoError(err, "Failed to generate tokens")
+
+	req, err := http.NewRequest("POST", ats.server.URL+"/logout", nil)
+	ats.NoError(err, "Failed to make request")
+	req.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+
+	resp, err := http.DefaultClient.Do(req)
+	ats.NoError(err, "Failed to make POST request")
+	resp.Body.Close()
+
+	ats.Equal(http.StatusNoContent, resp.StatusCode)
+
+	// Neither the access token nor the refresh token are accepted anymore
+	resp, err = http.DefaultClient.Do(req)
+	ats.NoError(err, "Failed to make POST request")
+	resp.Body.Close()
+
+	ats.Equal(http.StatusUnauthorized, resp.StatusCode)
+
+	resp, _ = ats.refresh(tokens.RefreshToken)
+	ats.Equal(http.StatusUnauthorized, resp.StatusCode)
 }
@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	common "chat-system/internal/api/common/constants"
+	"chat-system/internal/api/common/utils"
+	"chat-system/internal/api/middlewares"
+	"chat-system/internal/api/realtime"
+	"chat-system/internal/api/validators"
+	"chat-system/internal/logging"
+	"chat-system/internal/models"
+	"chat-system/internal/services"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gocql/gocql"
+	"github.com/gorilla/mux"
+)
+
+type ConversationHandler interface {
+	GetConversations(w http.ResponseWriter, r *http.Request)
+	GetConversationMessages(w http.ResponseWriter, r *http.Request)
+	ReadConversation(w http.ResponseWriter, r *http.Request)
+}
+
+type conversationHandler struct {
+	service     services.ConversationService
+	userService services.UserService
+	broker      realtime.Broker
+}
+
+func NewConversationHandler(
+	conversationService services.ConversationService,
+	userService services.UserService,
+	broker realtime.Broker,
+) *conversationHandler {
+	return &conversationHandler{
+		service:     conversationService,
+		userService: userService,
+		broker:      broker,
+	}
+}
+
+// GetConversations lists the peers of the authenticated user along with a preview of the last message exchanged
+func (ch *conversationHandler) GetConversations(w http.ResponseWriter, r *http.Request) {
+	userClaims := middlewares.GetUserFromContext(r.Context())
+
+	conversations, err := ch.service.GetConversations(r.Context(), userClaims.Username)
+	if err != nil {
+		panic(err)
+	}
+
+	unreadCounts, err := ch.service.GetUnreadCounts(r.Context(), userClaims.Username)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to fetch unread counts", "user", userClaims.Username, "error", err)
+	}
+	for i := range conversations {
+		conversations[i].UnreadCount = unreadCounts[conversations[i].Peer]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"conversations": conversations,
+	})
+}
+
+// GetConversationMessages retrieves the messages exchanged between the authenticated user & the given peer
+func (ch *conversationHandler) GetConversationMessages(w http.ResponseWriter, r *http.Request) {
+	peer := ch.peerOf(r)
+
+	userClaims := middlewares.GetUserFromContext(r.Context())
+	page, pageSize := getPageParams(r)
+
+	messages, err := ch.service.GetConversationMessages(r.Context(), userClaims.Username, peer, page)
+	if err != nil {
+		panic(err)
+	}
+
+	readMarks, err := ch.service.GetReadMarks(r.Context(), userClaims.Username)
+	if err != nil {
+		panic(err)
+	}
+	setReadStatus(messages, userClaims.Username, readMarks)
+
+	res := paginateMessages(page, pageSize, messages)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(res)
+}
+
+// ReadConversation marks the messages of the conversation as read by the authenticated user,
+// up to & including the given one, then lets the peer know their messages were read
+func (ch *conversationHandler) ReadConversation(w http.ResponseWriter, r *http.Request) {
+	peer := ch.peerOf(r)
+
+	var input models.ReadConversationInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		panic(middlewares.NewHTTPError(http.StatusBadRequest, errors.New(common.BAD_REQUEST)))
+	}
+
+	if err := validators.ValidateReadConversationInput(input); err != nil {
+		panic(middlewares.NewHTTPError(http.StatusBadRequest, errors.New(common.BAD_REQUEST)))
+	}
+
+	lastReadID, err := utils.ParseTimeUUID(input.LastReadID)
+	if err != nil {
+		panic(middlewares.NewHTTPError(http.StatusBadRequest, errors.New(common.BAD_REQUEST)))
+	}
+
+	userClaims := middlewares.GetUserFromContext(r.Context())
+
+	lastRead, err := ch.service.GetConversationMessage(r.Context(), userClaims.Username, peer, lastReadID)
+	if err != nil {
+		panic(err)
+	}
+	if lastRead == nil {
+		panic(middlewares.NewHTTPError(http.StatusNotFound, errors.New(common.MESSAGE_NOT_FOUND)))
+	}
+
+	if err := ch.service.MarkAsRead(r.Context(), userClaims.Username, peer, lastRead); err != nil {
+		panic(err)
+	}
+
+	// The read mark is moved already, so the count & the peer get updated even if the client went away meanwhile
+	ctx := context.WithoutCancel(r.Context())
+
+	// Recount rather than reset, since messages might have arrived past the one read
+	unreadCount, err := ch.service.RecountUnread(ctx, userClaims.Username, peer, lastRead)
+	if err != nil {
+		panic(err)
+	}
+
+	lastRead.Status = models.MESSAGE_STATUS_READ
+	if err := ch.broker.Publish(ctx, realtime.EVENT_MESSAGE_READ, lastRead, directAudience(lastRead)); err != nil {
+		logging.FromContext(ctx).Error("Failed to publish read message", "message_id", lastRead.ID, "error", err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"lastReadId":  lastRead.ID,
+		"unreadCount": unreadCount,
+	})
+}
+
+// peerOf returns the peer of the `peer` path param, as long as they exist
+func (ch *conversationHandler) peerOf(r *http.Request) string {
+	peer := mux.Vars(r)["peer"]
+
+	exists, err := ch.userService.UserExists(r.Context(), peer)
+	if err != nil {
+		panic(err)
+	}
+	if !exists {
+		panic(middlewares.NewHTTPError(http.StatusNotFound, errors.New(common.CONVERSATION_NO_PEER)))
+	}
+
+	return peer
+}
+
+// setReadStatus sets the status of the direct messages sent by the user, given how far each peer has read them
+func setReadStatus(messages []models.Message, username string, readMarks map[string]gocql.UUID) {
+	for i := range messages {
+		msg := &messages[i]
+		if msg.Sender != username || msg.GroupID != nil {
+			continue
+		}
+
+		msg.Status = models.MESSAGE_STATUS_DELIVERED
+		if mark, ok := readMarks[msg.Recipient]; ok && !msg.ID.Time().After(mark.Time()) {
+			msg.Status = models.MESSAGE_STATUS_READ
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	common "chat-system/internal/api/common/constants"
+	"chat-system/internal/api/common/responses"
+	"chat-system/internal/api/middlewares"
+	"chat-system/internal/api/realtime"
+	"chat-system/internal/models"
+	"chat-system/mocks"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type ConversationsTestSuite struct {
+	suite.Suite
+	conversationService *mocks.ConversationService
+	userService         *mocks.UserService
+	broker              *mocks.Broker
+	handler             *conversationHandler
+	server              *httptest.Server
+	authHeader          string
+}
+
+func TestConversationsTestSuite(t *testing.T) {
+	suite.Run(t, new(ConversationsTestSuite))
+}
+
+func (cts *ConversationsTestSuite) SetupTest() {
+	tokens := newTestTokens()
+	cts.conversationService = &mocks.ConversationService{}
+	cts.userService = &mocks.UserService{}
+	cts.broker = &mocks.Broker{}
+	cts.handler = NewConversationHandler(cts.conversationService, cts.userService, cts.broker)
+
+	r := mux.NewRouter()
+	r.Use(middlewares.HandleErrors)
+	r.Use(middlewares.IsAuth(tokens, "Bearer"))
+	r.HandleFunc("/conversations", cts.handler.GetConversations).Methods("GET")
+	r.HandleFunc("/conversations/{peer}/messages", cts.handler.GetConversationMessages).Methods("GET")
+	r.HandleFunc("/conversations/{peer}/read", cts.handler.ReadConversation).Methods("POST")
+
+	cts.server = httptest.NewServer(r)
+
+	token, err := tokens.GenerateToken(&models.User{ID: gocql.TimeUUID(), Username: "User1"})
+	cts.NoError(err, "Failed to create token")
+	cts.authHeader = fmt.Sprintf("Bearer %s", token)
+}
+
+func (cts *ConversationsTestSuite) TearDownTest() {
+	cts.server.Close()
+}
+
+func (cts *ConversationsTestSuite) get(path string) *http.Response {
+	req, err := http.NewRequest("GET", cts.server.URL+path, nil)
+	cts.NoError(err, "Failed to make request")
+
+	req.Header.Set("Authorization", cts.authHeader)
+
+	resp, err := http.DefaultClient.Do(req)
+	cts.NoError(err, "Failed to make GET request")
+
+	return resp
+}
+
+func (cts *ConversationsTestSuite) TestGetConversations_Success() {
+	expected := []models.Conversation{
+		{Peer: "User2", LastMessageID: gocql.TimeUUID(), LastMessageSender: "User2", LastMessagePreview: "Hi", LastMessageAt: time.Now()},
+		{Peer: "User3", LastMessageID: gocql.TimeUUID(), LastMessageSender: "User1", LastMessagePreview: "Bye", LastMessageAt: time.Now()},
+	}
+	cts.conversationService.On("GetConversations", mock.Anything, "User1").Return(expected, nil).Once()
+	cts.conversationService.On("GetUnreadCounts", mock.Anything, "User1").Return(map[string]int64{"User2": 3}, nil).Once()
+
+	resp := cts.get("/conversations")
+	defer resp.Body.Close()
+
+	cts.Equal(http.StatusOK, resp.StatusCode)
+
+	var res responses.ConversationsResponse
+	err := json.NewDecoder(resp.Body).Decode(&res)
+	cts.NoError(err, "Failed to decode response body")
+
+	cts.Len(res.Conversations, 2)
+	cts.Equal("User2", res.Conversations[0].Peer)
+	cts.Equal("Hi", res.Conversations[0].LastMessagePreview)
+	cts.Equal(int64(3), res.Conversations[0].UnreadCount)
+	cts.Equal("User1", res.Conversations[1].LastMessageSender)
+	cts.Equal(int64(0), res.Conversations[1].UnreadCount)
+}
+
+func (cts *ConversationsTestSuite) TestGetConversations_Error() {
+	cts.conversationService.On("GetConversations", mock.Anything, mock.Anything).Return(nil, errors.New("DB is Down :(")).Once()
+
+	resp := cts.get("/conversations")
+	defer resp.Body.Close()
+
+	cts.Equal(http.StatusInternalServerError, resp.StatusCode)
+}
+
+func (cts *ConversationsTestSuite) TestGetConversationMessages_Peer_Not_Found() {
+	cts.userService.On("UserExists", mock.Anything, "ghost").Return(false, nil).Once()
+
+	resp := cts.get("/conversations/ghost/messages")
+	defer resp.Body.Close()
+
+	cts.Equal(http.StatusNotFound, resp.StatusCode)
+
+	var errResponse responses.ErrResponse
+	err := json.NewDecoder(resp.Body).Decode(&errResponse)
+	cts.NoError(err, "Failed to decode response body")
+
+	cts.Equal(common.CONVERSATION_NO_PEER, errResponse.Error)
+}
+
+func (cts *ConversationsTestSuite) TestGetConversationMessages_Success() {
+	cts.userService.On("UserExists", mock.Anything, "User2").Return(true, nil).Once()
+
+	thread := []models.Message{
+		{ID: gocql.TimeUUID(), Sender: "User1", Recipient: "User2", Content: "Hi"},
+		{ID: gocql.TimeUUID(), Sender: "User1", Recipient: "User2", Content: "Hey"},
+	}
+	cts.conversationService.On("GetConversationMessages", mock.Anything, "User1", "User2", mock.Anything).Return(thread, nil).Once()
+	cts.conversationService.On("GetReadMarks", mock.Anything, "User1").Return(map[string]gocql.UUID{"User2": thread[0].ID}, nil).Once()
+
+	resp := cts.get("/conversations/User2/messages?pageSize=1")
+	defer resp.Body.Close()
+
+	cts.Equal(http.StatusOK, resp.StatusCode)
+
+	var msgsResponse responses.MessagesResponse
+	err := json.NewDecoder(resp.Body).Decode(&msgsResponse)
+	cts.NoError(err, "Failed to decode response body")
+
+	cts.Len(msgsResponse.Messages, 1)
+	cts.Equal("Hi", msgsResponse.Messages[0].Content)
+	cts.Equal(models.MESSAGE_STATUS_READ, msgsResponse.Messages[0].Status)
+	cts.NotNil(msgsResponse.Pagination.NextCursor)
+}
+
+func (cts *ConversationsTestSuite) read(peer string, body string) *http.Response {
+	req, err := http.NewRequest("POST", cts.server.URL+"/conversations/"+peer+"/read", strings.NewReader(body))
+	cts.NoError(err, "Failed to make request")
+
+	req.Header.Set("Authorization", cts.authHeader)
+
+	resp, err := http.DefaultClient.Do(req)
+	cts.NoError(err, "Failed to make POST request")
+
+	return resp
+}
+
+func (cts *ConversationsTestSuite) TestReadConversation_Not_TimeUUID() {
+	cts.userService.On("UserExists", mock.Anything, "User2").Return(true, nil).Once()
+
+	resp := cts.read("User2", `{"lastReadId": "`+gocql.MustRandomUUID().String()+`"}`)
+	defer resp.Body.Close()
+
+	cts.Equal(http.StatusBadRequest, resp.StatusCode)
+}
+
+func (cts *ConversationsTestSuite) TestReadConversation_Message_Not_Found() {
+	id := gocql.TimeUUID()
+	cts.userService.On("UserExists", mock.Anything, "User2").Return(true, nil).Once()
+	cts.conversationService.On("GetConversationMessage", mock.Anything, "User1", "User2", id).Return(nil, nil).Once()
+
+	resp := cts.read("User2", `{"lastReadId": "`+id.String()+`"}`)
+	defer resp.Body.Close()
+
+	cts.Equal(http.StatusNotFound, resp.StatusCode)
+}
+
+func (cts *ConversationsTestSuite) TestReadConversation_Success() {
+	lastRead := &models.Message{ID: gocql.TimeUUID(), Sender: "User2", Recipient: "User1", Content: "Hi"}
+
+	cts.userService.On("UserExists", mock.Anything, "User2").Return(true, nil).Once()
+	cts.conversationService.On("GetConversationMessage", mock.Anything, "User1", "User2", lastRead.ID).Return(lastRead, nil).Once()
+	cts.conversationService.On("MarkAsRead", mock.Anything, "User1", "User2", lastRead).Return(nil).Once()
+	cts.conversationService.On("RecountUnread", mock.Anything, "User1", "User2", lastRead).Return(2, nil).Once()
+	cts.broker.On("Publish", mock.Anything, realtime.EVENT_MESSAGE_READ, lastRead, []string{"User2", "User1"}).Return(nil).Once()
+
+	resp := cts.read("User2", `{"lastReadId": "`+lastRead.ID.String()+`"}`)
+	defer resp.Body.Close()
+
+	cts.Equal(http.StatusOK, resp.StatusCode)
+
+	var res struct {
+		LastReadID  string `json:"lastReadId"`
+		UnreadCount int    `json:"unreadCount"`
+	}
+	err := json.NewDecoder(resp.Body).Decode(&res)
+	cts.NoError(err, "Failed to decode response body")
+
+	cts.Equal(lastRead.ID.String(), res.LastReadID)
+	cts.Equal(2, res.UnreadCount)
+	cts.Equal(models.MESSAGE_STATUS_READ, lastRead.Status)
+	cts.broker.AssertExpectations(cts.T())
+}
@@ -0,0 +1,224 @@
+package handlers
+
+import (
+	common "chat-system/internal/api/common/constants"
+	"chat-system/internal/api/middlewares"
+	"chat-system/internal/api/validators"
+	"chat-system/internal/models"
+	"chat-system/internal/services"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gocql/gocql"
+	"github.com/gorilla/mux"
+)
+
+// The higher the rank, the more privileges the role has over the others
+var groupRoleRanks = map[string]int{
+	models.GROUP_ROLE_MEMBER: 1,
+	models.GROUP_ROLE_ADMIN:  2,
+	models.GROUP_ROLE_OWNER:  3,
+}
+
+type GroupHandler interface {
+	CreateGroup(w http.ResponseWriter, r *http.Request)
+	GetMembers(w http.ResponseWriter, r *http.Request)
+	AddMember(w http.ResponseWriter, r *http.Request)
+	RemoveMember(w http.ResponseWriter, r *http.Request)
+	GetGroupMessages(w http.ResponseWriter, r *http.Request)
+}
+
+type groupHandler struct {
+	service     services.GroupService
+	userService services.UserService
+}
+
+func NewGroupHandler(groupService services.GroupService, userService services.UserService) *groupHandler {
+	return &groupHandler{
+		service:     groupService,
+		userService: userService,
+	}
+}
+
+// CreateGroup creates a group owned by the authenticated user along with its initial members
+func (gh *groupHandler) CreateGroup(w http.ResponseWriter, r *http.Request) {
+	var input models.CreateGroupInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		panic(middlewares.NewHTTPError(http.StatusBadRequest, errors.New(common.BAD_REQUEST)))
+	}
+
+	if err := validators.ValidateCreateGroupInput(input); err != nil {
+		panic(middlewares.NewHTTPError(http.StatusBadRequest, errors.New(common.BAD_REQUEST)))
+	}
+
+	userClaims := middlewares.GetUserFromContext(r.Context())
+
+	members := make([]string, 0, len(input.Members))
+	seen := map[string]bool{userClaims.Username: true}
+	for _, member := range input.Members {
+		if seen[member] {
+			continue
+		}
+		seen[member] = true
+
+		gh.ensureUserExists(r.Context(), member)
+		members = append(members, member)
+	}
+
+	group := &models.Group{
+		Name:  input.Name,
+		Owner: userClaims.Username,
+	}
+	if err := gh.service.CreateGroup(r.Context(), group, members); err != nil {
+		panic(err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(group)
+}
+
+// GetMembers lists the members of the group along with their roles. Available to members only
+func (gh *groupHandler) GetMembers(w http.ResponseWriter, r *http.Request) {
+	members, _ := gh.membersOfGroupFor(r)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"members": members,
+	})
+}
+
+// AddMember adds a user to the group or changes the role of an existing member.
+// Owner & admins can add members, while only the owner can grant the admin role.
+func (gh *groupHandler) AddMember(w http.ResponseWriter, r *http.Request) {
+	var input models.AddGroupMemberInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		panic(middlewares.NewHTTPError(http.StatusBadRequest, errors.New(common.BAD_REQUEST)))
+	}
+
+	if err := validators.ValidateAddGroupMemberInput(input); err != nil {
+		panic(middlewares.NewHTTPError(http.StatusBadRequest, errors.New(common.BAD_REQUEST)))
+	}
+
+	if input.Role == "" {
+		input.Role = models.GROUP_ROLE_MEMBER
+	}
+
+	members, actor := gh.membersOfGroupFor(r)
+
+	if actor.Role == models.GROUP_ROLE_MEMBER ||
+		(input.Role == models.GROUP_ROLE_ADMIN && actor.Role != models.GROUP_ROLE_OWNER) {
+		panic(middlewares.NewHTTPError(http.StatusForbidden, errors.New(common.GROUP_FORBIDDEN)))
+	}
+
+	target := findGroupMember(members, input.Username)
+	if target != nil && groupRoleRanks[target.Role] >= groupRoleRanks[actor.Role] {
+		panic(middlewares.NewHTTPError(http.StatusForbidden, errors.New(common.GROUP_FORBIDDEN)))
+	}
+
+	if target == nil {
+		if len(members) >= services.MAX_GROUP_MEMBERS {
+			panic(middlewares.NewHTTPError(http.StatusConflict, errors.New(common.GROUP_FULL)))
+		}
+
+		gh.ensureUserExists(r.Context(), input.Username)
+	}
+
+	if err := gh.service.AddMember(r.Context(), actor.GroupID, input.Username, input.Role); err != nil {
+		panic(err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(models.GroupMember{
+		GroupID:  actor.GroupID,
+		Username: input.Username,
+		Role:     input.Role,
+	})
+}
+
+// RemoveMember removes a member from the group. Members can leave on their own, except for the owner.
+// Otherwise, only a member of a higher role can remove another one.
+func (gh *groupHandler) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	members, actor := gh.membersOfGroupFor(r)
+
+	target := findGroupMember(members, mux.Vars(r)["username"])
+	if target == nil {
+		panic(middlewares.NewHTTPError(http.StatusNotFound, errors.New(common.GROUP_MEMBER_NOT_FOUND)))
+	}
+
+	leaving := target.Username == actor.Username
+	if (leaving && actor.Role == models.GROUP_ROLE_OWNER) ||
+		(!leaving && groupRoleRanks[actor.Role] <= groupRoleRanks[target.Role]) ||
+		(!leaving && actor.Role == models.GROUP_ROLE_MEMBER) {
+		panic(middlewares.NewHTTPError(http.StatusForbidden, errors.New(common.GROUP_FORBIDDEN)))
+	}
+
+	if err := gh.service.RemoveMember(r.Context(), actor.GroupID, target.Username); err != nil {
+		panic(err)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetGroupMessages retrieves the timeline of the group. Available to members only
+func (gh *groupHandler) GetGroupMessages(w http.ResponseWriter, r *http.Request) {
+	_, actor := gh.membersOfGroupFor(r)
+	page, pageSize := getPageParams(r)
+
+	messages, err := gh.service.GetGroupMessages(r.Context(), actor.GroupID, page)
+	if err != nil {
+		panic(err)
+	}
+
+	res := paginateMessages(page, pageSize, messages)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(res)
+}
+
+// membersOfGroupFor fetches the members of the group in the request path
+// and ensures the authenticated user is one of them
+func (gh *groupHandler) membersOfGroupFor(r *http.Request) ([]models.GroupMember, *models.GroupMember) {
+	groupID, err := gocql.ParseUUID(mux.Vars(r)["id"])
+	if err != nil {
+		panic(middlewares.NewHTTPError(http.StatusBadRequest, errors.New(common.BAD_REQUEST)))
+	}
+
+	members, err := gh.service.GetMembers(r.Context(), groupID)
+	if err != nil {
+		panic(err)
+	}
+
+	userClaims := middlewares.GetUserFromContext(r.Context())
+	actor := findGroupMember(members, userClaims.Username)
+	if actor == nil {
+		panic(middlewares.NewHTTPError(http.StatusForbidden, errors.New(common.GROUP_NOT_MEMBER)))
+	}
+
+	return members, actor
+}
+
+func (gh *groupHandler) ensureUserExists(ctx context.Context, username string) {
+	exists, err := gh.userService.UserExists(ctx, username)
+	if err != nil {
+		panic(err)
+	}
+	if !exists {
+		panic(middlewares.NewHTTPError(http.StatusBadRequest, errors.New(common.GROUP_MEMBER_NOT_FOUND)))
+	}
+}
+
+func findGroupMember(members []models.GroupMember, username string) *models.GroupMember {
+	for i := range members {
+		if members[i].Username == username {
+			return &members[i]
+		}
+	}
+
+	return nil
+}
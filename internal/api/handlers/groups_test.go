@@ -0,0 +1,277 @@
+package handlers
+
+import (
+	"bytes"
+	common "chat-system/internal/api/common/constants"
+	"chat-system/internal/api/common/responses"
+	"chat-system/internal/api/middlewares"
+	"chat-system/internal/models"
+	"chat-system/mocks"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gocql/gocql"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type GroupsTestSuite struct {
+	suite.Suite
+	groupService *mocks.GroupService
+	userService  *mocks.UserService
+	handler      *groupHandler
+	server       *httptest.Server
+	authHeader   string
+	groupID      gocql.UUID
+}
+
+func TestGroupsTestSuite(t *testing.T) {
+	suite.Run(t, new(GroupsTestSuite))
+}
+
+func (gts *GroupsTestSuite) SetupTest() {
+	tokens := newTestTokens()
+	gts.groupService = &mocks.GroupService{}
+	gts.userService = &mocks.UserService{}
+	gts.handler = NewGroupHandler(gts.groupService, gts.userService)
+
+	r := mux.NewRouter()
+	r.Use(middlewares.HandleErrors)
+	r.Use(middlewares.IsAuth(tokens, "Bearer"))
+	r.HandleFunc("/groups", gts.handler.CreateGroup).Methods("POST")
+	r.HandleFunc("/groups/{id}/members", gts.handler.GetMembers).Methods("GET")
+	r.HandleFunc("/groups/{id}/members", gts.handler.AddMember).Methods("POST")
+	r.HandleFunc("/groups/{id}/members/{username}", gts.handler.RemoveMember).Methods("DELETE")
+	r.HandleFunc("/groups/{id}/messages", gts.handler.GetGroupMessages).Methods("GET")
+
+	gts.server = httptest.NewServer(r)
+
+	token, err := tokens.GenerateToken(&models.User{ID: gocql.TimeUUID(), Username: "User1"})
+	gts.NoError(err, "Failed to create token")
+	gts.authHeader = fmt.Sprintf("Bearer %s", token)
+
+	gts.groupID = gocql.TimeUUID()
+}
+
+func (gts *GroupsTestSuite) TearDownTest() {
+	gts.server.Close()
+}
+
+func (gts *GroupsTestSuite) do(method, path string, payload interface{}) *http.Response {
+	var body io.Reader
+	if payload != nil {
+		encoded, err := json.Marshal(payload)
+		gts.NoError(err, "Failed to marshal payload")
+		body = bytes.NewBuffer(encoded)
+	}
+
+	req, err := http.NewRequest(method, gts.server.URL+path, body)
+	gts.NoError(err, "Failed to make request")
+
+	req.Header.Set("Authorization", gts.authHeader)
+
+	resp, err := http.DefaultClient.Do(req)
+	gts.NoError(err, "Failed to send request")
+
+	return resp
+}
+
+// withMembers mocks the current members of the group, where the first one is the authenticated user
+func (gts *GroupsTestSuite) withMembers(roles ...string) {
+	members := make([]models.GroupMember, 0, len(roles))
+	for i, role := range roles {
+		members = append(members, models.GroupMember{
+			GroupID:  gts.groupID,
+			Username: fmt.Sprintf("User%d", i+1),
+			Role:     role,
+		})
+	}
+
+	gts.groupService.On("GetMembers", mock.Anything, gts.groupID).Return(members, nil).Once()
+}
+
+func (gts *GroupsTestSuite) assertError(resp *http.Response, statusCode int, errMsg string) {
+	defer resp.Body.Close()
+
+	gts.Equal(statusCode, resp.StatusCode)
+
+	var errResponse responses.ErrResponse
+	err := json.NewDecoder(resp.Body).Decode(&errResponse)
+	gts.NoError(err, "Failed to decode response body")
+
+	gts.Equal(errMsg, errResponse.Error)
+}
+
+func (gts *GroupsTestSuite) TestCreateGroup_Invalid_Input() {
+	resp := gts.do("POST", "/groups", &models.CreateGroupInput{})
+
+	gts.assertError(resp, http.StatusBadRequest, common.BAD_REQUEST)
+}
+
+func (gts *GroupsTestSuite) TestCreateGroup_Member_Not_Found() {
+	gts.userService.On("UserExists", mock.Anything, "ghost").Return(false, nil).Once()
+
+	resp := gts.do("POST", "/groups", &models.CreateGroupInput{Name: "friends", Members: []string{"ghost"}})
+
+	gts.assertError(resp, http.StatusBadRequest, common.GROUP_MEMBER_NOT_FOUND)
+}
+
+func (gts *GroupsTestSuite) TestCreateGroup_Success() {
+	gts.userService.On("UserExists", mock.Anything, "User2").Return(true, nil).Once()
+	// The creator & duplicates are not added twice
+	gts.groupService.On("CreateGroup", mock.Anything, mock.Anything, []string{"User2"}).Return(nil).Once()
+
+	resp := gts.do("POST", "/groups", &models.CreateGroupInput{Name: "friends", Members: []string{"User1", "User2", "User2"}})
+	defer resp.Body.Close()
+
+	gts.Equal(http.StatusCreated, resp.StatusCode)
+
+	var group models.Group
+	err := json.NewDecoder(resp.Body).Decode(&group)
+	gts.NoError(err, "Failed to decode response body")
+
+	gts.Equal("friends", group.Name)
+	gts.Equal("User1", group.Owner)
+}
+
+func (gts *GroupsTestSuite) TestGetMembers_Not_Member() {
+	gts.groupService.On("GetMembers", mock.Anything, gts.groupID).Return([]models.GroupMember{}, nil).Once()
+
+	resp := gts.do("GET", "/groups/"+gts.groupID.String()+"/members", nil)
+
+	gts.assertError(resp, http.StatusForbidden, common.GROUP_NOT_MEMBER)
+}
+
+func (gts *GroupsTestSuite) TestGetMembers_Invalid_Group() {
+	resp := gts.do("GET", "/groups/not-a-uuid/members", nil)
+
+	gts.assertError(resp, http.StatusBadRequest, common.BAD_REQUEST)
+}
+
+func (gts *GroupsTestSuite) TestGetMembers_Success() {
+	gts.withMembers(models.GROUP_ROLE_MEMBER, models.GROUP_ROLE_OWNER)
+
+	resp := gts.do("GET", "/groups/"+gts.groupID.String()+"/members", nil)
+	defer resp.Body.Close()
+
+	gts.Equal(http.StatusOK, resp.StatusCode)
+
+	var res struct {
+		Members []models.GroupMember `json:"members"`
+	}
+	err := json.NewDecoder(resp.Body).Decode(&res)
+	gts.NoError(err, "Failed to decode response body")
+
+	gts.Len(res.Members, 2)
+}
+
+func (gts *GroupsTestSuite) TestAddMember_By_Member_Forbidden() {
+	gts.withMembers(models.GROUP_ROLE_MEMBER, models.GROUP_ROLE_OWNER)
+
+	resp := gts.do("POST", "/groups/"+gts.groupID.String()+"/members", &models.AddGroupMemberInput{Username: "User9"})
+
+	gts.assertError(resp, http.StatusForbidden, common.GROUP_FORBIDDEN)
+}
+
+func (gts *GroupsTestSuite) TestAddMember_Admin_Role_By_Admin_Forbidden() {
+	gts.withMembers(models.GROUP_ROLE_ADMIN, models.GROUP_ROLE_OWNER)
+
+	resp := gts.do("POST", "/groups/"+gts.groupID.String()+"/members", &models.AddGroupMemberInput{
+		Username: "User9",
+		Role:     models.GROUP_ROLE_ADMIN,
+	})
+
+	gts.assertError(resp, http.StatusForbidden, common.GROUP_FORBIDDEN)
+}
+
+func (gts *GroupsTestSuite) TestAddMember_By_Admin_Success() {
+	gts.withMembers(models.GROUP_ROLE_ADMIN, models.GROUP_ROLE_OWNER)
+	gts.userService.On("UserExists", mock.Anything, "User9").Return(true, nil).Once()
+	gts.groupService.On("AddMember", mock.Anything, gts.groupID, "User9", models.GROUP_ROLE_MEMBER).Return(nil).Once()
+
+	resp := gts.do("POST", "/groups/"+gts.groupID.String()+"/members", &models.AddGroupMemberInput{Username: "User9"})
+	defer resp.Body.Close()
+
+	gts.Equal(http.StatusCreated, resp.StatusCode)
+}
+
+func (gts *GroupsTestSuite) TestAddMember_Promote_By_Owner_Success() {
+	gts.withMembers(models.GROUP_ROLE_OWNER, models.GROUP_ROLE_MEMBER)
+	gts.groupService.On("AddMember", mock.Anything, gts.groupID, "User2", models.GROUP_ROLE_ADMIN).Return(nil).Once()
+
+	resp := gts.do("POST", "/groups/"+gts.groupID.String()+"/members", &models.AddGroupMemberInput{
+		Username: "User2",
+		Role:     models.GROUP_ROLE_ADMIN,
+	})
+	defer resp.Body.Close()
+
+	gts.Equal(http.StatusCreated, resp.StatusCode)
+	gts.userService.AssertNotCalled(gts.T(), "UserExists", mock.Anything, mock.Anything)
+}
+
+func (gts *GroupsTestSuite) TestRemoveMember_Owner_Cannot_Leave() {
+	gts.withMembers(models.GROUP_ROLE_OWNER, models.GROUP_ROLE_MEMBER)
+
+	resp := gts.do("DELETE", "/groups/"+gts.groupID.String()+"/members/User1", nil)
+
+	gts.assertError(resp, http.StatusForbidden, common.GROUP_FORBIDDEN)
+}
+
+func (gts *GroupsTestSuite) TestRemoveMember_Admin_Cannot_Remove_Admin() {
+	gts.withMembers(models.GROUP_ROLE_ADMIN, models.GROUP_ROLE_ADMIN)
+
+	resp := gts.do("DELETE", "/groups/"+gts.groupID.String()+"/members/User2", nil)
+
+	gts.assertError(resp, http.StatusForbidden, common.GROUP_FORBIDDEN)
+}
+
+func (gts *GroupsTestSuite) TestRemoveMember_Not_Found() {
+	gts.withMembers(models.GROUP_ROLE_OWNER)
+
+	resp := gts.do("DELETE", "/groups/"+gts.groupID.String()+"/members/User9", nil)
+
+	gts.assertError(resp, http.StatusNotFound, common.GROUP_MEMBER_NOT_FOUND)
+}
+
+func (gts *GroupsTestSuite) TestRemoveMember_Leave_Success() {
+	gts.withMembers(models.GROUP_ROLE_MEMBER, models.GROUP_ROLE_OWNER)
+	gts.groupService.On("RemoveMember", mock.Anything, gts.groupID, "User1").Return(nil).Once()
+
+	resp := gts.do("DELETE", "/groups/"+gts.groupID.String()+"/members/User1", nil)
+	defer resp.Body.Close()
+
+	gts.Equal(http.StatusNoContent, resp.StatusCode)
+}
+
+func (gts *GroupsTestSuite) TestRemoveMember_By_Owner_Success() {
+	gts.withMembers(models.GROUP_ROLE_OWNER, models.GROUP_ROLE_ADMIN)
+	gts.groupService.On("RemoveMember", mock.Anything, gts.groupID, "User2").Return(nil).Once()
+
+	resp := gts.do("DELETE", "/groups/"+gts.groupID.String()+"/members/User2", nil)
+	defer resp.Body.Close()
+
+	gts.Equal(http.StatusNoContent, resp.StatusCode)
+}
+
+func (gts *GroupsTestSuite) TestGetGroupMessages_Success() {
+	gts.withMembers(models.GROUP_ROLE_MEMBER)
+	messages := []models.Message{{Sender: "User2", Content: "Hi all", GroupID: &gts.groupID}}
+	gts.groupService.On("GetGroupMessages", mock.Anything, gts.groupID, mock.Anything).Return(messages, nil).Once()
+
+	resp := gts.do("GET", "/groups/"+gts.groupID.String()+"/messages", nil)
+	defer resp.Body.Close()
+
+	gts.Equal(http.StatusOK, resp.StatusCode)
+
+	var msgsResponse responses.MessagesResponse
+	err := json.NewDecoder(resp.Body).Decode(&msgsResponse)
+	gts.NoError(err, "Failed to decode response body")
+
+	gts.Len(msgsResponse.Messages, 1)
+	gts.Equal("Hi all", msgsResponse.Messages[0].Content)
+}
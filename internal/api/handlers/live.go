@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	common "chat-system/internal/api/common/constants"
+	"chat-system/internal/api/middlewares"
+	"chat-system/internal/api/realtime"
+	"chat-system/internal/logging"
+	"chat-system/internal/metrics"
+	"chat-system/internal/models"
+	"chat-system/internal/services"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/gorilla/websocket"
+)
+
+// Comments are ignored by EventSource clients, yet they keep proxies from closing idle streams
+const HEARTBEAT_PERIOD = 15 * time.Second
+
+// Pages of missed messages replayed on reconnection at most, past which the client is told to resync through the
+// history, so that an old `Last-Event-ID` can't have a whole history streamed on every reconnection
+const MAX_REPLAYED_PAGES = 10
+
+type LiveHandler interface {
+	Connect(w http.ResponseWriter, r *http.Request)
+	Stream(w http.ResponseWriter, r *http.Request)
+}
+
+type liveHandler struct {
+	hub      *realtime.Hub
+	service  services.MessageService
+	upgrader websocket.Upgrader
+}
+
+func NewLiveHandler(hub *realtime.Hub, msgService services.MessageService) *liveHandler {
+	return &liveHandler{
+		hub:     hub,
+		service: msgService,
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  1024,
+			WriteBufferSize: 1024,
+			// Connections are authenticated by token rather than cookies, so any origin is fine
+			CheckOrigin: func(r *http.Request) bool { return true },
+		},
+	}
+}
+
+// Connect upgrades the request to a WebSocket that receives every message sent to or by the authenticated user
+func (lh *liveHandler) Connect(w http.ResponseWriter, r *http.Request) {
+	userClaims := middlewares.GetUserFromContext(r.Context())
+
+	conn, err := lh.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader has already replied to the client with the respective HTTP error
+		logging.FromContext(r.Context()).Warn("Failed to upgrade connection", "user", userClaims.Username, "error", err)
+		return
+	}
+
+	sessions := metrics.LiveSessions.WithLabelValues(metrics.TRANSPORT_WEBSOCKET)
+	sessions.Inc()
+	defer sessions.Dec()
+
+	realtime.NewSession(lh.hub, conn, userClaims.Username).Serve()
+}
+
+// Stream is the Server-Sent Events fallback of Connect for clients that cannot upgrade to WebSocket.
+// Sending the ID of the last received message as `Last-Event-ID` replays whatever was missed meanwhile, up to
+// MAX_REPLAYED_PAGES.
+func (lh *liveHandler) Stream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		panic(errors.New("streaming is not supported by the response writer"))
+	}
+
+	var lastEventID *gocql.UUID
+	if header := r.Header.Get("Last-Event-ID"); header != "" {
+		id, err := gocql.ParseUUID(header)
+		if err != nil {
+			panic(middlewares.NewHTTPError(http.StatusBadRequest, errors.New(common.BAD_REQUEST)))
+		}
+		lastEventID = &id
+	}
+
+	userClaims := middlewares.GetUserFromContext(r.Context())
+
+	// Subscribe before looking up the missed messages, so nothing sent in between gets lost
+	subscription := realtime.NewSubscription(userClaims.Username)
+	lh.hub.Register(subscription)
+	defer lh.hub.Unregister(subscription)
+
+	sessions := metrics.LiveSessions.WithLabelValues(metrics.TRANSPORT_SSE)
+	sessions.Inc()
+	defer sessions.Dec()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	// Tell nginx not to buffer the stream
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	replayed := make(map[gocql.UUID]struct{})
+	if lastEventID != nil {
+		if err := lh.replay(r.Context(), w, flusher, userClaims.Username, *lastEventID, replayed); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(HEARTBEAT_PERIOD)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-subscription.Events():
+			if !ok {
+				return
+			}
+			if _, ok := replayed[event.Data.ID]; ok && event.Type == realtime.EVENT_MESSAGE {
+				continue
+			}
+			if err := writeEvent(w, event); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, event *realtime.Event) error {
+	data, err := json.Marshal(event.Data)
+	if err != nil {
+		return err
+	}
+
+	// Only new messages carry an ID, so `Last-Event-ID` never goes back in time due to an edit of an older one
+	if event.Type == realtime.EVENT_MESSAGE {
+		if _, err := fmt.Fprintf(w, "id: %s\n", event.Data.ID); err != nil {
+			return err
+		}
+	}
+
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+	return err
+}
+
+// replay writes the messages sent after the given one in chronological order, a page at a time, keeping track of
+// the replayed ones. Past MAX_REPLAYED_PAGES, or if they can't be read, the client is told to resync instead.
+func (lh *liveHandler) replay(
+	ctx context.Context,
+	w http.ResponseWriter,
+	flusher http.Flusher,
+	username string,
+	lastID gocql.UUID,
+	replayed map[gocql.UUID]struct{},
+) error {
+	bound := models.KeyFromID(lastID)
+
+	for pages := 0; pages < MAX_REPLAYED_PAGES; pages++ {
+		page, err := lh.service.GetMessages(ctx, username, models.MessagesPage{After: &bound, Limit: models.MAX_PAGE_SIZE})
+		if err != nil {
+			if ctx.Err() != nil {
+				return err
+			}
+			logging.FromContext(ctx).Warn("Failed to replay the missed messages", "user", username, "error", err)
+			return writeEvent(w, &realtime.Event{Type: realtime.EVENT_RESYNC})
+		}
+
+		// Pages are sorted newest first
+		for i := len(page) - 1; i >= 0; i-- {
+			if err := writeEvent(w, &realtime.Event{Type: realtime.EVENT_MESSAGE, Data: &page[i]}); err != nil {
+				return err
+			}
+			replayed[page[i].ID] = struct{}{}
+		}
+		flusher.Flush()
+
+		if len(page) < models.MAX_PAGE_SIZE {
+			return nil
+		}
+		bound = models.KeyOf(&page[0])
+	}
+
+	return writeEvent(w, &realtime.Event{Type: realtime.EVENT_RESYNC})
+}
@@ -0,0 +1,325 @@
+package handlers
+
+import (
+	"bufio"
+	"chat-system/internal/api/middlewares"
+	"chat-system/internal/api/realtime"
+	"chat-system/internal/models"
+	"chat-system/mocks"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type LiveTestSuite struct {
+	suite.Suite
+	hub        *realtime.Hub
+	msgService *mocks.MessageService
+	handler    *liveHandler
+	server     *httptest.Server
+	wsServer   *httptest.Server
+	wsUrl      string
+	token      string
+}
+
+func TestLiveTestSuite(t *testing.T) {
+	suite.Run(t, new(LiveTestSuite))
+}
+
+func (lts *LiveTestSuite) SetupTest() {
+	tokens := newTestTokens()
+	lts.hub = realtime.NewHub()
+	lts.msgService = &mocks.MessageService{}
+	lts.handler = NewLiveHandler(lts.hub, lts.msgService)
+	lts.wsServer = httptest.NewServer(middlewares.IsLiveAuth(tokens, "Bearer")(http.HandlerFunc(lts.handler.Connect)))
+	lts.wsUrl = "ws" + strings.TrimPrefix(lts.wsServer.URL, "http")
+	lts.server = httptest.NewServer(
+		middlewares.IsLiveAuth(tokens, "Bearer")(middlewares.HandleErrors(http.HandlerFunc(lts.handler.Stream))),
+	)
+
+	token, err := tokens.GenerateToken(&models.User{ID: gocql.TimeUUID(), Username: "User1"})
+	lts.NoError(err, "Failed to create token")
+	lts.token = token
+}
+
+func (lts *LiveTestSuite) TearDownTest() {
+	lts.wsServer.Close()
+	lts.server.Close()
+}
+
+func (lts *LiveTestSuite) dial(header http.Header, query string) (*websocket.Conn, *http.Response, error) {
+	return websocket.DefaultDialer.Dial(lts.wsUrl+query, header)
+}
+
+func (lts *LiveTestSuite) waitForSessions(count int) {
+	lts.Eventually(func() bool {
+		return lts.hub.SessionsCount() == count
+	}, time.Second, 10*time.Millisecond)
+}
+
+func (lts *LiveTestSuite) TestConnect_Unauthorized() {
+	_, resp, err := lts.dial(nil, "")
+
+	lts.Error(err)
+	lts.Equal(http.StatusUnauthorized, resp.StatusCode)
+}
+
+func (lts *LiveTestSuite) TestConnect_Receives_Messages() {
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+lts.token)
+
+	conn, _, err := lts.dial(header, "")
+	lts.NoError(err, "Failed to dial")
+	defer conn.Close()
+
+	lts.waitForSessions(1)
+
+	lts.hub.Deliver(messageEvent(&models.Message{Sender: "User2", Recipient: "User1", Content: "Hi"}), []string{"User2", "User1"})
+	// Not for this user, must not be delivered
+	lts.hub.Deliver(messageEvent(&models.Message{Sender: "User2", Recipient: "User3", Content: "Psst"}), []string{"User2", "User3"})
+	lts.hub.Deliver(messageEvent(&models.Message{Sender: "User1", Recipient: "User2", Content: "Hey"}), []string{"User1", "User2"})
+
+	var event realtime.Event
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+
+	lts.NoError(conn.ReadJSON(&event))
+	lts.Equal(realtime.EVENT_MESSAGE, event.Type)
+	lts.Equal("Hi", event.Data.Content)
+
+	lts.NoError(conn.ReadJSON(&event))
+	lts.Equal("Hey", event.Data.Content)
+}
+
+func (lts *LiveTestSuite) TestConnect_Token_As_Query_Param() {
+	conn, _, err := lts.dial(nil, "?"+middlewares.TOKEN_QUERY_PARAM+"="+lts.token)
+	lts.NoError(err, "Failed to dial")
+
+	lts.waitForSessions(1)
+
+	conn.Close()
+
+	lts.waitForSessions(0)
+}
+
+func (lts *LiveTestSuite) TestConnect_Closed_On_Shutdown() {
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+lts.token)
+
+	conn, _, err := lts.dial(header, "")
+	lts.NoError(err, "Failed to dial")
+	defer conn.Close()
+
+	lts.waitForSessions(1)
+
+	lts.hub.Close()
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, _, err = conn.ReadMessage()
+	lts.True(websocket.IsCloseError(err, websocket.CloseGoingAway), "Expected a going away close frame, got %v", err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	lts.NoError(lts.hub.Wait(ctx))
+
+	// Sessions opened meanwhile end right away
+	conn, _, err = lts.dial(header, "")
+	lts.NoError(err, "Failed to dial")
+	defer conn.Close()
+
+	_, _, err = conn.ReadMessage()
+	lts.True(websocket.IsCloseError(err, websocket.CloseGoingAway), "Expected a going away close frame, got %v", err)
+}
+
+// openStream opens an SSE stream and returns a reader over its events data lines
+func (lts *LiveTestSuite) openStream(ctx context.Context, lastEventID string) (*http.Response, *bufio.Scanner) {
+	req, err := http.NewRequestWithContext(ctx, "GET", lts.server.URL, nil)
+	lts.NoError(err, "Failed to make request")
+
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("Authorization", "Bearer "+lts.token)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	lts.NoError(err, "Failed to open stream")
+
+	return resp, bufio.NewScanner(resp.Body)
+}
+
+func (lts *LiveTestSuite) nextEventData(scanner *bufio.Scanner) *models.Message {
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+
+		var msg models.Message
+		lts.NoError(json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &msg))
+		return &msg
+	}
+
+	lts.FailNow("stream ended unexpectedly")
+	return nil
+}
+
+func messageEvent(msg *models.Message) *realtime.Event {
+	return &realtime.Event{Type: realtime.EVENT_MESSAGE, Data: msg}
+}
+
+func (lts *LiveTestSuite) TestStream_Invalid_Last_Event_ID() {
+	resp, _ := lts.openStream(context.Background(), "not-a-uuid")
+	defer resp.Body.Close()
+
+	lts.Equal(http.StatusBadRequest, resp.StatusCode)
+}
+
+func (lts *LiveTestSuite) TestStream_Receives_Messages() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	resp, scanner := lts.openStream(ctx, "")
+	defer resp.Body.Close()
+
+	lts.Equal(http.StatusOK, resp.StatusCode)
+	lts.Equal("text/event-stream", resp.Header.Get("Content-Type"))
+
+	lts.waitForSessions(1)
+
+	lts.hub.Deliver(messageEvent(&models.Message{ID: gocql.TimeUUID(), Sender: "User2", Recipient: "User1", Content: "Hi"}), []string{"User1"})
+
+	lts.Equal("Hi", lts.nextEventData(scanner).Content)
+}
+
+func (lts *LiveTestSuite) TestStream_Replays_Missed_Messages() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	now := time.Now()
+	seen := models.Message{ID: gocql.UUIDFromTime(now.Add(-time.Minute)), Sender: "User2", Recipient: "User1", Content: "seen"}
+	missed1 := models.Message{ID: gocql.UUIDFromTime(now.Add(-time.Second * 2)), Sender: "User2", Recipient: "User1", Content: "missed1"}
+	missed2 := models.Message{ID: gocql.UUIDFromTime(now.Add(-time.Second)), Sender: "User1", Recipient: "User2", Content: "missed2"}
+
+	// Sorted DESC just like the history
+	missed := []models.Message{missed2, missed1}
+	lts.msgService.On("GetMessages", mock.Anything, "User1", mock.MatchedBy(func(page models.MessagesPage) bool {
+		return page.After != nil && page.After.ID == seen.ID
+	})).Return(missed, nil).Once()
+
+	resp, scanner := lts.openStream(ctx, seen.ID.String())
+	defer resp.Body.Close()
+
+	lts.Equal(http.StatusOK, resp.StatusCode)
+
+	lts.Equal("missed1", lts.nextEventData(scanner).Content)
+	lts.Equal("missed2", lts.nextEventData(scanner).Content)
+
+	// A live delivery of an already replayed message is not sent twice
+	lts.hub.Deliver(messageEvent(&missed2), []string{"User1"})
+	lts.hub.Deliver(messageEvent(&models.Message{ID: gocql.TimeUUID(), Sender: "User2", Recipient: "User1", Content: "live"}), []string{"User1"})
+
+	lts.Equal("live", lts.nextEventData(scanner).Content)
+}
+
+// eventTypes reads the types of the events of the stream up to the end of the given one
+func (lts *LiveTestSuite) eventTypes(scanner *bufio.Scanner, until string) []string {
+	var types []string
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" && len(types) > 0 && types[len(types)-1] == until {
+			return types
+		}
+		if eventType, ok := strings.CutPrefix(line, "event: "); ok {
+			types = append(types, eventType)
+		}
+	}
+
+	lts.FailNow("stream ended unexpectedly")
+	return nil
+}
+
+func (lts *LiveTestSuite) TestStream_Replay_Capped() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	page := make([]models.Message, models.MAX_PAGE_SIZE)
+	for i := range page {
+		page[i] = models.Message{ID: gocql.TimeUUID(), Sender: "User2", Recipient: "User1", Content: "missed"}
+	}
+	lts.msgService.On("GetMessages", mock.Anything, "User1", mock.Anything).Return(page, nil)
+
+	resp, scanner := lts.openStream(ctx, gocql.TimeUUID().String())
+	defer resp.Body.Close()
+
+	types := lts.eventTypes(scanner, realtime.EVENT_RESYNC)
+
+	lts.Len(types, MAX_REPLAYED_PAGES*models.MAX_PAGE_SIZE+1)
+	lts.msgService.AssertNumberOfCalls(lts.T(), "GetMessages", MAX_REPLAYED_PAGES)
+}
+
+func (lts *LiveTestSuite) TestStream_Resync_On_Replay_Failure() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	lts.msgService.On("GetMessages", mock.Anything, "User1", mock.Anything).Return(nil, errors.New("unavailable"))
+
+	resp, scanner := lts.openStream(ctx, gocql.TimeUUID().String())
+	defer resp.Body.Close()
+
+	lts.Equal(http.StatusOK, resp.StatusCode)
+	lts.Equal([]string{realtime.EVENT_RESYNC}, lts.eventTypes(scanner, realtime.EVENT_RESYNC))
+
+	// Live events keep coming nonetheless
+	lts.waitForSessions(1)
+	lts.hub.Deliver(messageEvent(&models.Message{ID: gocql.TimeUUID(), Sender: "User2", Recipient: "User1", Content: "live"}), []string{"User1"})
+
+	lts.Equal("live", lts.nextEventData(scanner).Content)
+}
+
+func (lts *LiveTestSuite) TestStream_Edited_Message_Has_No_Event_ID() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	resp, scanner := lts.openStream(ctx, "")
+	defer resp.Body.Close()
+
+	lts.waitForSessions(1)
+
+	edited := &models.Message{ID: gocql.TimeUUID(), Sender: "User2", Recipient: "User1", Content: "Hi!"}
+	lts.hub.Deliver(&realtime.Event{Type: realtime.EVENT_MESSAGE_EDITED, Data: edited}, []string{"User1"})
+
+	var lines []string
+	for scanner.Scan() && scanner.Text() != "" {
+		lines = append(lines, scanner.Text())
+	}
+
+	lts.Equal("event: "+realtime.EVENT_MESSAGE_EDITED, lines[0])
+	lts.Contains(lines[1], `"content":"Hi!"`)
+}
+
+func (lts *LiveTestSuite) TestStream_Ends_On_Shutdown() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	resp, scanner := lts.openStream(ctx, "")
+	defer resp.Body.Close()
+
+	lts.waitForSessions(1)
+
+	lts.hub.Close()
+
+	for scanner.Scan() {
+	}
+	lts.NoError(scanner.Err(), "Stream must end rather than time out")
+	lts.NoError(lts.hub.Wait(ctx))
+}
@@ -1,17 +1,30 @@
 package handlers
 
 import (
-	"chat-system/internal/api/cache"
 	common "chat-system/internal/api/common/constants"
 	"chat-system/internal/api/common/utils"
 	"chat-system/internal/api/middlewares"
+	"chat-system/internal/api/realtime"
 	"chat-system/internal/api/validators"
+	"chat-system/internal/logging"
+	"chat-system/internal/metrics"
 	"chat-system/internal/models"
 	"chat-system/internal/services"
+	"context"
 	"encoding/json"
 	"errors"
-	"log"
 	"net/http"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/gorilla/mux"
+	"golang.org/x/sync/singleflight"
+)
+
+// Who a message gets deleted for
+const (
+	DELETE_SCOPE_ME       = "me"
+	DELETE_SCOPE_EVERYONE = "everyone"
 )
 
 type MessageHandler interface {
@@ -19,20 +32,44 @@ type MessageHandler interface {
 	GetMessage(w http.ResponseWriter, r *http.Request)
 }
 
+// A replica missing the cached messages of a user while another one rebuilds them
+// polls the cache this often, up to this many times
+const (
+	CACHE_REBUILD_POLL_INTERVAL = 50 * time.Millisecond
+	CACHE_REBUILD_POLL_ATTEMPTS = 10
+)
+
+// Concurrent requests missing the cached messages of the same user share a single rebuild
+var cacheRebuilds singleflight.Group
+
 type MsgHandler interface {
 	SendMessage(w http.ResponseWriter, r *http.Request)
 	GetMessages(w http.ResponseWriter, r *http.Request)
+	EditMessage(w http.ResponseWriter, r *http.Request)
+	DeleteMessage(w http.ResponseWriter, r *http.Request)
 }
 
 type msgHandler struct {
-	service     services.MessageService
-	userService services.UserService
+	service             services.MessageService
+	userService         services.UserService
+	groupService        services.GroupService
+	conversationService services.ConversationService
+	broker              realtime.Broker
 }
 
-func NewMsgHandler(msgService services.MessageService, userService services.UserService) *msgHandler {
+func NewMsgHandler(
+	msgService services.MessageService,
+	userService services.UserService,
+	groupService services.GroupService,
+	conversationService services.ConversationService,
+	broker realtime.Broker,
+) *msgHandler {
 	return &msgHandler{
-		service:     msgService,
-		userService: userService,
+		service:             msgService,
+		userService:         userService,
+		groupService:        groupService,
+		conversationService: conversationService,
+		broker:              broker,
 	}
 }
 
@@ -46,14 +83,6 @@ func (mh *msgHandler) SendMessage(w http.ResponseWriter, r *http.Request) {
 		panic(middlewares.NewHTTPError(http.StatusBadRequest, errors.New(common.BAD_REQUEST)))
 	}
 
-	exists, err := mh.userService.UserExists(input.Recipient)
-	if err != nil {
-		panic(err)
-	}
-	if !exists {
-		panic(middlewares.NewHTTPError(http.StatusBadRequest, errors.New(common.SEND_MESSAGE_NO_RECIPIENT)))
-	}
-
 	userClaims := middlewares.GetUserFromContext(r.Context())
 
 	msg := &models.Message{
@@ -61,23 +90,32 @@ func (mh *msgHandler) SendMessage(w http.ResponseWriter, r *http.Request) {
 		Recipient: input.Recipient,
 		Content:   input.Content,
 	}
-	if err := mh.service.CreateMessage(msg); err != nil {
-		panic(err)
-	}
 
-	cacheKeySuffix := cache.CACHE_KEY_SUFFIX
+	var audience []string
+	if input.GroupID != "" {
+		audience = mh.createGroupMessage(r.Context(), input.GroupID, msg)
+		metrics.MessagesSent.WithLabelValues(metrics.MESSAGE_GROUP).Inc()
+	} else {
+		audience = mh.createDirectMessage(r.Context(), msg)
+		metrics.MessagesSent.WithLabelValues(metrics.MESSAGE_DIRECT).Inc()
+	}
 
-	senderCacheKey := userClaims.Username + cacheKeySuffix
+	// The message is stored already, so the cache & live sessions get updated even if the client went away meanwhile
+	ctx := context.WithoutCancel(r.Context())
 
-	if err := mh.service.UpdateCachedMsgsForUser(senderCacheKey, msg); err != nil {
-		log.Printf("Failed to cache new message for sender %v with error: %v", msg, err)
+	for _, username := range audience {
+		if err := mh.service.CacheMessage(ctx, username, msg); err != nil {
+			logging.FromContext(ctx).Error("Failed to cache new message", "user", username, "message_id", msg.ID, "error", err)
+		}
 	}
 
-	recipientCacheKey := msg.Recipient + cacheKeySuffix
+	// Push to live sessions of the audience on whatever replica they are connected to
+	if err := mh.broker.Publish(ctx, realtime.EVENT_MESSAGE, msg, audience); err != nil {
+		logging.FromContext(ctx).Error("Failed to publish new message", "message_id", msg.ID, "error", err)
+	}
 
-	// Let's do the same for recipient
-	if err := mh.service.UpdateCachedMsgsForUser(recipientCacheKey, msg); err != nil {
-		log.Printf("Failed to cache new message for recipient %v with error: %v", msg, err)
+	if msg.GroupID == nil {
+		msg.Status = models.MESSAGE_STATUS_DELIVERED
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -85,70 +123,350 @@ func (mh *msgHandler) SendMessage(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(msg)
 }
 
-// GetMessages retrieves all messages for the authenticated user
-func (mh *msgHandler) GetMessages(w http.ResponseWriter, r *http.Request) {
-	var (
-		messages       []models.Message
-		err            error
-		page, pageSize int
-	)
+// createDirectMessage persists the message sent to a single recipient & returns both parties
+func (mh *msgHandler) createDirectMessage(ctx context.Context, msg *models.Message) []string {
+	exists, err := mh.userService.UserExists(ctx, msg.Recipient)
+	if err != nil {
+		panic(err)
+	}
+	if !exists {
+		panic(middlewares.NewHTTPError(http.StatusBadRequest, errors.New(common.SEND_MESSAGE_NO_RECIPIENT)))
+	}
+
+	if err := mh.service.CreateMessage(ctx, msg); err != nil {
+		panic(err)
+	}
+
+	if msg.Recipient != msg.Sender {
+		if err := mh.conversationService.IncrUnreadCount(context.WithoutCancel(ctx), msg.Recipient, msg.Sender); err != nil {
+			logging.FromContext(ctx).Error("Failed to count unread message", "user", msg.Recipient, "message_id", msg.ID, "error", err)
+		}
+	}
+
+	return directAudience(msg)
+}
+
+// createGroupMessage persists the message sent to a group the sender is a member of & returns all members
+func (mh *msgHandler) createGroupMessage(ctx context.Context, rawGroupID string, msg *models.Message) []string {
+	groupID, err := gocql.ParseUUID(rawGroupID)
+	if err != nil {
+		panic(middlewares.NewHTTPError(http.StatusBadRequest, errors.New(common.BAD_REQUEST)))
+	}
+
+	members, err := mh.groupService.GetMembers(ctx, groupID)
+	if err != nil {
+		panic(err)
+	}
+	if findGroupMember(members, msg.Sender) == nil {
+		panic(middlewares.NewHTTPError(http.StatusForbidden, errors.New(common.GROUP_NOT_MEMBER)))
+	}
+
+	audience := memberUsernames(members)
 
+	msg.GroupID = &groupID
+	if err := mh.groupService.CreateGroupMessage(ctx, msg, audience); err != nil {
+		panic(err)
+	}
+
+	return audience
+}
+
+// GetMessages retrieves a page of the messages sent to or by the authenticated user, newest first.
+// Pages within the most recent messages are served from cache, while older ones are read from DB within cursor bounds.
+func (mh *msgHandler) GetMessages(w http.ResponseWriter, r *http.Request) {
 	userClaims := middlewares.GetUserFromContext(r.Context())
-	username := userClaims.Username
-	page, pageSize = utils.GetPaginationParams(r)
+	page, pageSize := getPageParams(r)
 
-	cacheKey := username + cache.CACHE_KEY_SUFFIX
-	messages, err = mh.service.GetFromCache(cacheKey)
+	messages := fetchMessages(r.Context(), mh.service, userClaims.Username, page)
+
+	readMarks, err := mh.conversationService.GetReadMarks(r.Context(), userClaims.Username)
 	if err != nil {
-		log.Printf("Failed to fetch cached messages error: %v", err)
+		panic(err)
 	}
+	setReadStatus(messages, userClaims.Username, readMarks)
 
-	if messages == nil {
-		messages, err = mh.service.GetMessages(username)
+	res := paginateMessages(page, pageSize, messages)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(res)
+}
+
+// fetchMessages retrieves the page of messages of the user from cache, falling back to DB on a miss.
+// Missing the most recent messages rebuilds their cache along the way.
+func fetchMessages(ctx context.Context, service services.MessageService, username string, page models.MessagesPage) []models.Message {
+	messages, hit, err := service.GetCachedMessages(ctx, username, page)
+	if err != nil {
+		logging.FromContext(ctx).Error("Failed to fetch cached messages", "user", username, "error", err)
+	}
+	if hit {
+		return messages
+	}
+
+	if page.Before != nil || page.After != nil {
+		messages, err = service.GetMessages(ctx, username, page)
 		if err != nil {
 			panic(err)
 		}
+		return messages
+	}
 
-		err = mh.service.SetMessagesToCache(cacheKey, messages)
-		if err != nil {
-			log.Printf("Failed to cache messages error: %v", err)
+	// The rebuild is shared, so it goes on even if the request that started it goes away
+	recent, err, _ := cacheRebuilds.Do(username, func() (interface{}, error) {
+		return rebuildCachedMessages(context.WithoutCancel(ctx), service, username)
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	// The recent messages are shared by all the requests joining the rebuild, so each one copies its own page
+	messages = recent.([]models.Message)
+	if len(messages) > page.Limit {
+		messages = messages[:page.Limit]
+	}
+
+	return append([]models.Message(nil), messages...)
+}
+
+// rebuildCachedMessages reads the most recent messages of the user from DB & caches them.
+// A single replica rebuilds the cache of a user at once, while the others wait to read it from cache.
+func rebuildCachedMessages(ctx context.Context, service services.MessageService, username string) ([]models.Message, error) {
+	recentPage := models.MessagesPage{Limit: services.CACHED_MSGS_LIMIT}
+
+	token, acquired, err := service.AcquireCacheRebuild(ctx, username)
+	if err != nil {
+		logging.FromContext(ctx).Error("Failed to lease the cache rebuild", "user", username, "error", err)
+	}
+
+	if acquired {
+		defer func() {
+			if err := service.ReleaseCacheRebuild(ctx, username, token); err != nil {
+				logging.FromContext(ctx).Error("Failed to release the cache rebuild", "user", username, "error", err)
+			}
+		}()
+	} else if err == nil {
+		for i := 0; i < CACHE_REBUILD_POLL_ATTEMPTS; i++ {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(CACHE_REBUILD_POLL_INTERVAL):
+			}
+
+			messages, hit, err := service.GetCachedMessages(ctx, username, recentPage)
+			if err == nil && hit {
+				return messages, nil
+			}
 		}
+		// The other replica is taking too long, so read from DB anyway
 	}
 
-	res := paginateMessages(page, pageSize, messages)
+	// Read before the messages, so that they don't stay cached if they get invalidated meanwhile
+	version, err := service.CachedMessagesVersion(ctx, username)
+	if err != nil {
+		logging.FromContext(ctx).Error("Failed to read the version of the cached messages", "user", username, "error", err)
+	}
+
+	messages, err := service.GetMessages(ctx, username, recentPage)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fewer messages than the limit means that's the whole history, so empty histories get cached as well
+	err = service.CacheMessages(ctx, username, messages, len(messages) < services.CACHED_MSGS_LIMIT, version)
+	if err != nil {
+		logging.FromContext(ctx).Error("Failed to cache messages", "user", username, "error", err)
+	}
+
+	return messages, nil
+}
+
+// EditMessage updates the content of a message sent by the authenticated user, for everyone who has it
+func (mh *msgHandler) EditMessage(w http.ResponseWriter, r *http.Request) {
+	var input models.EditMessageInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		panic(middlewares.NewHTTPError(http.StatusBadRequest, errors.New(common.BAD_REQUEST)))
+	}
+
+	if err := validators.ValidateEditMessageInput(input); err != nil {
+		panic(middlewares.NewHTTPError(http.StatusBadRequest, errors.New(common.BAD_REQUEST)))
+	}
+
+	userClaims := middlewares.GetUserFromContext(r.Context())
+
+	msg := mh.messageFor(r, userClaims.Username)
+	if msg.Sender != userClaims.Username {
+		panic(middlewares.NewHTTPError(http.StatusForbidden, errors.New(common.MESSAGE_NOT_SENDER)))
+	}
+
+	msg.Content = input.Content
+	audience := mh.messageAudience(r.Context(), msg)
+
+	var err error
+	if msg.GroupID != nil {
+		err = mh.groupService.EditGroupMessage(r.Context(), msg, audience)
+	} else {
+		err = mh.service.EditMessage(r.Context(), msg)
+	}
+	if err != nil {
+		panic(err)
+	}
+
+	mh.invalidateCachedMsgs(r.Context(), audience)
+
+	if err := mh.broker.Publish(context.WithoutCancel(r.Context()), realtime.EVENT_MESSAGE_EDITED, msg, audience); err != nil {
+		logging.FromContext(r.Context()).Error("Failed to publish edited message", "message_id", msg.ID, "error", err)
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(res)
+	json.NewEncoder(w).Encode(msg)
 }
 
-// TODO:: When message update, delete cache must be invalidated
+// DeleteMessage deletes a message either for the authenticated user only (`?scope=me`, the default),
+// or for everyone who has it (`?scope=everyone`) which is restricted to the sender
+func (mh *msgHandler) DeleteMessage(w http.ResponseWriter, r *http.Request) {
+	scope := r.URL.Query().Get("scope")
+	if scope == "" {
+		scope = DELETE_SCOPE_ME
+	}
+	if scope != DELETE_SCOPE_ME && scope != DELETE_SCOPE_EVERYONE {
+		panic(middlewares.NewHTTPError(http.StatusBadRequest, errors.New(common.BAD_REQUEST)))
+	}
 
-// TODO:: Can be more generic to paginate any entity
-// paginateMessages paginates the messages
-func paginateMessages(page, pageSize int, messages []models.Message) map[string]interface{} {
-	itemsCount := len(messages)
+	userClaims := middlewares.GetUserFromContext(r.Context())
 
-	startIndex := (page - 1) * pageSize
+	msg := mh.messageFor(r, userClaims.Username)
 
-	if startIndex > itemsCount {
-		startIndex = itemsCount
+	var audience []string
+	if scope == DELETE_SCOPE_ME {
+		audience = []string{userClaims.Username}
+		if err := mh.service.DeleteMessageForUser(r.Context(), userClaims.Username, msg); err != nil {
+			panic(err)
+		}
+	} else {
+		if msg.Sender != userClaims.Username {
+			panic(middlewares.NewHTTPError(http.StatusForbidden, errors.New(common.MESSAGE_NOT_SENDER)))
+		}
+
+		audience = mh.messageAudience(r.Context(), msg)
+
+		var err error
+		if msg.GroupID != nil {
+			err = mh.groupService.DeleteGroupMessage(r.Context(), msg, audience)
+		} else {
+			err = mh.service.DeleteMessage(r.Context(), msg)
+		}
+		if err != nil {
+			panic(err)
+		}
 	}
 
-	endIndex := page * pageSize
-	if endIndex > itemsCount {
-		endIndex = itemsCount
+	mh.invalidateCachedMsgs(r.Context(), audience)
+
+	if err := mh.broker.Publish(context.WithoutCancel(r.Context()), realtime.EVENT_MESSAGE_DELETED, msg, audience); err != nil {
+		logging.FromContext(r.Context()).Error("Failed to publish deleted message", "message_id", msg.ID, "error", err)
 	}
 
-	paginatedMessages := messages[startIndex:endIndex]
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// messageFor retrieves the message of the `id` path param from the messages of the user
+func (mh *msgHandler) messageFor(r *http.Request, username string) *models.Message {
+	id, err := gocql.ParseUUID(mux.Vars(r)["id"])
+	if err != nil {
+		panic(middlewares.NewHTTPError(http.StatusBadRequest, errors.New(common.BAD_REQUEST)))
+	}
+
+	msg, err := mh.service.GetMessage(r.Context(), username, id)
+	if err != nil {
+		panic(err)
+	}
+	if msg == nil {
+		panic(middlewares.NewHTTPError(http.StatusNotFound, errors.New(common.MESSAGE_NOT_FOUND)))
+	}
+
+	return msg
+}
+
+// messageAudience returns the users having the message, i.e. both parties or the current members of the group
+func (mh *msgHandler) messageAudience(ctx context.Context, msg *models.Message) []string {
+	if msg.GroupID == nil {
+		return directAudience(msg)
+	}
+
+	members, err := mh.groupService.GetMembers(ctx, *msg.GroupID)
+	if err != nil {
+		panic(err)
+	}
+
+	return memberUsernames(members)
+}
+
+// invalidateCachedMsgs drops the cached messages of the users, rather than patching them in place, bumping their
+// version so that a concurrent rebuild which read the stale message beforehand can't bring it back.
+// The message is updated already, so the cache gets invalidated even if the client went away meanwhile.
+func (mh *msgHandler) invalidateCachedMsgs(ctx context.Context, usernames []string) {
+	ctx = context.WithoutCancel(ctx)
+	for _, username := range usernames {
+		if err := mh.service.InvalidateCachedMessages(ctx, username); err != nil {
+			logging.FromContext(ctx).Error("Failed to invalidate cached messages", "user", username, "error", err)
+		}
+	}
+}
+
+func directAudience(msg *models.Message) []string {
+	if msg.Sender == msg.Recipient {
+		return []string{msg.Sender}
+	}
+
+	return []string{msg.Sender, msg.Recipient}
+}
+
+func memberUsernames(members []models.GroupMember) []string {
+	usernames := make([]string, 0, len(members))
+	for _, member := range members {
+		usernames = append(usernames, member.Username)
+	}
+
+	return usernames
+}
+
+func getPageParams(r *http.Request) (models.MessagesPage, int) {
+	page, pageSize, err := utils.GetCursorParams(r)
+	if err != nil {
+		panic(middlewares.NewHTTPError(http.StatusBadRequest, errors.New(common.BAD_REQUEST)))
+	}
+
+	return page, pageSize
+}
+
+// paginateMessages trims the page of messages (sorted newest first) to the page size.
+// Fetching one extra message tells whether there's a next page to build the cursor for.
+func paginateMessages(page models.MessagesPage, pageSize int, messages []models.Message) map[string]interface{} {
+	var nextCursor *string
+
+	if len(messages) > pageSize {
+		var cursor string
+		if page.After != nil {
+			// Walking towards newer messages, the extra one is the newest
+			messages = messages[len(messages)-pageSize:]
+			cursor = utils.EncodeCursor(utils.CURSOR_AFTER, models.KeyOf(&messages[0]))
+		} else {
+			messages = messages[:pageSize]
+			cursor = utils.EncodeCursor(utils.CURSOR_BEFORE, models.KeyOf(&messages[pageSize-1]))
+		}
+		nextCursor = &cursor
+	}
+
+	if messages == nil {
+		messages = make([]models.Message, 0)
+	}
 
 	res := map[string]interface{}{
-		"messages": paginatedMessages,
+		"messages": messages,
 		"pagination": map[string]interface{}{
-			"currentPage":   page,
-			"pageSize":      pageSize,
-			"totalMessages": itemsCount,
-			"totalPages":    (itemsCount + pageSize - 1) / pageSize,
+			"pageSize":   pageSize,
+			"nextCursor": nextCursor,
 		},
 	}
 
@@ -2,20 +2,25 @@ package handlers
 
 import (
 	"bytes"
-	"chat-system/internal/api/auth"
 	common "chat-system/internal/api/common/constants"
 	"chat-system/internal/api/common/responses"
+	"chat-system/internal/api/common/utils"
 	"chat-system/internal/api/middlewares"
+	"chat-system/internal/api/realtime"
 	"chat-system/internal/models"
+	"chat-system/internal/services"
 	"chat-system/mocks"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
-	"os"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/gocql/gocql"
+	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
 )
@@ -24,6 +29,9 @@ type MessagesTestSuite struct {
 	suite.Suite
 	msgService         *mocks.MessageService
 	userService        *mocks.UserService
+	groupService       *mocks.GroupService
+	convService        *mocks.ConversationService
+	broker             *mocks.Broker
 	sendEndpointUrl    string
 	getMsgsEndpointUrl string
 	authHeader         string
@@ -37,23 +45,25 @@ func TestMessagesTestSuite(t *testing.T) {
 }
 
 func (mts *MessagesTestSuite) SetupSuite() {
-	// Set up environment variable
-	os.Setenv("AUTH_HEADER_PREFIX", "Bearer")
-	os.Setenv("JWT_SECRET_KEY", "secret")
-
+	tokens := newTestTokens()
 	mts.msgService = &mocks.MessageService{}
 	mts.userService = &mocks.UserService{}
+	mts.groupService = &mocks.GroupService{}
+	mts.convService = &mocks.ConversationService{}
+	mts.convService.On("GetReadMarks", mock.Anything, mock.Anything).Return(map[string]gocql.UUID{}, nil)
+	mts.convService.On("IncrUnreadCount", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	mts.broker = &mocks.Broker{}
 
 	reqSenderUsername := "User1"
-	token, err := auth.GenerateToken(reqSenderUsername)
+	token, err := tokens.GenerateToken(&models.User{ID: gocql.TimeUUID(), Username: reqSenderUsername})
 	mts.NoError(err, "Failed to create token")
 
 	mts.authHeader = fmt.Sprintf("Bearer %s", token)
 
-	mts.handler = NewMsgHandler(mts.msgService, mts.userService)
+	mts.handler = NewMsgHandler(mts.msgService, mts.userService, mts.groupService, mts.convService, mts.broker)
 
 	mts.middleware = func(handlerMethod func(w http.ResponseWriter, r *http.Request)) http.Handler {
-		return middlewares.IsAuth(
+		return middlewares.IsAuth(tokens, "Bearer")(
 			http.HandlerFunc(
 				middlewares.HandleErrors(
 					http.HandlerFunc(handlerMethod),
@@ -120,7 +130,7 @@ func (mts *MessagesTestSuite) Test_Send_Invalid_Input_NoContent() {
 
 func (mts *MessagesTestSuite) Test_Send_Err_Checking_Recipient() {
 	expectedErr := errors.New(common.INTERNAL_SERVER_ERROR)
-	mts.userService.On("UserExists", mock.Anything).Return(false, expectedErr).Once()
+	mts.userService.On("UserExists", mock.Anything, mock.Anything).Return(false, expectedErr).Once()
 
 	recipient := "x"
 	content := "Test"
@@ -148,7 +158,7 @@ func (mts *MessagesTestSuite) Test_Send_Err_Checking_Recipient() {
 }
 
 func (mts *MessagesTestSuite) Test_Send_Recipient_Not_Found() {
-	mts.userService.On("UserExists", mock.Anything).Return(false, nil).Once()
+	mts.userService.On("UserExists", mock.Anything, mock.Anything).Return(false, nil).Once()
 
 	recipient := "x"
 	content := "Test"
@@ -176,10 +186,10 @@ func (mts *MessagesTestSuite) Test_Send_Recipient_Not_Found() {
 }
 
 func (mts *MessagesTestSuite) Test_Send_DB_Err() {
-	mts.userService.On("UserExists", mock.Anything).Return(true, nil).Once()
+	mts.userService.On("UserExists", mock.Anything, mock.Anything).Return(true, nil).Once()
 
 	expectedErr := errors.New(common.INTERNAL_SERVER_ERROR)
-	mts.msgService.On("CreateMessage", mock.Anything).Return(expectedErr).Once()
+	mts.msgService.On("CreateMessage", mock.Anything, mock.Anything).Return(expectedErr).Once()
 
 	recipient := "x"
 	content := "Test"
@@ -219,9 +229,10 @@ func (mts *MessagesTestSuite) Test_Send_Success() {
 
 	req.Header.Set("Authorization", mts.authHeader)
 
-	mts.userService.On("UserExists", mock.Anything).Return(true, nil).Once()
-	mts.msgService.On("CreateMessage", mock.Anything).Return(nil).Once()
-	mts.msgService.On("UpdateCachedMsgsForUser", mock.Anything, mock.Anything).Return(nil).Twice()
+	mts.userService.On("UserExists", mock.Anything, mock.Anything).Return(true, nil).Once()
+	mts.msgService.On("CreateMessage", mock.Anything, mock.Anything).Return(nil).Once()
+	mts.msgService.On("CacheMessage", mock.Anything, mock.Anything, mock.Anything).Return(nil).Twice()
+	mts.broker.On("Publish", mock.Anything, realtime.EVENT_MESSAGE, mock.Anything, mock.Anything).Return(nil).Once()
 
 	rr := httptest.NewRecorder()
 
@@ -238,6 +249,35 @@ func (mts *MessagesTestSuite) Test_Send_Success() {
 	mts.Equal(msgResponse.Recipient, recipient)
 	mts.Equal(msgResponse.Content, content)
 	mts.Equal(msgResponse.Sender, "User1")
+	mts.Equal(models.MESSAGE_STATUS_DELIVERED, msgResponse.Status)
+	mts.convService.AssertCalled(mts.T(), "IncrUnreadCount", mock.Anything, "User2", "User1")
+	mts.broker.AssertCalled(mts.T(), "Publish", mock.Anything, realtime.EVENT_MESSAGE, mock.Anything, []string{"User1", "User2"})
+}
+
+func (mts *MessagesTestSuite) Test_Send_Publish_Err_Still_Succeeds() {
+	recipient := "User2"
+	content := "Test Content"
+	reqBody := &models.SendMessageInput{Recipient: recipient, Content: content}
+	body, err := json.Marshal(reqBody)
+	mts.NoError(err, "Failed to marshal registerInput")
+
+	req, err := http.NewRequest("POST", mts.sendEndpointUrl, bytes.NewBuffer(body))
+	mts.NoError(err, "Failed to make POST request")
+
+	req.Header.Set("Authorization", mts.authHeader)
+
+	mts.userService.On("UserExists", mock.Anything, mock.Anything).Return(true, nil).Once()
+	mts.msgService.On("CreateMessage", mock.Anything, mock.Anything).Return(nil).Once()
+	mts.msgService.On("CacheMessage", mock.Anything, mock.Anything, mock.Anything).Return(nil).Twice()
+	mts.broker.On("Publish", mock.Anything, realtime.EVENT_MESSAGE, mock.Anything, mock.Anything).Return(errors.New("redis is down")).Once()
+
+	rr := httptest.NewRecorder()
+
+	mts.middleware(mts.handler.SendMessage).ServeHTTP(rr, req)
+
+	resp := rr.Result()
+
+	mts.Equal(http.StatusCreated, resp.StatusCode)
 }
 
 func (mts *MessagesTestSuite) Test_GetMessages_Success() {
@@ -246,14 +286,18 @@ func (mts *MessagesTestSuite) Test_GetMessages_Success() {
 
 	req.Header.Set("Authorization", mts.authHeader)
 
-	mts.msgService.On("GetFromCache", mock.Anything).Return(nil, nil).Once()
+	mts.msgService.On("GetCachedMessages", mock.Anything, "User1", mock.Anything).Return(nil, false, nil).Once()
+	mts.msgService.On("AcquireCacheRebuild", mock.Anything, "User1").Return("token", true, nil).Once()
+	mts.msgService.On("ReleaseCacheRebuild", mock.Anything, "User1", "token").Return(nil).Once()
+	mts.msgService.On("CachedMessagesVersion", mock.Anything, "User1").Return(int64(7), nil).Once()
 
 	expectedMsg := models.Message{Sender: "Mickey", Recipient: "Minnie", Content: "Hi"}
 	msgsArr := make([]models.Message, 0)
 	msgsArr = append(msgsArr, expectedMsg)
-	mts.msgService.On("GetMessages", mock.Anything).Return(msgsArr, nil).Once()
+	mts.msgService.On("GetMessages", mock.Anything, mock.Anything, mock.Anything).Return(msgsArr, nil).Once()
 
-	mts.msgService.On("SetMessagesToCache", mock.Anything, mock.Anything).Return(nil).Once()
+	// Fewer messages than the limit, so that's the whole history
+	mts.msgService.On("CacheMessages", mock.Anything, "User1", msgsArr, true, int64(7)).Return(nil).Once()
 
 	rr := httptest.NewRecorder()
 
@@ -279,12 +323,13 @@ func (mts *MessagesTestSuite) Test_GetMessages_Error() {
 
 	req.Header.Set("Authorization", mts.authHeader)
 
-	mts.msgService.On("GetFromCache", mock.Anything).Return(nil, nil).Once()
+	mts.msgService.On("GetCachedMessages", mock.Anything, "User1", mock.Anything).Return(nil, false, nil).Once()
+	mts.msgService.On("AcquireCacheRebuild", mock.Anything, "User1").Return("token", true, nil).Once()
+	mts.msgService.On("ReleaseCacheRebuild", mock.Anything, "User1", "token").Return(nil).Once()
+	mts.msgService.On("CachedMessagesVersion", mock.Anything, "User1").Return(int64(7), nil).Once()
 
 	expectedErr := errors.New("DB is Down :(")
-	mts.msgService.On("GetMessages", mock.Anything).Return(nil, expectedErr).Once()
-
-	mts.msgService.On("SetMessagesToCache", mock.Anything, mock.Anything).Return(nil).Once()
+	mts.msgService.On("GetMessages", mock.Anything, mock.Anything, mock.Anything).Return(nil, expectedErr).Once()
 
 	rr := httptest.NewRecorder()
 
@@ -300,3 +345,487 @@ func (mts *MessagesTestSuite) Test_GetMessages_Error() {
 
 	mts.Equal(errResponse.Error, common.INTERNAL_SERVER_ERROR)
 }
+
+func (mts *MessagesTestSuite) Test_GetMessages_Empty_History_Cached() {
+	req, err := http.NewRequest("GET", mts.getMsgsEndpointUrl, nil)
+	mts.NoError(err, "Failed to make request")
+
+	req.Header.Set("Authorization", mts.authHeader)
+
+	mts.msgService.On("GetCachedMessages", mock.Anything, "User1", mock.Anything).Return(nil, false, nil).Once()
+	mts.msgService.On("AcquireCacheRebuild", mock.Anything, "User1").Return("token", true, nil).Once()
+	mts.msgService.On("ReleaseCacheRebuild", mock.Anything, "User1", "token").Return(nil).Once()
+	mts.msgService.On("CachedMessagesVersion", mock.Anything, "User1").Return(int64(7), nil).Once()
+	mts.msgService.On("GetMessages", mock.Anything, "User1", mock.Anything).Return([]models.Message{}, nil).Once()
+	mts.msgService.On("CacheMessages", mock.Anything, "User1", []models.Message{}, true, int64(7)).Return(nil).Once()
+
+	rr := httptest.NewRecorder()
+
+	mts.middleware(mts.handler.GetMessages).ServeHTTP(rr, req)
+
+	mts.Equal(http.StatusOK, rr.Result().StatusCode)
+	mts.msgService.AssertCalled(mts.T(), "CacheMessages", mock.Anything, "User1", []models.Message{}, true, int64(7))
+}
+
+func (mts *MessagesTestSuite) Test_GetMessages_Waits_For_Other_Replica_Rebuild() {
+	req, err := http.NewRequest("GET", mts.getMsgsEndpointUrl+"?pageSize=1", nil)
+	mts.NoError(err, "Failed to make request")
+
+	req.Header.Set("Authorization", mts.authHeader)
+
+	cached := []models.Message{{ID: gocql.TimeUUID(), Content: "2"}, {ID: gocql.TimeUUID(), Content: "1"}}
+	mts.msgService.On("GetCachedMessages", mock.Anything, "User1", mock.MatchedBy(func(page models.MessagesPage) bool {
+		return page.Limit == 2
+	})).Return(nil, false, nil).Once()
+	mts.msgService.On("AcquireCacheRebuild", mock.Anything, "User1").Return("", false, nil).Once()
+	// Rebuilt by the other replica meanwhile
+	mts.msgService.On("GetCachedMessages", mock.Anything, "User1", mock.MatchedBy(func(page models.MessagesPage) bool {
+		return page.Limit == services.CACHED_MSGS_LIMIT
+	})).Return(cached, true, nil).Once()
+
+	rr := httptest.NewRecorder()
+
+	mts.middleware(mts.handler.GetMessages).ServeHTTP(rr, req)
+
+	resp := rr.Result()
+
+	mts.Equal(http.StatusOK, resp.StatusCode)
+
+	var msgsResponse responses.MessagesResponse
+	err = json.NewDecoder(resp.Body).Decode(&msgsResponse)
+	mts.NoError(err, "Failed to decode response body")
+
+	mts.Len(msgsResponse.Messages, 1)
+	mts.Equal("2", msgsResponse.Messages[0].Content)
+	mts.NotNil(msgsResponse.Pagination.NextCursor)
+}
+
+func (mts *MessagesTestSuite) Test_GetMessages_Concurrent_Misses_Share_Rebuild() {
+	const requests = 5
+
+	var missed sync.WaitGroup
+	missed.Add(requests)
+	mts.msgService.On("GetCachedMessages", mock.Anything, "User1", mock.Anything).Return(nil, false, nil).Times(requests).
+		Run(func(mock.Arguments) { missed.Done() })
+	mts.msgService.On("AcquireCacheRebuild", mock.Anything, "User1").Return("token", true, nil).Once()
+	mts.msgService.On("ReleaseCacheRebuild", mock.Anything, "User1", "token").Return(nil).Once()
+	mts.msgService.On("CachedMessagesVersion", mock.Anything, "User1").Return(int64(7), nil).Once()
+
+	// Hold the DB read until all requests missed the cache
+	release := make(chan time.Time)
+	recent := []models.Message{{ID: gocql.TimeUUID(), Content: "Hi"}}
+	mts.msgService.On("GetMessages", mock.Anything, "User1", mock.Anything).Return(recent, nil).Once().WaitUntil(release)
+	mts.msgService.On("CacheMessages", mock.Anything, "User1", recent, true, int64(7)).Return(nil).Once()
+
+	var done sync.WaitGroup
+	statuses := make(chan int, requests)
+	for i := 0; i < requests; i++ {
+		done.Add(1)
+		go func() {
+			defer done.Done()
+
+			req, err := http.NewRequest("GET", mts.getMsgsEndpointUrl, nil)
+			mts.NoError(err, "Failed to make request")
+			req.Header.Set("Authorization", mts.authHeader)
+
+			rr := httptest.NewRecorder()
+			mts.middleware(mts.handler.GetMessages).ServeHTTP(rr, req)
+			statuses <- rr.Result().StatusCode
+		}()
+	}
+
+	missed.Wait()
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	done.Wait()
+	close(statuses)
+
+	for status := range statuses {
+		mts.Equal(http.StatusOK, status)
+	}
+}
+
+func (mts *MessagesTestSuite) Test_Send_Invalid_Input_Recipient_And_Group() {
+	reqBody := &models.SendMessageInput{Recipient: "User2", GroupID: gocql.TimeUUID().String(), Content: "Test"}
+	body, err := json.Marshal(reqBody)
+	mts.NoError(err, "Failed to marshal sendMessageInput")
+
+	req, err := http.NewRequest("POST", mts.sendEndpointUrl, bytes.NewBuffer(body))
+	mts.NoError(err, "Failed to make POST request")
+
+	req.Header.Set("Authorization", mts.authHeader)
+
+	rr := httptest.NewRecorder()
+
+	mts.middleware(mts.handler.SendMessage).ServeHTTP(rr, req)
+
+	mts.Equal(http.StatusBadRequest, rr.Result().StatusCode)
+}
+
+func (mts *MessagesTestSuite) Test_Send_Group_Not_Member() {
+	groupID := gocql.TimeUUID()
+	members := []models.GroupMember{{GroupID: groupID, Username: "User2", Role: models.GROUP_ROLE_OWNER}}
+	mts.groupService.On("GetMembers", mock.Anything, groupID).Return(members, nil).Once()
+
+	reqBody := &models.SendMessageInput{GroupID: groupID.String(), Content: "Test"}
+	body, err := json.Marshal(reqBody)
+	mts.NoError(err, "Failed to marshal sendMessageInput")
+
+	req, err := http.NewRequest("POST", mts.sendEndpointUrl, bytes.NewBuffer(body))
+	mts.NoError(err, "Failed to make POST request")
+
+	req.Header.Set("Authorization", mts.authHeader)
+
+	rr := httptest.NewRecorder()
+
+	mts.middleware(mts.handler.SendMessage).ServeHTTP(rr, req)
+
+	resp := rr.Result()
+
+	mts.Equal(http.StatusForbidden, resp.StatusCode)
+
+	err = json.NewDecoder(resp.Body).Decode(&mts.errResponse)
+	mts.NoError(err, "Failed to decode response body")
+
+	mts.Equal(common.GROUP_NOT_MEMBER, mts.errResponse.Error)
+}
+
+func (mts *MessagesTestSuite) Test_Send_Group_Success() {
+	groupID := gocql.TimeUUID()
+	members := []models.GroupMember{
+		{GroupID: groupID, Username: "User1", Role: models.GROUP_ROLE_MEMBER},
+		{GroupID: groupID, Username: "User2", Role: models.GROUP_ROLE_OWNER},
+		{GroupID: groupID, Username: "User3", Role: models.GROUP_ROLE_ADMIN},
+	}
+	audience := []string{"User1", "User2", "User3"}
+
+	mts.groupService.On("GetMembers", mock.Anything, groupID).Return(members, nil).Once()
+	mts.groupService.On("CreateGroupMessage", mock.Anything, mock.Anything, audience).Return(nil).Once()
+	mts.msgService.On("CacheMessage", mock.Anything, mock.Anything, mock.Anything).Return(nil).Times(3)
+	mts.broker.On("Publish", mock.Anything, realtime.EVENT_MESSAGE, mock.Anything, audience).Return(nil).Once()
+
+	reqBody := &models.SendMessageInput{GroupID: groupID.String(), Content: "Hi all"}
+	body, err := json.Marshal(reqBody)
+	mts.NoError(err, "Failed to marshal sendMessageInput")
+
+	req, err := http.NewRequest("POST", mts.sendEndpointUrl, bytes.NewBuffer(body))
+	mts.NoError(err, "Failed to make POST request")
+
+	req.Header.Set("Authorization", mts.authHeader)
+
+	rr := httptest.NewRecorder()
+
+	mts.middleware(mts.handler.SendMessage).ServeHTTP(rr, req)
+
+	resp := rr.Result()
+
+	mts.Equal(http.StatusCreated, resp.StatusCode)
+
+	var msg models.Message
+	err = json.NewDecoder(resp.Body).Decode(&msg)
+	mts.NoError(err, "Failed to decode response body")
+
+	mts.Equal("User1", msg.Sender)
+	mts.Equal("Hi all", msg.Content)
+	mts.Equal(groupID, *msg.GroupID)
+	mts.msgService.AssertCalled(mts.T(), "CacheMessage", mock.Anything, "User3", mock.Anything)
+}
+
+func (mts *MessagesTestSuite) Test_GetMessages_First_Page_From_Cache() {
+	req, err := http.NewRequest("GET", mts.getMsgsEndpointUrl+"?pageSize=2", nil)
+	mts.NoError(err, "Failed to make request")
+
+	req.Header.Set("Authorization", mts.authHeader)
+
+	cached := []models.Message{
+		{ID: gocql.TimeUUID(), Content: "3"},
+		{ID: gocql.TimeUUID(), Content: "2"},
+		{ID: gocql.TimeUUID(), Content: "1"},
+	}
+	mts.msgService.On("GetCachedMessages", mock.Anything, "User1", mock.MatchedBy(func(page models.MessagesPage) bool {
+		return page.Before == nil && page.After == nil && page.Limit == 3
+	})).Return(cached, true, nil).Once()
+
+	rr := httptest.NewRecorder()
+
+	mts.middleware(mts.handler.GetMessages).ServeHTTP(rr, req)
+
+	resp := rr.Result()
+
+	mts.Equal(http.StatusOK, resp.StatusCode)
+
+	var msgsResponse responses.MessagesResponse
+	err = json.NewDecoder(resp.Body).Decode(&msgsResponse)
+	mts.NoError(err, "Failed to decode response body")
+
+	mts.Len(msgsResponse.Messages, 2)
+	mts.Equal("3", msgsResponse.Messages[0].Content)
+	mts.Equal(2, msgsResponse.Pagination.PageSize)
+	mts.NotNil(msgsResponse.Pagination.NextCursor)
+
+	direction, key, err := utils.DecodeCursor(*msgsResponse.Pagination.NextCursor)
+	mts.NoError(err)
+	mts.Equal(utils.CURSOR_BEFORE, direction)
+	mts.Equal(cached[1].ID, key.ID)
+}
+
+func (mts *MessagesTestSuite) Test_GetMessages_Next_Page_By_Cursor() {
+	bound := models.KeyFromID(gocql.TimeUUID())
+	cursor := utils.EncodeCursor(utils.CURSOR_BEFORE, bound)
+
+	req, err := http.NewRequest("GET", mts.getMsgsEndpointUrl+"?pageSize=2&cursor="+cursor, nil)
+	mts.NoError(err, "Failed to make request")
+
+	req.Header.Set("Authorization", mts.authHeader)
+
+	mts.msgService.On("GetCachedMessages", mock.Anything, "User1", mock.Anything).Return(nil, false, nil).Once()
+	older := []models.Message{{ID: gocql.TimeUUID(), Content: "older"}}
+	mts.msgService.On("GetMessages", mock.Anything, "User1", mock.MatchedBy(func(page models.MessagesPage) bool {
+		return page.Before != nil && page.Before.ID == bound.ID && page.Limit == 3
+	})).Return(older, nil).Once()
+
+	rr := httptest.NewRecorder()
+
+	mts.middleware(mts.handler.GetMessages).ServeHTTP(rr, req)
+
+	resp := rr.Result()
+
+	mts.Equal(http.StatusOK, resp.StatusCode)
+
+	var msgsResponse responses.MessagesResponse
+	err = json.NewDecoder(resp.Body).Decode(&msgsResponse)
+	mts.NoError(err, "Failed to decode response body")
+
+	mts.Len(msgsResponse.Messages, 1)
+	mts.Equal("older", msgsResponse.Messages[0].Content)
+	// Last page
+	mts.Nil(msgsResponse.Pagination.NextCursor)
+}
+
+func (mts *MessagesTestSuite) Test_GetMessages_Next_Page_From_Cache() {
+	bound := models.KeyFromID(gocql.TimeUUID())
+	cursor := utils.EncodeCursor(utils.CURSOR_BEFORE, bound)
+
+	req, err := http.NewRequest("GET", mts.getMsgsEndpointUrl+"?pageSize=1&cursor="+cursor, nil)
+	mts.NoError(err, "Failed to make request")
+
+	req.Header.Set("Authorization", mts.authHeader)
+
+	cached := []models.Message{{ID: gocql.TimeUUID(), Content: "older"}}
+	mts.msgService.On("GetCachedMessages", mock.Anything, "User1", mock.MatchedBy(func(page models.MessagesPage) bool {
+		return page.Before != nil && page.Before.ID == bound.ID && page.Limit == 2
+	})).Return(cached, true, nil).Once()
+
+	rr := httptest.NewRecorder()
+
+	mts.middleware(mts.handler.GetMessages).ServeHTTP(rr, req)
+
+	resp := rr.Result()
+
+	mts.Equal(http.StatusOK, resp.StatusCode)
+
+	var msgsResponse responses.MessagesResponse
+	err = json.NewDecoder(resp.Body).Decode(&msgsResponse)
+	mts.NoError(err, "Failed to decode response body")
+
+	mts.Len(msgsResponse.Messages, 1)
+	mts.Equal("older", msgsResponse.Messages[0].Content)
+}
+
+func (mts *MessagesTestSuite) Test_GetMessages_After() {
+	after := gocql.TimeUUID()
+
+	req, err := http.NewRequest("GET", mts.getMsgsEndpointUrl+"?pageSize=1&after="+after.String(), nil)
+	mts.NoError(err, "Failed to make request")
+
+	req.Header.Set("Authorization", mts.authHeader)
+
+	mts.msgService.On("GetCachedMessages", mock.Anything, "User1", mock.Anything).Return(nil, false, nil).Once()
+	// Newest first, the extra one is the newest
+	newer := []models.Message{{ID: gocql.TimeUUID(), Content: "newest"}, {ID: gocql.TimeUUID(), Content: "newer"}}
+	mts.msgService.On("GetMessages", mock.Anything, "User1", mock.MatchedBy(func(page models.MessagesPage) bool {
+		return page.After != nil && page.After.ID == after
+	})).Return(newer, nil).Once()
+
+	rr := httptest.NewRecorder()
+
+	mts.middleware(mts.handler.GetMessages).ServeHTTP(rr, req)
+
+	resp := rr.Result()
+
+	mts.Equal(http.StatusOK, resp.StatusCode)
+
+	var msgsResponse responses.MessagesResponse
+	err = json.NewDecoder(resp.Body).Decode(&msgsResponse)
+	mts.NoError(err, "Failed to decode response body")
+
+	mts.Len(msgsResponse.Messages, 1)
+	mts.Equal("newer", msgsResponse.Messages[0].Content)
+
+	direction, _, err := utils.DecodeCursor(*msgsResponse.Pagination.NextCursor)
+	mts.NoError(err)
+	mts.Equal(utils.CURSOR_AFTER, direction)
+}
+
+func (mts *MessagesTestSuite) Test_GetMessages_Invalid_Cursor() {
+	for _, query := range []string{"?cursor=garbage", "?before=not-a-uuid", "?before=" + gocql.MustRandomUUID().String()} {
+		req, err := http.NewRequest("GET", mts.getMsgsEndpointUrl+query, nil)
+		mts.NoError(err, "Failed to make request")
+
+		req.Header.Set("Authorization", mts.authHeader)
+
+		rr := httptest.NewRecorder()
+
+		mts.middleware(mts.handler.GetMessages).ServeHTTP(rr, req)
+
+		mts.Equal(http.StatusBadRequest, rr.Result().StatusCode, query)
+	}
+}
+
+// newMessageRequest makes an authenticated request targeting the message as the `id` path param
+func (mts *MessagesTestSuite) newMessageRequest(method, url string, id gocql.UUID, body []byte) *http.Request {
+	req, err := http.NewRequest(method, url, bytes.NewBuffer(body))
+	mts.NoError(err, "Failed to make request")
+
+	req.Header.Set("Authorization", mts.authHeader)
+
+	return mux.SetURLVars(req, map[string]string{"id": id.String()})
+}
+
+func (mts *MessagesTestSuite) Test_Edit_Not_Found() {
+	id := gocql.TimeUUID()
+	mts.msgService.On("GetMessage", mock.Anything, "User1", id).Return(nil, nil).Once()
+
+	body, err := json.Marshal(&models.EditMessageInput{Content: "Edited"})
+	mts.NoError(err, "Failed to marshal editMessageInput")
+
+	rr := httptest.NewRecorder()
+
+	mts.middleware(mts.handler.EditMessage).ServeHTTP(rr, mts.newMessageRequest("PATCH", mts.getMsgsEndpointUrl, id, body))
+
+	resp := rr.Result()
+
+	mts.Equal(http.StatusNotFound, resp.StatusCode)
+
+	err = json.NewDecoder(resp.Body).Decode(&mts.errResponse)
+	mts.NoError(err, "Failed to decode response body")
+
+	mts.Equal(common.MESSAGE_NOT_FOUND, mts.errResponse.Error)
+}
+
+func (mts *MessagesTestSuite) Test_Edit_Not_Sender() {
+	id := gocql.TimeUUID()
+	msg := &models.Message{ID: id, Sender: "User2", Recipient: "User1", Content: "Hi"}
+	mts.msgService.On("GetMessage", mock.Anything, "User1", id).Return(msg, nil).Once()
+
+	body, err := json.Marshal(&models.EditMessageInput{Content: "Edited"})
+	mts.NoError(err, "Failed to marshal editMessageInput")
+
+	rr := httptest.NewRecorder()
+
+	mts.middleware(mts.handler.EditMessage).ServeHTTP(rr, mts.newMessageRequest("PATCH", mts.getMsgsEndpointUrl, id, body))
+
+	resp := rr.Result()
+
+	mts.Equal(http.StatusForbidden, resp.StatusCode)
+
+	err = json.NewDecoder(resp.Body).Decode(&mts.errResponse)
+	mts.NoError(err, "Failed to decode response body")
+
+	mts.Equal(common.MESSAGE_NOT_SENDER, mts.errResponse.Error)
+}
+
+func (mts *MessagesTestSuite) Test_Edit_Success() {
+	id := gocql.TimeUUID()
+	msg := &models.Message{ID: id, Sender: "User1", Recipient: "User2", Content: "Hi"}
+	mts.msgService.On("GetMessage", mock.Anything, "User1", id).Return(msg, nil).Once()
+	mts.msgService.On("EditMessage", mock.Anything, mock.MatchedBy(func(m *models.Message) bool {
+		return m.ID == id && m.Content == "Edited"
+	})).Return(nil).Once()
+	mts.msgService.On("InvalidateCachedMessages", mock.Anything, "User1").Return(nil).Once()
+	mts.msgService.On("InvalidateCachedMessages", mock.Anything, "User2").Return(nil).Once()
+	mts.broker.On("Publish", mock.Anything, realtime.EVENT_MESSAGE_EDITED, mock.Anything, []string{"User1", "User2"}).Return(nil).Once()
+
+	body, err := json.Marshal(&models.EditMessageInput{Content: "Edited"})
+	mts.NoError(err, "Failed to marshal editMessageInput")
+
+	rr := httptest.NewRecorder()
+
+	mts.middleware(mts.handler.EditMessage).ServeHTTP(rr, mts.newMessageRequest("PATCH", mts.getMsgsEndpointUrl, id, body))
+
+	resp := rr.Result()
+
+	mts.Equal(http.StatusOK, resp.StatusCode)
+
+	var edited models.Message
+	err = json.NewDecoder(resp.Body).Decode(&edited)
+	mts.NoError(err, "Failed to decode response body")
+
+	mts.Equal("Edited", edited.Content)
+	mts.broker.AssertCalled(mts.T(), "Publish", mock.Anything, realtime.EVENT_MESSAGE_EDITED, mock.Anything, []string{"User1", "User2"})
+}
+
+func (mts *MessagesTestSuite) Test_Delete_Invalid_Scope() {
+	rr := httptest.NewRecorder()
+
+	req := mts.newMessageRequest("DELETE", mts.getMsgsEndpointUrl+"?scope=nobody", gocql.TimeUUID(), nil)
+	mts.middleware(mts.handler.DeleteMessage).ServeHTTP(rr, req)
+
+	mts.Equal(http.StatusBadRequest, rr.Result().StatusCode)
+}
+
+func (mts *MessagesTestSuite) Test_Delete_For_Me() {
+	id := gocql.TimeUUID()
+	// Not the sender, still allowed to delete it on their side
+	msg := &models.Message{ID: id, Sender: "User2", Recipient: "User1", Content: "Hi"}
+	mts.msgService.On("GetMessage", mock.Anything, "User1", id).Return(msg, nil).Once()
+	mts.msgService.On("DeleteMessageForUser", mock.Anything, "User1", msg).Return(nil).Once()
+	mts.msgService.On("InvalidateCachedMessages", mock.Anything, "User1").Return(nil).Once()
+	mts.broker.On("Publish", mock.Anything, realtime.EVENT_MESSAGE_DELETED, msg, []string{"User1"}).Return(nil).Once()
+
+	rr := httptest.NewRecorder()
+
+	mts.middleware(mts.handler.DeleteMessage).ServeHTTP(rr, mts.newMessageRequest("DELETE", mts.getMsgsEndpointUrl, id, nil))
+
+	mts.Equal(http.StatusNoContent, rr.Result().StatusCode)
+	mts.msgService.AssertCalled(mts.T(), "DeleteMessageForUser", mock.Anything, "User1", msg)
+}
+
+func (mts *MessagesTestSuite) Test_Delete_For_Everyone_Not_Sender() {
+	id := gocql.TimeUUID()
+	msg := &models.Message{ID: id, Sender: "User2", Recipient: "User1", Content: "Hi"}
+	mts.msgService.On("GetMessage", mock.Anything, "User1", id).Return(msg, nil).Once()
+
+	rr := httptest.NewRecorder()
+
+	req := mts.newMessageRequest("DELETE", mts.getMsgsEndpointUrl+"?scope=everyone", id, nil)
+	mts.middleware(mts.handler.DeleteMessage).ServeHTTP(rr, req)
+
+	mts.Equal(http.StatusForbidden, rr.Result().StatusCode)
+}
+
+func (mts *MessagesTestSuite) Test_Delete_Group_Message_For_Everyone() {
+	id := gocql.TimeUUID()
+	groupID := gocql.TimeUUID()
+	msg := &models.Message{ID: id, Sender: "User1", GroupID: &groupID, Content: "Hi all"}
+	members := []models.GroupMember{
+		{GroupID: groupID, Username: "User1", Role: models.GROUP_ROLE_OWNER},
+		{GroupID: groupID, Username: "User2", Role: models.GROUP_ROLE_MEMBER},
+		{GroupID: groupID, Username: "User3", Role: models.GROUP_ROLE_MEMBER},
+	}
+	audience := []string{"User1", "User2", "User3"}
+
+	mts.msgService.On("GetMessage", mock.Anything, "User1", id).Return(msg, nil).Once()
+	mts.groupService.On("GetMembers", mock.Anything, groupID).Return(members, nil).Once()
+	mts.groupService.On("DeleteGroupMessage", mock.Anything, msg, audience).Return(nil).Once()
+	mts.msgService.On("InvalidateCachedMessages", mock.Anything, mock.Anything).Return(nil).Times(3)
+	mts.broker.On("Publish", mock.Anything, realtime.EVENT_MESSAGE_DELETED, msg, audience).Return(nil).Once()
+
+	rr := httptest.NewRecorder()
+
+	req := mts.newMessageRequest("DELETE", mts.getMsgsEndpointUrl+"?scope=everyone", id, nil)
+	mts.middleware(mts.handler.DeleteMessage).ServeHTTP(rr, req)
+
+	mts.Equal(http.StatusNoContent, rr.Result().StatusCode)
+	mts.groupService.AssertCalled(mts.T(), "DeleteGroupMessage", mock.Anything, msg, audience)
+}
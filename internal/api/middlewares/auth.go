@@ -4,7 +4,6 @@ import (
 	"chat-system/internal/api/auth"
 	"context"
 	"net/http"
-	"os"
 	"strings"
 )
 
@@ -12,30 +11,58 @@ type key string
 
 var ctxClaimsKey key
 
-func IsAuth(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" {
-			http.Error(w, "Authorization header required", http.StatusUnauthorized)
-			return
-		}
-
-		tokenString := strings.TrimPrefix(authHeader, os.Getenv("AUTH_HEADER_PREFIX")+" ")
-		if tokenString == authHeader {
-			http.Error(w, "Bearer token required", http.StatusUnauthorized)
-			return
-		}
-
-		claims, err := auth.ValidateToken(tokenString)
-		if err != nil {
-			http.Error(w, "Invalid token", http.StatusUnauthorized)
-			return
-		}
-
-		// Add claims to the request context
-		ctx := context.WithValue(r.Context(), ctxClaimsKey, claims)
-		next.ServeHTTP(w, r.WithContext(ctx))
-	})
+// Browsers cannot set headers while opening a WebSocket or an EventSource,
+// so the token is accepted as a query param by the live routes only
+const TOKEN_QUERY_PARAM = "access_token"
+
+// IsAuth requires an access token of the tokens within the `Authorization` header, following the scheme of the prefix
+func IsAuth(tokens *auth.Tokens, headerPrefix string) func(next http.Handler) http.Handler {
+	return authenticate(tokens, headerPrefix, false)
+}
+
+// IsLiveAuth also accepts the token as the `access_token` query param, for the live routes only since URLs end up
+// within access logs & browser histories. The param is dropped once read, so nothing further down logs it.
+func IsLiveAuth(tokens *auth.Tokens, headerPrefix string) func(next http.Handler) http.Handler {
+	return authenticate(tokens, headerPrefix, true)
+}
+
+func authenticate(tokens *auth.Tokens, headerPrefix string, queryToken bool) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			authHeader := r.Header.Get("Authorization")
+			if queryToken {
+				query := r.URL.Query()
+				if token := query.Get(TOKEN_QUERY_PARAM); token != "" {
+					if authHeader == "" {
+						authHeader = headerPrefix + " " + token
+					}
+					query.Del(TOKEN_QUERY_PARAM)
+					r.URL.RawQuery = query.Encode()
+				}
+			}
+
+			if authHeader == "" {
+				http.Error(w, "Authorization header required", http.StatusUnauthorized)
+				return
+			}
+
+			tokenString := strings.TrimPrefix(authHeader, headerPrefix+" ")
+			if tokenString == authHeader {
+				http.Error(w, "Bearer token required", http.StatusUnauthorized)
+				return
+			}
+
+			claims, err := tokens.ValidateToken(r.Context(), tokenString)
+			if err != nil {
+				http.Error(w, "Invalid token", http.StatusUnauthorized)
+				return
+			}
+
+			// Add claims to the request context
+			ctx := context.WithValue(r.Context(), ctxClaimsKey, claims)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
 }
 
 func GetUserFromContext(ctx context.Context) *auth.Claims {
@@ -0,0 +1,68 @@
+package middlewares
+
+import (
+	"chat-system/internal/api/auth"
+	"chat-system/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gocql/gocql"
+	"github.com/stretchr/testify/suite"
+)
+
+type AuthTestSuite struct {
+	suite.Suite
+	tokens *auth.Tokens
+	token  string
+	// Query of the last request served
+	query string
+}
+
+func TestAuthTestSuite(t *testing.T) {
+	suite.Run(t, new(AuthTestSuite))
+}
+
+func (ats *AuthTestSuite) SetupTest() {
+	tokens, err := auth.NewTokens(auth.Config{
+		SecretKey: []byte("secret"),
+		Issuer:    auth.DEFAULT_ISSUER,
+		Audience:  auth.DEFAULT_AUDIENCE,
+	}, auth.NewMemoryRevocationList())
+	ats.Require().NoError(err)
+	ats.tokens = tokens
+
+	ats.token, err = tokens.GenerateToken(&models.User{ID: gocql.TimeUUID(), Username: "user1"})
+	ats.Require().NoError(err)
+	ats.query = ""
+}
+
+func (ats *AuthTestSuite) serve(middleware func(next http.Handler) http.Handler, req *http.Request) int {
+	rr := httptest.NewRecorder()
+	middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ats.query = r.URL.RawQuery
+	})).ServeHTTP(rr, req)
+
+	return rr.Code
+}
+
+func (ats *AuthTestSuite) Test_Query_Token_Rejected_Outside_Live_Routes() {
+	req := httptest.NewRequest("GET", "/messages?"+TOKEN_QUERY_PARAM+"="+ats.token, nil)
+	req.Header.Set("Accept", "text/event-stream")
+
+	ats.Equal(http.StatusUnauthorized, ats.serve(IsAuth(ats.tokens, "Bearer"), req))
+}
+
+func (ats *AuthTestSuite) Test_Query_Token_Dropped_Once_Read() {
+	req := httptest.NewRequest("GET", "/messages/stream?"+TOKEN_QUERY_PARAM+"="+ats.token+"&since=1", nil)
+
+	ats.Equal(http.StatusOK, ats.serve(IsLiveAuth(ats.tokens, "Bearer"), req))
+	ats.Equal("since=1", ats.query)
+}
+
+func (ats *AuthTestSuite) Test_Header_Token() {
+	req := httptest.NewRequest("GET", "/messages", nil)
+	req.Header.Set("Authorization", "Bearer "+ats.token)
+
+	ats.Equal(http.StatusOK, ats.serve(IsAuth(ats.tokens, "Bearer"), req))
+}
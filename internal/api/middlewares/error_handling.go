@@ -2,8 +2,9 @@ package middlewares
 
 import (
 	common "chat-system/internal/api/common/constants"
+	"chat-system/internal/logging"
 	"encoding/json"
-	"log"
+	"log/slog"
 	"net/http"
 )
 
@@ -37,14 +38,19 @@ func HandleErrors(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
 			if err := recover(); err != nil {
+				logger := logging.FromContext(r.Context())
 				switch e := err.(type) {
 				case *HTTPError:
-					log.Printf("HTTP error: %v", e.Err)
+					level := slog.LevelInfo
+					if e.StatusCode >= http.StatusInternalServerError {
+						level = slog.LevelError
+					}
+					logger.Log(r.Context(), level, "HTTP error", "status", e.StatusCode, "error", e.Err)
 					w.Header().Set("Content-Type", "application/json")
 					w.WriteHeader(e.StatusCode)
 					json.NewEncoder(w).Encode(ErrorResponse{Error: e.Error()})
 				default:
-					log.Printf("An unexpected error occurred: %v", err)
+					logger.Error("An unexpected error occurred", "error", err)
 					w.Header().Set("Content-Type", "application/json")
 					w.WriteHeader(http.StatusInternalServerError)
 					json.NewEncoder(w).Encode(ErrorResponse{Error: common.INTERNAL_SERVER_ERROR})
@@ -0,0 +1,82 @@
+package middlewares
+
+import (
+	"chat-system/internal/metrics"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/suite"
+)
+
+type MetricsTestSuite struct {
+	suite.Suite
+	router *mux.Router
+}
+
+func TestMetricsTestSuite(t *testing.T) {
+	suite.Run(t, new(MetricsTestSuite))
+}
+
+func (mts *MetricsTestSuite) SetupTest() {
+	mts.router = mux.NewRouter()
+	mts.router.Use(RecordMetrics)
+	mts.router.Use(HandleErrors)
+
+	mts.router.HandleFunc("/test/messages/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}).Methods("DELETE")
+	mts.router.HandleFunc("/test/messages/{id}", func(w http.ResponseWriter, r *http.Request) {
+		panic(NewHTTPError(http.StatusForbidden, errors.New("forbidden")))
+	}).Methods("PATCH")
+	mts.router.HandleFunc("/test/stream", func(w http.ResponseWriter, r *http.Request) {
+		_, ok := w.(http.Flusher)
+		mts.True(ok)
+		w.Write([]byte("data: {}\n\n"))
+	}).Methods("GET")
+}
+
+func (mts *MetricsTestSuite) serve(method, path string) *httptest.ResponseRecorder {
+	rr := httptest.NewRecorder()
+	mts.router.ServeHTTP(rr, httptest.NewRequest(method, path, nil))
+	return rr
+}
+
+func (mts *MetricsTestSuite) Test_By_Route_Template() {
+	before := testutil.ToFloat64(metrics.HTTPRequests.WithLabelValues("/test/messages/{id}", "DELETE", "204"))
+
+	mts.serve("DELETE", "/test/messages/1")
+	mts.serve("DELETE", "/test/messages/2")
+
+	mts.Equal(before+2, testutil.ToFloat64(metrics.HTTPRequests.WithLabelValues("/test/messages/{id}", "DELETE", "204")))
+}
+
+func (mts *MetricsTestSuite) Test_Handled_Errors() {
+	before := testutil.ToFloat64(metrics.HTTPRequests.WithLabelValues("/test/messages/{id}", "PATCH", "403"))
+
+	rr := mts.serve("PATCH", "/test/messages/1")
+
+	mts.Equal(http.StatusForbidden, rr.Code)
+	mts.Equal(before+1, testutil.ToFloat64(metrics.HTTPRequests.WithLabelValues("/test/messages/{id}", "PATCH", "403")))
+}
+
+func (mts *MetricsTestSuite) Test_Streams_Still_Flush() {
+	before := testutil.ToFloat64(metrics.HTTPRequests.WithLabelValues("/test/stream", "GET", "200"))
+
+	rr := mts.serve("GET", "/test/stream")
+
+	mts.Equal(http.StatusOK, rr.Code)
+	mts.Equal(before+1, testutil.ToFloat64(metrics.HTTPRequests.WithLabelValues("/test/stream", "GET", "200")))
+}
+
+func (mts *MetricsTestSuite) Test_Unknown_Paths_Not_Recorded() {
+	before := testutil.CollectAndCount(metrics.HTTPRequests)
+
+	rr := mts.serve("GET", "/test/users/1")
+
+	mts.Equal(http.StatusNotFound, rr.Code)
+	mts.Equal(before, testutil.CollectAndCount(metrics.HTTPRequests))
+}
@@ -0,0 +1,60 @@
+package middlewares
+
+import (
+	"chat-system/internal/logging"
+	"net/http"
+
+	"github.com/gocql/gocql"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const REQUEST_ID_HEADER = "X-Request-ID"
+
+// Longest request ID accepted from the callers, e.g a proxy in front of the service
+const MAX_REQUEST_ID_LENGTH = 128
+
+// AssignRequestID keeps the `X-Request-ID` of the caller, or generates one, & echoes it back.
+// The request then carries a logger of its own within its context, tagging every line with its request ID,
+// route & trace ID so that they can be correlated.
+func AssignRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(REQUEST_ID_HEADER)
+		if !isValidRequestID(requestID) {
+			requestID = gocql.TimeUUID().String()
+		}
+		w.Header().Set(REQUEST_ID_HEADER, requestID)
+
+		logger := logging.FromContext(r.Context()).With(
+			"request_id", requestID,
+			"method", r.Method,
+			"route", routeTemplate(r),
+		)
+
+		span := trace.SpanFromContext(r.Context())
+		if span.SpanContext().IsValid() {
+			logger = logger.With("trace_id", span.SpanContext().TraceID().String())
+		}
+		span.SetAttributes(attribute.String("http.request_id", requestID))
+
+		next.ServeHTTP(w, r.WithContext(logging.WithLogger(r.Context(), logger)))
+	})
+}
+
+// isValidRequestID only accepts IDs that are safe to log & echo back as they are
+func isValidRequestID(id string) bool {
+	if id == "" || len(id) > MAX_REQUEST_ID_LENGTH {
+		return false
+	}
+
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,85 @@
+package middlewares
+
+import (
+	"bytes"
+	"chat-system/internal/logging"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/suite"
+)
+
+type RequestIDTestSuite struct {
+	suite.Suite
+	out    *bytes.Buffer
+	router *mux.Router
+}
+
+func TestRequestIDTestSuite(t *testing.T) {
+	suite.Run(t, new(RequestIDTestSuite))
+}
+
+func (rts *RequestIDTestSuite) SetupTest() {
+	rts.out = &bytes.Buffer{}
+	logger := slog.New(logging.NewHandler(rts.out, logging.Config{Level: slog.LevelInfo, Format: logging.FORMAT_JSON}))
+
+	rts.router = mux.NewRouter()
+	rts.router.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(logging.WithLogger(r.Context(), logger)))
+		})
+	})
+	rts.router.Use(AssignRequestID)
+	rts.router.HandleFunc("/test/messages/{id}", func(w http.ResponseWriter, r *http.Request) {
+		logging.FromContext(r.Context()).Info("test")
+	}).Methods("GET")
+}
+
+func (rts *RequestIDTestSuite) serve(requestID string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", "/test/messages/1", nil)
+	if requestID != "" {
+		req.Header.Set(REQUEST_ID_HEADER, requestID)
+	}
+
+	rr := httptest.NewRecorder()
+	rts.router.ServeHTTP(rr, req)
+	return rr
+}
+
+func (rts *RequestIDTestSuite) line() map[string]interface{} {
+	var line map[string]interface{}
+	rts.Require().NoError(json.Unmarshal(rts.out.Bytes(), &line))
+	return line
+}
+
+func (rts *RequestIDTestSuite) Test_Honors_Caller_ID() {
+	rr := rts.serve("abc-123")
+
+	rts.Equal("abc-123", rr.Header().Get(REQUEST_ID_HEADER))
+	line := rts.line()
+	rts.Equal("abc-123", line["request_id"])
+	rts.Equal("GET", line["method"])
+	rts.Equal("/test/messages/{id}", line["route"])
+}
+
+func (rts *RequestIDTestSuite) Test_Generates_ID() {
+	rr := rts.serve("")
+
+	requestID := rr.Header().Get(REQUEST_ID_HEADER)
+	rts.NotEmpty(requestID)
+	rts.Equal(requestID, rts.line()["request_id"])
+}
+
+func (rts *RequestIDTestSuite) Test_Replaces_Unsafe_ID() {
+	for _, requestID := range []string{"abc\ninjected", "abc 123", strings.Repeat("a", MAX_REQUEST_ID_LENGTH+1)} {
+		rr := rts.serve(requestID)
+
+		rts.NotEqual(requestID, rr.Header().Get(REQUEST_ID_HEADER))
+		rts.NotEmpty(rr.Header().Get(REQUEST_ID_HEADER))
+	}
+}
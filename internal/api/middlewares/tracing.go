@@ -0,0 +1,22 @@
+package middlewares
+
+import (
+	"net/http"
+
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// NameSpans names the span of the request after the route it matched rather than its path, which holds IDs,
+// so that the traces of the same endpoint get grouped together
+func NameSpans(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := routeTemplate(r)
+
+		span := trace.SpanFromContext(r.Context())
+		span.SetName(r.Method + " " + route)
+		span.SetAttributes(semconv.HTTPRoute(route))
+
+		next.ServeHTTP(w, r)
+	})
+}
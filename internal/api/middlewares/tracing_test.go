@@ -0,0 +1,64 @@
+package middlewares
+
+import (
+	"chat-system/internal/tracing"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/suite"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+type TracingTestSuite struct {
+	suite.Suite
+	spans   *tracetest.SpanRecorder
+	handler http.Handler
+}
+
+func TestTracingTestSuite(t *testing.T) {
+	suite.Run(t, new(TracingTestSuite))
+}
+
+func (tts *TracingTestSuite) SetupSuite() {
+	tts.spans = tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(tts.spans)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	router := mux.NewRouter()
+	router.Use(NameSpans)
+	router.HandleFunc("/test/messages/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}).Methods("DELETE")
+	router.HandleFunc(tracing.METRICS_PATH, func(w http.ResponseWriter, r *http.Request) {}).Methods("GET")
+
+	tts.handler = tracing.Handler(router)
+}
+
+func (tts *TracingTestSuite) Test_Named_After_Route_Within_Caller_Trace() {
+	req := httptest.NewRequest("DELETE", "/test/messages/1", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	tts.handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := tts.spans.Ended()
+	tts.Require().NotEmpty(spans)
+	span := spans[len(spans)-1]
+	tts.Equal("DELETE /test/messages/{id}", span.Name())
+	tts.Equal("4bf92f3577b34da6a3ce929d0e0e4736", span.SpanContext().TraceID().String())
+	tts.Equal("00f067aa0ba902b7", span.Parent().SpanID().String())
+	tts.Contains(span.Attributes(), semconv.HTTPRoute("/test/messages/{id}"))
+}
+
+func (tts *TracingTestSuite) Test_Metrics_Not_Traced() {
+	before := len(tts.spans.Ended())
+
+	tts.handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", tracing.METRICS_PATH, nil))
+
+	tts.Len(tts.spans.Ended(), before)
+}
@@ -0,0 +1,122 @@
+package realtime
+
+import (
+	"chat-system/internal/models"
+	"context"
+	"encoding/json"
+	"log/slog"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const MESSAGES_CHANNEL = "live-messages"
+
+// Broker publishes the changes of messages (e.g sent, edited) to all replicas,
+// so each one delivers to its own local sessions
+type Broker interface {
+	Publish(ctx context.Context, eventType string, msg *models.Message, audience []string) error
+}
+
+// publication is what actually travels over the pub/sub channel
+type publication struct {
+	Event    *Event   `json:"event"`
+	Audience []string `json:"audience"`
+}
+
+type redisBroker struct {
+	client  *redis.Client
+	channel string
+}
+
+func NewRedisBroker(client *redis.Client, channel string) *redisBroker {
+	return &redisBroker{
+		client:  client,
+		channel: channel,
+	}
+}
+
+// Live is the hub of the local sessions, along with the broker sharing their events with the replicas
+type Live struct {
+	Hub    *Hub
+	Broker Broker
+	// Stops listening to the events published by the replicas
+	stopListening context.CancelFunc
+}
+
+// Start delivers the events published by any replica through Redis to the local sessions.
+// Without Redis there's a single replica, so events are delivered to its own sessions right away.
+func Start(client *redis.Client) *Live {
+	hub := NewHub()
+	if client == nil {
+		return &Live{Hub: hub, Broker: NewLocalBroker(hub), stopListening: func() {}}
+	}
+
+	broker := NewRedisBroker(client, MESSAGES_CHANNEL)
+	ctx, cancel := context.WithCancel(context.Background())
+	go broker.Listen(ctx, hub)
+
+	return &Live{Hub: hub, Broker: broker, stopListening: cancel}
+}
+
+// Close stops delivering the events published by the replicas, once the local sessions are gone
+func (l *Live) Close() {
+	l.stopListening()
+}
+
+func (b *redisBroker) Publish(ctx context.Context, eventType string, msg *models.Message, audience []string) error {
+	payload, err := json.Marshal(publication{
+		Event:    &Event{Type: eventType, Data: msg},
+		Audience: audience,
+	})
+	if err != nil {
+		return err
+	}
+
+	return b.client.Publish(ctx, b.channel, payload).Err()
+}
+
+// Listen blocks delivering the messages published by any replica to the local sessions of the hub,
+// until the context is done
+func (b *redisBroker) Listen(ctx context.Context, hub *Hub) {
+	pubSub := b.client.Subscribe(ctx, b.channel)
+	defer pubSub.Close()
+
+	events := pubSub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			deliver(hub, event)
+		}
+	}
+}
+
+func deliver(hub *Hub, event *redis.Message) {
+	var published publication
+	if err := json.Unmarshal([]byte(event.Payload), &published); err != nil || published.Event == nil {
+		slog.Error("Failed to decode published event", "error", err)
+		return
+	}
+
+	hub.Deliver(published.Event, published.Audience)
+}
+
+// localBroker delivers events to the sessions of its own replica only
+type localBroker struct {
+	hub *Hub
+}
+
+func NewLocalBroker(hub *Hub) *localBroker {
+	return &localBroker{
+		hub: hub,
+	}
+}
+
+func (b *localBroker) Publish(_ context.Context, eventType string, msg *models.Message, audience []string) error {
+	b.hub.Deliver(&Event{Type: eventType, Data: msg}, audience)
+	return nil
+}
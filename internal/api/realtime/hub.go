@@ -0,0 +1,174 @@
+// The purpose of this package is to push live events (e.g new messages) to the connected sessions of users.
+// Every replica of the service keeps track of its own local sessions only, while Redis pub/sub is used
+// to fan the events out across all replicas sitting behind the load balancer.
+
+package realtime
+
+import (
+	"chat-system/internal/models"
+	"context"
+	"log/slog"
+	"sync"
+)
+
+// Types of the live events
+const (
+	EVENT_MESSAGE         = "message"
+	EVENT_MESSAGE_EDITED  = "message.edited"
+	EVENT_MESSAGE_DELETED = "message.deleted"
+	EVENT_MESSAGE_READ    = "message.read"
+	// Tells a client it missed more than could be replayed, so it catches up through the history instead
+	EVENT_RESYNC = "resync"
+)
+
+// Number of events buffered per subscription before considering it a slow consumer
+const SEND_BUFFER_SIZE = 64
+
+// Event is the envelope pushed to the connected sessions
+type Event struct {
+	Type string          `json:"type"`
+	Data *models.Message `json:"data"`
+}
+
+// Subscription is the transport-agnostic part of a live session (WebSocket, SSE, etc...)
+type Subscription struct {
+	username string
+	send     chan *Event
+	// Guarded by the mutex of the hub
+	closed       bool
+	unregistered bool
+}
+
+func NewSubscription(username string) *Subscription {
+	return &Subscription{
+		username: username,
+		send:     make(chan *Event, SEND_BUFFER_SIZE),
+	}
+}
+
+// Events returns the channel of delivered events. It gets closed once the subscription is unregistered
+// or the hub is closed
+func (s *Subscription) Events() <-chan *Event {
+	return s.send
+}
+
+func (s *Subscription) close() {
+	if !s.closed {
+		s.closed = true
+		close(s.send)
+	}
+}
+
+// Hub keeps track of the live subscriptions on the current replica, grouped by username
+type Hub struct {
+	mu            sync.RWMutex
+	subscriptions map[string]map[*Subscription]struct{}
+	// Subscriptions not unregistered by their sessions yet, awaited while shutting down
+	active sync.WaitGroup
+	closed bool
+}
+
+func NewHub() *Hub {
+	return &Hub{
+		subscriptions: make(map[string]map[*Subscription]struct{}),
+	}
+}
+
+// Register subscribes to the events of the user, unless the hub is closed in which case the subscription ends right away
+func (h *Hub) Register(s *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		s.unregistered = true
+		s.close()
+		return
+	}
+
+	h.active.Add(1)
+	if h.subscriptions[s.username] == nil {
+		h.subscriptions[s.username] = make(map[*Subscription]struct{})
+	}
+	h.subscriptions[s.username][s] = struct{}{}
+}
+
+func (h *Hub) Unregister(s *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if s.unregistered {
+		return
+	}
+	s.unregistered = true
+	s.close()
+	h.active.Done()
+
+	if userSubscriptions, ok := h.subscriptions[s.username]; ok {
+		delete(userSubscriptions, s)
+		if len(userSubscriptions) == 0 {
+			delete(h.subscriptions, s.username)
+		}
+	}
+}
+
+// Close ends every subscription, which in turn ends their sessions, & turns the new ones away (e.g while shutting down).
+// Sessions still unregister their subscriptions once they are done, which Wait waits for.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for _, userSubscriptions := range h.subscriptions {
+		for s := range userSubscriptions {
+			s.close()
+		}
+	}
+	h.subscriptions = make(map[string]map[*Subscription]struct{})
+}
+
+// Wait blocks until every session unregistered its subscription, or the context is done
+func (h *Hub) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		h.active.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Deliver pushes the event to every local subscription of the given audience (e.g sender & recipient, group members)
+func (h *Hub) Deliver(event *Event, audience []string) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for _, username := range audience {
+		for s := range h.subscriptions[username] {
+			select {
+			case s.send <- event:
+			default:
+				// Slow consumer, drop the event rather than blocking the whole hub.
+				// The client can still catch up through the messages history endpoint.
+				slog.Warn("Dropping live event for a slow session", "user", username, "event", event.Type)
+			}
+		}
+	}
+}
+
+// SessionsCount returns the number of live subscriptions on the current replica
+func (h *Hub) SessionsCount() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	count := 0
+	for _, userSubscriptions := range h.subscriptions {
+		count += len(userSubscriptions)
+	}
+
+	return count
+}
@@ -0,0 +1,97 @@
+package realtime
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// Time allowed to write a frame to the peer
+	WRITE_WAIT = 10 * time.Second
+	// Time allowed to read the next pong frame from the peer
+	PONG_WAIT = 60 * time.Second
+	// Send pings to the peer within this period. Must be less than PONG_WAIT
+	PING_PERIOD = (PONG_WAIT * 9) / 10
+	// Max size of a frame accepted from the peer. Clients are not expected to send anything but control frames
+	MAX_FRAME_SIZE = 512
+)
+
+// Session is a single live WebSocket connection of a user. A user may have many at once (e.g several devices/tabs)
+type Session struct {
+	hub          *Hub
+	conn         *websocket.Conn
+	username     string
+	subscription *Subscription
+}
+
+func NewSession(hub *Hub, conn *websocket.Conn, username string) *Session {
+	return &Session{
+		hub:          hub,
+		conn:         conn,
+		username:     username,
+		subscription: NewSubscription(username),
+	}
+}
+
+// Serve registers the session within the hub and pumps events until the connection is gone
+func (s *Session) Serve() {
+	s.hub.Register(s.subscription)
+
+	go s.writePump()
+	s.readPump()
+}
+
+// readPump keeps reading from the connection to process control frames (ping, pong & close)
+// Once the peer goes away, the session gets unregistered which in turn stops the writePump
+func (s *Session) readPump() {
+	defer func() {
+		s.hub.Unregister(s.subscription)
+		s.conn.Close()
+	}()
+
+	s.conn.SetReadLimit(MAX_FRAME_SIZE)
+	s.conn.SetReadDeadline(time.Now().Add(PONG_WAIT))
+	s.conn.SetPongHandler(func(string) error {
+		return s.conn.SetReadDeadline(time.Now().Add(PONG_WAIT))
+	})
+
+	for {
+		if _, _, err := s.conn.ReadMessage(); err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+				slog.Warn("Live session closed unexpectedly", "user", s.username, "error", err)
+			}
+			return
+		}
+	}
+}
+
+func (s *Session) writePump() {
+	ticker := time.NewTicker(PING_PERIOD)
+	defer func() {
+		ticker.Stop()
+		s.conn.Close()
+	}()
+
+	for {
+		select {
+		case event, ok := <-s.subscription.Events():
+			s.conn.SetWriteDeadline(time.Now().Add(WRITE_WAIT))
+			if !ok {
+				// The hub closed the channel, e.g the replica is shutting down
+				s.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, ""))
+				return
+			}
+
+			if err := s.conn.WriteJSON(event); err != nil {
+				return
+			}
+		case <-ticker.C:
+			s.conn.SetWriteDeadline(time.Now().Add(WRITE_WAIT))
+			if err := s.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
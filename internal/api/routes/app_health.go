@@ -1,13 +1,27 @@
 package routes
 
 import (
-	"chat-system/internal/api/handlers"
+	"chat-system/internal/tracing"
 
 	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
-func getAppRoutes(apiRouter *mux.Router) *mux.Router {
-	apiRouter.HandleFunc("/health", handlers.HowAmI).Methods("GET")
+func (rt *appRoutes) getAppRoutes(apiRouter *mux.Router) *mux.Router {
+	healthHandler := rt.appConfig.GetHealthHandler()
+	apiRouter.HandleFunc("/health/live", healthHandler.Live).Methods("GET")
+	apiRouter.HandleFunc("/health/ready", healthHandler.Ready).Methods("GET")
+	// Former probe of the liveness, kept for the callers still relying on it
+	apiRouter.HandleFunc("/health", healthHandler.Live).Methods("GET")
 
 	return apiRouter
 }
+
+// InitMetricsRoutes serves the metrics of the replica in the Prometheus format. They're served on a listener of
+// their own rather than along the API, so the proxy in front of the replicas never exposes them.
+func InitMetricsRoutes() *mux.Router {
+	r := mux.NewRouter()
+	r.Handle(tracing.METRICS_PATH, promhttp.Handler()).Methods("GET")
+
+	return r
+}
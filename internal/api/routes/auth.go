@@ -1,12 +1,21 @@
 package routes
 
 import (
+	"net/http"
+
 	"github.com/gorilla/mux"
 )
 
-func getAuthRoutes(apiRouter *mux.Router) *mux.Router {
+func (rt *appRoutes) getAuthRoutes(apiRouter *mux.Router) *mux.Router {
 	authRouter := apiRouter.PathPrefix("/auth").Subrouter()
-	authRouter.HandleFunc("/register", appConfig.GetUserHandler().Register).Methods("POST")
-	authRouter.HandleFunc("/login", appConfig.GetUserHandler().Login).Methods("POST")
+	authRouter.HandleFunc("/register", rt.appConfig.GetUserHandler().Register).Methods("POST")
+	authRouter.HandleFunc("/login", rt.appConfig.GetUserHandler().Login).Methods("POST")
+	authRouter.HandleFunc("/refresh", rt.appConfig.GetUserHandler().Refresh).Methods("POST")
+	authRouter.Handle("/logout", rt.isAuth(http.HandlerFunc(rt.appConfig.GetUserHandler().Logout))).Methods("POST")
 	return authRouter
 }
+
+func (rt *appRoutes) getWellKnownRoutes(r *mux.Router) *mux.Router {
+	r.HandleFunc("/.well-known/jwks.json", rt.appConfig.GetUserHandler().JWKS).Methods("GET")
+	return r
+}
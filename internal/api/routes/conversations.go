@@ -0,0 +1,18 @@
+package routes
+
+import (
+	"github.com/gorilla/mux"
+)
+
+func (rt *appRoutes) getConversationsRoutes(apiRouter *mux.Router) *mux.Router {
+	conversationRouter := apiRouter.PathPrefix("/conversations").Subrouter().StrictSlash(true)
+
+	// Apply Auth middleware
+	conversationRouter.Use(rt.isAuth)
+
+	conversationRouter.HandleFunc("/", rt.appConfig.GetConversationHandler().GetConversations).Methods("GET")
+	conversationRouter.HandleFunc("/{peer}/messages", rt.appConfig.GetConversationHandler().GetConversationMessages).Methods("GET")
+	conversationRouter.HandleFunc("/{peer}/read", rt.appConfig.GetConversationHandler().ReadConversation).Methods("POST")
+
+	return apiRouter
+}
@@ -0,0 +1,20 @@
+package routes
+
+import (
+	"github.com/gorilla/mux"
+)
+
+func (rt *appRoutes) getGroupsRoutes(apiRouter *mux.Router) *mux.Router {
+	groupRouter := apiRouter.PathPrefix("/groups").Subrouter().StrictSlash(true)
+
+	// Apply Auth middleware
+	groupRouter.Use(rt.isAuth)
+
+	groupRouter.HandleFunc("/", rt.appConfig.GetGroupHandler().CreateGroup).Methods("POST")
+	groupRouter.HandleFunc("/{id}/members", rt.appConfig.GetGroupHandler().GetMembers).Methods("GET")
+	groupRouter.HandleFunc("/{id}/members", rt.appConfig.GetGroupHandler().AddMember).Methods("POST")
+	groupRouter.HandleFunc("/{id}/members/{username}", rt.appConfig.GetGroupHandler().RemoveMember).Methods("DELETE")
+	groupRouter.HandleFunc("/{id}/messages", rt.appConfig.GetGroupHandler().GetGroupMessages).Methods("GET")
+
+	return apiRouter
+}
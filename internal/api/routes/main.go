@@ -3,28 +3,45 @@ package routes
 import (
 	appconfig "chat-system/internal/api/app_config"
 	"chat-system/internal/api/middlewares"
+	"chat-system/internal/config"
 
 	"github.com/gorilla/mux"
 )
 
-var appConfig appconfig.AppConfig
-
-func init() {
-	appConfig = appconfig.NewAppConfig()
+// appRoutes registers the routes of the app along with their handlers & middlewares
+type appRoutes struct {
+	appConfig appconfig.AppConfig
+	isAuth    mux.MiddlewareFunc
+	// Also accepts the token as a query param, for the live routes only
+	isLiveAuth mux.MiddlewareFunc
 }
 
-func InitRoutes() *mux.Router {
+func InitRoutes(cfg *config.Config, deps appconfig.Deps) *mux.Router {
+	rt := &appRoutes{
+		appConfig:  appconfig.NewAppConfig(cfg, deps),
+		isAuth:     middlewares.IsAuth(deps.Tokens, cfg.Auth.HeaderPrefix),
+		isLiveAuth: middlewares.IsLiveAuth(deps.Tokens, cfg.Auth.HeaderPrefix),
+	}
+
 	r := mux.NewRouter()
 
+	// Record the metrics of the requests, errors handled below included
+	r.Use(middlewares.RecordMetrics)
+	r.Use(middlewares.NameSpans)
+	r.Use(middlewares.AssignRequestID)
 	// Apply the error handler middleware
 	r.Use(middlewares.HandleErrors)
 
+	rt.getWellKnownRoutes(r)
+
 	// API routes
 	apiRouter := r.PathPrefix("/api/v1").Subrouter()
 
-	getAppRoutes(apiRouter)
-	getAuthRoutes(apiRouter)
-	getMsgsRoutes(apiRouter)
+	rt.getAppRoutes(apiRouter)
+	rt.getAuthRoutes(apiRouter)
+	rt.getMsgsRoutes(apiRouter)
+	rt.getConversationsRoutes(apiRouter)
+	rt.getGroupsRoutes(apiRouter)
 
 	return r
 }
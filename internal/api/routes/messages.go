@@ -1,19 +1,26 @@
 package routes
 
 import (
-	"chat-system/internal/api/middlewares"
-
 	"github.com/gorilla/mux"
 )
 
-func getMsgsRoutes(apiRouter *mux.Router) *mux.Router {
+func (rt *appRoutes) getMsgsRoutes(apiRouter *mux.Router) *mux.Router {
+	// Live routes are matched first, since browsers can't set headers on them & pass the token as a query param
+	liveRouter := apiRouter.PathPrefix("/messages").Subrouter()
+	liveRouter.Use(rt.isLiveAuth)
+
+	liveRouter.HandleFunc("/ws", rt.appConfig.GetLiveHandler().Connect).Methods("GET")
+	liveRouter.HandleFunc("/stream", rt.appConfig.GetLiveHandler().Stream).Methods("GET")
+
 	msgRouter := apiRouter.PathPrefix("/messages").Subrouter().StrictSlash(true)
 
 	// Apply Auth middleware
-	msgRouter.Use(middlewares.IsAuth)
+	msgRouter.Use(rt.isAuth)
 
-	msgRouter.HandleFunc("/send", appConfig.GetMsgHandler().SendMessage).Methods("POST")
-	msgRouter.HandleFunc("/", appConfig.GetMsgHandler().GetMessages).Methods("GET")
+	msgRouter.HandleFunc("/send", rt.appConfig.GetMsgHandler().SendMessage).Methods("POST")
+	msgRouter.HandleFunc("/", rt.appConfig.GetMsgHandler().GetMessages).Methods("GET")
+	msgRouter.HandleFunc("/{id}", rt.appConfig.GetMsgHandler().EditMessage).Methods("PATCH")
+	msgRouter.HandleFunc("/{id}", rt.appConfig.GetMsgHandler().DeleteMessage).Methods("DELETE")
 
 	return apiRouter
 }
@@ -1,6 +1,7 @@
 package transformers
 
 import (
+	"chat-system/internal/api/auth"
 	"chat-system/internal/models"
 
 	"github.com/gocql/gocql"
@@ -19,3 +20,19 @@ func TransUserToUserResponse(user *models.User) *UserResponse {
 		Username: user.Username,
 	}
 }
+
+// TokensResponse struct with the `token` key kept for the access token, as issued before refresh tokens
+type TokensResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refreshToken"`
+	ExpiresIn    int64  `json:"expiresIn"`
+}
+
+// Function to map TokenPair to TokensResponse
+func TransTokenPairToTokensResponse(tokens *auth.TokenPair) *TokensResponse {
+	return &TokensResponse{
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    tokens.ExpiresIn,
+	}
+}
@@ -19,3 +19,7 @@ func ValidateRegisterInput(input models.RegisterInput) error {
 func ValidateLoginInput(input models.LoginInput) error {
 	return validate.Struct(input)
 }
+
+func ValidateRefreshTokenInput(input models.RefreshTokenInput) error {
+	return validate.Struct(input)
+}
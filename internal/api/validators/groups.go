@@ -0,0 +1,13 @@
+package validators
+
+import (
+	"chat-system/internal/models"
+)
+
+func ValidateCreateGroupInput(input models.CreateGroupInput) error {
+	return validate.Struct(input)
+}
+
+func ValidateAddGroupMemberInput(input models.AddGroupMemberInput) error {
+	return validate.Struct(input)
+}
@@ -7,3 +7,11 @@ import (
 func ValidateSendMessageInput(input models.SendMessageInput) error {
 	return validate.Struct(input)
 }
+
+func ValidateEditMessageInput(input models.EditMessageInput) error {
+	return validate.Struct(input)
+}
+
+func ValidateReadConversationInput(input models.ReadConversationInput) error {
+	return validate.Struct(input)
+}
@@ -1,49 +1,89 @@
 package cassandra
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"log/slog"
 
 	"github.com/gocql/gocql"
 )
 
-var Session *gocql.Session
-
-func Init(keyspace string, replicaCount int, clusterAddresses ...string) (*gocql.Session, error) {
-	var (
-		err           error
-		clusterConfig *gocql.ClusterConfig
-	)
-
-	clusterConfig, Session, err = connectToCassandra(clusterAddresses...)
+var (
+	// WriteConsistency is set on writes through Write & NewWriteBatch, reads use the default one of the session
+	WriteConsistency = gocql.Quorum
+	// speculativeExecution is set on reads through Read
+	speculativeExecution gocql.SpeculativeExecutionPolicy = &gocql.NonSpeculativeExecution{}
+)
 
+// Init creates the keyspace if missing, then connects to it. The session returned is the only one left open,
+// which the caller closes on shutdown.
+func Init(keyspace string, config Config) (*gocql.Session, error) {
+	// The keyspace may not exist yet, so it's created through a session of its own, closed once done
+	clusterConfig, bootstrap, err := connectToCassandra(config)
 	if err != nil {
-		log.Printf("Failed to connect to Cassandra: %v", err)
+		slog.Error("Failed to connect to Cassandra", "error", err)
 		return nil, err
 	}
+	defer bootstrap.Close()
+
+	slog.Info("Connected to Cassandra")
 
-	log.Println("Connected to Cassandra")
+	WriteConsistency = config.WriteConsistency
+	speculativeExecution = &gocql.NonSpeculativeExecution{}
+	if config.SpeculativeAttempts > 0 {
+		speculativeExecution = &gocql.SimpleSpeculativeExecution{
+			NumAttempts:  config.SpeculativeAttempts,
+			TimeoutDelay: config.SpeculativeDelay,
+		}
+	}
 
-	err = createKeyspace(replicaCount, keyspace)
+	err = createKeyspace(bootstrap, config, keyspace)
 	if err != nil {
-		log.Printf("Failed to create keyspace '%s' : %v", keyspace, err)
+		slog.Error("Failed to create keyspace", "keyspace", keyspace, "error", err)
 		return nil, err
 	}
 
 	// Reconnect to the keyspace
 	clusterConfig.Keyspace = keyspace
-	Session, err = clusterConfig.CreateSession()
+	session, err := clusterConfig.CreateSession()
 	if err != nil {
-		log.Printf("Failed to connect to keyspace '%s': %v", keyspace, err)
+		slog.Error("Failed to connect to keyspace", "keyspace", keyspace, "error", err)
 		return nil, err
 	}
 
-	fmt.Printf("Successfully connected to '%s' keyspace. Now you may want to run migrations!", keyspace)
-	return Session, nil
+	slog.Info("Connected to keyspace", "keyspace", keyspace)
+	return session, nil
+}
+
+// Read marks the query as a read, which is safe to speculatively send to other replicas
+func Read(query *gocql.Query) *gocql.Query {
+	return query.Idempotent(true).SetSpeculativeExecutionPolicy(speculativeExecution)
+}
+
+// Write sets the write consistency on the query
+func Write(query *gocql.Query) *gocql.Query {
+	return query.Consistency(WriteConsistency)
+}
+
+// NewWriteBatch starts a logged batch at the write consistency
+func NewWriteBatch(session *gocql.Session) *gocql.Batch {
+	batch := session.NewBatch(gocql.LoggedBatch)
+	batch.SetConsistency(WriteConsistency)
+	return batch
+}
+
+// Ping reads the version of the node the session is connected to, which only takes a local read
+func Ping(ctx context.Context, session *gocql.Session) error {
+	var version string
+	return session.Query(`SELECT release_version FROM system.local`).WithContext(ctx).Consistency(gocql.One).Scan(&version)
 }
 
-func connectToCassandra(clusterAddresses ...string) (*gocql.ClusterConfig, *gocql.Session, error) {
-	clusterConfig := gocql.NewCluster(clusterAddresses...)
+func connectToCassandra(config Config) (*gocql.ClusterConfig, *gocql.Session, error) {
+	clusterConfig, err := config.clusterConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+
 	session, err := clusterConfig.CreateSession()
 	if err != nil {
 		return nil, nil, err
@@ -52,17 +92,21 @@ func connectToCassandra(clusterAddresses ...string) (*gocql.ClusterConfig, *gocq
 	return clusterConfig, session, nil
 }
 
-func createKeyspace(replicaCount int, keyspace string) error {
-	createKeyspaceQuery := fmt.Sprintf(`
-		CREATE KEYSPACE IF NOT EXISTS %s WITH replication = {
-			'class': 'SimpleStrategy',
-			'replication_factor': '%d'
-		}`, keyspace, replicaCount)
+func createKeyspace(session *gocql.Session, config Config, keyspace string) error {
+	quotedKeyspace, err := QuoteIdentifier(keyspace)
+	if err != nil {
+		return err
+	}
+
+	createKeyspaceQuery := fmt.Sprintf(
+		`CREATE KEYSPACE IF NOT EXISTS %s WITH replication = %s`,
+		quotedKeyspace,
+		config.replicationOptions(),
+	)
 
-	if err := Session.Query(createKeyspaceQuery).Exec(); err != nil {
-		log.Printf("failed to create keyspace '%s': %v", keyspace, err)
+	if err := session.Query(createKeyspaceQuery).Exec(); err != nil {
 		return err
 	}
-	fmt.Printf("Keyspace '%s' created\n", keyspace)
+	slog.Info("Keyspace created", "keyspace", keyspace)
 	return nil
 }
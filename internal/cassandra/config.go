@@ -0,0 +1,270 @@
+package cassandra
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gocql/gocql"
+)
+
+const (
+	DEFAULT_RETRIES           = 3
+	DEFAULT_SPECULATIVE_DELAY = 100 * time.Millisecond
+
+	RETRY_MIN_BACKOFF = 100 * time.Millisecond
+	RETRY_MAX_BACKOFF = 2 * time.Second
+)
+
+type Config struct {
+	Hosts []string
+	// Replicas per data center, creating the keyspace with NetworkTopologyStrategy.
+	// SimpleStrategy with ReplicationFactor replicas otherwise.
+	Replication       map[string]int
+	ReplicationFactor int
+	// Queries are routed to the replicas of this data center first
+	LocalDC           string
+	ReadConsistency   gocql.Consistency
+	WriteConsistency  gocql.Consistency
+	SerialConsistency gocql.SerialConsistency
+	Username          string
+	Password          string
+	TLS               TLSConfig
+	// Zero keeps the defaults of the driver
+	Timeout        time.Duration
+	ConnectTimeout time.Duration
+	Retries        int
+	// Extra attempts of reads sent to other replicas after SpeculativeDelay without a response, zero disables them
+	SpeculativeAttempts int
+	SpeculativeDelay    time.Duration
+}
+
+type TLSConfig struct {
+	Enabled bool
+	// Trusts the system's CAs unless set
+	CAFile string
+	// Client cert & key, for clusters requiring them
+	CertFile   string
+	KeyFile    string
+	SkipVerify bool
+}
+
+// DefaultConfig connects to the hosts with a replica per host within a single data center
+func DefaultConfig(hosts ...string) Config {
+	return Config{
+		Hosts:             hosts,
+		ReplicationFactor: len(hosts),
+		ReadConsistency:   gocql.Quorum,
+		WriteConsistency:  gocql.Quorum,
+		SerialConsistency: gocql.Serial,
+		Retries:           DEFAULT_RETRIES,
+		SpeculativeDelay:  DEFAULT_SPECULATIVE_DELAY,
+	}
+}
+
+// ParseConfig reads the config of the cluster out of the settings, i.e. `CASSANDRA_NODES` along with the optional `CASSANDRA_REPLICATION`
+// (e.g `dc1:3,dc2:3`), `CASSANDRA_REPLICATION_FACTOR`, `CASSANDRA_LOCAL_DC`, `CASSANDRA_<READ|WRITE|SERIAL>_CONSISTENCY`,
+// `CASSANDRA_USERNAME`, `CASSANDRA_PASSWORD`, `CASSANDRA_TLS`, `CASSANDRA_TLS_<CA|CERT|KEY>_FILE`, `CASSANDRA_TLS_SKIP_VERIFY`,
+// `CASSANDRA_TIMEOUT`, `CASSANDRA_CONNECT_TIMEOUT`, `CASSANDRA_RETRIES` & `CASSANDRA_SPECULATIVE_<ATTEMPTS|DELAY>`.
+// Consistency levels default to their LOCAL_ variants once a local data center is set.
+func ParseConfig(get func(name string) string) (Config, error) {
+	var hosts []string
+	for _, host := range strings.Split(get("CASSANDRA_NODES"), ",") {
+		if host = strings.TrimSpace(host); host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+	if len(hosts) == 0 {
+		return Config{}, errors.New("CASSANDRA_NODES is not set")
+	}
+
+	config := DefaultConfig(hosts...)
+	config.LocalDC = get("CASSANDRA_LOCAL_DC")
+	config.Username = get("CASSANDRA_USERNAME")
+	config.Password = get("CASSANDRA_PASSWORD")
+	config.TLS.CAFile = get("CASSANDRA_TLS_CA_FILE")
+	config.TLS.CertFile = get("CASSANDRA_TLS_CERT_FILE")
+	config.TLS.KeyFile = get("CASSANDRA_TLS_KEY_FILE")
+	config.TLS.Enabled = config.TLS.CAFile != "" || config.TLS.CertFile != ""
+
+	if config.LocalDC != "" {
+		config.ReadConsistency = gocql.LocalQuorum
+		config.WriteConsistency = gocql.LocalQuorum
+		config.SerialConsistency = gocql.LocalSerial
+	}
+
+	var err error
+	if value := get("CASSANDRA_REPLICATION"); value != "" {
+		if config.Replication, err = parseReplication(value); err != nil {
+			return config, fmt.Errorf("invalid CASSANDRA_REPLICATION: %w", err)
+		}
+	}
+
+	if value := get("CASSANDRA_REPLICATION_FACTOR"); value != "" {
+		if config.ReplicationFactor, err = strconv.Atoi(value); err != nil || config.ReplicationFactor <= 0 {
+			return config, fmt.Errorf("invalid CASSANDRA_REPLICATION_FACTOR: %q", value)
+		}
+	}
+
+	for name, consistency := range map[string]*gocql.Consistency{
+		"CASSANDRA_READ_CONSISTENCY":  &config.ReadConsistency,
+		"CASSANDRA_WRITE_CONSISTENCY": &config.WriteConsistency,
+	} {
+		if value := get(name); value != "" {
+			if *consistency, err = gocql.ParseConsistencyWrapper(strings.ToUpper(value)); err != nil {
+				return config, fmt.Errorf("invalid %s: %w", name, err)
+			}
+		}
+	}
+
+	if value := get("CASSANDRA_SERIAL_CONSISTENCY"); value != "" {
+		if err = config.SerialConsistency.UnmarshalText([]byte(strings.ToUpper(value))); err != nil {
+			return config, fmt.Errorf("invalid CASSANDRA_SERIAL_CONSISTENCY: %w", err)
+		}
+	}
+
+	if value := get("CASSANDRA_TLS"); value != "" {
+		if config.TLS.Enabled, err = strconv.ParseBool(value); err != nil {
+			return config, fmt.Errorf("invalid CASSANDRA_TLS: %w", err)
+		}
+	}
+
+	if value := get("CASSANDRA_TLS_SKIP_VERIFY"); value != "" {
+		if config.TLS.SkipVerify, err = strconv.ParseBool(value); err != nil {
+			return config, fmt.Errorf("invalid CASSANDRA_TLS_SKIP_VERIFY: %w", err)
+		}
+	}
+
+	for name, duration := range map[string]*time.Duration{
+		"CASSANDRA_TIMEOUT":           &config.Timeout,
+		"CASSANDRA_CONNECT_TIMEOUT":   &config.ConnectTimeout,
+		"CASSANDRA_SPECULATIVE_DELAY": &config.SpeculativeDelay,
+	} {
+		if value := get(name); value != "" {
+			if *duration, err = time.ParseDuration(value); err != nil || *duration <= 0 {
+				return config, fmt.Errorf("invalid %s: %q", name, value)
+			}
+		}
+	}
+
+	for name, count := range map[string]*int{
+		"CASSANDRA_RETRIES":              &config.Retries,
+		"CASSANDRA_SPECULATIVE_ATTEMPTS": &config.SpeculativeAttempts,
+	} {
+		if value := get(name); value != "" {
+			if *count, err = strconv.Atoi(value); err != nil || *count < 0 {
+				return config, fmt.Errorf("invalid %s: %q", name, value)
+			}
+		}
+	}
+
+	return config, nil
+}
+
+// parseReplication reads replicas per data center formatted as `dc1:3,dc2:3`
+func parseReplication(value string) (map[string]int, error) {
+	replication := make(map[string]int)
+	for _, entry := range strings.Split(value, ",") {
+		dc, factor, found := strings.Cut(strings.TrimSpace(entry), ":")
+		if !found || dc == "" {
+			return nil, fmt.Errorf("expected <dc>:<replicas>, got %q", entry)
+		}
+
+		replicas, err := strconv.Atoi(factor)
+		if err != nil || replicas <= 0 {
+			return nil, fmt.Errorf("invalid replicas of data center %q: %q", dc, factor)
+		}
+		replication[dc] = replicas
+	}
+
+	return replication, nil
+}
+
+// replicationOptions formats the replication map of a keyspace
+func (c Config) replicationOptions() string {
+	if len(c.Replication) == 0 {
+		return fmt.Sprintf(`{'class': 'SimpleStrategy', 'replication_factor': '%d'}`, c.ReplicationFactor)
+	}
+
+	dcs := make([]string, 0, len(c.Replication))
+	for dc := range c.Replication {
+		dcs = append(dcs, dc)
+	}
+	sort.Strings(dcs)
+
+	options := []string{`'class': 'NetworkTopologyStrategy'`}
+	for _, dc := range dcs {
+		// Data centers are string literals, whose quotes are escaped by doubling them
+		options = append(options, fmt.Sprintf(`'%s': '%d'`, strings.ReplaceAll(dc, "'", "''"), c.Replication[dc]))
+	}
+	return "{" + strings.Join(options, ", ") + "}"
+}
+
+func (c Config) clusterConfig() (*gocql.ClusterConfig, error) {
+	cluster := gocql.NewCluster(c.Hosts...)
+	cluster.Consistency = c.ReadConsistency
+	cluster.SerialConsistency = c.SerialConsistency
+	cluster.RetryPolicy = &gocql.ExponentialBackoffRetryPolicy{
+		NumRetries: c.Retries,
+		Min:        RETRY_MIN_BACKOFF,
+		Max:        RETRY_MAX_BACKOFF,
+	}
+
+	fallback := gocql.RoundRobinHostPolicy()
+	if c.LocalDC != "" {
+		fallback = gocql.DCAwareRoundRobinPolicy(c.LocalDC)
+	}
+	cluster.PoolConfig.HostSelectionPolicy = gocql.TokenAwareHostPolicy(fallback, gocql.ShuffleReplicas())
+
+	if c.Timeout > 0 {
+		cluster.Timeout = c.Timeout
+	}
+	if c.ConnectTimeout > 0 {
+		cluster.ConnectTimeout = c.ConnectTimeout
+	}
+
+	if c.Username != "" {
+		cluster.Authenticator = gocql.PasswordAuthenticator{Username: c.Username, Password: c.Password}
+	}
+
+	if c.TLS.Enabled {
+		tlsConfig, err := c.TLS.tlsConfig()
+		if err != nil {
+			return nil, err
+		}
+		cluster.SslOpts = &gocql.SslOptions{Config: tlsConfig, EnableHostVerification: !c.TLS.SkipVerify}
+	}
+
+	return cluster, nil
+}
+
+func (t TLSConfig) tlsConfig() (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12, InsecureSkipVerify: t.SkipVerify}
+
+	if t.CAFile != "" {
+		pem, err := os.ReadFile(t.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found within CA file %s", t.CAFile)
+		}
+	}
+
+	if t.CertFile != "" || t.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client cert: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
@@ -0,0 +1,77 @@
+package cassandra
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/stretchr/testify/suite"
+)
+
+type ConfigTestSuite struct {
+	suite.Suite
+}
+
+func TestConfigTestSuite(t *testing.T) {
+	suite.Run(t, new(ConfigTestSuite))
+}
+
+func (cts *ConfigTestSuite) Test_Defaults_To_Replica_Per_Node() {
+	cts.T().Setenv("CASSANDRA_NODES", "node1, node2")
+
+	config, err := ParseConfig(os.Getenv)
+	cts.Require().NoError(err)
+
+	cts.Equal([]string{"node1", "node2"}, config.Hosts)
+	cts.Equal(gocql.Quorum, config.ReadConsistency)
+	cts.Equal(gocql.Quorum, config.WriteConsistency)
+	cts.Equal(`{'class': 'SimpleStrategy', 'replication_factor': '2'}`, config.replicationOptions())
+	cts.False(config.TLS.Enabled)
+}
+
+func (cts *ConfigTestSuite) Test_Multi_DC() {
+	cts.T().Setenv("CASSANDRA_NODES", "node1")
+	cts.T().Setenv("CASSANDRA_REPLICATION", "eu:3,us:2")
+	cts.T().Setenv("CASSANDRA_LOCAL_DC", "eu")
+	cts.T().Setenv("CASSANDRA_WRITE_CONSISTENCY", "each_quorum")
+	cts.T().Setenv("CASSANDRA_SPECULATIVE_ATTEMPTS", "2")
+	cts.T().Setenv("CASSANDRA_SPECULATIVE_DELAY", "50ms")
+
+	config, err := ParseConfig(os.Getenv)
+	cts.Require().NoError(err)
+
+	cts.Equal(`{'class': 'NetworkTopologyStrategy', 'eu': '3', 'us': '2'}`, config.replicationOptions())
+	cts.Equal(`{'class': 'NetworkTopologyStrategy', 'eu''': '1'}`, Config{Replication: map[string]int{"eu'": 1}}.replicationOptions())
+	cts.Equal(gocql.LocalQuorum, config.ReadConsistency)
+	cts.Equal(gocql.EachQuorum, config.WriteConsistency)
+	cts.Equal(gocql.LocalSerial, config.SerialConsistency)
+	cts.Equal(2, config.SpeculativeAttempts)
+	cts.Equal(50*time.Millisecond, config.SpeculativeDelay)
+}
+
+func (cts *ConfigTestSuite) Test_Invalid() {
+	cts.T().Setenv("CASSANDRA_NODES", "node1")
+
+	for name, value := range map[string]string{
+		"CASSANDRA_REPLICATION":        "eu",
+		"CASSANDRA_READ_CONSISTENCY":   "most",
+		"CASSANDRA_SERIAL_CONSISTENCY": "quorum",
+		"CASSANDRA_RETRIES":            "-1",
+		"CASSANDRA_TIMEOUT":            "soon",
+	} {
+		cts.Run(name, func() {
+			cts.T().Setenv(name, value)
+
+			_, err := ParseConfig(os.Getenv)
+			cts.ErrorContains(err, name)
+		})
+	}
+}
+
+func (cts *ConfigTestSuite) Test_Nodes_Required() {
+	cts.T().Setenv("CASSANDRA_NODES", "")
+
+	_, err := ParseConfig(os.Getenv)
+	cts.Error(err)
+}
@@ -0,0 +1,34 @@
+package cassandra
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Keyspaces & tables are created unquoted, i.e. lowercased, and Cassandra caps their names at 48 characters
+var identifierPattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,47}$`)
+
+// QuoteIdentifier validates the name of a keyspace or table & quotes it, so it can't be mistaken for anything
+// else once put within a statement
+func QuoteIdentifier(name string) (string, error) {
+	if !identifierPattern.MatchString(name) {
+		return "", fmt.Errorf("invalid identifier %q", name)
+	}
+
+	return `"` + name + `"`, nil
+}
+
+// QualifiedTable validates the names of the keyspace & the table, quoting them as `"keyspace"."table"`
+func QualifiedTable(keyspace, table string) (string, error) {
+	quotedKeyspace, err := QuoteIdentifier(keyspace)
+	if err != nil {
+		return "", err
+	}
+
+	quotedTable, err := QuoteIdentifier(table)
+	if err != nil {
+		return "", err
+	}
+
+	return quotedKeyspace + "." + quotedTable, nil
+}
@@ -0,0 +1,49 @@
+package cassandra
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type IdentifierTestSuite struct {
+	suite.Suite
+}
+
+func TestIdentifierTestSuite(t *testing.T) {
+	suite.Run(t, new(IdentifierTestSuite))
+}
+
+func (its *IdentifierTestSuite) Test_Quotes_Valid_Names() {
+	quoted, err := QuoteIdentifier("user_messages2")
+
+	its.NoError(err)
+	its.Equal(`"user_messages2"`, quoted)
+}
+
+func (its *IdentifierTestSuite) Test_Rejects_Invalid_Names() {
+	for _, name := range []string{
+		"",
+		"Users",
+		"2users",
+		"users; DROP TABLE users",
+		`users"`,
+		"chat.users",
+		"a_very_long_table_name_which_goes_past_the_48_chars",
+	} {
+		_, err := QuoteIdentifier(name)
+		its.Error(err, name)
+	}
+}
+
+func (its *IdentifierTestSuite) Test_Qualifies_Tables() {
+	qualified, err := QualifiedTable("chat", "users")
+	its.NoError(err)
+	its.Equal(`"chat"."users"`, qualified)
+
+	_, err = QualifiedTable("chat; DROP KEYSPACE chat", "users")
+	its.Error(err)
+
+	_, err = QualifiedTable("chat", "users WHERE")
+	its.Error(err)
+}
@@ -0,0 +1,157 @@
+package cassandra
+
+import (
+	"chat-system/internal/logging"
+	"chat-system/internal/metrics"
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gocql/gocql"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// Executions taking longer than this get logged along with the name of their statement
+const SLOW_QUERY_THRESHOLD = 200 * time.Millisecond
+
+// QueryLatency sums up the executions of a named statement or batch, retries included
+type QueryLatency struct {
+	Name   string
+	Count  int64
+	Errors int64
+	Total  time.Duration
+	Max    time.Duration
+}
+
+func (l QueryLatency) Mean() time.Duration {
+	if l.Count == 0 {
+		return 0
+	}
+
+	return l.Total / time.Duration(l.Count)
+}
+
+// Latencies records how long statements take by name, as an observer of the queries & batches running them.
+// They're exported as metrics & traced as well.
+type Latencies struct {
+	mu sync.Mutex
+	// Names of the statements by their text, since that's all the query observers are given
+	names     map[string]string
+	latencies map[string]*QueryLatency
+}
+
+func NewLatencies() *Latencies {
+	return &Latencies{
+		names:     make(map[string]string),
+		latencies: make(map[string]*QueryLatency),
+	}
+}
+
+// Name sets the name the executions of the statement are recorded under
+func (l *Latencies) Name(statement, name string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.names[statement] = name
+}
+
+// NameOf returns the name of the statement, or the statement itself if it has none
+func (l *Latencies) NameOf(statement string) string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if name, ok := l.names[statement]; ok {
+		return name
+	}
+	return statement
+}
+
+func (l *Latencies) ObserveQuery(ctx context.Context, query gocql.ObservedQuery) {
+	name := l.NameOf(query.Statement)
+	l.record(ctx, name, query.End.Sub(query.Start), query.Err)
+
+	traceExecution(ctx, execution{
+		name:     name,
+		keyspace: query.Keyspace,
+		host:     query.Host,
+		attempt:  query.Attempt,
+		start:    query.Start,
+		end:      query.End,
+		err:      query.Err,
+	}, semconv.DBQueryText(query.Statement))
+}
+
+// Batch returns an observer recording the executions of a batch under the name
+func (l *Latencies) Batch(name string) gocql.BatchObserver {
+	return batchObserver{latencies: l, name: name}
+}
+
+// Snapshot returns the latencies recorded so far, the ones taking the most time overall first
+func (l *Latencies) Snapshot() []QueryLatency {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	snapshot := make([]QueryLatency, 0, len(l.latencies))
+	for _, latency := range l.latencies {
+		snapshot = append(snapshot, *latency)
+	}
+
+	sort.Slice(snapshot, func(i, j int) bool {
+		if snapshot[i].Total != snapshot[j].Total {
+			return snapshot[i].Total > snapshot[j].Total
+		}
+		return snapshot[i].Name < snapshot[j].Name
+	})
+
+	return snapshot
+}
+
+func (l *Latencies) record(ctx context.Context, name string, elapsed time.Duration, err error) {
+	if elapsed > SLOW_QUERY_THRESHOLD {
+		logging.FromContext(ctx).Warn("Slow cassandra query", "statement", name, "elapsed", elapsed)
+	}
+
+	metrics.CassandraQueryDuration.WithLabelValues(name).Observe(elapsed.Seconds())
+	if err != nil {
+		metrics.CassandraQueryErrors.WithLabelValues(name).Inc()
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	latency, ok := l.latencies[name]
+	if !ok {
+		latency = &QueryLatency{Name: name}
+		l.latencies[name] = latency
+	}
+
+	latency.Count++
+	if err != nil {
+		latency.Errors++
+	}
+	latency.Total += elapsed
+	if elapsed > latency.Max {
+		latency.Max = elapsed
+	}
+}
+
+type batchObserver struct {
+	latencies *Latencies
+	name      string
+}
+
+func (o batchObserver) ObserveBatch(ctx context.Context, batch gocql.ObservedBatch) {
+	o.latencies.record(ctx, o.name, batch.End.Sub(batch.Start), batch.Err)
+
+	traceExecution(ctx, execution{
+		name:     o.name,
+		keyspace: batch.Keyspace,
+		host:     batch.Host,
+		attempt:  batch.Attempt,
+		start:    batch.Start,
+		end:      batch.End,
+		err:      batch.Err,
+	}, attribute.Int("db.cassandra.batch.size", len(batch.Statements)))
+}
@@ -0,0 +1,92 @@
+package cassandra
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/stretchr/testify/suite"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+type LatencyTestSuite struct {
+	suite.Suite
+	spans *tracetest.SpanRecorder
+}
+
+func TestLatencyTestSuite(t *testing.T) {
+	suite.Run(t, new(LatencyTestSuite))
+}
+
+func (lts *LatencyTestSuite) SetupSuite() {
+	lts.spans = tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(lts.spans)))
+}
+
+func (lts *LatencyTestSuite) observe(latencies *Latencies, statement string, elapsed time.Duration, err error) {
+	start := time.Now()
+	latencies.ObserveQuery(context.Background(), gocql.ObservedQuery{
+		Statement: statement,
+		Start:     start,
+		End:       start.Add(elapsed),
+		Err:       err,
+	})
+}
+
+func (lts *LatencyTestSuite) Test_Records_By_Name() {
+	latencies := NewLatencies()
+	latencies.Name(`SELECT * FROM "users" WHERE username = ?`, "users.get")
+
+	lts.observe(latencies, `SELECT * FROM "users" WHERE username = ?`, 2*time.Millisecond, nil)
+	lts.observe(latencies, `SELECT * FROM "users" WHERE username = ?`, 4*time.Millisecond, errors.New("timeout"))
+
+	lts.Equal([]QueryLatency{{
+		Name:   "users.get",
+		Count:  2,
+		Errors: 1,
+		Total:  6 * time.Millisecond,
+		Max:    4 * time.Millisecond,
+	}}, latencies.Snapshot())
+	lts.Equal(3*time.Millisecond, latencies.Snapshot()[0].Mean())
+}
+
+func (lts *LatencyTestSuite) Test_Hottest_First() {
+	latencies := NewLatencies()
+	latencies.Name("statement1", "cold")
+	latencies.Name("statement2", "hot")
+
+	lts.observe(latencies, "statement1", time.Millisecond, nil)
+	lts.observe(latencies, "statement2", time.Millisecond, nil)
+	lts.observe(latencies, "statement2", time.Millisecond, nil)
+
+	start := time.Now()
+	latencies.Batch("batch").ObserveBatch(context.Background(), gocql.ObservedBatch{
+		Start: start,
+		End:   start.Add(5 * time.Millisecond),
+	})
+
+	snapshot := latencies.Snapshot()
+	lts.Require().Len(snapshot, 3)
+	lts.Equal([]string{"batch", "hot", "cold"}, []string{snapshot[0].Name, snapshot[1].Name, snapshot[2].Name})
+}
+
+func (lts *LatencyTestSuite) Test_Traced() {
+	latencies := NewLatencies()
+	latencies.Name("statement", "users.get")
+
+	lts.observe(latencies, "statement", 3*time.Millisecond, errors.New("timeout"))
+
+	spans := lts.spans.Ended()
+	span := spans[len(spans)-1]
+	lts.Equal("cassandra users.get", span.Name())
+	lts.Equal(3*time.Millisecond, span.EndTime().Sub(span.StartTime()))
+	lts.Equal(codes.Error, span.Status().Code)
+	lts.Contains(span.Attributes(), semconv.DBSystemCassandra)
+	lts.Contains(span.Attributes(), semconv.DBQueryText("statement"))
+}
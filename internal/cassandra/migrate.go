@@ -0,0 +1,261 @@
+package cassandra
+
+import (
+	"chat-system/internal/migrations"
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/gocql/gocql"
+)
+
+//go:embed migrations/*.cql
+var migrationFiles embed.FS
+
+const MIGRATIONS_LOCK_TABLE = "schema_migrations_lock"
+const MIGRATIONS_LOCK_NAME = "migrations"
+
+// The lock expires on its own in case its holder dies while migrating, which renews it meanwhile
+// so that migrations taking longer than the TTL don't let another replica migrate concurrently
+const MIGRATIONS_LOCK_TTL = 10 * time.Minute
+const MIGRATIONS_LOCK_RENEW_INTERVAL = MIGRATIONS_LOCK_TTL / 5
+const MIGRATIONS_LOCK_WAIT = time.Minute
+const MIGRATIONS_LOCK_POLL_INTERVAL = time.Second
+
+var (
+	ErrLockTimeout = errors.New("timed out waiting for the migrations lock")
+	ErrLockLost    = errors.New("lost the migrations lock, another replica may be migrating")
+)
+
+// migrationDriver keeps the version of the schema of a keyspace
+type migrationDriver struct {
+	session  *gocql.Session
+	keyspace string
+	owner    string
+	// Tables of the version & of the lock, validated & quoted along with the keyspace
+	versionTable string
+	lockTable    string
+
+	// stopRenewal stops renewing the lock held, renewalDone being closed once it's stopped
+	stopRenewal context.CancelFunc
+	renewalDone chan struct{}
+
+	mu sync.Mutex
+	// lockErr is set once the lock got lost, failing any statement run afterwards
+	lockErr error
+}
+
+// NewMigrator runs the migrations embedded within the binary against the keyspace
+func NewMigrator(ctx context.Context, session *gocql.Session, keyspace string) (*migrations.Migrator, error) {
+	loaded, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+
+	versionTable, err := QualifiedTable(keyspace, migrations.TABLE)
+	if err != nil {
+		return nil, err
+	}
+	lockTable, err := QualifiedTable(keyspace, MIGRATIONS_LOCK_TABLE)
+	if err != nil {
+		return nil, err
+	}
+
+	driver := &migrationDriver{
+		session:      session,
+		keyspace:     keyspace,
+		owner:        gocql.TimeUUID().String(),
+		versionTable: versionTable,
+		lockTable:    lockTable,
+	}
+	if err := driver.ensureTables(ctx); err != nil {
+		return nil, err
+	}
+
+	return migrations.NewMigrator(driver, loaded), nil
+}
+
+// Migrations lists the migrations embedded within the binary, oldest first
+func Migrations() ([]migrations.Migration, error) {
+	return migrations.Load(migrationFiles, "migrations", "cql")
+}
+
+func (d *migrationDriver) Version(ctx context.Context) (int, bool, error) {
+	return SchemaVersion(ctx, d.session, d.keyspace)
+}
+
+// SchemaVersion returns the version the schema of the keyspace is at, without setting the migrations up
+func SchemaVersion(ctx context.Context, session *gocql.Session, keyspace string) (int, bool, error) {
+	versionTable, err := QualifiedTable(keyspace, migrations.TABLE)
+	if err != nil {
+		return migrations.NilVersion, false, err
+	}
+	query := `SELECT version, dirty FROM ` + versionTable + ` LIMIT 1`
+
+	var version int64
+	var dirty bool
+	err = session.Query(query).WithContext(ctx).Scan(&version, &dirty)
+	if errors.Is(err, gocql.ErrNotFound) {
+		return migrations.NilVersion, false, nil
+	}
+	if err != nil {
+		return migrations.NilVersion, false, err
+	}
+
+	return int(version), dirty, nil
+}
+
+// SetVersion keeps a single row within the version table, same as golang-migrate does
+func (d *migrationDriver) SetVersion(ctx context.Context, version int, dirty bool) error {
+	if err := d.lockLost(); err != nil {
+		return err
+	}
+
+	truncate := `TRUNCATE ` + d.versionTable
+	if err := d.session.Query(truncate).WithContext(ctx).Exec(); err != nil {
+		return err
+	}
+
+	if version == migrations.NilVersion && !dirty {
+		return nil
+	}
+
+	insert := `INSERT INTO ` + d.versionTable + ` (version, dirty) VALUES (?, ?)`
+	return d.session.Query(insert, int64(version), dirty).WithContext(ctx).Exec()
+}
+
+// Run executes a single CQL statement
+func (d *migrationDriver) Run(ctx context.Context, statement string) error {
+	if err := d.lockLost(); err != nil {
+		return err
+	}
+
+	return d.session.Query(statement).WithContext(ctx).Exec()
+}
+
+func (d *migrationDriver) ensureTables(ctx context.Context) error {
+	queries := []string{
+		`CREATE TABLE IF NOT EXISTS ` + d.versionTable + ` (version BIGINT, dirty BOOLEAN, PRIMARY KEY (version))`,
+		`CREATE TABLE IF NOT EXISTS ` + d.lockTable + ` (name TEXT PRIMARY KEY, owner TEXT)`,
+	}
+
+	for _, query := range queries {
+		if err := d.session.Query(query).WithContext(ctx).Exec(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Lock takes the lock through a lightweight transaction, so only one replica holds it at a time
+func (d *migrationDriver) Lock(ctx context.Context) error {
+	query := `INSERT INTO ` + d.lockTable + ` (name, owner) VALUES (?, ?) IF NOT EXISTS USING TTL ?`
+
+	deadline := time.Now().Add(MIGRATIONS_LOCK_WAIT)
+	for {
+		existing := make(map[string]interface{})
+		applied, err := d.session.Query(query, MIGRATIONS_LOCK_NAME, d.owner, int(MIGRATIONS_LOCK_TTL.Seconds())).
+			WithContext(ctx).
+			MapScanCAS(existing)
+		if err != nil {
+			return err
+		}
+		if applied {
+			d.startRenewal(ctx)
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%w (held by %v)", ErrLockTimeout, existing["owner"])
+		}
+		slog.Info("Waiting for the migrations lock", "owner", existing["owner"])
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(MIGRATIONS_LOCK_POLL_INTERVAL):
+		}
+	}
+}
+
+// startRenewal renews the lock in the background until it's released or the context is done
+func (d *migrationDriver) startRenewal(ctx context.Context) {
+	d.mu.Lock()
+	d.lockErr = nil
+	d.mu.Unlock()
+
+	ctx, cancel := context.WithCancel(ctx)
+	d.stopRenewal = cancel
+	d.renewalDone = make(chan struct{})
+
+	go func() {
+		defer close(d.renewalDone)
+		d.renew(ctx)
+	}()
+}
+
+// renew extends the TTL of the lock as long as it's still held by this replica. Failing to reach Cassandra is
+// retried on the next tick, the lock outliving a few of them.
+func (d *migrationDriver) renew(ctx context.Context) {
+	query := `UPDATE ` + d.lockTable + ` USING TTL ? SET owner = ? WHERE name = ? IF owner = ?`
+
+	ticker := time.NewTicker(MIGRATIONS_LOCK_RENEW_INTERVAL)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		existing := make(map[string]interface{})
+		applied, err := d.session.Query(query, int(MIGRATIONS_LOCK_TTL.Seconds()), d.owner, MIGRATIONS_LOCK_NAME, d.owner).
+			WithContext(ctx).
+			MapScanCAS(existing)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			slog.Warn("Failed to renew the migrations lock", "error", err)
+			continue
+		}
+		if !applied {
+			d.loseLock(fmt.Errorf("%w (held by %v)", ErrLockLost, existing["owner"]))
+			return
+		}
+	}
+}
+
+func (d *migrationDriver) loseLock(err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	slog.Error("Migrations lock lost", "error", err)
+	d.lockErr = err
+}
+
+// lockLost returns why the lock got lost, nil while it's still held
+func (d *migrationDriver) lockLost() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.lockErr
+}
+
+func (d *migrationDriver) Unlock(ctx context.Context) {
+	if d.stopRenewal != nil {
+		d.stopRenewal()
+		<-d.renewalDone
+		d.stopRenewal = nil
+	}
+
+	query := `DELETE FROM ` + d.lockTable + ` WHERE name = ? IF owner = ?`
+
+	if _, err := d.session.Query(query, MIGRATIONS_LOCK_NAME, d.owner).WithContext(ctx).MapScanCAS(make(map[string]interface{})); err != nil {
+		slog.Error("Failed to release the migrations lock", "expires_in", MIGRATIONS_LOCK_TTL, "error", err)
+	}
+}
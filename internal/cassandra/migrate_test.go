@@ -0,0 +1,45 @@
+package cassandra
+
+import (
+	"chat-system/internal/migrations"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type MigrationsTestSuite struct {
+	suite.Suite
+}
+
+func TestMigrationsTestSuite(t *testing.T) {
+	suite.Run(t, new(MigrationsTestSuite))
+}
+
+func (mts *MigrationsTestSuite) Test_Embedded_Migrations_Are_Sequential() {
+	loaded, err := migrations.Load(migrationFiles, "migrations", "cql")
+	mts.Require().NoError(err)
+	mts.NotEmpty(loaded)
+
+	for i, migration := range loaded {
+		mts.Equal(i+1, migration.Version)
+		mts.NotEmpty(migration.Up)
+		mts.NotEmpty(migration.Down)
+	}
+}
+
+func (mts *MigrationsTestSuite) Test_Nothing_Runs_Once_Lock_Lost() {
+	driver := &migrationDriver{keyspace: "chat", owner: "replica1"}
+	driver.loseLock(ErrLockLost)
+
+	mts.ErrorIs(driver.Run(context.Background(), "ALTER TABLE chat.users ADD email TEXT"), ErrLockLost)
+	mts.ErrorIs(driver.SetVersion(context.Background(), 2, false), ErrLockLost)
+}
+
+func (mts *MigrationsTestSuite) Test_Keyspace_Validated_Before_Querying() {
+	_, err := NewMigrator(context.Background(), nil, "chat; DROP KEYSPACE chat")
+	mts.ErrorContains(err, "invalid identifier")
+
+	_, _, err = SchemaVersion(context.Background(), nil, "Chat")
+	mts.ErrorContains(err, "invalid identifier")
+}
@@ -0,0 +1,47 @@
+package cassandra
+
+import (
+	"chat-system/internal/tracing"
+	"context"
+	"time"
+
+	"github.com/gocql/gocql"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// execution sums up an attempt of a statement or batch, as observed by gocql once it's done
+type execution struct {
+	name     string
+	keyspace string
+	host     *gocql.HostInfo
+	attempt  int
+	start    time.Time
+	end      time.Time
+	err      error
+}
+
+// traceExecution records the attempt as a span of the query context, i.e. of the request running it.
+// Observers only run once the attempt is done, so the span gets backdated to its start.
+func traceExecution(ctx context.Context, e execution, attributes ...attribute.KeyValue) {
+	attributes = append(attributes,
+		semconv.DBSystemCassandra,
+		semconv.DBNamespace(e.keyspace),
+		semconv.DBOperationName(e.name),
+		attribute.Int("db.cassandra.attempt", e.attempt),
+	)
+	if e.host != nil {
+		attributes = append(attributes,
+			semconv.ServerAddress(e.host.ConnectAddress().String()),
+			semconv.DBCassandraCoordinatorDC(e.host.DataCenter()),
+		)
+	}
+
+	_, span := tracing.Tracer.Start(ctx, "cassandra "+e.name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithTimestamp(e.start),
+		trace.WithAttributes(attributes...),
+	)
+	tracing.End(span, e.err, trace.WithTimestamp(e.end))
+}
@@ -0,0 +1,272 @@
+// The purpose of this package is to gather the whole config of the app at startup, out of several sources,
+// then hand each package its own typed config rather than having them read the env on their own.
+
+package config
+
+import (
+	"chat-system/internal/api/auth"
+	"chat-system/internal/api/cache"
+	dbmanager "chat-system/internal/db_manager"
+	"chat-system/internal/logging"
+	"chat-system/internal/tracing"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+const DEFAULT_PORT = 8000
+
+// Metrics are served on a port of their own, which only Prometheus reaches rather than the clients
+const DEFAULT_METRICS_PORT = 9100
+
+// In-flight requests & live sessions are given this long to finish on shutdown, within the 30 seconds
+// orchestrators (e.g Kubernetes, Docker) wait by default before killing the process
+const DEFAULT_SHUTDOWN_TIMEOUT = 25 * time.Second
+
+// Env var naming the config file, unless the `-config` flag does
+const CONFIG_FILE_ENV = "CONFIG_FILE"
+
+// Section is a part of the config, validated only by the binaries relying on it, e.g the backfill has no use for
+// the auth settings
+type Section int
+
+const (
+	SECTION_SERVER Section = iota // The ports & the shutdown timeout
+	SECTION_LOG
+	SECTION_DB
+	SECTION_CACHE
+	SECTION_AUTH
+	SECTION_TRACING
+)
+
+// Sections the API relies on
+var ALL_SECTIONS = []Section{SECTION_SERVER, SECTION_LOG, SECTION_DB, SECTION_CACHE, SECTION_AUTH, SECTION_TRACING}
+
+type Config struct {
+	Port            int
+	MetricsPort     int
+	ShutdownTimeout time.Duration
+	Log             logging.Config
+	DB              dbmanager.Config
+	Cache           cache.Config
+	Auth            auth.Config
+	Tracing         tracing.Config
+
+	// Invalid settings by section, reported once validated
+	errs map[Section][]error
+}
+
+// Load reads the settings out of their defaults, the optional YAML config file, the env vars (`.env` included)
+// & the CLI flags, each one overriding the previous ones. The sections relied on must be validated afterwards.
+// The args left after the flags are returned as well, e.g a subcommand.
+func Load(name string, args []string) (*Config, []string, error) {
+	if err := godotenv.Load(); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, nil, fmt.Errorf("failed to load .env: %w", err)
+	}
+
+	values, rest, err := gather(name, args)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return parse(func(name string) string { return values[name] }), rest, nil
+}
+
+// Validate reports the invalid settings of the sections at once
+func (c *Config) Validate(sections ...Section) error {
+	var errs []error
+	for _, section := range sections {
+		errs = append(errs, c.errs[section]...)
+	}
+
+	return errors.Join(errs...)
+}
+
+// gather merges the settings of every source, keyed by their env var names
+func gather(name string, args []string) (map[string]string, []string, error) {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	configFile := flags.String("config", os.Getenv(CONFIG_FILE_ENV), "YAML config file, also set by `"+CONFIG_FILE_ENV+"`")
+	// Names of the settings by their flags
+	flagSettings := make(map[string]string, len(settings))
+	for _, s := range settings {
+		if secrets[s.name] {
+			continue
+		}
+		flags.String(flagName(s.name), "", s.usage+" (`"+s.name+"`)")
+		flagSettings[flagName(s.name)] = s.name
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	values := make(map[string]string, len(settings))
+
+	if *configFile != "" {
+		fileValues, err := readFile(*configFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		for name, value := range fileValues {
+			values[name] = value
+		}
+	}
+
+	// Empty env vars are left out just like unset ones, e.g the ones left blank within `.env`
+	for _, s := range settings {
+		if value := os.Getenv(s.name); value != "" {
+			values[s.name] = value
+		}
+	}
+
+	flags.Visit(func(f *flag.Flag) {
+		if name, ok := flagSettings[f.Name]; ok {
+			values[name] = f.Value.String()
+		}
+	})
+
+	return values, flags.Args(), nil
+}
+
+// parse hands the settings to the parsers of each package, which apply their own defaults, keeping the invalid
+// settings of each section
+func parse(get func(name string) string) *Config {
+	config := &Config{
+		Port:            DEFAULT_PORT,
+		MetricsPort:     DEFAULT_METRICS_PORT,
+		ShutdownTimeout: DEFAULT_SHUTDOWN_TIMEOUT,
+		errs:            make(map[Section][]error),
+	}
+	invalid := func(section Section, err error) {
+		if err != nil {
+			config.errs[section] = append(config.errs[section], err)
+		}
+	}
+
+	if value := get("APP_PORT"); value != "" {
+		port, err := strconv.Atoi(value)
+		if err != nil || port <= 0 || port > 65535 {
+			invalid(SECTION_SERVER, fmt.Errorf("invalid APP_PORT: %q", value))
+		}
+		config.Port = port
+	}
+
+	if value := get("METRICS_PORT"); value != "" {
+		port, err := strconv.Atoi(value)
+		if err != nil || port <= 0 || port > 65535 {
+			invalid(SECTION_SERVER, fmt.Errorf("invalid METRICS_PORT: %q", value))
+		}
+		config.MetricsPort = port
+	}
+	if config.MetricsPort == config.Port {
+		invalid(SECTION_SERVER, fmt.Errorf("METRICS_PORT must differ from APP_PORT %d", config.Port))
+	}
+
+	if value := get("SHUTDOWN_TIMEOUT"); value != "" {
+		timeout, err := time.ParseDuration(value)
+		if err != nil || timeout <= 0 {
+			invalid(SECTION_SERVER, fmt.Errorf("invalid SHUTDOWN_TIMEOUT: %q", value))
+		}
+		config.ShutdownTimeout = timeout
+	}
+
+	var err error
+	config.Log, err = logging.ParseConfig(get)
+	invalid(SECTION_LOG, err)
+	config.DB, err = dbmanager.ParseConfig(get)
+	invalid(SECTION_DB, err)
+	// Keeping the app data in memory runs without any external dependency, so does the cache unless set otherwise
+	cacheGet := get
+	if config.DB.Storage == dbmanager.STORAGE_MEMORY {
+		cacheGet = withDefault(get, "CACHE_DRIVER", cache.DRIVER_MEMORY)
+	}
+	config.Cache, err = cache.ParseConfig(cacheGet)
+	invalid(SECTION_CACHE, err)
+	config.Auth, err = auth.ParseConfig(get)
+	invalid(SECTION_AUTH, err)
+	config.Tracing, err = tracing.ParseConfig(get)
+	invalid(SECTION_TRACING, err)
+
+	return config
+}
+
+// withDefault falls back to the value for the setting when it's not set
+func withDefault(get func(name string) string, setting, value string) func(name string) string {
+	return func(name string) string {
+		if current := get(name); current != "" || name != setting {
+			return current
+		}
+		return value
+	}
+}
+
+// readFile reads the settings of a YAML file, whose keys are the settings in lowercase, optionally nested
+// by their prefix, e.g `cassandra: {nodes: [node1, node2], tls_ca_file: ca.pem}`
+func readFile(path string) (map[string]string, error) {
+	if ext := filepath.Ext(path); ext != ".yaml" && ext != ".yml" {
+		return nil, fmt.Errorf("config file %s must be a YAML one", path)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var tree map[string]interface{}
+	if err := yaml.Unmarshal(content, &tree); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+
+	values := make(map[string]string)
+	if err := flatten(values, "", tree); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+
+	return values, nil
+}
+
+func flatten(values map[string]string, prefix string, tree map[string]interface{}) error {
+	for key, value := range tree {
+		name := strings.ToUpper(key)
+		if prefix != "" {
+			name = prefix + "_" + name
+		}
+
+		switch value := value.(type) {
+		case map[string]interface{}:
+			if err := flatten(values, name, value); err != nil {
+				return err
+			}
+			continue
+		case []interface{}:
+			items := make([]string, 0, len(value))
+			for _, item := range value {
+				items = append(items, fmt.Sprint(item))
+			}
+			values[name] = strings.Join(items, ",")
+		case nil:
+			values[name] = ""
+		default:
+			values[name] = fmt.Sprint(value)
+		}
+
+		if !isSetting(name) {
+			return fmt.Errorf("unknown setting %q", strings.ToLower(name))
+		}
+	}
+
+	return nil
+}
+
+// flagName names the flag of a setting, e.g `-cassandra-nodes` for `CASSANDRA_NODES`
+func flagName(setting string) string {
+	return strings.ReplaceAll(strings.ToLower(setting), "_", "-")
+}
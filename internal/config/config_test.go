@@ -0,0 +1,191 @@
+package config
+
+import (
+	"chat-system/internal/api/cache"
+	dbmanager "chat-system/internal/db_manager"
+	"chat-system/internal/logging"
+	"chat-system/internal/tracing"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ConfigTestSuite struct {
+	suite.Suite
+	dir string
+}
+
+func TestConfigTestSuite(t *testing.T) {
+	suite.Run(t, new(ConfigTestSuite))
+}
+
+func (cts *ConfigTestSuite) SetupTest() {
+	cts.dir = cts.T().TempDir()
+
+	// Empty env vars count as unset, so the ones of the machine running the tests don't get in the way
+	for _, s := range settings {
+		cts.T().Setenv(s.name, "")
+	}
+	cts.T().Setenv(CONFIG_FILE_ENV, "")
+}
+
+func (cts *ConfigTestSuite) writeFile(name, content string) string {
+	path := filepath.Join(cts.dir, name)
+	cts.Require().NoError(os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+// load loads the config of the API, validating all of it
+func (cts *ConfigTestSuite) load(args []string) (*Config, []string, error) {
+	config, rest, err := Load("chat", args)
+	if err != nil {
+		return nil, nil, err
+	}
+	return config, rest, config.Validate(ALL_SECTIONS...)
+}
+
+func (cts *ConfigTestSuite) Test_Defaults() {
+	cts.T().Setenv("CASSANDRA_NODES", "node1")
+	cts.T().Setenv("JWT_SECRET_KEY", "secret")
+
+	config, args, err := cts.load(nil)
+	cts.Require().NoError(err)
+
+	cts.Empty(args)
+	cts.Equal(DEFAULT_PORT, config.Port)
+	cts.Equal(DEFAULT_METRICS_PORT, config.MetricsPort)
+	cts.Equal(DEFAULT_SHUTDOWN_TIMEOUT, config.ShutdownTimeout)
+	cts.Equal(slog.LevelInfo, config.Log.Level)
+	cts.Equal(logging.FORMAT_JSON, config.Log.Format)
+	cts.Equal(dbmanager.STORAGE_CASSANDRA, config.DB.Storage)
+	cts.Equal([]string{"node1"}, config.DB.Cassandra.Hosts)
+	cts.Equal(cache.DRIVER_REDIS, config.Cache.Driver)
+	cts.Equal("Bearer", config.Auth.HeaderPrefix)
+	cts.Equal(14, config.Auth.BcryptCost)
+	cts.Equal(tracing.EXPORTER_NONE, config.Tracing.Exporter)
+}
+
+func (cts *ConfigTestSuite) Test_Memory_Storage_Caches_In_Memory() {
+	cts.T().Setenv("STORAGE", "memory")
+	cts.T().Setenv("JWT_SECRET_KEY", "secret")
+
+	config, _, err := cts.load(nil)
+	cts.Require().NoError(err)
+	cts.Equal(cache.DRIVER_MEMORY, config.Cache.Driver)
+
+	cts.T().Setenv("CACHE_DRIVER", "redis")
+
+	config, _, err = cts.load(nil)
+	cts.Require().NoError(err)
+	cts.Equal(cache.DRIVER_REDIS, config.Cache.Driver, "Still set otherwise")
+}
+
+func (cts *ConfigTestSuite) Test_File_Then_Env_Then_Flags() {
+	path := cts.writeFile("chat.yaml", `
+app:
+  port: 8001
+storage: cassandra
+cassandra:
+  nodes: [node1, node2]
+  tls_ca_file: ca.pem
+cache:
+  driver: memory
+jwt:
+  secret_key: from-file
+  issuer: from-file
+`)
+	cts.T().Setenv("APP_PORT", "8002")
+	cts.T().Setenv("JWT_ISSUER", "from-env")
+
+	config, args, err := cts.load([]string{"-config", path, "-app-port", "8003", "migrate", "up"})
+	cts.Require().NoError(err)
+
+	cts.Equal([]string{"migrate", "up"}, args)
+	cts.Equal(8003, config.Port)
+	cts.Equal([]string{"node1", "node2"}, config.DB.Cassandra.Hosts)
+	cts.Equal("ca.pem", config.DB.Cassandra.TLS.CAFile)
+	cts.Equal(cache.DRIVER_MEMORY, config.Cache.Driver)
+	cts.Equal([]byte("from-file"), config.Auth.SecretKey)
+	cts.Equal("from-env", config.Auth.Issuer)
+}
+
+func (cts *ConfigTestSuite) Test_File_From_Env() {
+	cts.T().Setenv(CONFIG_FILE_ENV, cts.writeFile("chat.yml", "storage: memory\njwt_secret_key: secret\n"))
+
+	config, _, err := cts.load(nil)
+	cts.Require().NoError(err)
+
+	cts.Equal(dbmanager.STORAGE_MEMORY, config.DB.Storage)
+}
+
+func (cts *ConfigTestSuite) Test_Unknown_File_Setting() {
+	path := cts.writeFile("chat.yaml", "cassandra:\n  nodez: node1\n")
+
+	_, _, err := cts.load([]string{"-config", path})
+
+	cts.ErrorContains(err, `unknown setting "cassandra_nodez"`)
+}
+
+func (cts *ConfigTestSuite) Test_Missing_File() {
+	_, _, err := cts.load([]string{"-config", filepath.Join(cts.dir, "missing.yaml")})
+
+	cts.Error(err)
+}
+
+func (cts *ConfigTestSuite) Test_Reports_All_Errors() {
+	cts.T().Setenv("APP_PORT", "http")
+	cts.T().Setenv("SHUTDOWN_TIMEOUT", "soon")
+	cts.T().Setenv("LOG_LEVEL", "verbose")
+	cts.T().Setenv("CACHE_DRIVER", "memcached")
+	cts.T().Setenv("BCRYPT_COST", "100")
+	cts.T().Setenv("TRACING_SAMPLE_RATIO", "2")
+
+	_, _, err := cts.load(nil)
+
+	cts.Require().Error(err)
+	for _, setting := range []string{"APP_PORT", "SHUTDOWN_TIMEOUT", "LOG_LEVEL", "CASSANDRA_NODES", "cache driver", "BCRYPT_COST", "TRACING_SAMPLE_RATIO"} {
+		cts.ErrorContains(err, setting)
+	}
+}
+
+func (cts *ConfigTestSuite) Test_Metrics_Port_Apart() {
+	cts.T().Setenv("STORAGE", "memory")
+	cts.T().Setenv("JWT_SECRET_KEY", "secret")
+	cts.T().Setenv("APP_PORT", "9100")
+
+	_, _, err := cts.load(nil)
+
+	cts.ErrorContains(err, "METRICS_PORT must differ from APP_PORT")
+}
+
+func (cts *ConfigTestSuite) Test_Secrets_Not_Flags() {
+	for name := range secrets {
+		_, _, err := Load("chat", []string{"-" + flagName(name), "secret"})
+
+		cts.ErrorContains(err, "flag provided but not defined", name)
+	}
+}
+
+func (cts *ConfigTestSuite) Test_Secret_Required() {
+	cts.T().Setenv("STORAGE", "memory")
+
+	_, _, err := cts.load(nil)
+
+	cts.ErrorContains(err, "JWT_SECRET_KEY is required")
+}
+
+func (cts *ConfigTestSuite) Test_Validates_Sections_Relied_On_Only() {
+	cts.T().Setenv("STORAGE", "memory")
+	cts.T().Setenv("BCRYPT_COST", "100")
+
+	config, _, err := Load("backfill", nil)
+	cts.Require().NoError(err)
+
+	cts.NoError(config.Validate(SECTION_LOG, SECTION_DB))
+	err = config.Validate(SECTION_LOG, SECTION_AUTH)
+	cts.ErrorContains(err, "JWT_SECRET_KEY is required")
+	cts.ErrorContains(err, "BCRYPT_COST")
+}
@@ -0,0 +1,76 @@
+package config
+
+type setting struct {
+	name  string
+	usage string
+}
+
+// settings lists every setting of the app by its env var name, which the config file & the flags are named after
+var settings = []setting{
+	{"APP_PORT", "HTTP port"},
+	{"METRICS_PORT", "HTTP port of the metrics, kept off the public one"},
+	{"SHUTDOWN_TIMEOUT", "Time given to in-flight requests & live sessions to finish on shutdown"},
+	{"LOG_LEVEL", "Lowest level logged: debug, info, warn or error"},
+	{"LOG_FORMAT", "Format of the logs: json or text"},
+
+	{"STORAGE", "Storage of the app data: cassandra, postgres or memory"},
+	{"POSTGRES_URL", "Connection string of PostgreSQL"},
+
+	{"CASSANDRA_NODES", "Comma separated Cassandra nodes"},
+	{"CASSANDRA_REPLICATION", "Replicas per data center e.g dc1:3,dc2:3"},
+	{"CASSANDRA_REPLICATION_FACTOR", "Replicas within a single data center"},
+	{"CASSANDRA_LOCAL_DC", "Data center of this replica"},
+	{"CASSANDRA_READ_CONSISTENCY", "Consistency level of reads"},
+	{"CASSANDRA_WRITE_CONSISTENCY", "Consistency level of writes"},
+	{"CASSANDRA_SERIAL_CONSISTENCY", "Consistency level of lightweight transactions"},
+	{"CASSANDRA_USERNAME", "Cassandra username"},
+	{"CASSANDRA_PASSWORD", "Cassandra password"},
+	{"CASSANDRA_TLS", "Connect to Cassandra over TLS"},
+	{"CASSANDRA_TLS_CA_FILE", "CA of the Cassandra nodes"},
+	{"CASSANDRA_TLS_CERT_FILE", "Client cert for Cassandra"},
+	{"CASSANDRA_TLS_KEY_FILE", "Client key for Cassandra"},
+	{"CASSANDRA_TLS_SKIP_VERIFY", "Skip verifying the certs of the Cassandra nodes"},
+	{"CASSANDRA_TIMEOUT", "Timeout of Cassandra queries"},
+	{"CASSANDRA_CONNECT_TIMEOUT", "Timeout of Cassandra connections"},
+	{"CASSANDRA_RETRIES", "Retries of failed Cassandra queries"},
+	{"CASSANDRA_SPECULATIVE_ATTEMPTS", "Extra attempts of slow reads on other replicas"},
+	{"CASSANDRA_SPECULATIVE_DELAY", "Delay before a speculative attempt"},
+
+	{"CACHE_DRIVER", "Cache: redis or memory, the default with STORAGE=memory"},
+	{"REDIS_ADDR", "Redis address"},
+	{"REDIS_DB", "Redis DB"},
+	{"REDIS_PASSWORD", "Redis password"},
+	{"REDIS_TLS", "Connect to Redis over TLS"},
+	{"CACHE_TTL", "Cached messages expire after this long without any write"},
+	{"CACHE_MAX_ENTRIES", "Max number of cached keys of the in-memory cache, counters aside"},
+
+	{"JWT_SECRET_KEY", "Secret signing the tokens with HS256"},
+	{"JWT_KEYS_DIR", "Directory of the asymmetric signing keys"},
+	{"JWT_SIGNING_KEY_ID", "ID of the key signing the tokens"},
+	{"JWT_ISSUER", "Issuer of the tokens"},
+	{"JWT_AUDIENCE", "Audience of the tokens"},
+	{"AUTH_HEADER_PREFIX", "Scheme of the Authorization header"},
+	{"BCRYPT_COST", "Cost of hashing the passwords"},
+
+	{"TRACING_EXPORTER", "Exporter of the traces: none, otlp or stdout"},
+	{"TRACING_OTLP_ENDPOINT", "URL of the OTLP/HTTP collector"},
+	{"TRACING_SAMPLE_RATIO", "Share of the new traces getting sampled, 0 to 1"},
+}
+
+// Secrets only come from the env or the config file, never from flags which any user of the host can read,
+// e.g within `ps` or `/proc/*/cmdline`
+var secrets = map[string]bool{
+	"CASSANDRA_PASSWORD": true,
+	"REDIS_PASSWORD":     true,
+	"JWT_SECRET_KEY":     true,
+}
+
+func isSetting(name string) bool {
+	for _, s := range settings {
+		if s.name == name {
+			return true
+		}
+	}
+
+	return false
+}
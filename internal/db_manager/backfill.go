@@ -0,0 +1,90 @@
+package dbmanager
+
+import (
+	"chat-system/internal/cassandra"
+	"chat-system/internal/models"
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/gocql/gocql"
+)
+
+const BACKFILL_PAGE_SIZE = 500
+const BACKFILL_LOG_EVERY = 10000
+
+// BackfillMessageBuckets copies the messages of the legacy table into their monthly buckets.
+// Rows keep their original write time, so edits or deletions made meanwhile through the bucketed table win.
+// Copying is idempotent, so it's safe to run it again after being interrupted, e.g by cancelling the context.
+func BackfillMessageBuckets(ctx context.Context, session *gocql.Session) (int, error) {
+	tables := make(map[string]string, 3)
+	for _, table := range []string{LEGACY_MSGS_TABLE, MSGS_TABLE, MSG_BUCKETS_TABLE} {
+		qualified, err := cassandra.QualifiedTable(CASSANDRA_KEYSPACE, table)
+		if err != nil {
+			return 0, err
+		}
+		tables[table] = qualified
+	}
+
+	iter := session.Query(
+		`SELECT user, timestamp, id, sender, recipient, content, group_id, edited_at, WRITETIME(content) FROM ` + tables[LEGACY_MSGS_TABLE],
+	).WithContext(ctx).PageSize(BACKFILL_PAGE_SIZE).Iter()
+
+	insertBucket := `INSERT INTO ` + tables[MSG_BUCKETS_TABLE] + ` (user, bucket) VALUES (?, ?)`
+	// The buckets of a user are usually written by consecutive rows of the same partition
+	seenBuckets := make(map[string]int)
+
+	var (
+		user, sender, recipient, content string
+		timestamp                        time.Time
+		id                               gocql.UUID
+		groupID                          *gocql.UUID
+		editedAt                         *time.Time
+		writeTime                        int64
+	)
+	copied := 0
+	for iter.Scan(&user, &timestamp, &id, &sender, &recipient, &content, &groupID, &editedAt, &writeTime) {
+		bucket := models.BucketOf(timestamp)
+
+		query, values := backfillInsert(tables[MSGS_TABLE], user, bucket, timestamp, id, sender, recipient, content, groupID, editedAt)
+		if err := cassandra.Write(session.Query(query, append(values, writeTime)...)).WithContext(ctx).Exec(); err != nil {
+			iter.Close()
+			return copied, err
+		}
+
+		if seen, ok := seenBuckets[user]; !ok || seen != bucket {
+			if err := cassandra.Write(session.Query(insertBucket, user, bucket)).WithContext(ctx).Exec(); err != nil {
+				iter.Close()
+				return copied, err
+			}
+			seenBuckets[user] = bucket
+		}
+
+		copied++
+		if copied%BACKFILL_LOG_EVERY == 0 {
+			slog.Info("Backfilling messages", "copied", copied)
+		}
+	}
+
+	return copied, iter.Close()
+}
+
+// backfillInsert leaves the unset columns out, so that copying doesn't write tombstones for them
+func backfillInsert(table, user string, bucket int, timestamp time.Time, id gocql.UUID, sender, recipient, content string, groupID *gocql.UUID, editedAt *time.Time) (string, []interface{}) {
+	columns := "user, bucket, timestamp, id, sender, recipient, content"
+	placeholders := "?, ?, ?, ?, ?, ?, ?"
+	values := []interface{}{user, bucket, timestamp, id, sender, recipient, content}
+
+	if groupID != nil {
+		columns += ", group_id"
+		placeholders += ", ?"
+		values = append(values, *groupID)
+	}
+	if editedAt != nil {
+		columns += ", edited_at"
+		placeholders += ", ?"
+		values = append(values, *editedAt)
+	}
+
+	return `INSERT INTO ` + table + ` (` + columns + `) VALUES (` + placeholders + `) USING TIMESTAMP ?`, values
+}
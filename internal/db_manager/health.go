@@ -0,0 +1,58 @@
+package dbmanager
+
+import (
+	"chat-system/internal/cassandra"
+	"chat-system/internal/health"
+	"chat-system/internal/migrations"
+	"chat-system/internal/postgres"
+	"context"
+)
+
+// Name of the check of the schema, next to the one of the storage
+const MIGRATIONS_CHECK = "migrations"
+
+// HealthChecks check the storage the app data is kept in answers & its schema is migrated,
+// none being needed for the data kept in memory
+func (db *DB) HealthChecks() []health.Check {
+	switch db.Storage {
+	case STORAGE_POSTGRES:
+		return []health.Check{
+			{Name: STORAGE_POSTGRES, Run: db.PostgresPool.Ping},
+			{Name: MIGRATIONS_CHECK, Run: checkSchema(postgres.Migrations, func(ctx context.Context) (int, bool, error) {
+				return postgres.SchemaVersion(ctx, db.PostgresPool)
+			})},
+		}
+	case STORAGE_MEMORY:
+		return nil
+	}
+
+	return []health.Check{
+		{Name: STORAGE_CASSANDRA, Run: func(ctx context.Context) error {
+			return cassandra.Ping(ctx, db.CassandraSession)
+		}},
+		{Name: MIGRATIONS_CHECK, Run: checkSchema(cassandra.Migrations, func(ctx context.Context) (int, bool, error) {
+			return cassandra.SchemaVersion(ctx, db.CassandraSession, CASSANDRA_KEYSPACE)
+		})},
+	}
+}
+
+// checkSchema fails until the schema is at the last of the migrations embedded within the binary, which are
+// loaded once rather than on each probe
+func checkSchema(
+	load func() ([]migrations.Migration, error),
+	schemaVersion func(ctx context.Context) (int, bool, error),
+) func(ctx context.Context) error {
+	loaded, loadErr := load()
+
+	return func(ctx context.Context) error {
+		if loadErr != nil {
+			return loadErr
+		}
+
+		version, dirty, err := schemaVersion(ctx)
+		if err != nil {
+			return err
+		}
+		return migrations.CheckVersion(version, dirty, loaded)
+	}
+}
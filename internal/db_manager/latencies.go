@@ -0,0 +1,46 @@
+package dbmanager
+
+import (
+	"chat-system/internal/repositories"
+	"context"
+	"log/slog"
+	"time"
+)
+
+// The Cassandra statements taking the most time overall are logged this often, so the hot paths stand out
+const LATENCIES_LOG_INTERVAL = 5 * time.Minute
+const LATENCIES_LOG_TOP = 10
+
+// logLatencies logs the latencies of the statements taking the most time overall until the context is done
+func logLatencies(ctx context.Context, queries *repositories.CassandraQueries) {
+	ticker := time.NewTicker(LATENCIES_LOG_INTERVAL)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		latencies := queries.Latencies()
+		if len(latencies) == 0 {
+			continue
+		}
+		if len(latencies) > LATENCIES_LOG_TOP {
+			latencies = latencies[:LATENCIES_LOG_TOP]
+		}
+
+		attrs := make([]any, 0, len(latencies))
+		for _, latency := range latencies {
+			attrs = append(attrs, slog.Group(latency.Name,
+				"count", latency.Count,
+				"errors", latency.Errors,
+				"total", latency.Total,
+				"mean", latency.Mean(),
+				"max", latency.Max,
+			))
+		}
+		slog.Info("Cassandra statements taking the most time", attrs...)
+	}
+}
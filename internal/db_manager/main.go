@@ -1,38 +1,157 @@
 // The purpose of this package is to handle several different DB connections.
-// e.g PostgreSQL for configs, Cassandra for app data, ES for app usage behaviors and Data-Lakes, etc
+// e.g Cassandra or PostgreSQL for app data, ES for app usage behaviors and Data-Lakes, etc
 // Many different DB engines can be employed for diff purposes, where each one is best suited for some data nature
 
 package dbmanager
 
 import (
 	"chat-system/internal/cassandra"
-	"log"
-	"os"
-	"strings"
+	"chat-system/internal/logging"
+	"chat-system/internal/postgres"
+	"chat-system/internal/repositories"
+	"context"
+	"fmt"
+	"log/slog"
 
 	"github.com/gocql/gocql"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 const CASSANDRA_KEYSPACE = "chat"
 const USERS_TABLE = "users"
-const MSGS_TABLE = "messages"
+const MSGS_TABLE = "user_messages"
+const MSG_BUCKETS_TABLE = "user_message_buckets"
 
-var CassandraSession *gocql.Session
+// Messages of users used to live in a single partition per user, before being bucketed by month
+const LEGACY_MSGS_TABLE = "messages"
+const CONVERSATIONS_TABLE = "conversations"
+const CONVERSATION_MSGS_TABLE = "conversation_messages"
+const GROUPS_TABLE = "groups"
+const GROUP_MEMBERS_TABLE = "group_members"
+const GROUP_MSGS_TABLE = "group_messages"
+
+// Storages the app data can be kept in, picked by `STORAGE`
+const STORAGE_CASSANDRA = "cassandra"
+const STORAGE_POSTGRES = "postgres"
+
+// Keeps the data within the process, which only fits a single replica & loses everything on restart
+const STORAGE_MEMORY = "memory"
+
+// DB holds the connections to the storage the app data is kept in, which the repositories are built upon
+type DB struct {
+	// Storage is where the app data is kept, which decides the repositories of the services
+	Storage          string
+	CassandraSession *gocql.Session
+	// CassandraQueries holds the prepared statements of the Cassandra repositories along with their latencies
+	CassandraQueries *repositories.CassandraQueries
+	PostgresPool     *pgxpool.Pool
+	MemoryStore      *repositories.MemoryStore
+
+	// stopLatencies stops logging the latencies of the Cassandra queries
+	stopLatencies context.CancelFunc
+}
+
+// Config of the storage the app data is kept in, along with the one of its engine only
+type Config struct {
+	Storage   string
+	Cassandra cassandra.Config
+	Postgres  *pgxpool.Config
+}
+
+// ParseConfig reads the storage picked by `STORAGE` (Cassandra by default) out of the settings,
+// along with the config of its engine
+func ParseConfig(get func(name string) string) (Config, error) {
+	config := Config{Storage: get("STORAGE")}
 
-func InitDB() *gocql.Session {
 	var err error
-	CassandraSession, err = setupCassandra()
+	switch config.Storage {
+	case "", STORAGE_CASSANDRA:
+		config.Storage = STORAGE_CASSANDRA
+		config.Cassandra, err = cassandra.ParseConfig(get)
+	case STORAGE_POSTGRES:
+		config.Postgres, err = postgres.ParseConfig(get)
+	case STORAGE_MEMORY:
+	default:
+		err = fmt.Errorf("unknown STORAGE %q, expected %s, %s or %s", config.Storage, STORAGE_CASSANDRA, STORAGE_POSTGRES, STORAGE_MEMORY)
+	}
+
+	return config, err
+}
+
+// Open connects to the storage of the config, giving up once the context is done
+func Open(ctx context.Context, config Config) *DB {
+	db := &DB{Storage: config.Storage}
+
+	var err error
+	switch db.Storage {
+	case STORAGE_POSTGRES:
+		db.PostgresPool, err = postgres.Init(ctx, config.Postgres)
+		if err != nil {
+			logging.Fatal("Failed to set PostgreSQL up", "error", err)
+		}
+	case STORAGE_MEMORY:
+		db.MemoryStore = repositories.NewMemoryStore()
+		slog.Warn("App data is kept in memory, which only fits a single replica & is lost on restart")
+	default:
+		db.CassandraSession = InitCassandra(config.Cassandra)
+
+		db.CassandraQueries, err = setupCassandraQueries(db.CassandraSession)
+		if err != nil {
+			logging.Fatal("Failed to build the Cassandra queries", "error", err)
+		}
+
+		var latenciesCtx context.Context
+		latenciesCtx, db.stopLatencies = context.WithCancel(ctx)
+		go logLatencies(latenciesCtx, db.CassandraQueries)
+	}
+
+	return db
+}
+
+// InitCassandra connects to Cassandra whichever the storage is, e.g for tools only meant for it
+func InitCassandra(config cassandra.Config) *gocql.Session {
+	session, err := cassandra.Init(CASSANDRA_KEYSPACE, config)
 	if err != nil {
-		log.Fatalf("error while setting up cassandra: %v", err)
+		logging.Fatal("Failed to set Cassandra up", "error", err)
 	}
 
-	return CassandraSession
+	return session
+}
+
+// Close closes the connections to whichever storage got set up
+func (db *DB) Close() {
+	if db.stopLatencies != nil {
+		db.stopLatencies()
+	}
+	if db.CassandraSession != nil {
+		db.CassandraSession.Close()
+	}
+	if db.PostgresPool != nil {
+		db.PostgresPool.Close()
+	}
 }
 
-func setupCassandra() (*gocql.Session, error) {
-	cassandraNodes := os.Getenv("CASSANDRA_NODES")
-	clusterAddresses := strings.Split(cassandraNodes, ",")
-	replicaCount := len(clusterAddresses)
+// setupCassandraQueries builds & prepares the statements of the repositories
+func setupCassandraQueries(session *gocql.Session) (*repositories.CassandraQueries, error) {
+	queries, err := repositories.NewCassandraQueries(session, repositories.CassandraTables{
+		Keyspace:             CASSANDRA_KEYSPACE,
+		Users:                USERS_TABLE,
+		Messages:             MSGS_TABLE,
+		MessageBuckets:       MSG_BUCKETS_TABLE,
+		Conversations:        CONVERSATIONS_TABLE,
+		ConversationMessages: CONVERSATION_MSGS_TABLE,
+		Groups:               GROUPS_TABLE,
+		GroupMembers:         GROUP_MEMBERS_TABLE,
+		GroupMessages:        GROUP_MSGS_TABLE,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The schema may not be migrated yet on a fresh setup, in which case statements get prepared on their first use
+	if err := queries.Prepare(); err != nil {
+		slog.Warn("Unable to prepare the Cassandra queries, is the schema migrated?", "error", err)
+	}
 
-	return cassandra.Init(CASSANDRA_KEYSPACE, replicaCount, clusterAddresses...)
+	return queries, nil
 }
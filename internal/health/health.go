@@ -0,0 +1,92 @@
+// The purpose of this package is to tell whether a replica is ready to serve traffic, by checking each of the
+// dependencies it relies on (e.g the DB, the cache) within a deadline, so that a dependency hanging can't hang the
+// probes of the orchestrator or the load balancer as well
+
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	STATUS_UP   = "up"
+	STATUS_DOWN = "down"
+
+	STATUS_READY     = "ready"
+	STATUS_NOT_READY = "not_ready"
+)
+
+// Time given to each check, below the timeout of the probes calling them
+const CHECK_TIMEOUT = 2 * time.Second
+
+// Check tells whether a dependency can be relied on, failing if it doesn't answer before the context is done
+type Check struct {
+	Name string
+	Run  func(ctx context.Context) error
+}
+
+type Result struct {
+	Status    string `json:"status"`
+	LatencyMS int64  `json:"latency_ms"`
+	Error     string `json:"error,omitempty"`
+}
+
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks"`
+}
+
+func (r Report) Ready() bool {
+	return r.Status == STATUS_READY
+}
+
+// Run runs the checks concurrently, each within the timeout. The report is ready only if all of them passed.
+func Run(ctx context.Context, checks []Check, timeout time.Duration) Report {
+	report := Report{Status: STATUS_READY, Checks: make(map[string]Result, len(checks))}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, check := range checks {
+		wg.Add(1)
+		go func(check Check) {
+			defer wg.Done()
+			result := run(ctx, check, timeout)
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[check.Name] = result
+			if result.Status != STATUS_UP {
+				report.Status = STATUS_NOT_READY
+			}
+		}(check)
+	}
+	wg.Wait()
+
+	return report
+}
+
+// run fails the check once the timeout is up, even if the dependency doesn't honour the context
+func run(ctx context.Context, check Check, timeout time.Duration) Result {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() { done <- check.Run(ctx) }()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	result := Result{Status: STATUS_UP, LatencyMS: time.Since(start).Milliseconds()}
+	if err != nil {
+		result.Status = STATUS_DOWN
+		result.Error = err.Error()
+	}
+	return result
+}
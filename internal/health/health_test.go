@@ -0,0 +1,59 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type HealthTestSuite struct {
+	suite.Suite
+}
+
+func TestHealthTestSuite(t *testing.T) {
+	suite.Run(t, new(HealthTestSuite))
+}
+
+func up(name string) Check {
+	return Check{Name: name, Run: func(ctx context.Context) error { return nil }}
+}
+
+func (hts *HealthTestSuite) Test_Ready_When_All_Up() {
+	report := Run(context.Background(), []Check{up("cassandra"), up("redis")}, time.Second)
+
+	hts.True(report.Ready())
+	hts.Equal(STATUS_UP, report.Checks["cassandra"].Status)
+	hts.Equal(STATUS_UP, report.Checks["redis"].Status)
+}
+
+func (hts *HealthTestSuite) Test_Not_Ready_When_Any_Down() {
+	down := Check{Name: "redis", Run: func(ctx context.Context) error { return errors.New("connection refused") }}
+
+	report := Run(context.Background(), []Check{up("cassandra"), down}, time.Second)
+
+	hts.False(report.Ready())
+	hts.Equal(STATUS_NOT_READY, report.Status)
+	hts.Equal(STATUS_UP, report.Checks["cassandra"].Status)
+	hts.Equal(Result{Status: STATUS_DOWN, LatencyMS: report.Checks["redis"].LatencyMS, Error: "connection refused"}, report.Checks["redis"])
+}
+
+func (hts *HealthTestSuite) Test_Times_Out_Hanging_Checks() {
+	hanging := Check{Name: "cassandra", Run: func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	}}
+
+	start := time.Now()
+	report := Run(context.Background(), []Check{hanging}, 10*time.Millisecond)
+
+	hts.Less(time.Since(start), 500*time.Millisecond)
+	hts.False(report.Ready())
+	hts.Equal(context.DeadlineExceeded.Error(), report.Checks["cassandra"].Error)
+}
+
+func (hts *HealthTestSuite) Test_Ready_Without_Checks() {
+	hts.True(Run(context.Background(), nil, time.Second).Ready())
+}
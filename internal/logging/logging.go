@@ -0,0 +1,112 @@
+// The purpose of this package is to log structured lines (JSON by default) through `log/slog`, which Promtail
+// parses into fields. Loggers of the requests carry their request & trace IDs, so their lines can be correlated,
+// while secrets & message contents are redacted from every line.
+
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+const (
+	FORMAT_JSON = "json"
+	FORMAT_TEXT = "text"
+)
+
+// Value of the redacted attributes
+const REDACTED = "[REDACTED]"
+
+// Keys of the attributes never logged as-is, whatever group they're in
+var redactedKeys = map[string]struct{}{
+	"password":      {},
+	"content":       {},
+	"token":         {},
+	"access_token":  {},
+	"refresh_token": {},
+	"authorization": {},
+	"secret":        {},
+}
+
+type Config struct {
+	Level  slog.Level
+	Format string
+}
+
+// ParseConfig reads the config of the logs out of the settings, i.e. `LOG_LEVEL` (debug, info, warn or error)
+// & `LOG_FORMAT` (json or text), falling back to info & json
+func ParseConfig(get func(name string) string) (Config, error) {
+	config := Config{
+		Level:  slog.LevelInfo,
+		Format: FORMAT_JSON,
+	}
+
+	if value := get("LOG_LEVEL"); value != "" {
+		if err := config.Level.UnmarshalText([]byte(value)); err != nil {
+			return config, fmt.Errorf("invalid LOG_LEVEL: %q, expected debug, info, warn or error", value)
+		}
+	}
+
+	if format := get("LOG_FORMAT"); format != "" {
+		if format != FORMAT_JSON && format != FORMAT_TEXT {
+			return config, fmt.Errorf("unknown log format %q", format)
+		}
+		config.Format = format
+	}
+
+	return config, nil
+}
+
+// Init logs through slog from now on, the lines of the `log` package included
+func Init(config Config) {
+	slog.SetDefault(slog.New(NewHandler(os.Stderr, config)))
+}
+
+// NewHandler writes the lines at or above the level of the config to w, redacting the sensitive attributes
+func NewHandler(w io.Writer, config Config) slog.Handler {
+	options := &slog.HandlerOptions{
+		Level:       config.Level,
+		ReplaceAttr: replaceAttr,
+	}
+
+	if config.Format == FORMAT_TEXT {
+		return slog.NewTextHandler(w, options)
+	}
+	return slog.NewJSONHandler(w, options)
+}
+
+// replaceAttr redacts the sensitive attributes & writes durations the way they're configured, e.g `25s`
+func replaceAttr(_ []string, attr slog.Attr) slog.Attr {
+	if _, ok := redactedKeys[strings.ToLower(attr.Key)]; ok {
+		return slog.String(attr.Key, REDACTED)
+	}
+	if attr.Value.Kind() == slog.KindDuration {
+		return slog.String(attr.Key, attr.Value.Duration().String())
+	}
+	return attr
+}
+
+type ctxLoggerKey struct{}
+
+// WithLogger returns a copy of the context carrying the logger, e.g the one of a request
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, ctxLoggerKey{}, logger)
+}
+
+// FromContext returns the logger carried by the context, or the default one if none
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(ctxLoggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// Fatal logs the error & exits, e.g when the app can't start
+func Fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
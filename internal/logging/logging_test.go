@@ -0,0 +1,105 @@
+package logging
+
+import (
+	"bytes"
+	"chat-system/internal/models"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/stretchr/testify/suite"
+)
+
+type LoggingTestSuite struct {
+	suite.Suite
+	out    *bytes.Buffer
+	logger *slog.Logger
+}
+
+func TestLoggingTestSuite(t *testing.T) {
+	suite.Run(t, new(LoggingTestSuite))
+}
+
+func (lts *LoggingTestSuite) SetupTest() {
+	lts.out = &bytes.Buffer{}
+	lts.logger = slog.New(NewHandler(lts.out, Config{Level: slog.LevelInfo, Format: FORMAT_JSON}))
+}
+
+func (lts *LoggingTestSuite) line() map[string]interface{} {
+	var line map[string]interface{}
+	lts.Require().NoError(json.Unmarshal(lts.out.Bytes(), &line))
+	return line
+}
+
+func settings(values map[string]string) func(name string) string {
+	return func(name string) string { return values[name] }
+}
+
+func (lts *LoggingTestSuite) Test_Config() {
+	config, err := ParseConfig(settings(nil))
+	lts.Require().NoError(err)
+	lts.Equal(Config{Level: slog.LevelInfo, Format: FORMAT_JSON}, config)
+
+	config, err = ParseConfig(settings(map[string]string{"LOG_LEVEL": "debug", "LOG_FORMAT": "text"}))
+	lts.Require().NoError(err)
+	lts.Equal(Config{Level: slog.LevelDebug, Format: FORMAT_TEXT}, config)
+
+	_, err = ParseConfig(settings(map[string]string{"LOG_LEVEL": "verbose"}))
+	lts.ErrorContains(err, "LOG_LEVEL")
+	_, err = ParseConfig(settings(map[string]string{"LOG_FORMAT": "xml"}))
+	lts.Error(err)
+}
+
+func (lts *LoggingTestSuite) Test_Level() {
+	lts.logger.Debug("hidden")
+	lts.Empty(lts.out.String())
+
+	lts.logger.Info("shown")
+	lts.Equal("shown", lts.line()["msg"])
+}
+
+func (lts *LoggingTestSuite) Test_Redacts_Sensitive_Keys() {
+	lts.logger.Info("test", "Password", "secret1", slog.Group("input", "content", "hello", "user", "User1"))
+
+	line := lts.line()
+	lts.Equal(REDACTED, line["Password"])
+	lts.Equal(map[string]interface{}{"content": REDACTED, "user": "User1"}, line["input"])
+	lts.NotContains(lts.out.String(), "secret1")
+	lts.NotContains(lts.out.String(), "hello")
+}
+
+func (lts *LoggingTestSuite) Test_Models_Log_Identifiers_Only() {
+	groupID := gocql.TimeUUID()
+	message := &models.Message{ID: gocql.TimeUUID(), Sender: "User1", Recipient: "User2", Content: "hello", GroupID: &groupID}
+
+	lts.logger.Info("test", "message", message, "login", models.LoginInput{Username: "User1", Password: "secret1"})
+
+	line := lts.line()
+	lts.Equal(map[string]interface{}{
+		"id":        message.ID.String(),
+		"sender":    "User1",
+		"recipient": "User2",
+		"group_id":  groupID.String(),
+	}, line["message"])
+	lts.Equal(map[string]interface{}{"username": "User1"}, line["login"])
+	lts.NotContains(lts.out.String(), "hello")
+	lts.NotContains(lts.out.String(), "secret1")
+}
+
+func (lts *LoggingTestSuite) Test_Durations_As_Strings() {
+	lts.logger.Info("test", "elapsed", 1500*time.Millisecond)
+
+	lts.Equal("1.5s", lts.line()["elapsed"])
+}
+
+func (lts *LoggingTestSuite) Test_Logger_From_Context() {
+	lts.Equal(slog.Default(), FromContext(context.Background()))
+
+	ctx := WithLogger(context.Background(), lts.logger.With("request_id", "1"))
+	FromContext(ctx).Info("test")
+
+	lts.Equal("1", lts.line()["request_id"])
+}
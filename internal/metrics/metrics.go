@@ -0,0 +1,106 @@
+// The purpose of this package is to hold the Prometheus metrics of the service, served on `/metrics`.
+// Labels only ever take values out of a fixed set (route templates, statement names, etc...) rather than
+// anything coming from the clients (usernames, raw paths, IDs), so that the number of series stays bounded.
+
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const NAMESPACE = "chat"
+
+// Values of the labels
+const (
+	CACHE_HIT   = "hit"
+	CACHE_MISS  = "miss"
+	CACHE_ERROR = "error"
+
+	BCRYPT_HASH    = "hash"
+	BCRYPT_COMPARE = "compare"
+
+	TRANSPORT_WEBSOCKET = "websocket"
+	TRANSPORT_SSE       = "sse"
+
+	MESSAGE_DIRECT = "direct"
+	MESSAGE_GROUP  = "group"
+)
+
+var (
+	// HTTPRequests counts the handled requests by route template (e.g `/api/v1/messages/{id}`), method & status code
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: NAMESPACE,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "Handled HTTP requests by route template, method & status code.",
+	}, []string{"route", "method", "code"})
+
+	// HTTPRequestDuration observes how long requests take by route template & method.
+	// Live requests last as long as their sessions, which LiveSessions keeps track of instead.
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: NAMESPACE,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "Time taken to handle HTTP requests by route template & method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method"})
+
+	// HTTPConnections is the number of open client connections, until they get closed or hijacked (e.g WebSockets)
+	HTTPConnections = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: NAMESPACE,
+		Subsystem: "http",
+		Name:      "connections",
+		Help:      "Open HTTP connections, WebSockets excluded.",
+	})
+
+	// LiveSessions is the number of live sessions connected to the current replica by transport
+	LiveSessions = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: NAMESPACE,
+		Subsystem: "live",
+		Name:      "sessions",
+		Help:      "Live sessions connected to the replica by transport.",
+	}, []string{"transport"})
+
+	// CacheReads counts the pages of messages looked up within the cache by result (hit, miss or error)
+	CacheReads = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: NAMESPACE,
+		Subsystem: "cache",
+		Name:      "reads_total",
+		Help:      "Pages of messages looked up within the cache by result.",
+	}, []string{"result"})
+
+	// CassandraQueryDuration observes how long statements & batches take by name, retries included
+	CassandraQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: NAMESPACE,
+		Subsystem: "cassandra",
+		Name:      "query_duration_seconds",
+		Help:      "Time taken by Cassandra statements & batches by name, retries included.",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .2, .5, 1, 2.5},
+	}, []string{"statement"})
+
+	// CassandraQueryErrors counts the failed executions of statements & batches by name
+	CassandraQueryErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: NAMESPACE,
+		Subsystem: "cassandra",
+		Name:      "query_errors_total",
+		Help:      "Failed executions of Cassandra statements & batches by name.",
+	}, []string{"statement"})
+
+	// BcryptDuration observes how long hashing passwords & comparing them to their hashes take
+	BcryptDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: NAMESPACE,
+		Subsystem: "bcrypt",
+		Name:      "duration_seconds",
+		Help:      "Time taken to hash passwords or compare them to their hashes.",
+		Buckets:   []float64{.01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+	}, []string{"operation"})
+
+	// MessagesSent counts the messages stored by kind (direct or group)
+	MessagesSent = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: NAMESPACE,
+		Subsystem: "messages",
+		Name:      "sent_total",
+		Help:      "Messages sent by kind.",
+	}, []string{"kind"})
+)
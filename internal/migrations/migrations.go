@@ -0,0 +1,252 @@
+// The purpose of this package is to run the schema migrations of any of the DB engines the same way,
+// each engine providing a Driver keeping the version of its schema
+
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The version table is the one golang-migrate keeps, so either tool can pick up where the other left off
+const TABLE = "schema_migrations"
+
+// NilVersion is the version of a schema without any migration applied
+const NilVersion = -1
+
+var ErrDirty = errors.New("schema is dirty, fix it manually then force the version it's at")
+var ErrPending = errors.New("schema is behind the migrations")
+
+var fileName = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.([a-z]+)$`)
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Driver keeps the version of a schema & runs statements against it, giving up once the context is done
+type Driver interface {
+	// Lock waits for other replicas migrating meanwhile to finish first
+	Lock(ctx context.Context) error
+	Unlock(ctx context.Context)
+	// Version returns the version the schema is at, and whether a migration to it failed halfway
+	Version(ctx context.Context) (int, bool, error)
+	SetVersion(ctx context.Context, version int, dirty bool) error
+	Run(ctx context.Context, statement string) error
+}
+
+type Migrator struct {
+	driver     Driver
+	migrations []Migration
+}
+
+func NewMigrator(driver Driver, migrations []Migration) *Migrator {
+	return &Migrator{driver: driver, migrations: migrations}
+}
+
+// Load reads the `<version>_<name>.<up|down>.<ext>` files of the directory, sorted by version
+func Load(fsys fs.FS, dir, ext string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		parts := fileName.FindStringSubmatch(entry.Name())
+		if parts == nil || parts[4] != ext {
+			continue
+		}
+
+		version, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %s: %w", entry.Name(), err)
+		}
+		content, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: parts[2]}
+			byVersion[version] = migration
+		}
+		if parts[3] == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if strings.TrimSpace(migration.Up) == "" || strings.TrimSpace(migration.Down) == "" {
+			return nil, fmt.Errorf("migration %d_%s is missing its up or down file", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+// Migrations lists all the known migrations, oldest first
+func (m *Migrator) Migrations() []Migration {
+	return m.migrations
+}
+
+// Latest returns the version of the last of the migrations, NilVersion if none
+func Latest(migrations []Migration) int {
+	if len(migrations) == 0 {
+		return NilVersion
+	}
+	return migrations[len(migrations)-1].Version
+}
+
+// CheckVersion fails if the schema is dirty or behind the last of the migrations. A schema ahead of them is fine,
+// as replicas still running the previous release meet the schema of the next one while it rolls out.
+func CheckVersion(version int, dirty bool, migrations []Migration) error {
+	if dirty {
+		return fmt.Errorf("%w (version %d)", ErrDirty, version)
+	}
+	if latest := Latest(migrations); version < latest {
+		return fmt.Errorf("%w (version %d, expected %d)", ErrPending, version, latest)
+	}
+	return nil
+}
+
+func (m *Migrator) Version(ctx context.Context) (int, bool, error) {
+	return m.driver.Version(ctx)
+}
+
+// Up applies the next steps pending migrations, all of them if steps isn't positive
+func (m *Migrator) Up(ctx context.Context, steps int) error {
+	return m.withLock(ctx, func() error {
+		version, err := m.cleanVersion(ctx)
+		if err != nil {
+			return err
+		}
+
+		var pending []Migration
+		for _, migration := range m.migrations {
+			if migration.Version > version {
+				pending = append(pending, migration)
+			}
+		}
+		if steps > 0 && steps < len(pending) {
+			pending = pending[:steps]
+		}
+
+		for _, migration := range pending {
+			slog.Info("Applying migration", "version", migration.Version, "name", migration.Name)
+			if err := m.run(ctx, migration.Up, migration.Version); err != nil {
+				return fmt.Errorf("migration %d_%s failed: %w", migration.Version, migration.Name, err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// Down reverts the last steps applied migrations, all of them if steps isn't positive
+func (m *Migrator) Down(ctx context.Context, steps int) error {
+	return m.withLock(ctx, func() error {
+		version, err := m.cleanVersion(ctx)
+		if err != nil {
+			return err
+		}
+
+		if steps <= 0 {
+			steps = len(m.migrations)
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && steps > 0; i-- {
+			migration := m.migrations[i]
+			if migration.Version > version {
+				continue
+			}
+
+			previous := NilVersion
+			if i > 0 {
+				previous = m.migrations[i-1].Version
+			}
+
+			slog.Info("Reverting migration", "version", migration.Version, "name", migration.Name)
+			if err := m.run(ctx, migration.Down, previous); err != nil {
+				return fmt.Errorf("reverting migration %d_%s failed: %w", migration.Version, migration.Name, err)
+			}
+			steps--
+		}
+
+		return nil
+	})
+}
+
+// Force sets the schema to the version and marks it clean, without running any migration
+func (m *Migrator) Force(ctx context.Context, version int) error {
+	if version != NilVersion && m.find(version) == nil {
+		return fmt.Errorf("no migration found for version %d", version)
+	}
+
+	return m.withLock(ctx, func() error {
+		return m.driver.SetVersion(ctx, version, false)
+	})
+}
+
+// cleanVersion returns the current version, failing if it's dirty or unknown to this binary
+func (m *Migrator) cleanVersion(ctx context.Context) (int, error) {
+	version, dirty, err := m.driver.Version(ctx)
+	if err != nil {
+		return NilVersion, err
+	}
+	if dirty {
+		return NilVersion, fmt.Errorf("%w (at version %d)", ErrDirty, version)
+	}
+	if version != NilVersion && m.find(version) == nil {
+		return NilVersion, fmt.Errorf("no migration found for version %d", version)
+	}
+
+	return version, nil
+}
+
+func (m *Migrator) find(version int) *Migration {
+	for i := range m.migrations {
+		if m.migrations[i].Version == version {
+			return &m.migrations[i]
+		}
+	}
+	return nil
+}
+
+// run executes the statement of a migration, keeping the schema dirty at the version it leads to until it succeeds
+func (m *Migrator) run(ctx context.Context, statement string, version int) error {
+	if err := m.driver.SetVersion(ctx, version, true); err != nil {
+		return err
+	}
+	if err := m.driver.Run(ctx, statement); err != nil {
+		return err
+	}
+	return m.driver.SetVersion(ctx, version, false)
+}
+
+// withLock runs fn while holding the lock, which is released even once the context is done so that other replicas
+// don't wait for it to expire
+func (m *Migrator) withLock(ctx context.Context, fn func() error) error {
+	if err := m.driver.Lock(ctx); err != nil {
+		return err
+	}
+	defer m.driver.Unlock(context.WithoutCancel(ctx))
+
+	return fn()
+}
@@ -0,0 +1,141 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// fakeDriver keeps the version in memory & records the statements it runs
+type fakeDriver struct {
+	version int
+	dirty   bool
+	ran     []string
+	failOn  string
+	// Error of the context the lock got released with
+	unlockErr error
+}
+
+func (d *fakeDriver) Lock(ctx context.Context) error                 { return nil }
+func (d *fakeDriver) Unlock(ctx context.Context)                     { d.unlockErr = ctx.Err() }
+func (d *fakeDriver) Version(ctx context.Context) (int, bool, error) { return d.version, d.dirty, nil }
+
+func (d *fakeDriver) SetVersion(ctx context.Context, version int, dirty bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	d.version, d.dirty = version, dirty
+	return nil
+}
+
+func (d *fakeDriver) Run(ctx context.Context, statement string) error {
+	if statement == d.failOn {
+		return errors.New("failed")
+	}
+	d.ran = append(d.ran, statement)
+	return nil
+}
+
+var testCtx = context.Background()
+
+type MigrationsTestSuite struct {
+	suite.Suite
+	driver   *fakeDriver
+	migrator *Migrator
+}
+
+func TestMigrationsTestSuite(t *testing.T) {
+	suite.Run(t, new(MigrationsTestSuite))
+}
+
+func (mts *MigrationsTestSuite) SetupTest() {
+	mts.driver = &fakeDriver{version: NilVersion}
+	mts.migrator = NewMigrator(mts.driver, []Migration{
+		{Version: 1, Name: "a", Up: "up a", Down: "down a"},
+		{Version: 2, Name: "b", Up: "up b", Down: "down b"},
+		{Version: 3, Name: "c", Up: "up c", Down: "down c"},
+	})
+}
+
+func (mts *MigrationsTestSuite) Test_Load_Sorted_By_Version() {
+	loaded, err := Load(fstest.MapFS{
+		"m/000010_b.up.cql":   {Data: []byte("up b")},
+		"m/000010_b.down.cql": {Data: []byte("down b")},
+		"m/000002_a.up.cql":   {Data: []byte("up a")},
+		"m/000002_a.down.cql": {Data: []byte("down a")},
+		"m/000003_c.up.sql":   {Data: []byte("other engine")},
+		"m/README.md":         {Data: []byte("ignored")},
+	}, "m", "cql")
+	mts.Require().NoError(err)
+
+	mts.Len(loaded, 2)
+	mts.Equal(Migration{Version: 2, Name: "a", Up: "up a", Down: "down a"}, loaded[0])
+	mts.Equal(10, loaded[1].Version)
+}
+
+func (mts *MigrationsTestSuite) Test_Load_Missing_Direction() {
+	_, err := Load(fstest.MapFS{
+		"m/000001_a.up.cql": {Data: []byte("up a")},
+	}, "m", "cql")
+
+	mts.ErrorContains(err, "missing its up or down file")
+}
+
+func (mts *MigrationsTestSuite) Test_Up_And_Down() {
+	mts.NoError(mts.migrator.Up(testCtx, 2))
+	mts.Equal([]string{"up a", "up b"}, mts.driver.ran)
+	mts.Equal(2, mts.driver.version)
+
+	mts.NoError(mts.migrator.Up(testCtx, 0))
+	mts.Equal(3, mts.driver.version)
+
+	mts.NoError(mts.migrator.Down(testCtx, 1))
+	mts.Equal(2, mts.driver.version)
+
+	mts.NoError(mts.migrator.Down(testCtx, 0))
+	mts.Equal([]string{"up a", "up b", "up c", "down c", "down b", "down a"}, mts.driver.ran)
+	mts.Equal(NilVersion, mts.driver.version)
+	mts.False(mts.driver.dirty)
+}
+
+func (mts *MigrationsTestSuite) Test_Failure_Leaves_Dirty_Until_Forced() {
+	mts.driver.failOn = "up b"
+
+	mts.Error(mts.migrator.Up(testCtx, 0))
+	mts.Equal(2, mts.driver.version)
+	mts.True(mts.driver.dirty)
+
+	mts.ErrorIs(mts.migrator.Up(testCtx, 0), ErrDirty)
+
+	mts.NoError(mts.migrator.Force(testCtx, 1))
+	mts.driver.failOn = ""
+	mts.NoError(mts.migrator.Up(testCtx, 0))
+	mts.Equal(3, mts.driver.version)
+
+	mts.Error(mts.migrator.Force(testCtx, 7))
+}
+
+func (mts *MigrationsTestSuite) Test_Stops_Once_Cancelled() {
+	ctx, cancel := context.WithCancel(testCtx)
+	cancel()
+
+	mts.ErrorIs(mts.migrator.Up(ctx, 0), context.Canceled)
+	mts.Empty(mts.driver.ran)
+	mts.Equal(NilVersion, mts.driver.version)
+	mts.False(mts.driver.dirty)
+	mts.NoError(mts.driver.unlockErr, "Lock released regardless")
+}
+
+func (mts *MigrationsTestSuite) Test_Check_Version() {
+	migrations := mts.migrator.Migrations()
+
+	mts.ErrorIs(CheckVersion(NilVersion, false, migrations), ErrPending)
+	mts.ErrorIs(CheckVersion(2, false, migrations), ErrPending)
+	mts.ErrorIs(CheckVersion(3, true, migrations), ErrDirty)
+	mts.NoError(CheckVersion(3, false, migrations))
+	mts.NoError(CheckVersion(4, false, migrations), "Schema of the next release rolling out")
+	mts.NoError(CheckVersion(NilVersion, false, nil))
+}
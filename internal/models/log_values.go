@@ -0,0 +1,43 @@
+package models
+
+import "log/slog"
+
+// Models holding passwords, tokens or message contents log their identifying fields only, so that
+// logging them whole (e.g along with an error) can't leak any of those
+
+func (u User) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("id", u.ID.String()), slog.String("username", u.Username))
+}
+
+func (i RegisterInput) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("username", i.Username))
+}
+
+func (i LoginInput) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("username", i.Username))
+}
+
+func (i RefreshTokenInput) LogValue() slog.Value {
+	return slog.GroupValue()
+}
+
+func (m Message) LogValue() slog.Value {
+	attrs := []slog.Attr{
+		slog.String("id", m.ID.String()),
+		slog.String("sender", m.Sender),
+		slog.String("recipient", m.Recipient),
+	}
+	if m.GroupID != nil {
+		attrs = append(attrs, slog.String("group_id", m.GroupID.String()))
+	}
+
+	return slog.GroupValue(attrs...)
+}
+
+func (i EditMessageInput) LogValue() slog.Value {
+	return slog.GroupValue()
+}
+
+func (i SendMessageInput) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("recipient", i.Recipient), slog.String("group_id", i.GroupID))
+}
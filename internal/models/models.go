@@ -1,6 +1,7 @@
 package models
 
 import (
+	"bytes"
 	"time"
 
 	"github.com/gocql/gocql"
@@ -22,16 +23,137 @@ type LoginInput struct {
 	Password string `json:"password" validate:"required"`
 }
 
+type RefreshTokenInput struct {
+	RefreshToken string `json:"refreshToken" validate:"required"`
+}
+
 type Message struct {
-	ID        gocql.UUID `json:"id"`
-	Sender    string     `json:"sender"`
-	Recipient string     `json:"recipient"`
-	Timestamp time.Time  `json:"timestamp"`
-	Content   string     `json:"content" validate:"required,min=1,max=1000"`
-	User      string     `json:"-"`
+	ID        gocql.UUID  `json:"id"`
+	Sender    string      `json:"sender"`
+	Recipient string      `json:"recipient"`
+	Timestamp time.Time   `json:"timestamp"`
+	Content   string      `json:"content" validate:"required,min=1,max=1000"`
+	GroupID   *gocql.UUID `json:"groupId,omitempty"`
+	EditedAt  *time.Time  `json:"editedAt,omitempty"`
+	Status    string      `json:"status,omitempty"`
+	User      string      `json:"-"`
 }
 
+// Statuses of the direct messages as seen by their sender
+const (
+	MESSAGE_STATUS_DELIVERED = "delivered"
+	MESSAGE_STATUS_READ      = "read"
+)
+
+type EditMessageInput struct {
+	Content string `json:"content" validate:"required,min=1,max=1000"`
+}
+
+// SendMessageInput targets either a single recipient or a group
 type SendMessageInput struct {
 	Content   string `json:"content" validate:"required,min=1,max=1000"`
-	Recipient string `json:"recipient" validate:"required,min=1,max=16"`
+	Recipient string `json:"recipient" validate:"required_without=GroupID,excluded_with=GroupID,max=16"`
+	GroupID   string `json:"groupId" validate:"required_without=Recipient,omitempty,uuid"`
+}
+
+type Conversation struct {
+	Peer               string      `json:"peer"`
+	LastMessageID      gocql.UUID  `json:"lastMessageId"`
+	LastMessageSender  string      `json:"lastMessageSender"`
+	LastMessagePreview string      `json:"lastMessagePreview"`
+	LastMessageAt      time.Time   `json:"lastMessageAt"`
+	LastReadID         *gocql.UUID `json:"lastReadId,omitempty"`
+	PeerLastReadID     *gocql.UUID `json:"peerLastReadId,omitempty"`
+	UnreadCount        int64       `json:"unreadCount"`
+}
+
+// ReadConversationInput carries the ID of the last message of the conversation the user has read
+type ReadConversationInput struct {
+	LastReadID string `json:"lastReadId" validate:"required,uuid"`
+}
+
+// Group roles, ordered by privileges
+const (
+	GROUP_ROLE_MEMBER = "member"
+	GROUP_ROLE_ADMIN  = "admin"
+	GROUP_ROLE_OWNER  = "owner"
+)
+
+type Group struct {
+	ID        gocql.UUID `json:"id"`
+	Name      string     `json:"name"`
+	Owner     string     `json:"owner"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
+
+type GroupMember struct {
+	GroupID  gocql.UUID `json:"groupId"`
+	Username string     `json:"username"`
+	Role     string     `json:"role"`
+	JoinedAt time.Time  `json:"joinedAt"`
+}
+
+type CreateGroupInput struct {
+	Name    string   `json:"name" validate:"required,min=1,max=64"`
+	Members []string `json:"members" validate:"max=49,dive,min=1,max=16"`
+}
+
+type AddGroupMemberInput struct {
+	Username string `json:"username" validate:"required,min=1,max=16"`
+	Role     string `json:"role" validate:"omitempty,oneof=admin member"`
+}
+
+// MessageKey locates a message on the (timestamp, id) clustering key
+type MessageKey struct {
+	Timestamp time.Time
+	ID        gocql.UUID
+}
+
+// KeyOf returns the clustering key of the message
+func KeyOf(message *Message) MessageKey {
+	return MessageKey{Timestamp: message.Timestamp, ID: message.ID}
+}
+
+// KeyFromID derives the clustering key from the TimeUUID of a message,
+// since a message's timestamp is the time of its ID truncated to Cassandra's millisecond precision
+func KeyFromID(id gocql.UUID) MessageKey {
+	return MessageKey{Timestamp: TimestampOf(id), ID: id}
+}
+
+func TimestampOf(id gocql.UUID) time.Time {
+	return id.Time().UTC().Truncate(time.Millisecond)
+}
+
+// BucketOf returns the monthly bucket partitioning the messages of a user at that time, e.g 202405
+func BucketOf(timestamp time.Time) int {
+	timestamp = timestamp.UTC()
+	return timestamp.Year()*100 + int(timestamp.Month())
+}
+
+// Precedes tells whether the key comes before the other one in the (timestamp DESC, id ASC) clustering order,
+// comparing TimeUUIDs sharing the same millisecond by their time then their bytes like Cassandra does
+func (k MessageKey) Precedes(other MessageKey) bool {
+	if !k.Timestamp.Equal(other.Timestamp) {
+		return k.Timestamp.After(other.Timestamp)
+	}
+
+	if t, o := k.ID.Time(), other.ID.Time(); !t.Equal(o) {
+		return t.Before(o)
+	}
+
+	return bytes.Compare(k.ID[:], other.ID[:]) < 0
+}
+
+// Number of messages of a page, when not asked for & at most
+const (
+	DEFAULT_PAGE_SIZE = 10
+	MAX_PAGE_SIZE     = 100
+)
+
+// MessagesPage bounds a page of messages either before (older) or after (newer) a message.
+// No bounds means the most recent messages.
+type MessagesPage struct {
+	Before *MessageKey
+	After  *MessageKey
+	Limit  int
 }
@@ -0,0 +1,147 @@
+package postgres
+
+import (
+	"chat-system/internal/migrations"
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// Key of the advisory lock held while migrating, so only one replica migrates at a time
+const MIGRATIONS_LOCK_KEY = 7210469311
+
+// migrationDriver keeps the version of the schema of the DB. The advisory lock belongs to the session
+// holding it, so statements go through that very connection while locked.
+type migrationDriver struct {
+	pool *pgxpool.Pool
+	conn *pgxpool.Conn
+}
+
+// NewMigrator runs the migrations embedded within the binary against the DB
+func NewMigrator(ctx context.Context, pool *pgxpool.Pool) (*migrations.Migrator, error) {
+	loaded, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+
+	driver := &migrationDriver{pool: pool}
+	if err := driver.ensureTable(ctx); err != nil {
+		return nil, err
+	}
+
+	return migrations.NewMigrator(driver, loaded), nil
+}
+
+// querier is either the pool or the connection holding the lock
+type querier interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+func (d *migrationDriver) db() querier {
+	if d.conn != nil {
+		return d.conn
+	}
+	return d.pool
+}
+
+// Migrations lists the migrations embedded within the binary, oldest first
+func Migrations() ([]migrations.Migration, error) {
+	return migrations.Load(migrationFiles, "migrations", "sql")
+}
+
+func (d *migrationDriver) Version(ctx context.Context) (int, bool, error) {
+	return schemaVersion(ctx, d.db())
+}
+
+// SchemaVersion returns the version the schema of the DB is at, without setting the migrations up
+func SchemaVersion(ctx context.Context, pool *pgxpool.Pool) (int, bool, error) {
+	return schemaVersion(ctx, pool)
+}
+
+func schemaVersion(ctx context.Context, db querier) (int, bool, error) {
+	query := fmt.Sprintf(`SELECT version, dirty FROM %s LIMIT 1`, migrations.TABLE)
+
+	var version int64
+	var dirty bool
+	err := db.QueryRow(ctx, query).Scan(&version, &dirty)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return migrations.NilVersion, false, nil
+	}
+	if err != nil {
+		return migrations.NilVersion, false, err
+	}
+
+	return int(version), dirty, nil
+}
+
+// SetVersion keeps a single row within the version table, same as golang-migrate does
+func (d *migrationDriver) SetVersion(ctx context.Context, version int, dirty bool) error {
+	tx, err := d.db().Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, fmt.Sprintf(`TRUNCATE %s`, migrations.TABLE)); err != nil {
+		return err
+	}
+
+	if version != migrations.NilVersion || dirty {
+		insert := fmt.Sprintf(`INSERT INTO %s (version, dirty) VALUES ($1, $2)`, migrations.TABLE)
+		if _, err := tx.Exec(ctx, insert, int64(version), dirty); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+// Run executes the SQL of a migration, which may hold several statements run within a single transaction
+func (d *migrationDriver) Run(ctx context.Context, statement string) error {
+	_, err := d.db().Exec(ctx, statement)
+	return err
+}
+
+func (d *migrationDriver) ensureTable(ctx context.Context) error {
+	_, err := d.pool.Exec(ctx, fmt.Sprintf(
+		`CREATE TABLE IF NOT EXISTS %s (version BIGINT NOT NULL PRIMARY KEY, dirty BOOLEAN NOT NULL)`,
+		migrations.TABLE,
+	))
+	return err
+}
+
+// Lock blocks until the other replicas migrating meanwhile release the lock
+func (d *migrationDriver) Lock(ctx context.Context) error {
+	conn, err := d.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+
+	if _, err := conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, MIGRATIONS_LOCK_KEY); err != nil {
+		conn.Release()
+		return err
+	}
+
+	d.conn = conn
+	return nil
+}
+
+func (d *migrationDriver) Unlock(ctx context.Context) {
+	// Closing the session releases the lock anyway
+	if _, err := d.conn.Exec(ctx, `SELECT pg_advisory_unlock($1)`, MIGRATIONS_LOCK_KEY); err != nil {
+		d.conn.Hijack().Close(ctx)
+	} else {
+		d.conn.Release()
+	}
+	d.conn = nil
+}
@@ -0,0 +1,28 @@
+package postgres
+
+import (
+	"chat-system/internal/migrations"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type MigrationsTestSuite struct {
+	suite.Suite
+}
+
+func TestMigrationsTestSuite(t *testing.T) {
+	suite.Run(t, new(MigrationsTestSuite))
+}
+
+func (mts *MigrationsTestSuite) Test_Embedded_Migrations_Are_Sequential() {
+	loaded, err := migrations.Load(migrationFiles, "migrations", "sql")
+	mts.Require().NoError(err)
+	mts.NotEmpty(loaded)
+
+	for i, migration := range loaded {
+		mts.Equal(i+1, migration.Version)
+		mts.NotEmpty(migration.Up)
+		mts.NotEmpty(migration.Down)
+	}
+}
@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS users (
+    id UUID NOT NULL,
+    username TEXT PRIMARY KEY,
+    password TEXT NOT NULL
+);
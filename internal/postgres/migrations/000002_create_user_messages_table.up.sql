@@ -0,0 +1,16 @@
+-- Messages sent to or by each user, id_time being the time of the TimeUUID in 100ns
+-- so messages sharing the same millisecond are ordered like the clustering order of Cassandra
+CREATE TABLE IF NOT EXISTS user_messages (
+    username TEXT NOT NULL,
+    timestamp TIMESTAMPTZ NOT NULL,
+    id_time BIGINT NOT NULL,
+    id UUID NOT NULL,
+    sender TEXT NOT NULL,
+    recipient TEXT NOT NULL DEFAULT '',
+    content TEXT NOT NULL,
+    group_id UUID,
+    edited_at TIMESTAMPTZ,
+    PRIMARY KEY (username, id)
+);
+
+CREATE INDEX IF NOT EXISTS user_messages_by_time ON user_messages (username, timestamp DESC, id_time, id);
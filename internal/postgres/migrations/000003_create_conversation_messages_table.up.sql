@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS conversation_messages (
+    user_a TEXT NOT NULL,
+    user_b TEXT NOT NULL,
+    timestamp TIMESTAMPTZ NOT NULL,
+    id_time BIGINT NOT NULL,
+    id UUID NOT NULL,
+    sender TEXT NOT NULL,
+    recipient TEXT NOT NULL,
+    content TEXT NOT NULL,
+    edited_at TIMESTAMPTZ,
+    PRIMARY KEY (user_a, user_b, id)
+);
+
+CREATE INDEX IF NOT EXISTS conversation_messages_by_time ON conversation_messages (user_a, user_b, timestamp DESC, id_time, id);
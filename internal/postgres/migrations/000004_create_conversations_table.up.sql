@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS conversations (
+    username TEXT NOT NULL,
+    peer TEXT NOT NULL,
+    last_message_id UUID NOT NULL,
+    last_message_sender TEXT NOT NULL,
+    last_message_preview TEXT NOT NULL,
+    last_message_at TIMESTAMPTZ NOT NULL,
+    last_read_id UUID,
+    peer_last_read_id UUID,
+    PRIMARY KEY (username, peer)
+);
@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS groups (
+    id UUID PRIMARY KEY,
+    name TEXT NOT NULL,
+    owner TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL
+);
@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS group_members (
+    group_id UUID NOT NULL,
+    username TEXT NOT NULL,
+    role TEXT NOT NULL,
+    joined_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (group_id, username)
+);
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS group_messages (
+    group_id UUID NOT NULL,
+    timestamp TIMESTAMPTZ NOT NULL,
+    id_time BIGINT NOT NULL,
+    id UUID NOT NULL,
+    sender TEXT NOT NULL,
+    content TEXT NOT NULL,
+    edited_at TIMESTAMPTZ,
+    PRIMARY KEY (group_id, id)
+);
+
+CREATE INDEX IF NOT EXISTS group_messages_by_time ON group_messages (group_id, timestamp DESC, id_time, id);
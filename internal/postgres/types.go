@@ -0,0 +1,60 @@
+package postgres
+
+import (
+	"errors"
+
+	"github.com/gocql/gocql"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// RegisterTypes lets the models keep their gocql UUIDs, which pgx can't encode nor scan on its own
+func RegisterTypes(m *pgtype.Map) {
+	m.TryWrapEncodePlanFuncs = append([]pgtype.TryWrapEncodePlanFunc{tryWrapUUIDEncodePlan}, m.TryWrapEncodePlanFuncs...)
+	m.TryWrapScanPlanFuncs = append([]pgtype.TryWrapScanPlanFunc{tryWrapUUIDScanPlan}, m.TryWrapScanPlanFuncs...)
+}
+
+func tryWrapUUIDEncodePlan(value any) (pgtype.WrappedEncodePlanNextSetter, any, bool) {
+	if id, ok := value.(gocql.UUID); ok {
+		return &wrapUUIDEncodePlan{}, pgtype.UUID{Bytes: id, Valid: true}, true
+	}
+	return nil, nil, false
+}
+
+type wrapUUIDEncodePlan struct {
+	next pgtype.EncodePlan
+}
+
+func (p *wrapUUIDEncodePlan) SetNext(next pgtype.EncodePlan) { p.next = next }
+
+func (p *wrapUUIDEncodePlan) Encode(value any, buf []byte) ([]byte, error) {
+	return p.next.Encode(pgtype.UUID{Bytes: value.(gocql.UUID), Valid: true}, buf)
+}
+
+func tryWrapUUIDScanPlan(target any) (pgtype.WrappedScanPlanNextSetter, any, bool) {
+	if id, ok := target.(*gocql.UUID); ok {
+		return &wrapUUIDScanPlan{}, &uuidScanner{id: id}, true
+	}
+	return nil, nil, false
+}
+
+type wrapUUIDScanPlan struct {
+	next pgtype.ScanPlan
+}
+
+func (p *wrapUUIDScanPlan) SetNext(next pgtype.ScanPlan) { p.next = next }
+
+func (p *wrapUUIDScanPlan) Scan(src []byte, target any) error {
+	return p.next.Scan(src, &uuidScanner{id: target.(*gocql.UUID)})
+}
+
+type uuidScanner struct {
+	id *gocql.UUID
+}
+
+func (s *uuidScanner) ScanUUID(v pgtype.UUID) error {
+	if !v.Valid {
+		return errors.New("cannot scan NULL into gocql.UUID")
+	}
+	*s.id = v.Bytes
+	return nil
+}
@@ -0,0 +1,65 @@
+package postgres
+
+import (
+	"testing"
+
+	"github.com/gocql/gocql"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/suite"
+)
+
+type TypesTestSuite struct {
+	suite.Suite
+	typeMap *pgtype.Map
+}
+
+func TestTypesTestSuite(t *testing.T) {
+	suite.Run(t, new(TypesTestSuite))
+}
+
+func (tts *TypesTestSuite) SetupTest() {
+	tts.typeMap = pgtype.NewMap()
+	RegisterTypes(tts.typeMap)
+}
+
+func (tts *TypesTestSuite) Test_UUID_Round_Trip() {
+	id := gocql.TimeUUID()
+
+	for _, format := range []int16{pgtype.BinaryFormatCode, pgtype.TextFormatCode} {
+		buf, err := tts.typeMap.Encode(pgtype.UUIDOID, format, id, nil)
+		tts.Require().NoError(err)
+
+		var scanned gocql.UUID
+		tts.NoError(tts.typeMap.Scan(pgtype.UUIDOID, format, buf, &scanned))
+		tts.Equal(id, scanned)
+
+		var nullable *gocql.UUID
+		tts.NoError(tts.typeMap.Scan(pgtype.UUIDOID, format, buf, &nullable))
+		tts.Equal(id, *nullable)
+	}
+}
+
+func (tts *TypesTestSuite) Test_Null_UUID() {
+	var id *gocql.UUID
+	buf, err := tts.typeMap.Encode(pgtype.UUIDOID, pgtype.BinaryFormatCode, id, nil)
+	tts.NoError(err)
+	tts.Nil(buf)
+
+	scanned := &gocql.UUID{}
+	tts.NoError(tts.typeMap.Scan(pgtype.UUIDOID, pgtype.BinaryFormatCode, nil, &scanned))
+	tts.Nil(scanned)
+
+	var required gocql.UUID
+	tts.Error(tts.typeMap.Scan(pgtype.UUIDOID, pgtype.BinaryFormatCode, nil, &required))
+}
+
+func (tts *TypesTestSuite) Test_UUID_Array() {
+	ids := []gocql.UUID{gocql.TimeUUID(), gocql.TimeUUID()}
+
+	buf, err := tts.typeMap.Encode(pgtype.UUIDArrayOID, pgtype.BinaryFormatCode, ids, nil)
+	tts.Require().NoError(err)
+
+	var scanned []gocql.UUID
+	tts.NoError(tts.typeMap.Scan(pgtype.UUIDArrayOID, pgtype.BinaryFormatCode, buf, &scanned))
+	tts.Equal(ids, scanned)
+}
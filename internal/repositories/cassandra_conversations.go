@@ -0,0 +1,101 @@
+package repositories
+
+import (
+	"chat-system/internal/models"
+	"context"
+
+	"github.com/gocql/gocql"
+)
+
+type cassandraConversationRepository struct {
+	queries *CassandraQueries
+}
+
+func NewCassandraConversationRepository(queries *CassandraQueries) *cassandraConversationRepository {
+	return &cassandraConversationRepository{queries: queries}
+}
+
+// GetConversations lists the rows of the user clustered by peer, since the last message time keeps changing
+// it can't be a clustering column
+func (r *cassandraConversationRepository) GetConversations(ctx context.Context, username string) ([]models.Conversation, error) {
+	conversations := make([]models.Conversation, 0)
+
+	iter := r.queries.getConversations(ctx, username).Iter()
+
+	var conversation models.Conversation
+	for iter.Scan(
+		&conversation.Peer,
+		&conversation.LastMessageID,
+		&conversation.LastMessageSender,
+		&conversation.LastMessagePreview,
+		&conversation.LastMessageAt,
+		&conversation.LastReadID,
+		&conversation.PeerLastReadID,
+	) {
+		conversations = append(conversations, conversation)
+	}
+	if err := iter.Close(); err != nil {
+		return nil, err
+	}
+
+	return conversations, nil
+}
+
+func (r *cassandraConversationRepository) GetConversationMessages(ctx context.Context, username, peer string, page models.MessagesPage) ([]models.Message, error) {
+	userA, userB := conversationKey(username, peer)
+
+	return queryPage(ctx, r.queries, &r.queries.threadMessages, []interface{}{userA, userB}, page, scanThreadMessages)
+}
+
+func (r *cassandraConversationRepository) GetConversationMessage(ctx context.Context, username, peer string, id gocql.UUID) (*models.Message, error) {
+	userA, userB := conversationKey(username, peer)
+
+	return lookupMessage(ctx, r.queries, &r.queries.threadMessages, []interface{}{userA, userB}, id, scanThreadMessages)
+}
+
+func (r *cassandraConversationRepository) GetLastReadID(ctx context.Context, username, peer string) (*gocql.UUID, error) {
+	var lastReadID *gocql.UUID
+	err := r.queries.getLastReadID(ctx, username, peer).Scan(&lastReadID)
+	if err != nil && err != gocql.ErrNotFound {
+		return nil, err
+	}
+
+	return lastReadID, nil
+}
+
+func (r *cassandraConversationRepository) SetLastReadID(ctx context.Context, username, peer string, id gocql.UUID) error {
+	batch := r.queries.newBatch(ctx, "SetLastReadID")
+
+	r.queries.setLastReadID(batch, username, peer, id)
+	r.queries.setPeerLastReadID(batch, peer, username, id)
+
+	return r.queries.db.ExecuteBatch(batch)
+}
+
+func (r *cassandraConversationRepository) GetReadMarks(ctx context.Context, username string) (map[string]gocql.UUID, error) {
+	marks := make(map[string]gocql.UUID)
+
+	iter := r.queries.getReadMarks(ctx, username).Iter()
+
+	var peer string
+	var mark *gocql.UUID
+	for iter.Scan(&peer, &mark) {
+		if mark != nil {
+			marks[peer] = *mark
+		}
+	}
+	if err := iter.Close(); err != nil {
+		return nil, err
+	}
+
+	return marks, nil
+}
+
+func scanThreadMessages(iter *gocql.Iter) []models.Message {
+	var messages []models.Message
+	var message models.Message
+	for iter.Scan(&message.ID, &message.Sender, &message.Recipient, &message.Timestamp, &message.Content, &message.EditedAt) {
+		messages = append(messages, message)
+	}
+	return messages
+}
@@ -0,0 +1,106 @@
+package repositories
+
+import (
+	"chat-system/internal/models"
+	"context"
+
+	"github.com/gocql/gocql"
+)
+
+type cassandraGroupRepository struct {
+	queries *CassandraQueries
+}
+
+func NewCassandraGroupRepository(queries *CassandraQueries) *cassandraGroupRepository {
+	return &cassandraGroupRepository{queries: queries}
+}
+
+func (r *cassandraGroupRepository) CreateGroup(ctx context.Context, group *models.Group, members []models.GroupMember) error {
+	batch := r.queries.newBatch(ctx, "CreateGroup")
+
+	r.queries.addGroup(batch, group)
+	for i := range members {
+		r.queries.addMember(batch, &members[i])
+	}
+
+	return r.queries.db.ExecuteBatch(batch)
+}
+
+func (r *cassandraGroupRepository) GetMembers(ctx context.Context, groupID gocql.UUID) ([]models.GroupMember, error) {
+	members := make([]models.GroupMember, 0)
+
+	iter := r.queries.getMembers(ctx, groupID).Iter()
+
+	var member models.GroupMember
+	for iter.Scan(&member.GroupID, &member.Username, &member.Role, &member.JoinedAt) {
+		members = append(members, member)
+	}
+	if err := iter.Close(); err != nil {
+		return nil, err
+	}
+
+	return members, nil
+}
+
+func (r *cassandraGroupRepository) AddMember(ctx context.Context, member *models.GroupMember) error {
+	return r.queries.createMember(ctx, member).Exec()
+}
+
+func (r *cassandraGroupRepository) RemoveMember(ctx context.Context, groupID gocql.UUID, username string) error {
+	return r.queries.removeMember(ctx, groupID, username).Exec()
+}
+
+// CreateGroupMessage copies the message to the messages of every member first, then adds it to the timeline of the
+// group along with the bucket index of the members, which only hold small rows, within a single batch
+func (r *cassandraGroupRepository) CreateGroupMessage(ctx context.Context, message *models.Message, members []string) error {
+	if err := r.queries.fanOut(ctx, r.queries.memberMessages(members, message)); err != nil {
+		return err
+	}
+
+	batch := r.queries.newBatch(ctx, "CreateGroupMessage")
+
+	r.queries.addGroupMessage(batch, message)
+	r.queries.addUserBuckets(batch, members, models.BucketOf(message.Timestamp))
+
+	return r.queries.db.ExecuteBatch(batch)
+}
+
+func (r *cassandraGroupRepository) EditGroupMessage(ctx context.Context, message *models.Message, members []string) error {
+	owners, err := r.queries.messageOwners(ctx, members, message)
+	if err != nil {
+		return err
+	}
+
+	if err := r.queries.fanOut(ctx, r.queries.editedMessages(owners, message)); err != nil {
+		return err
+	}
+
+	batch := r.queries.newBatch(ctx, "EditGroupMessage")
+	r.queries.editGroupMessage(batch, message)
+
+	return r.queries.db.ExecuteBatch(batch)
+}
+
+// DeleteGroupMessage deletes the message from the messages of every member first, then from the timeline of the
+// group, so it can be deleted again if any member is left with it
+func (r *cassandraGroupRepository) DeleteGroupMessage(ctx context.Context, message *models.Message, members []string) error {
+	if err := r.queries.fanOut(ctx, r.queries.removedMessages(members, message)); err != nil {
+		return err
+	}
+
+	batch := r.queries.newBatch(ctx, "DeleteGroupMessage")
+	r.queries.removeGroupMessage(batch, message)
+
+	return r.queries.db.ExecuteBatch(batch)
+}
+
+func (r *cassandraGroupRepository) GetGroupMessages(ctx context.Context, groupID gocql.UUID, page models.MessagesPage) ([]models.Message, error) {
+	return queryPage(ctx, r.queries, &r.queries.groupMessages, []interface{}{groupID}, page, func(iter *gocql.Iter) []models.Message {
+		var messages []models.Message
+		var message models.Message
+		for iter.Scan(&message.ID, &message.Sender, &message.Timestamp, &message.Content, &message.GroupID, &message.EditedAt) {
+			messages = append(messages, message)
+		}
+		return messages
+	})
+}
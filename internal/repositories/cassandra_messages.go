@@ -0,0 +1,136 @@
+package repositories
+
+import (
+	"chat-system/internal/models"
+	"context"
+	"errors"
+
+	"github.com/gocql/gocql"
+)
+
+type cassandraMessageRepository struct {
+	queries *CassandraQueries
+}
+
+func NewCassandraMessageRepository(queries *CassandraQueries) *cassandraMessageRepository {
+	return &cassandraMessageRepository{queries: queries}
+}
+
+// CreateMessage writes the message at a write time derived from its timestamp, which the updates of the previews
+// of the conversations are ordered against
+func (r *cassandraMessageRepository) CreateMessage(ctx context.Context, message *models.Message, preview string) error {
+	batch := r.queries.newBatch(ctx, "CreateMessage").WithTimestamp(messageWriteTime(message))
+
+	r.queries.addMessage(batch, message.Sender, message)
+	r.queries.addMessage(batch, message.Recipient, message)
+	r.queries.addUserBuckets(batch, []string{message.Sender, message.Recipient}, models.BucketOf(message.Timestamp))
+
+	// Keep the per-peer views up to date along with the users' messages
+	r.queries.addThreadMessage(batch, message)
+	r.queries.setLastMessage(batch, message.Sender, message.Recipient, message, preview)
+	r.queries.setLastMessage(batch, message.Recipient, message.Sender, message, preview)
+
+	return r.queries.db.ExecuteBatch(batch)
+}
+
+// GetMessages reads across the monthly buckets of the user until the page is filled
+func (r *cassandraMessageRepository) GetMessages(ctx context.Context, username string, page models.MessagesPage) ([]models.Message, error) {
+	buckets, err := r.queries.userBuckets(ctx, username, page)
+	if err != nil {
+		return nil, err
+	}
+
+	return queryBucketedPage(ctx, r.queries, &r.queries.messages, []interface{}{username}, buckets, page, scanMessages)
+}
+
+func (r *cassandraMessageRepository) GetMessage(ctx context.Context, username string, id gocql.UUID) (*models.Message, error) {
+	// The lookup window may cross the end of a month
+	from := models.TimestampOf(id)
+	buckets := []int{models.BucketOf(from)}
+	if last := models.BucketOf(from.Add(MSG_LOOKUP_WINDOW)); last != buckets[0] {
+		buckets = append(buckets, last)
+	}
+
+	for _, bucket := range buckets {
+		message, err := lookupMessage(ctx, r.queries, &r.queries.messages, []interface{}{username, bucket}, id, scanMessages)
+		if err != nil || message != nil {
+			return message, err
+		}
+	}
+
+	return nil, nil
+}
+
+func (r *cassandraMessageRepository) EditMessage(ctx context.Context, message *models.Message) error {
+	owners, err := r.queries.messageOwners(ctx, []string{message.Sender, message.Recipient}, message)
+	if err != nil {
+		return err
+	}
+
+	batch := r.queries.newBatch(ctx, "EditMessage")
+
+	for _, owner := range owners {
+		r.queries.editMessage(batch, owner, message)
+	}
+	r.queries.editThreadMessage(batch, message)
+
+	return r.queries.db.ExecuteBatch(batch)
+}
+
+func (r *cassandraMessageRepository) DeleteMessage(ctx context.Context, message *models.Message) error {
+	batch := r.queries.newBatch(ctx, "DeleteMessage")
+
+	r.queries.removeMessage(batch, message.Sender, message)
+	r.queries.removeMessage(batch, message.Recipient, message)
+	r.queries.removeThreadMessage(batch, message)
+
+	return r.queries.db.ExecuteBatch(batch)
+}
+
+func (r *cassandraMessageRepository) DeleteMessageForUser(ctx context.Context, username string, message *models.Message) error {
+	return r.queries.removeMessageForUser(ctx, username, message).Exec()
+}
+
+// UpdateConversationPreviews compares the last message of the conversations before updating their previews, rather
+// than through a lightweight transaction which mixed with the plain writes of the new messages isn't supported.
+// A newer message written meanwhile still wins, its write time being higher than the one of the preview.
+func (r *cassandraMessageRepository) UpdateConversationPreviews(ctx context.Context, message *models.Message, preview string) error {
+	parties := [][2]string{{message.Sender, message.Recipient}, {message.Recipient, message.Sender}}
+	for _, party := range parties {
+		var lastID gocql.UUID
+		var writeTime int64
+		err := r.queries.getLastMessage(ctx, party[0], party[1]).Scan(&lastID, &writeTime)
+		if errors.Is(err, gocql.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if lastID != message.ID {
+			continue
+		}
+
+		if err := r.queries.setPreview(ctx, party[0], party[1], previewWriteTime(message, writeTime), preview).Exec(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func scanMessages(iter *gocql.Iter) []models.Message {
+	var messages []models.Message
+	var message models.Message
+	for iter.Scan(
+		&message.ID,
+		&message.Sender,
+		&message.Recipient,
+		&message.Timestamp,
+		&message.Content,
+		&message.GroupID,
+		&message.EditedAt,
+	) {
+		messages = append(messages, message)
+	}
+	return messages
+}
@@ -0,0 +1,176 @@
+package repositories
+
+import (
+	"chat-system/internal/models"
+	"context"
+
+	"github.com/gocql/gocql"
+)
+
+// pageBound is one of the ways queryPage bounds a page within a partition
+type pageBound int
+
+const (
+	pageUnbounded pageBound = iota
+	// Messages of the very same millisecond as the bound, past its ID
+	pageBeforeSameMillisecond
+	pageBefore
+	pageAfterSameMillisecond
+	pageAfter
+	pageBoundCount
+)
+
+var pageBoundConditions = [pageBoundCount]string{
+	pageUnbounded:             ` ORDER BY timestamp DESC`,
+	pageBeforeSameMillisecond: ` AND timestamp = ? AND id > ? ORDER BY timestamp DESC`,
+	pageBefore:                ` AND timestamp < ? ORDER BY timestamp DESC`,
+	pageAfterSameMillisecond:  ` AND timestamp = ? AND id < ? ORDER BY timestamp ASC`,
+	pageAfter:                 ` AND timestamp > ? ORDER BY timestamp ASC`,
+}
+
+// pagedStatement is a SELECT over a single partition (ending with its WHERE clause) along with its variants
+// for every page bound, with & without a LIMIT, as well as for looking up a single message
+type pagedStatement struct {
+	pages  [pageBoundCount][2]string
+	lookup string
+}
+
+func (q *CassandraQueries) pagedStatement(name, selectQuery string) pagedStatement {
+	var stmt pagedStatement
+	for bound, condition := range pageBoundConditions {
+		stmt.pages[bound][0] = q.statement(name, selectQuery+condition)
+		stmt.pages[bound][1] = q.statement(name, selectQuery+condition+` LIMIT ?`)
+	}
+	stmt.lookup = q.statement(name, selectQuery+` AND timestamp >= ? AND timestamp <= ?`)
+
+	return stmt
+}
+
+type boundedQuery struct {
+	bound pageBound
+	args  []interface{}
+}
+
+// queryPage runs a paged statement within the bounds of the page, walking the (timestamp DESC, id ASC)
+// clustering order in either direction. Messages sharing the very same millisecond are covered by a dedicated query,
+// since CQL tuple relations compare naturally rather than in clustering order. Messages are always returned newest first.
+func queryPage(
+	ctx context.Context,
+	q *CassandraQueries,
+	stmt *pagedStatement,
+	partitionKey []interface{},
+	page models.MessagesPage,
+	scan func(iter *gocql.Iter) []models.Message,
+) ([]models.Message, error) {
+	var queries []boundedQuery
+
+	switch {
+	case page.Before != nil:
+		queries = []boundedQuery{
+			{bound: pageBeforeSameMillisecond, args: []interface{}{page.Before.Timestamp, page.Before.ID}},
+			{bound: pageBefore, args: []interface{}{page.Before.Timestamp}},
+		}
+	case page.After != nil:
+		queries = []boundedQuery{
+			{bound: pageAfterSameMillisecond, args: []interface{}{page.After.Timestamp, page.After.ID}},
+			{bound: pageAfter, args: []interface{}{page.After.Timestamp}},
+		}
+	default:
+		queries = []boundedQuery{{bound: pageUnbounded}}
+	}
+
+	messages := make([]models.Message, 0)
+	for _, bounded := range queries {
+		remaining := page.Limit - len(messages)
+		if page.Limit > 0 && remaining <= 0 {
+			break
+		}
+
+		statement := stmt.pages[bounded.bound][0]
+		args := append(append([]interface{}{}, partitionKey...), bounded.args...)
+		if page.Limit > 0 {
+			statement = stmt.pages[bounded.bound][1]
+			args = append(args, remaining)
+		}
+
+		iter := q.read(ctx, statement, args...).Iter()
+		messages = append(messages, scan(iter)...)
+		if err := iter.Close(); err != nil {
+			return nil, err
+		}
+	}
+
+	if page.After != nil {
+		for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+			messages[i], messages[j] = messages[j], messages[i]
+		}
+	}
+
+	return messages, nil
+}
+
+// queryBucketedPage runs queryPage over the buckets of a partition (the partition key of the statement must end
+// with the bucket), walking them away from the bound until the page is filled. Buckets must be sorted
+// in the walking direction, i.e. newest first unless walking after the bound. Messages are always returned newest first.
+func queryBucketedPage(
+	ctx context.Context,
+	q *CassandraQueries,
+	stmt *pagedStatement,
+	partitionKey []interface{},
+	buckets []int,
+	page models.MessagesPage,
+	scan func(iter *gocql.Iter) []models.Message,
+) ([]models.Message, error) {
+	messages := make([]models.Message, 0)
+	for _, bucket := range buckets {
+		bucketPage := page
+		if page.Limit > 0 {
+			bucketPage.Limit = page.Limit - len(messages)
+			if bucketPage.Limit <= 0 {
+				break
+			}
+		}
+
+		key := append(append([]interface{}{}, partitionKey...), bucket)
+		found, err := queryPage(ctx, q, stmt, key, bucketPage, scan)
+		if err != nil {
+			return nil, err
+		}
+
+		if page.After != nil {
+			// Walking towards newer buckets, each one is newer than the messages found so far
+			messages = append(found, messages...)
+		} else {
+			messages = append(messages, found...)
+		}
+	}
+
+	return messages, nil
+}
+
+// lookupMessage finds a single message by ID within the partition of a paged statement, nil if there's no such message
+func lookupMessage(
+	ctx context.Context,
+	q *CassandraQueries,
+	stmt *pagedStatement,
+	partitionKey []interface{},
+	id gocql.UUID,
+	scan func(iter *gocql.Iter) []models.Message,
+) (*models.Message, error) {
+	from := models.TimestampOf(id)
+	args := append(append([]interface{}{}, partitionKey...), from, from.Add(MSG_LOOKUP_WINDOW))
+
+	iter := q.read(ctx, stmt.lookup, args...).Iter()
+	messages := scan(iter)
+	if err := iter.Close(); err != nil {
+		return nil, err
+	}
+
+	for i := range messages {
+		if messages[i].ID == id {
+			return &messages[i], nil
+		}
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,577 @@
+package repositories
+
+import (
+	"chat-system/internal/cassandra"
+	"chat-system/internal/models"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/gocql/gocql"
+	"golang.org/x/sync/errgroup"
+)
+
+// Number of the single-partition writes of a fan-out running at once
+const FAN_OUT_CONCURRENCY = 16
+
+// CassandraTables names the keyspace & the tables the Cassandra repositories run on
+type CassandraTables struct {
+	Keyspace             string
+	Users                string
+	Messages             string
+	MessageBuckets       string
+	Conversations        string
+	ConversationMessages string
+	Groups               string
+	GroupMembers         string
+	GroupMessages        string
+}
+
+// CassandraQueries holds every statement the Cassandra repositories run, built once out of the validated
+// names of the tables, along with the latencies of their executions
+type CassandraQueries struct {
+	db        *gocql.Session
+	latencies *cassandra.Latencies
+	// execute runs the single-partition writes of the fan-outs
+	execute func(ctx context.Context, write boundWrite) error
+	// All of the statements, in the order they were built
+	statements []string
+
+	selectUser string
+	insertUser string
+
+	insertMessage       string
+	insertMemberMessage string
+	messages            pagedStatement
+	selectMessageOwners string
+	updateMessage       string
+	deleteMessage       string
+
+	insertBucket        string
+	selectBuckets       string
+	selectBucketsBefore string
+	selectBucketsAfter  string
+
+	insertThreadMessage string
+	threadMessages      pagedStatement
+	updateThreadMessage string
+	deleteThreadMessage string
+
+	insertConversation   string
+	selectConversations  string
+	selectLastReadID     string
+	updateLastReadID     string
+	updatePeerLastReadID string
+	selectReadMarks      string
+	selectLastMessage    string
+	updatePreview        string
+
+	insertGroup        string
+	insertMember       string
+	selectMembers      string
+	deleteMember       string
+	insertGroupMessage string
+	groupMessages      pagedStatement
+	updateGroupMessage string
+	deleteGroupMessage string
+}
+
+// NewCassandraQueries validates the names of the tables & builds the statements of the repositories out of them
+func NewCassandraQueries(db *gocql.Session, tables CassandraTables) (*CassandraQueries, error) {
+	q := &CassandraQueries{
+		db:        db,
+		latencies: cassandra.NewLatencies(),
+	}
+	q.execute = q.executeWrite
+
+	names := []*string{
+		&tables.Users,
+		&tables.Messages,
+		&tables.MessageBuckets,
+		&tables.Conversations,
+		&tables.ConversationMessages,
+		&tables.Groups,
+		&tables.GroupMembers,
+		&tables.GroupMessages,
+	}
+	for _, name := range names {
+		qualified, err := cassandra.QualifiedTable(tables.Keyspace, *name)
+		if err != nil {
+			return nil, err
+		}
+		*name = qualified
+	}
+
+	q.selectUser = q.statement("users.get", `SELECT id, username, password FROM `+tables.Users+` WHERE username = ? LIMIT 1`)
+	q.insertUser = q.statement("users.insert", `INSERT INTO `+tables.Users+` (id, username, password) VALUES (?, ?, ?)`)
+
+	q.insertMessage = q.statement(
+		"messages.insert",
+		`INSERT INTO `+tables.Messages+`
+		(user, bucket, timestamp, id, sender, recipient, content)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+	)
+	q.insertMemberMessage = q.statement(
+		"messages.insert_group",
+		`INSERT INTO `+tables.Messages+`
+		(user, bucket, timestamp, id, sender, recipient, content, group_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+	)
+	q.messages = q.pagedStatement(
+		"messages.page",
+		`SELECT id, sender, recipient, timestamp, content, group_id, edited_at
+		FROM `+tables.Messages+`
+		WHERE user = ? AND bucket = ?`,
+	)
+	q.selectMessageOwners = q.statement(
+		"messages.owners",
+		`SELECT user FROM `+tables.Messages+` WHERE user IN ? AND bucket = ? AND timestamp = ? AND id = ?`,
+	)
+	q.updateMessage = q.statement(
+		"messages.update",
+		`UPDATE `+tables.Messages+` SET content = ?, edited_at = ? WHERE user = ? AND bucket = ? AND timestamp = ? AND id = ?`,
+	)
+	q.deleteMessage = q.statement(
+		"messages.delete",
+		`DELETE FROM `+tables.Messages+` WHERE user = ? AND bucket = ? AND timestamp = ? AND id = ?`,
+	)
+
+	q.insertBucket = q.statement("message_buckets.insert", `INSERT INTO `+tables.MessageBuckets+` (user, bucket) VALUES (?, ?)`)
+	q.selectBuckets = q.statement("message_buckets.list", `SELECT bucket FROM `+tables.MessageBuckets+` WHERE user = ?`)
+	q.selectBucketsBefore = q.statement(
+		"message_buckets.list_before",
+		`SELECT bucket FROM `+tables.MessageBuckets+` WHERE user = ? AND bucket <= ?`,
+	)
+	q.selectBucketsAfter = q.statement(
+		"message_buckets.list_after",
+		`SELECT bucket FROM `+tables.MessageBuckets+` WHERE user = ? AND bucket >= ? ORDER BY bucket ASC`,
+	)
+
+	q.insertThreadMessage = q.statement(
+		"conversation_messages.insert",
+		`INSERT INTO `+tables.ConversationMessages+`
+		(user_a, user_b, timestamp, id, sender, recipient, content)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+	)
+	q.threadMessages = q.pagedStatement(
+		"conversation_messages.page",
+		`SELECT id, sender, recipient, timestamp, content, edited_at
+		FROM `+tables.ConversationMessages+`
+		WHERE user_a = ? AND user_b = ?`,
+	)
+	q.updateThreadMessage = q.statement(
+		"conversation_messages.update",
+		`UPDATE `+tables.ConversationMessages+` SET content = ?, edited_at = ?
+		WHERE user_a = ? AND user_b = ? AND timestamp = ? AND id = ?`,
+	)
+	q.deleteThreadMessage = q.statement(
+		"conversation_messages.delete",
+		`DELETE FROM `+tables.ConversationMessages+` WHERE user_a = ? AND user_b = ? AND timestamp = ? AND id = ?`,
+	)
+
+	q.insertConversation = q.statement(
+		"conversations.insert",
+		`INSERT INTO `+tables.Conversations+`
+		(user, peer, last_message_id, last_message_sender, last_message_preview, last_message_at)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+	)
+	q.selectConversations = q.statement(
+		"conversations.list",
+		`SELECT peer, last_message_id, last_message_sender, last_message_preview, last_message_at,
+		last_read_id, peer_last_read_id
+		FROM `+tables.Conversations+`
+		WHERE user = ?`,
+	)
+	q.selectLastReadID = q.statement(
+		"conversations.last_read",
+		`SELECT last_read_id FROM `+tables.Conversations+` WHERE user = ? AND peer = ?`,
+	)
+	q.updateLastReadID = q.statement(
+		"conversations.update_last_read",
+		`UPDATE `+tables.Conversations+` SET last_read_id = ? WHERE user = ? AND peer = ?`,
+	)
+	q.updatePeerLastReadID = q.statement(
+		"conversations.update_peer_last_read",
+		`UPDATE `+tables.Conversations+` SET peer_last_read_id = ? WHERE user = ? AND peer = ?`,
+	)
+	q.selectReadMarks = q.statement(
+		"conversations.read_marks",
+		`SELECT peer, peer_last_read_id FROM `+tables.Conversations+` WHERE user = ?`,
+	)
+	q.selectLastMessage = q.statement(
+		"conversations.last_message",
+		`SELECT last_message_id, WRITETIME(last_message_preview) FROM `+tables.Conversations+` WHERE user = ? AND peer = ?`,
+	)
+	q.updatePreview = q.statement(
+		"conversations.update_preview",
+		`UPDATE `+tables.Conversations+` USING TIMESTAMP ? SET last_message_preview = ? WHERE user = ? AND peer = ?`,
+	)
+
+	q.insertGroup = q.statement(
+		"groups.insert",
+		`INSERT INTO `+tables.Groups+` (id, name, owner, created_at) VALUES (?, ?, ?, ?)`,
+	)
+	q.insertMember = q.statement(
+		"group_members.insert",
+		`INSERT INTO `+tables.GroupMembers+` (group_id, username, role, joined_at) VALUES (?, ?, ?, ?)`,
+	)
+	q.selectMembers = q.statement(
+		"group_members.list",
+		`SELECT group_id, username, role, joined_at FROM `+tables.GroupMembers+` WHERE group_id = ?`,
+	)
+	q.deleteMember = q.statement(
+		"group_members.delete",
+		`DELETE FROM `+tables.GroupMembers+` WHERE group_id = ? AND username = ?`,
+	)
+	q.insertGroupMessage = q.statement(
+		"group_messages.insert",
+		`INSERT INTO `+tables.GroupMessages+`
+		(group_id, timestamp, id, sender, content)
+		VALUES (?, ?, ?, ?, ?)`,
+	)
+	q.groupMessages = q.pagedStatement(
+		"group_messages.page",
+		`SELECT id, sender, timestamp, content, group_id, edited_at
+		FROM `+tables.GroupMessages+`
+		WHERE group_id = ?`,
+	)
+	q.updateGroupMessage = q.statement(
+		"group_messages.update",
+		`UPDATE `+tables.GroupMessages+` SET content = ?, edited_at = ? WHERE group_id = ? AND timestamp = ? AND id = ?`,
+	)
+	q.deleteGroupMessage = q.statement(
+		"group_messages.delete",
+		`DELETE FROM `+tables.GroupMessages+` WHERE group_id = ? AND timestamp = ? AND id = ?`,
+	)
+
+	return q, nil
+}
+
+// Returned by the binding of the statements being prepared, so that they don't get executed
+var errPrepareOnly = errors.New("statement prepared only")
+
+// Prepare prepares every statement up front, so that the ones not matching the schema fail at startup
+// rather than on their first use. Connections opened later prepare them on their first use as usual.
+func (q *CassandraQueries) Prepare() error {
+	for _, statement := range q.statements {
+		err := q.db.Bind(statement, func(*gocql.QueryInfo) ([]interface{}, error) {
+			return nil, errPrepareOnly
+		}).RetryPolicy(nil).Exec()
+		if !errors.Is(err, errPrepareOnly) {
+			return fmt.Errorf("failed to prepare '%s': %w", q.latencies.NameOf(statement), err)
+		}
+	}
+
+	return nil
+}
+
+// Latencies returns the latencies of the statements & batches run so far, the ones taking the most time overall first
+func (q *CassandraQueries) Latencies() []cassandra.QueryLatency {
+	return q.latencies.Snapshot()
+}
+
+func (q *CassandraQueries) statement(name, statement string) string {
+	q.latencies.Name(statement, name)
+	q.statements = append(q.statements, statement)
+
+	return statement
+}
+
+// read runs a statement as a read through the session, recording its latency
+func (q *CassandraQueries) read(ctx context.Context, statement string, values ...interface{}) *gocql.Query {
+	return cassandra.Read(q.db.Query(statement, values...)).Observer(q.latencies).WithContext(ctx)
+}
+
+// write runs a statement as a write through the session, recording its latency
+func (q *CassandraQueries) write(ctx context.Context, statement string, values ...interface{}) *gocql.Query {
+	return cassandra.Write(q.db.Query(statement, values...)).Observer(q.latencies).WithContext(ctx)
+}
+
+// newBatch starts a write batch, whose latency is recorded under the name
+func (q *CassandraQueries) newBatch(ctx context.Context, name string) *gocql.Batch {
+	return cassandra.NewWriteBatch(q.db).Observer(q.latencies.Batch(name)).WithContext(ctx)
+}
+
+// boundWrite is a statement writing to a single partition, along with its values
+type boundWrite struct {
+	statement string
+	values    []interface{}
+}
+
+func (q *CassandraQueries) executeWrite(ctx context.Context, write boundWrite) error {
+	return q.write(ctx, write.statement, write.values...).Idempotent(true).Exec()
+}
+
+// fanOut runs the writes concurrently rather than within a batch, which copies of a long message to every member
+// of a group would take past the size Cassandra accepts. A failure leaves the writes done so far in place,
+// which is fine since they're idempotent.
+func (q *CassandraQueries) fanOut(ctx context.Context, writes []boundWrite) error {
+	group, ctx := errgroup.WithContext(ctx)
+	group.SetLimit(FAN_OUT_CONCURRENCY)
+
+	for _, write := range writes {
+		group.Go(func() error {
+			return q.execute(ctx, write)
+		})
+	}
+
+	return group.Wait()
+}
+
+func (q *CassandraQueries) getUser(ctx context.Context, username string) *gocql.Query {
+	return q.read(ctx, q.selectUser, username)
+}
+
+func (q *CassandraQueries) createUser(ctx context.Context, user *models.User) *gocql.Query {
+	return q.write(ctx, q.insertUser, user.ID, user.Username, user.Password)
+}
+
+// addMessage adds the direct message to the messages of the owner
+func (q *CassandraQueries) addMessage(batch *gocql.Batch, owner string, message *models.Message) {
+	batch.Query(
+		q.insertMessage,
+		owner,
+		models.BucketOf(message.Timestamp),
+		message.Timestamp,
+		message.ID,
+		message.Sender,
+		message.Recipient,
+		message.Content,
+	)
+}
+
+// memberMessages adds the group message to the messages of each of the members
+func (q *CassandraQueries) memberMessages(members []string, message *models.Message) []boundWrite {
+	writes := make([]boundWrite, 0, len(members))
+	for _, member := range members {
+		writes = append(writes, boundWrite{
+			statement: q.insertMemberMessage,
+			values: []interface{}{
+				member,
+				models.BucketOf(message.Timestamp),
+				message.Timestamp,
+				message.ID,
+				message.Sender,
+				message.Recipient,
+				message.Content,
+				message.GroupID,
+			},
+		})
+	}
+	return writes
+}
+
+// messageOwners returns which of the users still have the message within their messages,
+// so that updating it doesn't bring it back for those who deleted it on their side
+func (q *CassandraQueries) messageOwners(ctx context.Context, users []string, message *models.Message) ([]string, error) {
+	iter := q.read(ctx, q.selectMessageOwners, users, models.BucketOf(message.Timestamp), message.Timestamp, message.ID).Iter()
+
+	var owners []string
+	var owner string
+	for iter.Scan(&owner) {
+		owners = append(owners, owner)
+	}
+	if err := iter.Close(); err != nil {
+		return nil, err
+	}
+
+	return owners, nil
+}
+
+func (q *CassandraQueries) editMessage(batch *gocql.Batch, owner string, message *models.Message) {
+	write := q.editedMessage(owner, message)
+	batch.Query(write.statement, write.values...)
+}
+
+// editedMessages updates the message within the messages of each of the owners
+func (q *CassandraQueries) editedMessages(owners []string, message *models.Message) []boundWrite {
+	writes := make([]boundWrite, 0, len(owners))
+	for _, owner := range owners {
+		writes = append(writes, q.editedMessage(owner, message))
+	}
+	return writes
+}
+
+func (q *CassandraQueries) editedMessage(owner string, message *models.Message) boundWrite {
+	return boundWrite{
+		statement: q.updateMessage,
+		values: []interface{}{
+			message.Content,
+			message.EditedAt,
+			owner,
+			models.BucketOf(message.Timestamp),
+			message.Timestamp,
+			message.ID,
+		},
+	}
+}
+
+func (q *CassandraQueries) removeMessage(batch *gocql.Batch, owner string, message *models.Message) {
+	write := q.removedMessage(owner, message)
+	batch.Query(write.statement, write.values...)
+}
+
+// removedMessages deletes the message from the messages of each of the owners
+func (q *CassandraQueries) removedMessages(owners []string, message *models.Message) []boundWrite {
+	writes := make([]boundWrite, 0, len(owners))
+	for _, owner := range owners {
+		writes = append(writes, q.removedMessage(owner, message))
+	}
+	return writes
+}
+
+func (q *CassandraQueries) removedMessage(owner string, message *models.Message) boundWrite {
+	return boundWrite{
+		statement: q.deleteMessage,
+		values:    []interface{}{owner, models.BucketOf(message.Timestamp), message.Timestamp, message.ID},
+	}
+}
+
+func (q *CassandraQueries) removeMessageForUser(ctx context.Context, username string, message *models.Message) *gocql.Query {
+	return q.write(ctx, q.deleteMessage, username, models.BucketOf(message.Timestamp), message.Timestamp, message.ID)
+}
+
+// addUserBuckets records the bucket as holding messages of the users, so reads know which buckets to walk
+func (q *CassandraQueries) addUserBuckets(batch *gocql.Batch, users []string, bucket int) {
+	for _, user := range users {
+		batch.Query(q.insertBucket, user, bucket)
+	}
+}
+
+// userBuckets lists the buckets holding messages of the user the page may cover, sorted in its walking direction
+func (q *CassandraQueries) userBuckets(ctx context.Context, username string, page models.MessagesPage) ([]int, error) {
+	var query *gocql.Query
+	switch {
+	case page.Before != nil:
+		query = q.read(ctx, q.selectBucketsBefore, username, models.BucketOf(page.Before.Timestamp))
+	case page.After != nil:
+		query = q.read(ctx, q.selectBucketsAfter, username, models.BucketOf(page.After.Timestamp))
+	default:
+		query = q.read(ctx, q.selectBuckets, username)
+	}
+
+	iter := query.Iter()
+
+	var buckets []int
+	var bucket int
+	for iter.Scan(&bucket) {
+		buckets = append(buckets, bucket)
+	}
+	if err := iter.Close(); err != nil {
+		return nil, err
+	}
+
+	return buckets, nil
+}
+
+func (q *CassandraQueries) addThreadMessage(batch *gocql.Batch, message *models.Message) {
+	userA, userB := conversationKey(message.Sender, message.Recipient)
+	batch.Query(
+		q.insertThreadMessage,
+		userA,
+		userB,
+		message.Timestamp,
+		message.ID,
+		message.Sender,
+		message.Recipient,
+		message.Content,
+	)
+}
+
+func (q *CassandraQueries) editThreadMessage(batch *gocql.Batch, message *models.Message) {
+	userA, userB := conversationKey(message.Sender, message.Recipient)
+	batch.Query(
+		q.updateThreadMessage,
+		message.Content,
+		message.EditedAt,
+		userA,
+		userB,
+		message.Timestamp,
+		message.ID,
+	)
+}
+
+func (q *CassandraQueries) removeThreadMessage(batch *gocql.Batch, message *models.Message) {
+	userA, userB := conversationKey(message.Sender, message.Recipient)
+	batch.Query(q.deleteThreadMessage, userA, userB, message.Timestamp, message.ID)
+}
+
+// setLastMessage makes the message the last one of the conversation of the user with the peer
+func (q *CassandraQueries) setLastMessage(batch *gocql.Batch, username, peer string, message *models.Message, preview string) {
+	batch.Query(q.insertConversation, username, peer, message.ID, message.Sender, preview, message.Timestamp)
+}
+
+func (q *CassandraQueries) getConversations(ctx context.Context, username string) *gocql.Query {
+	return q.read(ctx, q.selectConversations, username)
+}
+
+func (q *CassandraQueries) getLastReadID(ctx context.Context, username, peer string) *gocql.Query {
+	return q.read(ctx, q.selectLastReadID, username, peer)
+}
+
+func (q *CassandraQueries) setLastReadID(batch *gocql.Batch, username, peer string, id gocql.UUID) {
+	batch.Query(q.updateLastReadID, id, username, peer)
+}
+
+// setPeerLastReadID keeps a copy of the read watermark of the peer on the side of the user
+func (q *CassandraQueries) setPeerLastReadID(batch *gocql.Batch, username, peer string, id gocql.UUID) {
+	batch.Query(q.updatePeerLastReadID, id, username, peer)
+}
+
+func (q *CassandraQueries) getReadMarks(ctx context.Context, username string) *gocql.Query {
+	return q.read(ctx, q.selectReadMarks, username)
+}
+
+// getLastMessage reads the last message of the conversation of the user with the peer, along with the write time of its preview
+func (q *CassandraQueries) getLastMessage(ctx context.Context, username, peer string) *gocql.Query {
+	return q.read(ctx, q.selectLastMessage, username, peer)
+}
+
+// setPreview updates the preview of the conversation of the user with the peer at the write time
+func (q *CassandraQueries) setPreview(ctx context.Context, username, peer string, writeTime int64, preview string) *gocql.Query {
+	return q.write(ctx, q.updatePreview, writeTime, preview, username, peer)
+}
+
+// messageWriteTime is the write time of the rows of a new message, in microseconds as Cassandra keeps them.
+// Messages are timestamped to the millisecond, so a newer message is always written at least 1000 later.
+func messageWriteTime(message *models.Message) int64 {
+	return message.Timestamp.UnixMilli() * 1000
+}
+
+// previewWriteTime follows the current write time of the preview of the message, so that its updates override both
+// the message & each other while staying below the write time of a newer message, for its first 999 updates
+func previewWriteTime(message *models.Message, current int64) int64 {
+	return max(current+1, messageWriteTime(message)+1)
+}
+
+func (q *CassandraQueries) addGroup(batch *gocql.Batch, group *models.Group) {
+	batch.Query(q.insertGroup, group.ID, group.Name, group.Owner, group.CreatedAt)
+}
+
+func (q *CassandraQueries) addMember(batch *gocql.Batch, member *models.GroupMember) {
+	batch.Query(q.insertMember, member.GroupID, member.Username, member.Role, member.JoinedAt)
+}
+
+func (q *CassandraQueries) createMember(ctx context.Context, member *models.GroupMember) *gocql.Query {
+	return q.write(ctx, q.insertMember, member.GroupID, member.Username, member.Role, member.JoinedAt)
+}
+
+func (q *CassandraQueries) getMembers(ctx context.Context, groupID gocql.UUID) *gocql.Query {
+	return q.read(ctx, q.selectMembers, groupID)
+}
+
+func (q *CassandraQueries) removeMember(ctx context.Context, groupID gocql.UUID, username string) *gocql.Query {
+	return q.write(ctx, q.deleteMember, groupID, username)
+}
+
+func (q *CassandraQueries) addGroupMessage(batch *gocql.Batch, message *models.Message) {
+	batch.Query(q.insertGroupMessage, message.GroupID, message.Timestamp, message.ID, message.Sender, message.Content)
+}
+
+func (q *CassandraQueries) editGroupMessage(batch *gocql.Batch, message *models.Message) {
+	batch.Query(q.updateGroupMessage, message.Content, message.EditedAt, message.GroupID, message.Timestamp, message.ID)
+}
+
+func (q *CassandraQueries) removeGroupMessage(batch *gocql.Batch, message *models.Message) {
+	batch.Query(q.deleteGroupMessage, message.GroupID, message.Timestamp, message.ID)
+}
@@ -0,0 +1,214 @@
+package repositories
+
+import (
+	"chat-system/internal/models"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/stretchr/testify/suite"
+)
+
+const (
+	// Same as services.MAX_GROUP_MEMBERS, which can't be imported from here
+	TEST_MAX_GROUP_MEMBERS = 50
+	// Default batch_size_fail_threshold_in_kb of Cassandra
+	TEST_BATCH_SIZE_FAIL_THRESHOLD = 50 * 1024
+)
+
+type CassandraQueriesTestSuite struct {
+	suite.Suite
+	tables CassandraTables
+}
+
+func TestCassandraQueriesTestSuite(t *testing.T) {
+	suite.Run(t, new(CassandraQueriesTestSuite))
+}
+
+func (cqs *CassandraQueriesTestSuite) SetupTest() {
+	cqs.tables = CassandraTables{
+		Keyspace:             "chat",
+		Users:                "users",
+		Messages:             "user_messages",
+		MessageBuckets:       "user_message_buckets",
+		Conversations:        "conversations",
+		ConversationMessages: "conversation_messages",
+		Groups:               "groups",
+		GroupMembers:         "group_members",
+		GroupMessages:        "group_messages",
+	}
+}
+
+func (cqs *CassandraQueriesTestSuite) Test_Quotes_Table_Names() {
+	queries, err := NewCassandraQueries(nil, cqs.tables)
+	cqs.Require().NoError(err)
+
+	cqs.Equal(`SELECT id, username, password FROM "chat"."users" WHERE username = ? LIMIT 1`, queries.selectUser)
+	cqs.Equal("users.get", queries.latencies.NameOf(queries.selectUser))
+}
+
+func (cqs *CassandraQueriesTestSuite) Test_Builds_Page_Variants() {
+	queries, err := NewCassandraQueries(nil, cqs.tables)
+	cqs.Require().NoError(err)
+
+	stmt := queries.groupMessages
+	cqs.Equal(
+		`SELECT id, sender, timestamp, content, group_id, edited_at
+		FROM "chat"."group_messages"
+		WHERE group_id = ? AND timestamp = ? AND id < ? ORDER BY timestamp ASC LIMIT ?`,
+		stmt.pages[pageAfterSameMillisecond][1],
+	)
+	cqs.Equal(
+		`SELECT id, sender, timestamp, content, group_id, edited_at
+		FROM "chat"."group_messages"
+		WHERE group_id = ? ORDER BY timestamp DESC`,
+		stmt.pages[pageUnbounded][0],
+	)
+
+	for _, statement := range []string{stmt.pages[pageBefore][0], stmt.pages[pageAfter][1], stmt.lookup} {
+		cqs.Equal("group_messages.page", queries.latencies.NameOf(statement))
+	}
+}
+
+func (cqs *CassandraQueriesTestSuite) Test_Names_Bucket_Listings_Apart() {
+	queries, err := NewCassandraQueries(nil, cqs.tables)
+	cqs.Require().NoError(err)
+
+	cqs.Equal("message_buckets.list", queries.latencies.NameOf(queries.selectBuckets))
+	cqs.Equal("message_buckets.list_before", queries.latencies.NameOf(queries.selectBucketsBefore))
+	cqs.Equal("message_buckets.list_after", queries.latencies.NameOf(queries.selectBucketsAfter))
+}
+
+func (cqs *CassandraQueriesTestSuite) Test_Rejects_Invalid_Table_Names() {
+	cqs.tables.Messages = "user_messages WHERE user = 'admin'"
+
+	_, err := NewCassandraQueries(nil, cqs.tables)
+
+	cqs.Error(err)
+}
+
+func (cqs *CassandraQueriesTestSuite) Test_Rejects_Invalid_Keyspace() {
+	cqs.tables.Keyspace = "chat.users"
+
+	_, err := NewCassandraQueries(nil, cqs.tables)
+
+	cqs.Error(err)
+}
+
+func (cqs *CassandraQueriesTestSuite) Test_Fans_Out_Long_Messages_To_Full_Groups() {
+	queries, err := NewCassandraQueries(nil, cqs.tables)
+	cqs.Require().NoError(err)
+
+	var mu sync.Mutex
+	var running, maxRunning int
+	written := make(map[string]int)
+	queries.execute = func(ctx context.Context, write boundWrite) error {
+		mu.Lock()
+		running++
+		maxRunning = max(maxRunning, running)
+		written[write.values[0].(string)] += len(write.values[6].(string))
+		mu.Unlock()
+
+		time.Sleep(time.Millisecond)
+
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return nil
+	}
+
+	groupID := gocql.TimeUUID()
+	// Longest content allowed, out of 4 bytes runes
+	message := &models.Message{
+		ID:        gocql.TimeUUID(),
+		Sender:    "member0",
+		Timestamp: time.Now(),
+		Content:   strings.Repeat("𝄞", 1000),
+		GroupID:   &groupID,
+	}
+	members := make([]string, TEST_MAX_GROUP_MEMBERS)
+	for i := range members {
+		members[i] = fmt.Sprintf("member%d", i)
+	}
+	cqs.Greater(len(members)*len(message.Content), TEST_BATCH_SIZE_FAIL_THRESHOLD, "Too big for a single batch")
+
+	cqs.NoError(queries.fanOut(context.Background(), queries.memberMessages(members, message)))
+
+	cqs.Len(written, TEST_MAX_GROUP_MEMBERS)
+	for _, member := range members {
+		cqs.Equal(len(message.Content), written[member], "A single copy of the content per write")
+	}
+	cqs.LessOrEqual(maxRunning, FAN_OUT_CONCURRENCY)
+}
+
+func (cqs *CassandraQueriesTestSuite) Test_Fan_Out_Fails_With_Any_Write() {
+	queries, err := NewCassandraQueries(nil, cqs.tables)
+	cqs.Require().NoError(err)
+
+	queries.execute = func(ctx context.Context, write boundWrite) error {
+		if write.values[0] == "member1" {
+			return gocql.ErrTimeoutNoResponse
+		}
+		return nil
+	}
+
+	groupID := gocql.TimeUUID()
+	message := &models.Message{ID: gocql.TimeUUID(), Sender: "member0", Timestamp: time.Now(), Content: "Hi", GroupID: &groupID}
+
+	err = queries.fanOut(context.Background(), queries.memberMessages([]string{"member0", "member1", "member2"}, message))
+
+	cqs.ErrorIs(err, gocql.ErrTimeoutNoResponse)
+}
+
+func (cqs *CassandraQueriesTestSuite) Test_Fans_Out_Group_Message_Deletion() {
+	queries, err := NewCassandraQueries(nil, cqs.tables)
+	cqs.Require().NoError(err)
+
+	var mu sync.Mutex
+	deleted := make(map[string]int)
+	queries.execute = func(ctx context.Context, write boundWrite) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		cqs.Equal(queries.deleteMessage, write.statement)
+		deleted[write.values[0].(string)]++
+		if write.values[0] == "member1" {
+			return gocql.ErrTimeoutNoResponse
+		}
+		return nil
+	}
+
+	groupID := gocql.TimeUUID()
+	message := &models.Message{ID: gocql.TimeUUID(), Sender: "member0", Timestamp: time.Now(), Content: "Hi", GroupID: &groupID}
+	members := make([]string, TEST_MAX_GROUP_MEMBERS)
+	for i := range members {
+		members[i] = fmt.Sprintf("member%d", i)
+	}
+
+	// Failing before the timeline is touched, as there's no session to run its batch
+	err = NewCassandraGroupRepository(queries).DeleteGroupMessage(context.Background(), message, members)
+
+	cqs.ErrorIs(err, gocql.ErrTimeoutNoResponse)
+	cqs.Equal(1, deleted["member1"])
+	for member, count := range deleted {
+		cqs.Equal(1, count, "A single write per member, %s", member)
+	}
+}
+
+func (cqs *CassandraQueriesTestSuite) Test_Preview_Write_Times() {
+	message := &models.Message{Timestamp: time.UnixMilli(1717171717171)}
+	newer := &models.Message{Timestamp: message.Timestamp.Add(time.Millisecond)}
+
+	sent := messageWriteTime(message)
+	edited := previewWriteTime(message, sent)
+	deleted := previewWriteTime(message, edited)
+
+	cqs.Greater(edited, sent, "Updates override the message")
+	cqs.Greater(deleted, edited, "Updates override each other")
+	cqs.Less(deleted, messageWriteTime(newer), "Newer messages override the updates")
+	cqs.Equal(sent+1, previewWriteTime(message, 0), "Previews never written")
+}
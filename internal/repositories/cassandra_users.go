@@ -0,0 +1,35 @@
+package repositories
+
+import (
+	"chat-system/internal/models"
+	"context"
+	"errors"
+
+	"github.com/gocql/gocql"
+)
+
+type cassandraUserRepository struct {
+	queries *CassandraQueries
+}
+
+func NewCassandraUserRepository(queries *CassandraQueries) *cassandraUserRepository {
+	return &cassandraUserRepository{queries: queries}
+}
+
+func (r *cassandraUserRepository) GetUser(ctx context.Context, username string) (*models.User, error) {
+	var user models.User
+
+	err := r.queries.getUser(ctx, username).Scan(&user.ID, &user.Username, &user.Password)
+	if errors.Is(err, gocql.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func (r *cassandraUserRepository) CreateUser(ctx context.Context, user *models.User) error {
+	return r.queries.createUser(ctx, user).Exec()
+}
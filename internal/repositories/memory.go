@@ -0,0 +1,135 @@
+package repositories
+
+import (
+	"chat-system/internal/models"
+	"errors"
+	"sort"
+	"sync"
+
+	"github.com/gocql/gocql"
+)
+
+// Same error Cassandra returns, so either storage fails alike on missing keys
+var ErrEmptyKey = errors.New("Key may not be empty")
+
+// MemoryStore keeps all the app data of a single process, e.g for tests or running locally without any DB.
+// Messages are kept sorted newest first by their (timestamp, id) key, same as the clustering order of Cassandra.
+type MemoryStore struct {
+	mu            sync.RWMutex
+	users         map[string]models.User
+	messages      map[string][]models.Message
+	threads       map[[2]string][]models.Message
+	conversations map[string]map[string]models.Conversation
+	groups        map[gocql.UUID]models.Group
+	members       map[gocql.UUID]map[string]models.GroupMember
+	groupMessages map[gocql.UUID][]models.Message
+}
+
+func NewMemoryStore() *MemoryStore {
+	store := &MemoryStore{}
+	store.Reset()
+	return store
+}
+
+// Reset drops all the data of the store
+func (s *MemoryStore) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.users = make(map[string]models.User)
+	s.messages = make(map[string][]models.Message)
+	s.threads = make(map[[2]string][]models.Message)
+	s.conversations = make(map[string]map[string]models.Conversation)
+	s.groups = make(map[gocql.UUID]models.Group)
+	s.members = make(map[gocql.UUID]map[string]models.GroupMember)
+	s.groupMessages = make(map[gocql.UUID][]models.Message)
+}
+
+func checkKeys(keys ...string) error {
+	for _, key := range keys {
+		if key == "" {
+			return ErrEmptyKey
+		}
+	}
+	return nil
+}
+
+// threadKey maps both users to the very same thread
+func threadKey(user, peer string) [2]string {
+	userA, userB := conversationKey(user, peer)
+	return [2]string{userA, userB}
+}
+
+// upsertMessage inserts the message at its place, replacing the one of the same key if any
+func upsertMessage(messages []models.Message, message models.Message) []models.Message {
+	key := models.KeyOf(&message)
+	i := sort.Search(len(messages), func(i int) bool { return !models.KeyOf(&messages[i]).Precedes(key) })
+
+	if i < len(messages) && !key.Precedes(models.KeyOf(&messages[i])) {
+		messages[i] = message
+		return messages
+	}
+
+	messages = append(messages, models.Message{})
+	copy(messages[i+1:], messages[i:])
+	messages[i] = message
+	return messages
+}
+
+// indexOfMessage finds the message of that key, -1 if there's no such message
+func indexOfMessage(messages []models.Message, key models.MessageKey) int {
+	i := sort.Search(len(messages), func(i int) bool { return !models.KeyOf(&messages[i]).Precedes(key) })
+	if i < len(messages) && messages[i].ID == key.ID {
+		return i
+	}
+	return -1
+}
+
+func editMessage(messages []models.Message, message *models.Message) {
+	if i := indexOfMessage(messages, models.KeyOf(message)); i >= 0 {
+		messages[i].Content = message.Content
+		messages[i].EditedAt = message.EditedAt
+	}
+}
+
+func deleteMessage(messages []models.Message, key models.MessageKey) []models.Message {
+	if i := indexOfMessage(messages, key); i >= 0 {
+		return append(messages[:i], messages[i+1:]...)
+	}
+	return messages
+}
+
+// findMessage looks a message up by ID, nil if there's no such message
+func findMessage(messages []models.Message, id gocql.UUID) *models.Message {
+	for i := range messages {
+		if messages[i].ID == id {
+			message := messages[i]
+			return &message
+		}
+	}
+	return nil
+}
+
+// memoryPage copies the messages within the bounds of the page, newest first
+func memoryPage(messages []models.Message, page models.MessagesPage) []models.Message {
+	start, end := 0, len(messages)
+
+	switch {
+	case page.Before != nil:
+		start = sort.Search(len(messages), func(i int) bool { return page.Before.Precedes(models.KeyOf(&messages[i])) })
+		if page.Limit > 0 && end-start > page.Limit {
+			end = start + page.Limit
+		}
+	case page.After != nil:
+		end = sort.Search(len(messages), func(i int) bool { return !models.KeyOf(&messages[i]).Precedes(*page.After) })
+		if page.Limit > 0 && end-start > page.Limit {
+			start = end - page.Limit
+		}
+	default:
+		if page.Limit > 0 && end > page.Limit {
+			end = page.Limit
+		}
+	}
+
+	return append(make([]models.Message, 0, end-start), messages[start:end]...)
+}
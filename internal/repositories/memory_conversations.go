@@ -0,0 +1,104 @@
+package repositories
+
+import (
+	"chat-system/internal/models"
+	"context"
+
+	"github.com/gocql/gocql"
+)
+
+type memoryConversationRepository struct {
+	store *MemoryStore
+}
+
+func NewMemoryConversationRepository(store *MemoryStore) *memoryConversationRepository {
+	return &memoryConversationRepository{store: store}
+}
+
+func (r *memoryConversationRepository) GetConversations(_ context.Context, username string) ([]models.Conversation, error) {
+	if err := checkKeys(username); err != nil {
+		return nil, err
+	}
+
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	conversations := make([]models.Conversation, 0, len(r.store.conversations[username]))
+	for _, conversation := range r.store.conversations[username] {
+		conversations = append(conversations, conversation)
+	}
+
+	return conversations, nil
+}
+
+func (r *memoryConversationRepository) GetConversationMessages(_ context.Context, username, peer string, page models.MessagesPage) ([]models.Message, error) {
+	if err := checkKeys(username, peer); err != nil {
+		return nil, err
+	}
+
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	return memoryPage(r.store.threads[threadKey(username, peer)], page), nil
+}
+
+func (r *memoryConversationRepository) GetConversationMessage(_ context.Context, username, peer string, id gocql.UUID) (*models.Message, error) {
+	if err := checkKeys(username, peer); err != nil {
+		return nil, err
+	}
+
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	return findMessage(r.store.threads[threadKey(username, peer)], id), nil
+}
+
+func (r *memoryConversationRepository) GetLastReadID(_ context.Context, username, peer string) (*gocql.UUID, error) {
+	if err := checkKeys(username, peer); err != nil {
+		return nil, err
+	}
+
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	return r.store.conversations[username][peer].LastReadID, nil
+}
+
+func (r *memoryConversationRepository) SetLastReadID(_ context.Context, username, peer string, id gocql.UUID) error {
+	if err := checkKeys(username, peer); err != nil {
+		return err
+	}
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	// Conversations only start by sending a message
+	if conversation, ok := r.store.conversations[username][peer]; ok {
+		conversation.LastReadID = &id
+		r.store.conversations[username][peer] = conversation
+	}
+	if conversation, ok := r.store.conversations[peer][username]; ok {
+		conversation.PeerLastReadID = &id
+		r.store.conversations[peer][username] = conversation
+	}
+
+	return nil
+}
+
+func (r *memoryConversationRepository) GetReadMarks(_ context.Context, username string) (map[string]gocql.UUID, error) {
+	if err := checkKeys(username); err != nil {
+		return nil, err
+	}
+
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	marks := make(map[string]gocql.UUID)
+	for peer, conversation := range r.store.conversations[username] {
+		if conversation.PeerLastReadID != nil {
+			marks[peer] = *conversation.PeerLastReadID
+		}
+	}
+
+	return marks, nil
+}
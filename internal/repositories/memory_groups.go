@@ -0,0 +1,123 @@
+package repositories
+
+import (
+	"chat-system/internal/models"
+	"context"
+	"sort"
+
+	"github.com/gocql/gocql"
+)
+
+type memoryGroupRepository struct {
+	store *MemoryStore
+}
+
+func NewMemoryGroupRepository(store *MemoryStore) *memoryGroupRepository {
+	return &memoryGroupRepository{store: store}
+}
+
+func (r *memoryGroupRepository) CreateGroup(_ context.Context, group *models.Group, members []models.GroupMember) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	r.store.groups[group.ID] = *group
+	for _, member := range members {
+		r.addMember(member)
+	}
+
+	return nil
+}
+
+// GetMembers lists the members sorted by username, same as the clustering order of Cassandra
+func (r *memoryGroupRepository) GetMembers(_ context.Context, groupID gocql.UUID) ([]models.GroupMember, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	members := make([]models.GroupMember, 0, len(r.store.members[groupID]))
+	for _, member := range r.store.members[groupID] {
+		members = append(members, member)
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i].Username < members[j].Username })
+
+	return members, nil
+}
+
+func (r *memoryGroupRepository) AddMember(_ context.Context, member *models.GroupMember) error {
+	if err := checkKeys(member.Username); err != nil {
+		return err
+	}
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	r.addMember(*member)
+	return nil
+}
+
+func (r *memoryGroupRepository) RemoveMember(_ context.Context, groupID gocql.UUID, username string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	delete(r.store.members[groupID], username)
+	return nil
+}
+
+func (r *memoryGroupRepository) CreateGroupMessage(_ context.Context, message *models.Message, members []string) error {
+	if err := checkKeys(members...); err != nil {
+		return err
+	}
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	groupID := *message.GroupID
+	r.store.groupMessages[groupID] = upsertMessage(r.store.groupMessages[groupID], *message)
+	for _, member := range members {
+		r.store.messages[member] = upsertMessage(r.store.messages[member], *message)
+	}
+
+	return nil
+}
+
+func (r *memoryGroupRepository) EditGroupMessage(_ context.Context, message *models.Message, members []string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	editMessage(r.store.groupMessages[*message.GroupID], message)
+	for _, member := range members {
+		editMessage(r.store.messages[member], message)
+	}
+
+	return nil
+}
+
+func (r *memoryGroupRepository) DeleteGroupMessage(_ context.Context, message *models.Message, members []string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	key := models.KeyOf(message)
+	groupID := *message.GroupID
+	r.store.groupMessages[groupID] = deleteMessage(r.store.groupMessages[groupID], key)
+	for _, member := range members {
+		r.store.messages[member] = deleteMessage(r.store.messages[member], key)
+	}
+
+	return nil
+}
+
+func (r *memoryGroupRepository) GetGroupMessages(_ context.Context, groupID gocql.UUID, page models.MessagesPage) ([]models.Message, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	return memoryPage(r.store.groupMessages[groupID], page), nil
+}
+
+// addMember adds the member or updates their role, the store must be locked
+func (r *memoryGroupRepository) addMember(member models.GroupMember) {
+	members, ok := r.store.members[member.GroupID]
+	if !ok {
+		members = make(map[string]models.GroupMember)
+		r.store.members[member.GroupID] = members
+	}
+	members[member.Username] = member
+}
@@ -0,0 +1,139 @@
+package repositories
+
+import (
+	"chat-system/internal/models"
+	"context"
+
+	"github.com/gocql/gocql"
+)
+
+type memoryMessageRepository struct {
+	store *MemoryStore
+}
+
+func NewMemoryMessageRepository(store *MemoryStore) *memoryMessageRepository {
+	return &memoryMessageRepository{store: store}
+}
+
+func (r *memoryMessageRepository) CreateMessage(_ context.Context, message *models.Message, preview string) error {
+	if err := checkKeys(message.Sender, message.Recipient); err != nil {
+		return err
+	}
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	for _, user := range []string{message.Sender, message.Recipient} {
+		r.store.messages[user] = upsertMessage(r.store.messages[user], *message)
+	}
+
+	thread := threadKey(message.Sender, message.Recipient)
+	r.store.threads[thread] = upsertMessage(r.store.threads[thread], *message)
+
+	parties := [][2]string{{message.Sender, message.Recipient}, {message.Recipient, message.Sender}}
+	for _, party := range parties {
+		conversations, ok := r.store.conversations[party[0]]
+		if !ok {
+			conversations = make(map[string]models.Conversation)
+			r.store.conversations[party[0]] = conversations
+		}
+
+		// Read marks are kept along the way, and a newer last message isn't replaced
+		conversation := conversations[party[1]]
+		if conversation.LastMessageAt.After(message.Timestamp) {
+			continue
+		}
+		conversation.Peer = party[1]
+		conversation.LastMessageID = message.ID
+		conversation.LastMessageSender = message.Sender
+		conversation.LastMessagePreview = preview
+		conversation.LastMessageAt = message.Timestamp
+		conversations[party[1]] = conversation
+	}
+
+	return nil
+}
+
+func (r *memoryMessageRepository) GetMessages(_ context.Context, username string, page models.MessagesPage) ([]models.Message, error) {
+	if err := checkKeys(username); err != nil {
+		return nil, err
+	}
+
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	return memoryPage(r.store.messages[username], page), nil
+}
+
+func (r *memoryMessageRepository) GetMessage(_ context.Context, username string, id gocql.UUID) (*models.Message, error) {
+	if err := checkKeys(username); err != nil {
+		return nil, err
+	}
+
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	return findMessage(r.store.messages[username], id), nil
+}
+
+func (r *memoryMessageRepository) EditMessage(_ context.Context, message *models.Message) error {
+	if err := checkKeys(message.Sender, message.Recipient); err != nil {
+		return err
+	}
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	for _, user := range []string{message.Sender, message.Recipient} {
+		editMessage(r.store.messages[user], message)
+	}
+	editMessage(r.store.threads[threadKey(message.Sender, message.Recipient)], message)
+
+	return nil
+}
+
+func (r *memoryMessageRepository) DeleteMessage(_ context.Context, message *models.Message) error {
+	if err := checkKeys(message.Sender, message.Recipient); err != nil {
+		return err
+	}
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	key := models.KeyOf(message)
+	for _, user := range []string{message.Sender, message.Recipient} {
+		r.store.messages[user] = deleteMessage(r.store.messages[user], key)
+	}
+	thread := threadKey(message.Sender, message.Recipient)
+	r.store.threads[thread] = deleteMessage(r.store.threads[thread], key)
+
+	return nil
+}
+
+func (r *memoryMessageRepository) DeleteMessageForUser(_ context.Context, username string, message *models.Message) error {
+	if err := checkKeys(username); err != nil {
+		return err
+	}
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	r.store.messages[username] = deleteMessage(r.store.messages[username], models.KeyOf(message))
+	return nil
+}
+
+func (r *memoryMessageRepository) UpdateConversationPreviews(_ context.Context, message *models.Message, preview string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	parties := [][2]string{{message.Sender, message.Recipient}, {message.Recipient, message.Sender}}
+	for _, party := range parties {
+		conversation, ok := r.store.conversations[party[0]][party[1]]
+		if ok && conversation.LastMessageID == message.ID {
+			conversation.LastMessagePreview = preview
+			r.store.conversations[party[0]][party[1]] = conversation
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,102 @@
+package repositories
+
+import (
+	"chat-system/internal/models"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/stretchr/testify/suite"
+)
+
+var ctx = context.Background()
+
+type MemoryTestSuite struct {
+	suite.Suite
+	messages *memoryMessageRepository
+	// Newest first in clustering order
+	sent []models.Message
+}
+
+func TestMemoryTestSuite(t *testing.T) {
+	suite.Run(t, new(MemoryTestSuite))
+}
+
+func (mts *MemoryTestSuite) SetupTest() {
+	mts.messages = NewMemoryMessageRepository(NewMemoryStore())
+
+	// 2 messages per millisecond, sent out of order
+	base := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	mts.sent = nil
+	for i := 0; i < 6; i++ {
+		id := gocql.UUIDFromTime(base.Add(time.Duration(i/2)*time.Millisecond + time.Duration(i%2)*time.Microsecond))
+		mts.sent = append(mts.sent, models.Message{
+			ID:        id,
+			Sender:    "user1",
+			Recipient: "user2",
+			Timestamp: models.TimestampOf(id),
+			Content:   fmt.Sprint(i),
+		})
+	}
+	for _, i := range []int{3, 0, 5, 1, 4, 2} {
+		mts.Require().NoError(mts.messages.CreateMessage(ctx, &mts.sent[i], ""))
+	}
+
+	// Milliseconds newest first, then IDs oldest first within each of them
+	mts.sent = []models.Message{mts.sent[4], mts.sent[5], mts.sent[2], mts.sent[3], mts.sent[0], mts.sent[1]}
+}
+
+func (mts *MemoryTestSuite) contents(messages []models.Message) []string {
+	contents := make([]string, 0, len(messages))
+	for _, message := range messages {
+		contents = append(contents, message.Content)
+	}
+	return contents
+}
+
+func (mts *MemoryTestSuite) Test_Sorted_In_Clustering_Order() {
+	all, err := mts.messages.GetMessages(ctx, "user2", models.MessagesPage{})
+
+	mts.NoError(err)
+	mts.Equal(mts.contents(mts.sent), mts.contents(all))
+}
+
+func (mts *MemoryTestSuite) Test_Pages_Within_The_Same_Millisecond() {
+	bound := models.KeyOf(&mts.sent[2])
+
+	before, err := mts.messages.GetMessages(ctx, "user1", models.MessagesPage{Before: &bound, Limit: 2})
+	mts.NoError(err)
+	mts.Equal(mts.contents(mts.sent[3:5]), mts.contents(before))
+
+	after, err := mts.messages.GetMessages(ctx, "user1", models.MessagesPage{After: &bound, Limit: 1})
+	mts.NoError(err)
+	mts.Equal(mts.contents(mts.sent[1:2]), mts.contents(after))
+}
+
+func (mts *MemoryTestSuite) Test_Delete_For_User_Only() {
+	mts.NoError(mts.messages.DeleteMessageForUser(ctx, "user2", &mts.sent[0]))
+
+	gone, err := mts.messages.GetMessage(ctx, "user2", mts.sent[0].ID)
+	mts.NoError(err)
+	mts.Nil(gone)
+
+	kept, err := mts.messages.GetMessage(ctx, "user1", mts.sent[0].ID)
+	mts.NoError(err)
+	mts.Equal(mts.sent[0].Content, kept.Content)
+}
+
+func (mts *MemoryTestSuite) Test_Conversation_Keeps_Newest_Message() {
+	conversations, err := NewMemoryConversationRepository(mts.messages.store).GetConversations(ctx, "user2")
+
+	mts.NoError(err)
+	mts.Require().Len(conversations, 1)
+	mts.Equal(mts.sent[0].Timestamp, conversations[0].LastMessageAt)
+}
+
+func (mts *MemoryTestSuite) Test_Empty_Key() {
+	_, err := mts.messages.GetMessages(ctx, "", models.MessagesPage{})
+
+	mts.ErrorIs(err, ErrEmptyKey)
+}
@@ -0,0 +1,42 @@
+package repositories
+
+import (
+	"chat-system/internal/models"
+	"context"
+)
+
+type memoryUserRepository struct {
+	store *MemoryStore
+}
+
+func NewMemoryUserRepository(store *MemoryStore) *memoryUserRepository {
+	return &memoryUserRepository{store: store}
+}
+
+func (r *memoryUserRepository) GetUser(_ context.Context, username string) (*models.User, error) {
+	if err := checkKeys(username); err != nil {
+		return nil, err
+	}
+
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	user, ok := r.store.users[username]
+	if !ok {
+		return nil, nil
+	}
+
+	return &user, nil
+}
+
+func (r *memoryUserRepository) CreateUser(_ context.Context, user *models.User) error {
+	if err := checkKeys(user.Username); err != nil {
+		return err
+	}
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	r.store.users[user.Username] = *user
+	return nil
+}
@@ -0,0 +1,123 @@
+package repositories
+
+import (
+	"chat-system/internal/models"
+	"context"
+	"errors"
+
+	"github.com/gocql/gocql"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const postgresThreadQuery = `SELECT id, sender, recipient, timestamp, content, edited_at
+	FROM conversation_messages
+	WHERE user_a = $1 AND user_b = $2`
+
+type postgresConversationRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewPostgresConversationRepository(pool *pgxpool.Pool) *postgresConversationRepository {
+	return &postgresConversationRepository{pool: pool}
+}
+
+func (r *postgresConversationRepository) GetConversations(ctx context.Context, username string) ([]models.Conversation, error) {
+	rows, err := r.pool.Query(
+		ctx,
+		`SELECT peer, last_message_id, last_message_sender, last_message_preview, last_message_at,
+		last_read_id, peer_last_read_id
+		FROM conversations
+		WHERE username = $1`,
+		username,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.AppendRows(make([]models.Conversation, 0), rows, func(row pgx.CollectableRow) (models.Conversation, error) {
+		var conversation models.Conversation
+		err := row.Scan(
+			&conversation.Peer,
+			&conversation.LastMessageID,
+			&conversation.LastMessageSender,
+			&conversation.LastMessagePreview,
+			&conversation.LastMessageAt,
+			&conversation.LastReadID,
+			&conversation.PeerLastReadID,
+		)
+		conversation.LastMessageAt = conversation.LastMessageAt.UTC()
+		return conversation, err
+	})
+}
+
+func (r *postgresConversationRepository) GetConversationMessages(ctx context.Context, username, peer string, page models.MessagesPage) ([]models.Message, error) {
+	userA, userB := conversationKey(username, peer)
+
+	return queryPostgresPage(ctx, r.pool, postgresThreadQuery, []interface{}{userA, userB}, page, scanPostgresThreadMessage)
+}
+
+func (r *postgresConversationRepository) GetConversationMessage(ctx context.Context, username, peer string, id gocql.UUID) (*models.Message, error) {
+	userA, userB := conversationKey(username, peer)
+
+	return lookupPostgresMessage(ctx, r.pool, postgresThreadQuery, []interface{}{userA, userB}, id, scanPostgresThreadMessage)
+}
+
+func (r *postgresConversationRepository) GetLastReadID(ctx context.Context, username, peer string) (*gocql.UUID, error) {
+	var lastReadID *gocql.UUID
+	err := r.pool.QueryRow(
+		ctx,
+		`SELECT last_read_id FROM conversations WHERE username = $1 AND peer = $2`,
+		username,
+		peer,
+	).Scan(&lastReadID)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+
+	return lastReadID, nil
+}
+
+func (r *postgresConversationRepository) SetLastReadID(ctx context.Context, username, peer string, id gocql.UUID) error {
+	batch := &pgx.Batch{}
+
+	batch.Queue(`UPDATE conversations SET last_read_id = $1 WHERE username = $2 AND peer = $3`, id, username, peer)
+	batch.Queue(`UPDATE conversations SET peer_last_read_id = $1 WHERE username = $2 AND peer = $3`, id, peer, username)
+
+	return execPostgresBatch(ctx, r.pool, batch)
+}
+
+func (r *postgresConversationRepository) GetReadMarks(ctx context.Context, username string) (map[string]gocql.UUID, error) {
+	marks := make(map[string]gocql.UUID)
+
+	rows, err := r.pool.Query(
+		ctx,
+		`SELECT peer, peer_last_read_id FROM conversations WHERE username = $1 AND peer_last_read_id IS NOT NULL`,
+		username,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var peer string
+	var mark gocql.UUID
+	for rows.Next() {
+		if err := rows.Scan(&peer, &mark); err != nil {
+			return nil, err
+		}
+		marks[peer] = mark
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return marks, nil
+}
+
+func scanPostgresThreadMessage(row pgx.CollectableRow) (models.Message, error) {
+	var message models.Message
+	err := row.Scan(&message.ID, &message.Sender, &message.Recipient, &message.Timestamp, &message.Content, &message.EditedAt)
+	utcTimes(&message)
+	return message, err
+}
@@ -0,0 +1,161 @@
+package repositories
+
+import (
+	"chat-system/internal/models"
+	"context"
+
+	"github.com/gocql/gocql"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Adding a member twice updates their role, same as the upserts of Cassandra do
+const postgresInsertMemberQuery = `INSERT INTO group_members (group_id, username, role, joined_at)
+	VALUES ($1, $2, $3, $4)
+	ON CONFLICT (group_id, username) DO UPDATE SET role = EXCLUDED.role, joined_at = EXCLUDED.joined_at`
+
+type postgresGroupRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewPostgresGroupRepository(pool *pgxpool.Pool) *postgresGroupRepository {
+	return &postgresGroupRepository{pool: pool}
+}
+
+func (r *postgresGroupRepository) CreateGroup(ctx context.Context, group *models.Group, members []models.GroupMember) error {
+	batch := &pgx.Batch{}
+
+	batch.Queue(
+		`INSERT INTO groups (id, name, owner, created_at) VALUES ($1, $2, $3, $4)`,
+		group.ID,
+		group.Name,
+		group.Owner,
+		group.CreatedAt,
+	)
+
+	for _, member := range members {
+		batch.Queue(postgresInsertMemberQuery, member.GroupID, member.Username, member.Role, member.JoinedAt)
+	}
+
+	return execPostgresBatch(ctx, r.pool, batch)
+}
+
+func (r *postgresGroupRepository) GetMembers(ctx context.Context, groupID gocql.UUID) ([]models.GroupMember, error) {
+	rows, err := r.pool.Query(
+		ctx,
+		`SELECT group_id, username, role, joined_at FROM group_members WHERE group_id = $1 ORDER BY username`,
+		groupID,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.AppendRows(make([]models.GroupMember, 0), rows, func(row pgx.CollectableRow) (models.GroupMember, error) {
+		var member models.GroupMember
+		err := row.Scan(&member.GroupID, &member.Username, &member.Role, &member.JoinedAt)
+		member.JoinedAt = member.JoinedAt.UTC()
+		return member, err
+	})
+}
+
+func (r *postgresGroupRepository) AddMember(ctx context.Context, member *models.GroupMember) error {
+	_, err := r.pool.Exec(
+		ctx,
+		postgresInsertMemberQuery,
+		member.GroupID,
+		member.Username,
+		member.Role,
+		member.JoinedAt,
+	)
+	return err
+}
+
+func (r *postgresGroupRepository) RemoveMember(ctx context.Context, groupID gocql.UUID, username string) error {
+	_, err := r.pool.Exec(
+		ctx,
+		`DELETE FROM group_members WHERE group_id = $1 AND username = $2`,
+		groupID,
+		username,
+	)
+	return err
+}
+
+func (r *postgresGroupRepository) CreateGroupMessage(ctx context.Context, message *models.Message, members []string) error {
+	batch := &pgx.Batch{}
+
+	batch.Queue(
+		`INSERT INTO group_messages
+		(group_id, timestamp, id_time, id, sender, content)
+		VALUES ($1, $2, $3, $4, $5, $6)`,
+		message.GroupID,
+		message.Timestamp,
+		message.ID.Timestamp(),
+		message.ID,
+		message.Sender,
+		message.Content,
+	)
+
+	msgQuery := `INSERT INTO user_messages
+		(username, timestamp, id_time, id, sender, recipient, content, group_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (username, id) DO NOTHING`
+	for _, member := range members {
+		batch.Queue(
+			msgQuery,
+			member,
+			message.Timestamp,
+			message.ID.Timestamp(),
+			message.ID,
+			message.Sender,
+			message.Recipient,
+			message.Content,
+			message.GroupID,
+		)
+	}
+
+	return execPostgresBatch(ctx, r.pool, batch)
+}
+
+func (r *postgresGroupRepository) EditGroupMessage(ctx context.Context, message *models.Message, members []string) error {
+	batch := &pgx.Batch{}
+
+	batch.Queue(
+		`UPDATE group_messages SET content = $1, edited_at = $2 WHERE group_id = $3 AND id = $4`,
+		message.Content,
+		message.EditedAt,
+		message.GroupID,
+		message.ID,
+	)
+
+	batch.Queue(
+		`UPDATE user_messages SET content = $1, edited_at = $2 WHERE username = ANY($3) AND id = $4`,
+		message.Content,
+		message.EditedAt,
+		members,
+		message.ID,
+	)
+
+	return execPostgresBatch(ctx, r.pool, batch)
+}
+
+func (r *postgresGroupRepository) DeleteGroupMessage(ctx context.Context, message *models.Message, members []string) error {
+	batch := &pgx.Batch{}
+
+	batch.Queue(`DELETE FROM group_messages WHERE group_id = $1 AND id = $2`, message.GroupID, message.ID)
+	batch.Queue(`DELETE FROM user_messages WHERE username = ANY($1) AND id = $2`, members, message.ID)
+
+	return execPostgresBatch(ctx, r.pool, batch)
+}
+
+func (r *postgresGroupRepository) GetGroupMessages(ctx context.Context, groupID gocql.UUID, page models.MessagesPage) ([]models.Message, error) {
+	query := `SELECT id, sender, timestamp, content, group_id, edited_at
+		FROM group_messages
+		WHERE group_id = $1`
+
+	return queryPostgresPage(ctx, r.pool, query, []interface{}{groupID}, page, func(row pgx.CollectableRow) (models.Message, error) {
+		var message models.Message
+		err := row.Scan(&message.ID, &message.Sender, &message.Timestamp, &message.Content, &message.GroupID, &message.EditedAt)
+		utcTimes(&message)
+		return message, err
+	})
+}
@@ -0,0 +1,170 @@
+package repositories
+
+import (
+	"chat-system/internal/models"
+	"context"
+
+	"github.com/gocql/gocql"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const postgresMessagesQuery = `SELECT id, sender, recipient, timestamp, content, group_id, edited_at
+	FROM user_messages
+	WHERE username = $1`
+
+type postgresMessageRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewPostgresMessageRepository(pool *pgxpool.Pool) *postgresMessageRepository {
+	return &postgresMessageRepository{pool: pool}
+}
+
+func (r *postgresMessageRepository) CreateMessage(ctx context.Context, message *models.Message, preview string) error {
+	batch := &pgx.Batch{}
+
+	// Messages sent to oneself are stored once
+	msgQuery := `INSERT INTO user_messages
+		(username, timestamp, id_time, id, sender, recipient, content)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (username, id) DO NOTHING`
+	for _, user := range []string{message.Sender, message.Recipient} {
+		batch.Queue(
+			msgQuery,
+			user,
+			message.Timestamp,
+			message.ID.Timestamp(),
+			message.ID,
+			message.Sender,
+			message.Recipient,
+			message.Content,
+		)
+	}
+
+	userA, userB := conversationKey(message.Sender, message.Recipient)
+	batch.Queue(
+		`INSERT INTO conversation_messages
+		(user_a, user_b, timestamp, id_time, id, sender, recipient, content)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		userA,
+		userB,
+		message.Timestamp,
+		message.ID.Timestamp(),
+		message.ID,
+		message.Sender,
+		message.Recipient,
+		message.Content,
+	)
+
+	conversationQuery := `INSERT INTO conversations
+		(username, peer, last_message_id, last_message_sender, last_message_preview, last_message_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (username, peer) DO UPDATE SET
+		last_message_id = EXCLUDED.last_message_id,
+		last_message_sender = EXCLUDED.last_message_sender,
+		last_message_preview = EXCLUDED.last_message_preview,
+		last_message_at = EXCLUDED.last_message_at
+		WHERE conversations.last_message_at <= EXCLUDED.last_message_at`
+	parties := [][2]string{{message.Sender, message.Recipient}, {message.Recipient, message.Sender}}
+	for _, party := range parties {
+		batch.Queue(conversationQuery, party[0], party[1], message.ID, message.Sender, preview, message.Timestamp)
+	}
+
+	return execPostgresBatch(ctx, r.pool, batch)
+}
+
+func (r *postgresMessageRepository) GetMessages(ctx context.Context, username string, page models.MessagesPage) ([]models.Message, error) {
+	return queryPostgresPage(ctx, r.pool, postgresMessagesQuery, []interface{}{username}, page, scanPostgresMessage)
+}
+
+func (r *postgresMessageRepository) GetMessage(ctx context.Context, username string, id gocql.UUID) (*models.Message, error) {
+	return lookupPostgresMessage(ctx, r.pool, postgresMessagesQuery, []interface{}{username}, id, scanPostgresMessage)
+}
+
+// EditMessage only updates the sides that still have the message, since deleted rows are gone for good
+func (r *postgresMessageRepository) EditMessage(ctx context.Context, message *models.Message) error {
+	batch := &pgx.Batch{}
+
+	batch.Queue(
+		`UPDATE user_messages SET content = $1, edited_at = $2 WHERE username IN ($3, $4) AND id = $5`,
+		message.Content,
+		message.EditedAt,
+		message.Sender,
+		message.Recipient,
+		message.ID,
+	)
+
+	userA, userB := conversationKey(message.Sender, message.Recipient)
+	batch.Queue(
+		`UPDATE conversation_messages SET content = $1, edited_at = $2 WHERE user_a = $3 AND user_b = $4 AND id = $5`,
+		message.Content,
+		message.EditedAt,
+		userA,
+		userB,
+		message.ID,
+	)
+
+	return execPostgresBatch(ctx, r.pool, batch)
+}
+
+func (r *postgresMessageRepository) DeleteMessage(ctx context.Context, message *models.Message) error {
+	batch := &pgx.Batch{}
+
+	batch.Queue(
+		`DELETE FROM user_messages WHERE username IN ($1, $2) AND id = $3`,
+		message.Sender,
+		message.Recipient,
+		message.ID,
+	)
+
+	userA, userB := conversationKey(message.Sender, message.Recipient)
+	batch.Queue(
+		`DELETE FROM conversation_messages WHERE user_a = $1 AND user_b = $2 AND id = $3`,
+		userA,
+		userB,
+		message.ID,
+	)
+
+	return execPostgresBatch(ctx, r.pool, batch)
+}
+
+func (r *postgresMessageRepository) DeleteMessageForUser(ctx context.Context, username string, message *models.Message) error {
+	_, err := r.pool.Exec(
+		ctx,
+		`DELETE FROM user_messages WHERE username = $1 AND id = $2`,
+		username,
+		message.ID,
+	)
+	return err
+}
+
+func (r *postgresMessageRepository) UpdateConversationPreviews(ctx context.Context, message *models.Message, preview string) error {
+	_, err := r.pool.Exec(
+		ctx,
+		`UPDATE conversations SET last_message_preview = $1
+		WHERE ((username = $2 AND peer = $3) OR (username = $3 AND peer = $2))
+		AND last_message_id = $4 AND last_message_at <= $5`,
+		preview,
+		message.Sender,
+		message.Recipient,
+		message.ID,
+		message.Timestamp,
+	)
+	return err
+}
+
+func scanPostgresMessage(row pgx.CollectableRow) (models.Message, error) {
+	var message models.Message
+	err := row.Scan(
+		&message.ID,
+		&message.Sender,
+		&message.Recipient,
+		&message.Timestamp,
+		&message.Content,
+		&message.GroupID,
+		&message.EditedAt,
+	)
+	utcTimes(&message)
+	return message, err
+}
@@ -0,0 +1,119 @@
+package repositories
+
+import (
+	"chat-system/internal/models"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/gocql/gocql"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// queryPostgresPage runs a SELECT over the messages of a single owner (the query must end with its WHERE clause,
+// binding the key as its first parameters) within the bounds of the page. Rows are sorted by (timestamp DESC, id_time, id),
+// id_time being the time of the TimeUUID, which matches the clustering order of Cassandra.
+// Messages are always returned newest first.
+func queryPostgresPage(
+	ctx context.Context,
+	pool *pgxpool.Pool,
+	selectQuery string,
+	key []interface{},
+	page models.MessagesPage,
+	scan pgx.RowToFunc[models.Message],
+) ([]models.Message, error) {
+	stmt, args := postgresPageQuery(selectQuery, key, page)
+
+	rows, err := pool.Query(ctx, stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	messages, err := pgx.AppendRows(make([]models.Message, 0), rows, scan)
+	if err != nil {
+		return nil, err
+	}
+
+	if page.After != nil {
+		for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+			messages[i], messages[j] = messages[j], messages[i]
+		}
+	}
+
+	return messages, nil
+}
+
+// postgresPageQuery bounds the query to the page, binding the bounds right after the key
+func postgresPageQuery(selectQuery string, key []interface{}, page models.MessagesPage) (string, []interface{}) {
+	args := append([]interface{}{}, key...)
+	next := len(args) + 1
+
+	stmt := selectQuery
+	order := ` ORDER BY timestamp DESC, id_time ASC, id ASC`
+	switch {
+	case page.Before != nil:
+		stmt += fmt.Sprintf(
+			` AND (timestamp < $%d OR (timestamp = $%d AND (id_time, id) > ($%d, $%d)))`,
+			next, next, next+1, next+2,
+		)
+		args = append(args, page.Before.Timestamp, page.Before.ID.Timestamp(), page.Before.ID)
+		next += 3
+	case page.After != nil:
+		stmt += fmt.Sprintf(
+			` AND (timestamp > $%d OR (timestamp = $%d AND (id_time, id) < ($%d, $%d)))`,
+			next, next, next+1, next+2,
+		)
+		args = append(args, page.After.Timestamp, page.After.ID.Timestamp(), page.After.ID)
+		next += 3
+		order = ` ORDER BY timestamp ASC, id_time DESC, id DESC`
+	}
+
+	stmt += order
+	if page.Limit > 0 {
+		stmt += fmt.Sprintf(` LIMIT $%d`, next)
+		args = append(args, page.Limit)
+	}
+
+	return stmt, args
+}
+
+// lookupPostgresMessage finds a single message by ID (the query must end with its WHERE clause, binding the key
+// as its first parameters), nil if there's no such message
+func lookupPostgresMessage(
+	ctx context.Context,
+	pool *pgxpool.Pool,
+	selectQuery string,
+	key []interface{},
+	id gocql.UUID,
+	scan pgx.RowToFunc[models.Message],
+) (*models.Message, error) {
+	args := append(append([]interface{}{}, key...), id)
+
+	rows, err := pool.Query(ctx, selectQuery+fmt.Sprintf(` AND id = $%d`, len(args)), args...)
+	if err != nil {
+		return nil, err
+	}
+	message, err := pgx.CollectOneRow(rows, scan)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &message, nil
+}
+
+// execPostgresBatch runs all the statements of the batch within an implicit transaction
+func execPostgresBatch(ctx context.Context, pool *pgxpool.Pool, batch *pgx.Batch) error {
+	return pool.SendBatch(ctx, batch).Close()
+}
+
+// utcTimes converts the times scanned from the DB, which are in the local time zone, to the UTC ones the app uses
+func utcTimes(message *models.Message) {
+	message.Timestamp = message.Timestamp.UTC()
+	if message.EditedAt != nil {
+		editedAt := message.EditedAt.UTC()
+		message.EditedAt = &editedAt
+	}
+}
@@ -0,0 +1,56 @@
+package repositories
+
+import (
+	"chat-system/internal/models"
+	"testing"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/stretchr/testify/suite"
+)
+
+const testSelectQuery = `SELECT id FROM user_messages WHERE username = $1`
+
+type PostgresPaginationTestSuite struct {
+	suite.Suite
+	key models.MessageKey
+}
+
+func TestPostgresPaginationTestSuite(t *testing.T) {
+	suite.Run(t, new(PostgresPaginationTestSuite))
+}
+
+func (pts *PostgresPaginationTestSuite) SetupTest() {
+	pts.key = models.KeyFromID(gocql.UUIDFromTime(time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)))
+}
+
+func (pts *PostgresPaginationTestSuite) Test_Latest_Page() {
+	stmt, args := postgresPageQuery(testSelectQuery, []interface{}{"user1"}, models.MessagesPage{Limit: 20})
+
+	pts.Equal(testSelectQuery+` ORDER BY timestamp DESC, id_time ASC, id ASC LIMIT $2`, stmt)
+	pts.Equal([]interface{}{"user1", 20}, args)
+}
+
+func (pts *PostgresPaginationTestSuite) Test_Page_Before_Walks_Older_Messages() {
+	stmt, args := postgresPageQuery(testSelectQuery, []interface{}{"user1"}, models.MessagesPage{Before: &pts.key, Limit: 20})
+
+	pts.Equal(
+		testSelectQuery+
+			` AND (timestamp < $2 OR (timestamp = $2 AND (id_time, id) > ($3, $4)))`+
+			` ORDER BY timestamp DESC, id_time ASC, id ASC LIMIT $5`,
+		stmt,
+	)
+	pts.Equal([]interface{}{"user1", pts.key.Timestamp, pts.key.ID.Timestamp(), pts.key.ID, 20}, args)
+}
+
+func (pts *PostgresPaginationTestSuite) Test_Page_After_Walks_Newer_Messages_Backwards() {
+	stmt, args := postgresPageQuery(testSelectQuery+` AND peer = $2`, []interface{}{"user1", "user2"}, models.MessagesPage{After: &pts.key})
+
+	pts.Equal(
+		testSelectQuery+` AND peer = $2`+
+			` AND (timestamp > $3 OR (timestamp = $3 AND (id_time, id) < ($4, $5)))`+
+			` ORDER BY timestamp ASC, id_time DESC, id DESC`,
+		stmt,
+	)
+	pts.Equal([]interface{}{"user1", "user2", pts.key.Timestamp, pts.key.ID.Timestamp(), pts.key.ID}, args)
+}
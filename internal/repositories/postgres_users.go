@@ -0,0 +1,45 @@
+package repositories
+
+import (
+	"chat-system/internal/models"
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type postgresUserRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewPostgresUserRepository(pool *pgxpool.Pool) *postgresUserRepository {
+	return &postgresUserRepository{pool: pool}
+}
+
+func (r *postgresUserRepository) GetUser(ctx context.Context, username string) (*models.User, error) {
+	var user models.User
+
+	err := r.pool.QueryRow(
+		ctx,
+		`SELECT id, username, password FROM users WHERE username = $1`,
+		username,
+	).Scan(&user.ID, &user.Username, &user.Password)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func (r *postgresUserRepository) CreateUser(ctx context.Context, user *models.User) error {
+	_, err := r.pool.Exec(
+		ctx,
+		`INSERT INTO users (id, username, password) VALUES ($1, $2, $3)`,
+		user.ID, user.Username, user.Password,
+	)
+	return err
+}
@@ -0,0 +1,83 @@
+// The purpose of this package is to keep reading & writing the app data behind interfaces, implemented for each
+// supported DB engine. Services hold the rules of the app on top of them, whichever engine stores the data.
+
+package repositories
+
+import (
+	"chat-system/internal/models"
+	"context"
+	"time"
+
+	"github.com/gocql/gocql"
+)
+
+// Messages sent before timestamps were derived from their IDs got stamped right after the ID was generated,
+// so a message is looked up within this window past the time of its ID
+const MSG_LOOKUP_WINDOW = time.Second
+
+type UserRepository interface {
+	// GetUser returns the user, nil if there's no such user
+	GetUser(ctx context.Context, username string) (*models.User, error)
+	CreateUser(ctx context.Context, user *models.User) error
+}
+
+// MessageRepository stores the messages of each user, either direct or group ones, sorted newest first
+// by their (timestamp, id) key. Direct messages are also kept within the thread & the conversations of their parties.
+type MessageRepository interface {
+	// CreateMessage stores a direct message on both sides & within their thread, making it the last message
+	// of the conversation of both parties
+	CreateMessage(ctx context.Context, message *models.Message, preview string) error
+	// GetMessages retrieves a page of the messages sent to or by the user, newest first
+	GetMessages(ctx context.Context, username string, page models.MessagesPage) ([]models.Message, error)
+	// GetMessage retrieves a single message of the user, nil if there's no such message
+	GetMessage(ctx context.Context, username string, id gocql.UUID) (*models.Message, error)
+	// EditMessage updates the content of a direct message within the thread & on the sides that still have it
+	EditMessage(ctx context.Context, message *models.Message) error
+	// DeleteMessage deletes a direct message from both sides & the thread
+	DeleteMessage(ctx context.Context, message *models.Message) error
+	// DeleteMessageForUser deletes the message (either direct or group one) from the messages of the user only
+	DeleteMessageForUser(ctx context.Context, username string, message *models.Message) error
+	// UpdateConversationPreviews replaces the preview of the conversations of both parties,
+	// as long as the message is still the last one of the conversation
+	UpdateConversationPreviews(ctx context.Context, message *models.Message, preview string) error
+}
+
+type ConversationRepository interface {
+	// GetConversations lists the conversations of the user in no particular order
+	GetConversations(ctx context.Context, username string) ([]models.Conversation, error)
+	// GetConversationMessages retrieves a page of the thread between the user & the peer, newest first
+	GetConversationMessages(ctx context.Context, username, peer string, page models.MessagesPage) ([]models.Message, error)
+	// GetConversationMessage retrieves a single message of the thread, nil if there's no such message
+	GetConversationMessage(ctx context.Context, username, peer string, id gocql.UUID) (*models.Message, error)
+	// GetLastReadID returns how far the user has read the conversation, nil if not at all
+	GetLastReadID(ctx context.Context, username, peer string) (*gocql.UUID, error)
+	// SetLastReadID moves the read watermark of the user, along with its copy on the peer's side
+	SetLastReadID(ctx context.Context, username, peer string, id gocql.UUID) error
+	// GetReadMarks returns how far each peer has read the conversation with the user
+	GetReadMarks(ctx context.Context, username string) (map[string]gocql.UUID, error)
+}
+
+type GroupRepository interface {
+	CreateGroup(ctx context.Context, group *models.Group, members []models.GroupMember) error
+	GetMembers(ctx context.Context, groupID gocql.UUID) ([]models.GroupMember, error)
+	// AddMember adds the member to the group, or updates their role if they are a member already
+	AddMember(ctx context.Context, member *models.GroupMember) error
+	RemoveMember(ctx context.Context, groupID gocql.UUID, username string) error
+	// CreateGroupMessage stores the message within the group timeline & the messages of every member
+	CreateGroupMessage(ctx context.Context, message *models.Message, members []string) error
+	// EditGroupMessage updates the message within the group timeline & the messages of the members that still have it
+	EditGroupMessage(ctx context.Context, message *models.Message, members []string) error
+	// DeleteGroupMessage deletes the message from the group timeline & the messages of the members
+	DeleteGroupMessage(ctx context.Context, message *models.Message, members []string) error
+	// GetGroupMessages retrieves a page of the group timeline, newest first
+	GetGroupMessages(ctx context.Context, groupID gocql.UUID, page models.MessagesPage) ([]models.Message, error)
+}
+
+// conversationKey orders the pair of users, so both of them map to the very same thread
+func conversationKey(user, peer string) (string, string) {
+	if user < peer {
+		return user, peer
+	}
+
+	return peer, user
+}
@@ -2,81 +2,61 @@ package services
 
 import (
 	common "chat-system/internal/api/common/constants"
+	"chat-system/internal/metrics"
 	"chat-system/internal/models"
+	"chat-system/internal/repositories"
+	"chat-system/internal/tracing"
+	"context"
 	"errors"
-	"fmt"
+	"time"
 
 	"github.com/gocql/gocql"
 	"golang.org/x/crypto/bcrypt"
 )
 
 type UserService interface {
-	UserExists(username string) (bool, error)
-	CreateUser(userInput *models.RegisterInput) (*models.User, error)
-	GetUserByCreds(credentials models.LoginInput) (*models.User, error)
+	UserExists(ctx context.Context, username string) (bool, error)
+	CreateUser(ctx context.Context, userInput *models.RegisterInput) (*models.User, error)
+	GetUserByCreds(ctx context.Context, credentials models.LoginInput) (*models.User, error)
 }
 
 type userService struct {
-	db         *gocql.Session
-	dbKeyspace string
-	tableName  string
+	users repositories.UserRepository
+	// Cost of hashing the passwords, the higher the slower to brute force (& to log in)
+	bcryptCost int
 }
 
-func NewUserService(db *gocql.Session, keyspace, tableName string) *userService {
+func NewUserService(users repositories.UserRepository, bcryptCost int) *userService {
 	return &userService{
-		db:         db,
-		dbKeyspace: keyspace,
-		tableName:  tableName,
+		users:      users,
+		bcryptCost: bcryptCost,
 	}
 }
 
-func (s *userService) GetUserByCreds(credentials models.LoginInput) (*models.User, error) {
-	var existingUser models.User
-
-	query := fmt.Sprintf(
-		`SELECT id, username, password FROM %s.%s WHERE username = ? LIMIT 1`,
-		s.dbKeyspace,
-		s.tableName,
-	)
-
-	err := s.db.Query(query, credentials.Username).
-		Scan(&existingUser.ID, &existingUser.Username, &existingUser.Password)
-
-	if err != nil {
+func (s *userService) GetUserByCreds(ctx context.Context, credentials models.LoginInput) (*models.User, error) {
+	existingUser, err := s.users.GetUser(ctx, credentials.Username)
+	if err != nil || existingUser == nil {
 		return nil, errors.New(common.INVALID_LOGIN)
 	}
 
-	if !s.checkPasswordHash(credentials.Password, existingUser.Password) {
+	if !s.checkPasswordHash(ctx, credentials.Password, existingUser.Password) {
 		return nil, errors.New(common.INVALID_LOGIN)
 	}
 
-	return &existingUser, err
+	return existingUser, nil
 }
 
-func (s *userService) UserExists(username string) (bool, error) {
-	var existingUserId gocql.UUID
-
-	query := fmt.Sprintf(
-		`SELECT id FROM %s.%s WHERE username = ? LIMIT 1`,
-		s.dbKeyspace,
-		s.tableName,
-	)
-
-	err := s.db.Query(query, username).Scan(&existingUserId)
-
-	if err == nil {
-		return true, nil
-	}
-
-	if errors.Is(err, gocql.ErrNotFound) {
-		return false, nil
+func (s *userService) UserExists(ctx context.Context, username string) (bool, error) {
+	existingUser, err := s.users.GetUser(ctx, username)
+	if err != nil {
+		return false, err
 	}
 
-	return false, err
+	return existingUser != nil, nil
 }
 
-func (s *userService) CreateUser(userInput *models.RegisterInput) (*models.User, error) {
-	hashedPassword, err := s.hashPassword(userInput.Password)
+func (s *userService) CreateUser(ctx context.Context, userInput *models.RegisterInput) (*models.User, error) {
+	hashedPassword, err := s.hashPassword(ctx, userInput.Password)
 	if err != nil {
 		return nil, err
 	}
@@ -87,26 +67,32 @@ func (s *userService) CreateUser(userInput *models.RegisterInput) (*models.User,
 		Password: hashedPassword,
 	}
 
-	query := fmt.Sprintf(
-		`INSERT INTO %s.%s (id, username, password) VALUES (?, ?, ?)`,
-		s.dbKeyspace,
-		s.tableName,
-	)
-
-	err = s.db.Query(
-		query,
-		user.ID, user.Username, user.Password,
-	).Exec()
+	err = s.users.CreateUser(ctx, user)
 
 	return user, err
 }
 
-func (s *userService) hashPassword(password string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
+func (s *userService) hashPassword(ctx context.Context, password string) (string, error) {
+	defer observeBcrypt(ctx, metrics.BCRYPT_HASH)()
+
+	bytes, err := bcrypt.GenerateFromPassword([]byte(password), s.bcryptCost)
 	return string(bytes), err
 }
 
-func (s *userService) checkPasswordHash(password, hash string) bool {
+func (s *userService) checkPasswordHash(ctx context.Context, password, hash string) bool {
+	defer observeBcrypt(ctx, metrics.BCRYPT_COMPARE)()
+
 	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 	return err == nil
 }
+
+// observeBcrypt times & traces the operation until the returned func is called
+func observeBcrypt(ctx context.Context, operation string) func() {
+	start := time.Now()
+	_, span := tracing.Tracer.Start(ctx, "bcrypt "+operation)
+
+	return func() {
+		span.End()
+		metrics.BcryptDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+	}
+}
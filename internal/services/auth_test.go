@@ -5,23 +5,23 @@ import (
 	"errors"
 	"testing"
 
-	"github.com/gocql/gocql"
 	"github.com/stretchr/testify/suite"
+	"golang.org/x/crypto/bcrypt"
 )
 
 type AuthTestSuite struct {
 	suite.Suite
-	dbSession *gocql.Session
+	storage   *testStorage
 	tableName string
 	service   *userService
 }
 
-func (ats *AuthTestSuite) DBSession() *gocql.Session {
-	return ats.dbSession
+func (ats *AuthTestSuite) Storage() *testStorage {
+	return ats.storage
 }
 
-func (ats *AuthTestSuite) SetDBSession(session *gocql.Session) {
-	ats.dbSession = session
+func (ats *AuthTestSuite) SetStorage(storage *testStorage) {
+	ats.storage = storage
 }
 
 func (ats *AuthTestSuite) DBTable() string {
@@ -46,9 +46,9 @@ func TestAuthTestSuite(t *testing.T) {
 
 func (ats *AuthTestSuite) SetupSuite() {
 	ats.SetDBTable(USERS_TEST_TABLE_NAME)
-	ats.SetDBSession(setupDatabase(ats))
+	setupDatabase(ats)
 
-	ats.SetService(NewUserService(ats.DBSession(), KEYSPACE_TEST, ats.DBTable()))
+	ats.SetService(NewUserService(ats.Storage().users(), bcrypt.MinCost))
 }
 
 func (ats *AuthTestSuite) TearDownSuite() {
@@ -59,7 +59,7 @@ func (ats *AuthTestSuite) TestUserExists_True() {
 	seedTable(ats)
 
 	testUsername := "user1"
-	actual, err := ats.Service().UserExists(testUsername)
+	actual, err := ats.Service().UserExists(testCtx, testUsername)
 
 	ats.Nil(err)
 	ats.True(actual)
@@ -67,7 +67,7 @@ func (ats *AuthTestSuite) TestUserExists_True() {
 
 func (ats *AuthTestSuite) TestUserExists_False() {
 	testUsername := "non-exist-for-sure"
-	actual, err := ats.Service().UserExists(testUsername)
+	actual, err := ats.Service().UserExists(testCtx, testUsername)
 
 	ats.Nil(err)
 	ats.False(actual)
@@ -79,7 +79,7 @@ func (ats *AuthTestSuite) TestCreateUser_Success() {
 	userInput := &models.RegisterInput{Username: "user1", Password: "password123"}
 	expectedUser := &models.User{Username: "user1"}
 
-	actual, err := ats.Service().CreateUser(userInput)
+	actual, err := ats.Service().CreateUser(testCtx, userInput)
 
 	ats.Nil(err)
 	ats.Equal(expectedUser.Username, actual.Username)
@@ -93,7 +93,7 @@ func (ats *AuthTestSuite) TestCreateUser_ErrorHash() {
 
 	userInput := &models.RegisterInput{Username: "user1", Password: veryLongPass}
 
-	actual, err := ats.Service().CreateUser(userInput)
+	actual, err := ats.Service().CreateUser(testCtx, userInput)
 
 	ats.Nil(actual)
 	ats.Equal(expectedErr, err)
@@ -0,0 +1,230 @@
+package services
+
+import (
+	"chat-system/internal/api/cache"
+	"chat-system/internal/logging"
+	"chat-system/internal/models"
+	"chat-system/internal/repositories"
+	"context"
+	"sort"
+	"strconv"
+
+	"github.com/gocql/gocql"
+)
+
+// Max number of characters of the last message shown in the conversations listing
+const PREVIEW_LENGTH = 100
+
+// Unread messages are recounted up to this limit when the user reads a conversation
+const UNREAD_COUNT_LIMIT = 1000
+
+// Recounts of the unread messages tried at most while new messages keep changing the count meanwhile
+const UNREAD_RECOUNT_ATTEMPTS = 3
+
+// Field marking the read marks of a user as cached, even if there's none. It's longer than any username.
+const READ_MARKS_CACHED_FIELD = "(read marks cached)"
+
+type ConversationService interface {
+	GetConversations(ctx context.Context, username string) ([]models.Conversation, error)
+	GetConversationMessages(ctx context.Context, username, peer string, page models.MessagesPage) ([]models.Message, error)
+	GetConversationMessage(ctx context.Context, username, peer string, id gocql.UUID) (*models.Message, error)
+	MarkAsRead(ctx context.Context, username, peer string, lastRead *models.Message) error
+	GetReadMarks(ctx context.Context, username string) (map[string]gocql.UUID, error)
+	CountUnread(ctx context.Context, username, peer string, lastRead *models.Message) (int, error)
+	RecountUnread(ctx context.Context, username, peer string, lastRead *models.Message) (int, error)
+	IncrUnreadCount(ctx context.Context, username, peer string) error
+	GetUnreadCounts(ctx context.Context, username string) (map[string]int64, error)
+}
+
+type conversationService struct {
+	conversations repositories.ConversationRepository
+	cache         cache.Cache
+}
+
+func NewConversationService(conversations repositories.ConversationRepository, c cache.Cache) *conversationService {
+	return &conversationService{
+		conversations: conversations,
+		cache:         c,
+	}
+}
+
+// GetConversations lists the peers the user has exchanged messages with, most recently active first
+func (s *conversationService) GetConversations(ctx context.Context, username string) ([]models.Conversation, error) {
+	conversations, err := s.conversations.GetConversations(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(conversations, func(i, j int) bool {
+		return conversations[i].LastMessageAt.After(conversations[j].LastMessageAt)
+	})
+
+	return conversations, nil
+}
+
+// GetConversationMessages retrieves a page of the thread of messages exchanged between the user & the peer only
+func (s *conversationService) GetConversationMessages(ctx context.Context, username, peer string, page models.MessagesPage) ([]models.Message, error) {
+	return s.conversations.GetConversationMessages(ctx, username, peer, page)
+}
+
+// GetConversationMessage finds a single message of the thread between the user & the peer, nil if there's no such message
+func (s *conversationService) GetConversationMessage(ctx context.Context, username, peer string, id gocql.UUID) (*models.Message, error) {
+	return s.conversations.GetConversationMessage(ctx, username, peer, id)
+}
+
+// MarkAsRead moves the read watermark of the user forward up to the given message of the conversation.
+// The peer's side keeps a copy of it, so they can tell which of their messages were read.
+func (s *conversationService) MarkAsRead(ctx context.Context, username, peer string, lastRead *models.Message) error {
+	current, err := s.conversations.GetLastReadID(ctx, username, peer)
+	if err != nil {
+		return err
+	}
+
+	// Reading an older message again must not mark the newer ones as unread
+	if current != nil && !lastRead.ID.Time().After(current.Time()) {
+		return nil
+	}
+
+	if err := s.conversations.SetLastReadID(ctx, username, peer, lastRead.ID); err != nil {
+		return err
+	}
+
+	// The watermark is copied to the side of the peer, whose cached read marks get it written through rather than
+	// dropped, since a concurrent load of the older ones would bring them back otherwise
+	return s.cache.SetCachedField(ctx, peer+cache.READ_MARKS_KEY_SUFFIX, username, lastRead.ID.String())
+}
+
+// GetReadMarks returns how far each peer has read the conversation with the user.
+// They're cached next to the unread counts, so pages of messages served from cache don't hit DB for them.
+func (s *conversationService) GetReadMarks(ctx context.Context, username string) (map[string]gocql.UUID, error) {
+	key := username + cache.READ_MARKS_KEY_SUFFIX
+
+	fields, err := s.cache.GetFields(ctx, key)
+	if err != nil {
+		logging.FromContext(ctx).Error("Failed to fetch cached read marks", "user", username, "error", err)
+	}
+	if _, ok := fields[READ_MARKS_CACHED_FIELD]; ok {
+		return decodeReadMarks(fields)
+	}
+
+	marks, err := s.conversations.GetReadMarks(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+
+	fields = map[string]string{READ_MARKS_CACHED_FIELD: ""}
+	for peer, id := range marks {
+		fields[peer] = id.String()
+	}
+	// Marks written through meanwhile are newer than the ones just read, so they're kept
+	if err := s.cache.AddCachedFields(ctx, key, fields); err != nil {
+		logging.FromContext(ctx).Error("Failed to cache read marks", "user", username, "error", err)
+	}
+
+	return marks, nil
+}
+
+func decodeReadMarks(fields map[string]string) (map[string]gocql.UUID, error) {
+	marks := make(map[string]gocql.UUID, len(fields)-1)
+	for peer, value := range fields {
+		if peer == READ_MARKS_CACHED_FIELD {
+			continue
+		}
+
+		id, err := gocql.ParseUUID(value)
+		if err != nil {
+			return nil, err
+		}
+		marks[peer] = id
+	}
+
+	return marks, nil
+}
+
+// CountUnread counts the messages of the peer past the given one, up to UNREAD_COUNT_LIMIT
+func (s *conversationService) CountUnread(ctx context.Context, username, peer string, lastRead *models.Message) (int, error) {
+	lastReadKey := models.KeyOf(lastRead)
+
+	messages, err := s.GetConversationMessages(ctx, username, peer, models.MessagesPage{
+		After: &lastReadKey,
+		Limit: UNREAD_COUNT_LIMIT,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, message := range messages {
+		if message.Sender == peer {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+// IncrUnreadCount counts one more unread message of the peer for the user
+func (s *conversationService) IncrUnreadCount(ctx context.Context, username, peer string) error {
+	return s.cache.IncrField(ctx, username+cache.UNREAD_KEY_SUFFIX, peer)
+}
+
+// RecountUnread sets the unread count of the peer for the user to the messages past the given one. The count is
+// only set if no new message got counted meanwhile, recounting otherwise, so that none of them gets lost.
+func (s *conversationService) RecountUnread(ctx context.Context, username, peer string, lastRead *models.Message) (int, error) {
+	key := username + cache.UNREAD_KEY_SUFFIX
+
+	var count int
+	for attempt := 0; attempt < UNREAD_RECOUNT_ATTEMPTS; attempt++ {
+		fields, cacheErr := s.cache.GetFields(ctx, key)
+		current, _ := strconv.ParseInt(fields[peer], 10, 64)
+
+		var err error
+		count, err = s.CountUnread(ctx, username, peer, lastRead)
+		if err != nil {
+			return 0, err
+		}
+
+		set := false
+		if cacheErr == nil {
+			set, cacheErr = s.cache.CompareAndSetField(ctx, key, peer, current, int64(count))
+		}
+		if cacheErr != nil {
+			logging.FromContext(ctx).Error("Failed to set unread count", "user", username, "peer", peer, "error", cacheErr)
+			return count, nil
+		}
+		if set {
+			return count, nil
+		}
+	}
+
+	logging.FromContext(ctx).Warn("Unread count kept changing while recounting", "user", username, "peer", peer)
+	return count, nil
+}
+
+// GetUnreadCounts returns the number of unread messages of the user per peer
+func (s *conversationService) GetUnreadCounts(ctx context.Context, username string) (map[string]int64, error) {
+	fields, err := s.cache.GetFields(ctx, username+cache.UNREAD_KEY_SUFFIX)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int64, len(fields))
+	for peer, value := range fields {
+		count, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		counts[peer] = count
+	}
+
+	return counts, nil
+}
+
+func messagePreview(content string) string {
+	runes := []rune(content)
+	if len(runes) <= PREVIEW_LENGTH {
+		return content
+	}
+
+	return string(runes[:PREVIEW_LENGTH])
+}
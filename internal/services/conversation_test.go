@@ -0,0 +1,281 @@
+package services
+
+import (
+	"chat-system/internal/models"
+	"chat-system/internal/repositories"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/gocql/gocql"
+	"github.com/stretchr/testify/suite"
+)
+
+type ConversationsTestSuite struct {
+	suite.Suite
+	storage    *testStorage
+	tableName  string
+	service    *conversationService
+	msgService *messageService
+}
+
+func (cts *ConversationsTestSuite) Storage() *testStorage {
+	return cts.storage
+}
+
+func (cts *ConversationsTestSuite) SetStorage(storage *testStorage) {
+	cts.storage = storage
+}
+
+func (cts *ConversationsTestSuite) DBTable() string {
+	return cts.tableName
+}
+
+func (cts *ConversationsTestSuite) SetDBTable(tableName string) {
+	cts.tableName = tableName
+}
+
+func (cts *ConversationsTestSuite) Service() *conversationService {
+	return cts.service
+}
+
+func (cts *ConversationsTestSuite) SetService(service *conversationService) {
+	cts.service = service
+}
+
+func TestConversationsTestSuite(t *testing.T) {
+	suite.Run(t, new(ConversationsTestSuite))
+}
+
+func (cts *ConversationsTestSuite) SetupSuite() {
+	cts.SetDBTable(CONVERSATIONS_TEST_TABLE_NAME)
+	setupDatabase(cts)
+
+	cts.SetService(NewConversationService(cts.Storage().conversations(), newTestCache()))
+
+	// Conversations are written by sending messages
+	cts.msgService = NewMessageService(cts.Storage().messages(), newTestCache())
+}
+
+// SetupTest drops the read marks & unread counts cached by the previous tests, as their tables get cleaned
+func (cts *ConversationsTestSuite) SetupTest() {
+	cts.service.cache = newTestCache()
+}
+
+func (cts *ConversationsTestSuite) TearDownSuite() {
+	tearDownDatabase(cts)
+}
+
+func (cts *ConversationsTestSuite) send(sender, recipient, content string) {
+	nextMillisecond()
+	err := cts.msgService.CreateMessage(testCtx, &models.Message{Sender: sender, Recipient: recipient, Content: content})
+	cts.Require().Nil(err)
+}
+
+// hookedConversations runs the hook once, right after the next read from DB, so that a concurrent write
+// gets in between the read & whatever follows it
+type hookedConversations struct {
+	repositories.ConversationRepository
+	afterRead func()
+}
+
+func (h *hookedConversations) read() {
+	if hook := h.afterRead; hook != nil {
+		h.afterRead = nil
+		hook()
+	}
+}
+
+func (h *hookedConversations) GetConversationMessages(ctx context.Context, username, peer string, page models.MessagesPage) ([]models.Message, error) {
+	messages, err := h.ConversationRepository.GetConversationMessages(ctx, username, peer, page)
+	h.read()
+	return messages, err
+}
+
+func (h *hookedConversations) GetReadMarks(ctx context.Context, username string) (map[string]gocql.UUID, error) {
+	marks, err := h.ConversationRepository.GetReadMarks(ctx, username)
+	h.read()
+	return marks, err
+}
+
+// hook runs the hook right after the next read of the service from DB
+func (cts *ConversationsTestSuite) hook(afterRead func()) {
+	conversations := cts.service.conversations
+	cts.service.conversations = &hookedConversations{ConversationRepository: conversations, afterRead: afterRead}
+	cts.T().Cleanup(func() { cts.service.conversations = conversations })
+}
+
+func (cts *ConversationsTestSuite) TestGetConversations_Success() {
+	cleanTable(cts)
+
+	cts.send("user1", "user2", "first")
+	cts.send("user3", "user1", "second")
+	cts.send("user2", "user1", "third")
+
+	actual, err := cts.Service().GetConversations(testCtx, "user1")
+
+	cts.Nil(err)
+	cts.Len(actual, 2)
+	// Most recently active first
+	cts.Equal("user2", actual[0].Peer)
+	cts.Equal("third", actual[0].LastMessagePreview)
+	cts.Equal("user2", actual[0].LastMessageSender)
+	cts.Equal("user3", actual[1].Peer)
+	cts.Equal("second", actual[1].LastMessagePreview)
+
+	cleanTable(cts)
+}
+
+func (cts *ConversationsTestSuite) TestGetConversations_Preview_Truncated() {
+	cleanTable(cts)
+
+	cts.send("user1", "user2", strings.Repeat("a", PREVIEW_LENGTH+10))
+
+	actual, err := cts.Service().GetConversations(testCtx, "user2")
+
+	cts.Nil(err)
+	cts.Len(actual, 1)
+	cts.Len(actual[0].LastMessagePreview, PREVIEW_LENGTH)
+
+	cleanTable(cts)
+}
+
+func (cts *ConversationsTestSuite) TestGetConversations_Empty() {
+	actual, err := cts.Service().GetConversations(testCtx, "test-non-exist")
+
+	cts.Nil(err)
+	cts.Empty(actual)
+}
+
+func (cts *ConversationsTestSuite) TestGetConversationMessages_Success() {
+	cleanTable(cts)
+
+	cts.send("user1", "user2", "to user2")
+	cts.send("user3", "user1", "from user3")
+	cts.send("user2", "user1", "from user2")
+
+	// The thread is the same whichever side asks for it
+	for _, pair := range [][2]string{{"user1", "user2"}, {"user2", "user1"}} {
+		actual, err := cts.Service().GetConversationMessages(testCtx, pair[0], pair[1], models.MessagesPage{})
+
+		cts.Nil(err)
+		cts.Len(actual, 2)
+		cts.Equal("from user2", actual[0].Content)
+		cts.Equal("to user2", actual[1].Content)
+	}
+
+	cleanTable(cts)
+}
+
+func (cts *ConversationsTestSuite) TestMarkAsRead_Moves_Watermark_Forward() {
+	cleanTable(cts)
+
+	cts.send("user2", "user1", "first")
+	cts.send("user2", "user1", "second")
+	cts.send("user2", "user1", "third")
+
+	thread, err := cts.Service().GetConversationMessages(testCtx, "user1", "user2", models.MessagesPage{})
+	cts.Require().Nil(err)
+	third, second, first := &thread[0], &thread[1], &thread[2]
+
+	cts.Nil(cts.Service().MarkAsRead(testCtx, "user1", "user2", second))
+	// Going back is ignored
+	cts.Nil(cts.Service().MarkAsRead(testCtx, "user1", "user2", first))
+
+	conversations, err := cts.Service().GetConversations(testCtx, "user1")
+	cts.Nil(err)
+	cts.Equal(second.ID, *conversations[0].LastReadID)
+
+	marks, err := cts.Service().GetReadMarks(testCtx, "user2")
+	cts.Nil(err)
+	cts.Equal(second.ID, marks["user1"])
+
+	unread, err := cts.Service().CountUnread(testCtx, "user1", "user2", second)
+	cts.Nil(err)
+	cts.Equal(1, unread)
+
+	found, err := cts.Service().GetConversationMessage(testCtx, "user2", "user1", third.ID)
+	cts.Nil(err)
+	cts.Equal("third", found.Content)
+
+	cleanTable(cts)
+}
+
+func (cts *ConversationsTestSuite) TestGetReadMarks_Cached_Until_Read() {
+	cleanTable(cts)
+
+	cts.send("user2", "user1", "first")
+	cts.send("user2", "user1", "second")
+
+	thread, err := cts.Service().GetConversationMessages(testCtx, "user1", "user2", models.MessagesPage{})
+	cts.Require().Nil(err)
+	second, first := &thread[0], &thread[1]
+
+	marks, err := cts.Service().GetReadMarks(testCtx, "user2")
+	cts.Nil(err)
+	cts.Empty(marks)
+
+	// Written behind the back of the service, so the cached marks are served
+	cts.Nil(cts.Service().conversations.SetLastReadID(testCtx, "user1", "user2", first.ID))
+	marks, err = cts.Service().GetReadMarks(testCtx, "user2")
+	cts.Nil(err)
+	cts.Empty(marks)
+
+	// Reading through the service loads the marks of the peer again
+	cts.Nil(cts.Service().MarkAsRead(testCtx, "user1", "user2", second))
+	marks, err = cts.Service().GetReadMarks(testCtx, "user2")
+	cts.Nil(err)
+	cts.Equal(map[string]gocql.UUID{"user1": second.ID}, marks)
+
+	cleanTable(cts)
+}
+
+func (cts *ConversationsTestSuite) TestGetReadMarks_Load_Racing_Read_Not_Kept() {
+	cleanTable(cts)
+
+	cts.send("user2", "user1", "first")
+
+	thread, err := cts.Service().GetConversationMessages(testCtx, "user1", "user2", models.MessagesPage{})
+	cts.Require().Nil(err)
+	first := &thread[0]
+
+	// The marks get read before the user reads the conversation, yet cached after it
+	cts.hook(func() {
+		cts.Nil(cts.Service().MarkAsRead(testCtx, "user1", "user2", first))
+	})
+	marks, err := cts.Service().GetReadMarks(testCtx, "user2")
+	cts.Nil(err)
+	cts.Empty(marks)
+
+	marks, err = cts.Service().GetReadMarks(testCtx, "user2")
+	cts.Nil(err)
+	cts.Equal(map[string]gocql.UUID{"user1": first.ID}, marks)
+
+	cleanTable(cts)
+}
+
+func (cts *ConversationsTestSuite) TestRecountUnread_Keeps_Messages_Counted_Meanwhile() {
+	cleanTable(cts)
+
+	cts.send("user2", "user1", "first")
+	cts.send("user2", "user1", "second")
+
+	thread, err := cts.Service().GetConversationMessages(testCtx, "user1", "user2", models.MessagesPage{})
+	cts.Require().Nil(err)
+	first := &thread[1]
+
+	// A new message gets counted in between the recount & setting it
+	cts.hook(func() {
+		cts.send("user2", "user1", "third")
+		cts.Nil(cts.Service().IncrUnreadCount(testCtx, "user1", "user2"))
+	})
+	count, err := cts.Service().RecountUnread(testCtx, "user1", "user2", first)
+	cts.Nil(err)
+	cts.Equal(2, count)
+
+	counts, err := cts.Service().GetUnreadCounts(testCtx, "user1")
+	cts.Nil(err)
+	cts.Equal(map[string]int64{"user2": 2}, counts)
+
+	cleanTable(cts)
+}
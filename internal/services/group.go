@@ -0,0 +1,106 @@
+package services
+
+import (
+	"chat-system/internal/models"
+	"chat-system/internal/repositories"
+	"context"
+	"time"
+
+	"github.com/gocql/gocql"
+)
+
+// Group messages are fanned out into the partition of each member within a logged batch, so groups must stay small
+const MAX_GROUP_MEMBERS = 50
+
+type GroupService interface {
+	CreateGroup(ctx context.Context, group *models.Group, members []string) error
+	GetMembers(ctx context.Context, groupID gocql.UUID) ([]models.GroupMember, error)
+	AddMember(ctx context.Context, groupID gocql.UUID, username, role string) error
+	RemoveMember(ctx context.Context, groupID gocql.UUID, username string) error
+	CreateGroupMessage(ctx context.Context, message *models.Message, members []string) error
+	EditGroupMessage(ctx context.Context, message *models.Message, members []string) error
+	DeleteGroupMessage(ctx context.Context, message *models.Message, members []string) error
+	GetGroupMessages(ctx context.Context, groupID gocql.UUID, page models.MessagesPage) ([]models.Message, error)
+}
+
+type groupService struct {
+	groups repositories.GroupRepository
+}
+
+func NewGroupService(groups repositories.GroupRepository) *groupService {
+	return &groupService{groups: groups}
+}
+
+// CreateGroup creates the group with its owner & initial members all at once
+func (s *groupService) CreateGroup(ctx context.Context, group *models.Group, members []string) error {
+	group.ID = gocql.TimeUUID()
+	group.CreatedAt = time.Now().UTC()
+
+	groupMembers := make([]models.GroupMember, 0, len(members)+1)
+	for _, member := range members {
+		if member == group.Owner {
+			continue
+		}
+		groupMembers = append(groupMembers, models.GroupMember{
+			GroupID:  group.ID,
+			Username: member,
+			Role:     models.GROUP_ROLE_MEMBER,
+			JoinedAt: group.CreatedAt,
+		})
+	}
+	groupMembers = append(groupMembers, models.GroupMember{
+		GroupID:  group.ID,
+		Username: group.Owner,
+		Role:     models.GROUP_ROLE_OWNER,
+		JoinedAt: group.CreatedAt,
+	})
+
+	return s.groups.CreateGroup(ctx, group, groupMembers)
+}
+
+func (s *groupService) GetMembers(ctx context.Context, groupID gocql.UUID) ([]models.GroupMember, error) {
+	return s.groups.GetMembers(ctx, groupID)
+}
+
+// AddMember adds the user to the group, or updates their role if they are a member already
+func (s *groupService) AddMember(ctx context.Context, groupID gocql.UUID, username, role string) error {
+	return s.groups.AddMember(ctx, &models.GroupMember{
+		GroupID:  groupID,
+		Username: username,
+		Role:     role,
+		JoinedAt: time.Now().UTC(),
+	})
+}
+
+func (s *groupService) RemoveMember(ctx context.Context, groupID gocql.UUID, username string) error {
+	return s.groups.RemoveMember(ctx, groupID, username)
+}
+
+// CreateGroupMessage writes the message to the group timeline & fans it out into every member's messages,
+// so it shows up in their history just like the direct ones
+func (s *groupService) CreateGroupMessage(ctx context.Context, message *models.Message, members []string) error {
+	message.ID = gocql.TimeUUID()
+	message.Timestamp = models.TimestampOf(message.ID)
+
+	return s.groups.CreateGroupMessage(ctx, message, members)
+}
+
+// EditGroupMessage updates the content of the message within the group timeline & the messages of the members.
+// Former members keep the copy they got while they were members as it was when they left, since they aren't tracked.
+func (s *groupService) EditGroupMessage(ctx context.Context, message *models.Message, members []string) error {
+	editedAt := time.Now().UTC()
+	message.EditedAt = &editedAt
+
+	return s.groups.EditGroupMessage(ctx, message, members)
+}
+
+// DeleteGroupMessage deletes the message for everyone, i.e. from the group timeline & the messages of the members.
+// Former members keep their copy, same as with edits.
+func (s *groupService) DeleteGroupMessage(ctx context.Context, message *models.Message, members []string) error {
+	return s.groups.DeleteGroupMessage(ctx, message, members)
+}
+
+// GetGroupMessages retrieves a page of the group timeline, newest first
+func (s *groupService) GetGroupMessages(ctx context.Context, groupID gocql.UUID, page models.MessagesPage) ([]models.Message, error) {
+	return s.groups.GetGroupMessages(ctx, groupID, page)
+}
@@ -0,0 +1,203 @@
+package services
+
+import (
+	"chat-system/internal/models"
+	"testing"
+
+	"github.com/gocql/gocql"
+	"github.com/stretchr/testify/suite"
+)
+
+type GroupsTestSuite struct {
+	suite.Suite
+	storage    *testStorage
+	tableName  string
+	service    *groupService
+	msgService *messageService
+}
+
+func (gts *GroupsTestSuite) Storage() *testStorage {
+	return gts.storage
+}
+
+func (gts *GroupsTestSuite) SetStorage(storage *testStorage) {
+	gts.storage = storage
+}
+
+func (gts *GroupsTestSuite) DBTable() string {
+	return gts.tableName
+}
+
+func (gts *GroupsTestSuite) SetDBTable(tableName string) {
+	gts.tableName = tableName
+}
+
+func (gts *GroupsTestSuite) Service() *groupService {
+	return gts.service
+}
+
+func (gts *GroupsTestSuite) SetService(service *groupService) {
+	gts.service = service
+}
+
+func TestGroupsTestSuite(t *testing.T) {
+	suite.Run(t, new(GroupsTestSuite))
+}
+
+func (gts *GroupsTestSuite) SetupSuite() {
+	gts.SetDBTable(GROUPS_TEST_TABLE_NAME)
+	setupDatabase(gts)
+
+	gts.SetService(NewGroupService(gts.Storage().groups()))
+
+	// Group messages are fanned out into the members' history
+	gts.msgService = NewMessageService(gts.Storage().messages(), newTestCache())
+}
+
+func (gts *GroupsTestSuite) TearDownSuite() {
+	tearDownDatabase(gts)
+}
+
+func (gts *GroupsTestSuite) TestCreateGroup_Success() {
+	cleanTable(gts)
+
+	group := &models.Group{Name: "friends", Owner: "user1"}
+	err := gts.Service().CreateGroup(testCtx, group, []string{"user2", "user3"})
+
+	gts.Nil(err)
+	gts.NotEqual(gocql.UUID{}, group.ID)
+
+	members, err := gts.Service().GetMembers(testCtx, group.ID)
+
+	gts.Nil(err)
+	gts.Len(members, 3)
+
+	roles := make(map[string]string)
+	for _, member := range members {
+		roles[member.Username] = member.Role
+	}
+	gts.Equal(models.GROUP_ROLE_OWNER, roles["user1"])
+	gts.Equal(models.GROUP_ROLE_MEMBER, roles["user2"])
+
+	cleanTable(gts)
+}
+
+func (gts *GroupsTestSuite) TestAddAndRemoveMember_Success() {
+	cleanTable(gts)
+
+	group := &models.Group{Name: "friends", Owner: "user1"}
+	gts.Require().Nil(gts.Service().CreateGroup(testCtx, group, nil))
+
+	gts.Nil(gts.Service().AddMember(testCtx, group.ID, "user2", models.GROUP_ROLE_ADMIN))
+
+	members, err := gts.Service().GetMembers(testCtx, group.ID)
+	gts.Nil(err)
+	gts.Len(members, 2)
+
+	gts.Nil(gts.Service().RemoveMember(testCtx, group.ID, "user2"))
+
+	members, err = gts.Service().GetMembers(testCtx, group.ID)
+	gts.Nil(err)
+	gts.Len(members, 1)
+	gts.Equal("user1", members[0].Username)
+
+	cleanTable(gts)
+}
+
+func (gts *GroupsTestSuite) TestGetMembers_Empty() {
+	actual, err := gts.Service().GetMembers(testCtx, gocql.TimeUUID())
+
+	gts.Nil(err)
+	gts.Empty(actual)
+}
+
+func (gts *GroupsTestSuite) TestCreateGroupMessage_Fans_Out() {
+	cleanTable(gts)
+
+	groupID := gocql.TimeUUID()
+	msg := &models.Message{Sender: "user1", Content: "hi all", GroupID: &groupID}
+
+	err := gts.Service().CreateGroupMessage(testCtx, msg, []string{"user1", "user2"})
+	gts.Nil(err)
+
+	timeline, err := gts.Service().GetGroupMessages(testCtx, groupID, models.MessagesPage{})
+	gts.Nil(err)
+	gts.Len(timeline, 1)
+	gts.Equal("hi all", timeline[0].Content)
+
+	for _, member := range []string{"user1", "user2"} {
+		history, err := gts.msgService.GetMessages(testCtx, member, models.MessagesPage{})
+
+		gts.Nil(err)
+		gts.Len(history, 1)
+		gts.Equal(msg.ID, history[0].ID)
+		gts.Equal(groupID, *history[0].GroupID)
+	}
+
+	cleanTable(gts)
+}
+
+func (gts *GroupsTestSuite) TestEditAndDeleteGroupMessage() {
+	cleanTable(gts)
+
+	groupID := gocql.TimeUUID()
+	members := []string{"user1", "user2"}
+	msg := &models.Message{Sender: "user1", Content: "helo all", GroupID: &groupID}
+	gts.Nil(gts.Service().CreateGroupMessage(testCtx, msg, members))
+
+	stored, err := gts.msgService.GetMessage(testCtx, "user1", msg.ID)
+	gts.Nil(err)
+
+	stored.Content = "hello all"
+	gts.Nil(gts.Service().EditGroupMessage(testCtx, stored, members))
+
+	timeline, err := gts.Service().GetGroupMessages(testCtx, groupID, models.MessagesPage{})
+	gts.Nil(err)
+	gts.Equal("hello all", timeline[0].Content)
+	gts.NotNil(timeline[0].EditedAt)
+
+	gts.Nil(gts.Service().DeleteGroupMessage(testCtx, stored, members))
+
+	timeline, err = gts.Service().GetGroupMessages(testCtx, groupID, models.MessagesPage{})
+	gts.Nil(err)
+	gts.Empty(timeline)
+
+	for _, member := range members {
+		history, err := gts.msgService.GetMessages(testCtx, member, models.MessagesPage{})
+
+		gts.Nil(err)
+		gts.Empty(history)
+	}
+
+	cleanTable(gts)
+}
+
+func (gts *GroupsTestSuite) TestEditAndDeleteGroupMessage_Removed_Members_Keep_Their_Copy() {
+	cleanTable(gts)
+
+	groupID := gocql.TimeUUID()
+	msg := &models.Message{Sender: "user1", Content: "helo all", GroupID: &groupID}
+	gts.Nil(gts.Service().CreateGroupMessage(testCtx, msg, []string{"user1", "user2", "user3"}))
+
+	// user3 gets removed, so only the current members are updated from now on
+	members := []string{"user1", "user2"}
+	stored, err := gts.msgService.GetMessage(testCtx, "user1", msg.ID)
+	gts.Nil(err)
+	stored.Content = "hello all"
+	gts.Nil(gts.Service().EditGroupMessage(testCtx, stored, members))
+
+	history, err := gts.msgService.GetMessages(testCtx, "user3", models.MessagesPage{})
+	gts.Nil(err)
+	gts.Len(history, 1)
+	gts.Equal("helo all", history[0].Content)
+	gts.Nil(history[0].EditedAt)
+
+	gts.Nil(gts.Service().DeleteGroupMessage(testCtx, stored, members))
+
+	history, err = gts.msgService.GetMessages(testCtx, "user3", models.MessagesPage{})
+	gts.Nil(err)
+	gts.Len(history, 1)
+	gts.Equal(msg.ID, history[0].ID)
+
+	cleanTable(gts)
+}
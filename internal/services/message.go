@@ -2,154 +2,84 @@ package services
 
 import (
 	"chat-system/internal/api/cache"
-	"chat-system/internal/cassandra"
 	"chat-system/internal/models"
-	"encoding/json"
-	"fmt"
+	"chat-system/internal/repositories"
+	"context"
 	"time"
 
-	"github.com/go-redis/redis/v8"
 	"github.com/gocql/gocql"
 )
 
+// Preview shown in the conversations listing once the last message got deleted for everyone
+const DELETED_MSG_PREVIEW = "This message was deleted"
+
 type MessageService interface {
-	CreateMessage(message *models.Message) error
-	GetMessages(username string) ([]models.Message, error)
-	GetFromCache(cacheKey string) ([]models.Message, error)
-	SetMessagesToCache(cacheKey string, messages []models.Message) error
-	UpdateCachedMsgsForUser(cacheKey string, msg *models.Message) error
+	CreateMessage(ctx context.Context, message *models.Message) error
+	GetMessages(ctx context.Context, username string, page models.MessagesPage) ([]models.Message, error)
+	GetMessage(ctx context.Context, username string, id gocql.UUID) (*models.Message, error)
+	EditMessage(ctx context.Context, message *models.Message) error
+	DeleteMessage(ctx context.Context, message *models.Message) error
+	DeleteMessageForUser(ctx context.Context, username string, message *models.Message) error
+	GetCachedMessages(ctx context.Context, username string, page models.MessagesPage) ([]models.Message, bool, error)
+	CachedMessagesVersion(ctx context.Context, username string) (int64, error)
+	CacheMessages(ctx context.Context, username string, messages []models.Message, complete bool, version int64) error
+	CacheMessage(ctx context.Context, username string, message *models.Message) error
+	InvalidateCachedMessages(ctx context.Context, username string) error
+	AcquireCacheRebuild(ctx context.Context, username string) (string, bool, error)
+	ReleaseCacheRebuild(ctx context.Context, username, token string) error
 }
 
 type messageService struct {
-	db         *gocql.Session
-	dbKeyspace string
-	tableName  string
+	messages repositories.MessageRepository
+	cache    cache.Cache
 }
 
-func NewMessageService(db *gocql.Session, keyspace, tableName string) *messageService {
+func NewMessageService(messages repositories.MessageRepository, c cache.Cache) *messageService {
 	return &messageService{
-		db:         db,
-		dbKeyspace: keyspace,
-		tableName:  tableName,
+		messages: messages,
+		cache:    c,
 	}
 }
 
-func (s *messageService) CreateMessage(message *models.Message) error {
+func (s *messageService) CreateMessage(ctx context.Context, message *models.Message) error {
 	message.ID = gocql.TimeUUID()
-	message.Timestamp = time.Now().UTC()
-
-	query := fmt.Sprintf(
-		`INSERT INTO %s.%s
-		(user, timestamp, id, sender, recipient, content)
-		VALUES (?, ?, ?, ?, ?, ?)`,
-		s.dbKeyspace,
-		s.tableName,
-	)
-
-	batch := s.db.NewBatch(gocql.LoggedBatch)
-
-	batch.Query(
-		query,
-		message.Sender,
-		message.Timestamp,
-		message.ID,
-		message.Sender,
-		message.Recipient,
-		message.Content,
-	)
-
-	batch.Query(
-		query,
-		message.Recipient,
-		message.Timestamp,
-		message.ID,
-		message.Sender,
-		message.Recipient,
-		message.Content,
-	)
-
-	return cassandra.Session.ExecuteBatch(batch)
-}
-
-func (s *messageService) GetMessages(username string) ([]models.Message, error) {
-	var messages []models.Message
-	query := fmt.Sprintf(
-		`SELECT id, sender, recipient, timestamp, content
-		FROM %s.%s
-		WHERE user = ?
-		ORDER BY timestamp DESC`,
-		s.dbKeyspace,
-		s.tableName,
-	)
-	iter := cassandra.Session.Query(
-		query,
-		username,
-	).Iter()
-	var message models.Message
-	for iter.Scan(&message.ID, &message.Sender, &message.Recipient, &message.Timestamp, &message.Content) {
-		messages = append(messages, message)
-	}
-	if err := iter.Close(); err != nil {
-		return nil, err
-	}
+	message.Timestamp = models.TimestampOf(message.ID)
 
-	return messages, nil
+	return s.messages.CreateMessage(ctx, message, messagePreview(message.Content))
 }
 
-func (s *messageService) GetFromCache(cacheKey string) ([]models.Message, error) {
-	jsonData, err := cache.Get(cacheKey)
-	if err == redis.Nil {
-		return nil, nil
-	}
-
-	if err != nil {
-		return nil, err
-	}
-
-	var messages []models.Message
-	err = json.Unmarshal([]byte(jsonData), &messages)
-	if err != nil {
-		return nil, err
-	}
+// GetMessages retrieves a page of the messages sent to or by the user, newest first
+func (s *messageService) GetMessages(ctx context.Context, username string, page models.MessagesPage) ([]models.Message, error) {
+	return s.messages.GetMessages(ctx, username, page)
+}
 
-	return messages, nil
+// GetMessage retrieves a single message from the ones sent to or by the user, nil if there's no such message
+func (s *messageService) GetMessage(ctx context.Context, username string, id gocql.UUID) (*models.Message, error) {
+	return s.messages.GetMessage(ctx, username, id)
 }
 
-func (s *messageService) SetMessagesToCache(cacheKey string, messages []models.Message) error {
-	jsonData, err := json.Marshal(messages)
-	if err != nil {
-		return err
-	}
+// EditMessage updates the content of a direct message on both sides, as well as within the thread & previews
+func (s *messageService) EditMessage(ctx context.Context, message *models.Message) error {
+	editedAt := time.Now().UTC()
+	message.EditedAt = &editedAt
 
-	err = cache.Set(cacheKey, jsonData)
-	if err != nil {
+	if err := s.messages.EditMessage(ctx, message); err != nil {
 		return err
 	}
 
-	return nil
+	return s.messages.UpdateConversationPreviews(ctx, message, messagePreview(message.Content))
 }
 
-func (s *messageService) UpdateCachedMsgsForUser(cacheKey string, msg *models.Message) error {
-	// Append the message to the Redis list
-	// Set the updated messages list in Redis
-	messages, err := s.GetFromCache(cacheKey)
-	if err != nil {
+// DeleteMessage deletes a direct message for everyone, i.e. from both sides as well as from the thread
+func (s *messageService) DeleteMessage(ctx context.Context, message *models.Message) error {
+	if err := s.messages.DeleteMessage(ctx, message); err != nil {
 		return err
 	}
 
-	// if messages are not cached, we cannot update the cache now
-	// return early, fail fast and wait for messages to be available in cache to update them!
-	if messages == nil {
-		return nil
-	}
-
-	msgToCache := *msg
-	// Prepend to cached since they are already sorted by DB
-	messages = append([]models.Message{msgToCache}, messages...)
-	err = s.SetMessagesToCache(cacheKey, messages)
-	if err != nil {
-		return err
-	}
+	return s.messages.UpdateConversationPreviews(ctx, message, DELETED_MSG_PREVIEW)
+}
 
-	return nil
+// DeleteMessageForUser deletes the message (either direct or group one) from the messages of the user only
+func (s *messageService) DeleteMessageForUser(ctx context.Context, username string, message *models.Message) error {
+	return s.messages.DeleteMessageForUser(ctx, username, message)
 }
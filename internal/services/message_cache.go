@@ -0,0 +1,325 @@
+package services
+
+import (
+	"chat-system/internal/api/cache"
+	"chat-system/internal/metrics"
+	"chat-system/internal/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// The most recent messages of each user are cached within a sorted set scored by their timestamp,
+// enough to serve a few pages of any size
+const CACHED_MSGS_LIMIT = 5 * models.MAX_PAGE_SIZE
+
+// Time the rebuild of the cached messages of a user is leased to a single replica at most
+const CACHE_REBUILD_LEASE_TTL = 5 * time.Second
+
+// Field of the version of the cached messages of a user
+const CACHE_VERSION_FIELD = "version"
+
+// Member marking that the cached messages go all the way back to the start of the history of the user.
+// Scored below any message, it's the first one trimmed once older messages no longer fit in the cache.
+const HISTORY_START_MEMBER = "history-start"
+
+// GetCachedMessages serves the page from the cached messages of the user, walking the sorted set within
+// the bounds of the page the same way queryPage walks the DB. The cache holds the most recent messages
+// without gaps, so the page is served (true) only if it falls within them, otherwise it's a miss.
+func (s *messageService) GetCachedMessages(ctx context.Context, username string, page models.MessagesPage) ([]models.Message, bool, error) {
+	if page.Limit <= 0 {
+		return nil, false, nil
+	}
+
+	var messages []models.Message
+	var hit bool
+	var err error
+
+	key := username + cache.CACHE_KEY_SUFFIX
+	switch {
+	case page.Before != nil:
+		messages, hit, err = s.cachedMessagesBefore(ctx, key, *page.Before, page.Limit)
+	case page.After != nil:
+		messages, hit, err = s.cachedMessagesAfter(ctx, key, *page.After, page.Limit)
+	default:
+		messages, hit, err = s.cachedRecentMessages(ctx, key, page.Limit)
+	}
+
+	switch {
+	case err != nil:
+		metrics.CacheReads.WithLabelValues(metrics.CACHE_ERROR).Inc()
+	case hit:
+		metrics.CacheReads.WithLabelValues(metrics.CACHE_HIT).Inc()
+	default:
+		metrics.CacheReads.WithLabelValues(metrics.CACHE_MISS).Inc()
+	}
+
+	return messages, hit, err
+}
+
+func (s *messageService) cachedRecentMessages(ctx context.Context, key string, limit int) ([]models.Message, bool, error) {
+	// One extra member may be the start of the history
+	ranges, err := s.cache.GetSortedRanges(ctx, key, cache.ScoreRange{Min: "-inf", Max: "+inf", Reverse: true, Limit: int64(limit + 1)})
+	if err != nil {
+		return nil, false, err
+	}
+
+	recent, err := s.withWholeLastMillisecond(ctx, key, ranges[0], limit+1)
+	if err != nil {
+		return nil, false, err
+	}
+
+	messages, complete, err := decodeCachedMessages(recent)
+	if err != nil {
+		return nil, false, err
+	}
+	sortMessages(messages)
+
+	return trimCachedPage(messages, complete, limit)
+}
+
+func (s *messageService) cachedMessagesBefore(ctx context.Context, key string, bound models.MessageKey, limit int) ([]models.Message, bool, error) {
+	score := scoreOf(bound.Timestamp)
+	ranges, err := s.cache.GetSortedRanges(ctx, key,
+		cache.ScoreRange{Min: score, Max: score},
+		cache.ScoreRange{Min: "-inf", Max: "(" + score, Reverse: true, Limit: int64(limit + 1)},
+	)
+	if err != nil {
+		return nil, false, err
+	}
+
+	ties, _, err := decodeCachedMessages(ranges[0])
+	if err != nil {
+		return nil, false, err
+	}
+	olderMembers, err := s.withWholeLastMillisecond(ctx, key, ranges[1], limit+1)
+	if err != nil {
+		return nil, false, err
+	}
+	older, complete, err := decodeCachedMessages(olderMembers)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var messages []models.Message
+	for _, message := range ties {
+		if bound.Precedes(models.KeyOf(&message)) {
+			messages = append(messages, message)
+		}
+	}
+	messages = append(messages, older...)
+	sortMessages(messages)
+
+	return trimCachedPage(messages, complete, limit)
+}
+
+func (s *messageService) cachedMessagesAfter(ctx context.Context, key string, bound models.MessageKey, limit int) ([]models.Message, bool, error) {
+	score := scoreOf(bound.Timestamp)
+	ranges, err := s.cache.GetSortedRanges(ctx, key,
+		cache.ScoreRange{Min: "-inf", Max: "+inf", Limit: 1},
+		cache.ScoreRange{Min: score, Max: score},
+		cache.ScoreRange{Min: "(" + score, Max: "+inf", Limit: int64(limit)},
+	)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// Messages past the bound are all cached only if the cache goes back beyond it
+	oldest := ranges[0]
+	if len(oldest) == 0 || (oldest[0].Member != HISTORY_START_MEMBER && oldest[0].Score >= float64(bound.Timestamp.UnixMilli())) {
+		return nil, false, nil
+	}
+
+	ties, _, err := decodeCachedMessages(ranges[1])
+	if err != nil {
+		return nil, false, err
+	}
+	newerMembers, err := s.withWholeLastMillisecond(ctx, key, ranges[2], limit)
+	if err != nil {
+		return nil, false, err
+	}
+	newer, _, err := decodeCachedMessages(newerMembers)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var messages []models.Message
+	for _, message := range ties {
+		if models.KeyOf(&message).Precedes(bound) {
+			messages = append(messages, message)
+		}
+	}
+	messages = append(messages, newer...)
+	sortMessages(messages)
+
+	// The page holds the closest messages past the bound, i.e. the oldest ones
+	if len(messages) > limit {
+		messages = messages[len(messages)-limit:]
+	}
+
+	return messages, true, nil
+}
+
+// withWholeLastMillisecond completes the members of a range cut by its limit with the rest of the members
+// sharing the score of the last one, since the sorted set doesn't order the messages of the same millisecond
+// in clustering order, so the cut could skip the ones coming first
+func (s *messageService) withWholeLastMillisecond(ctx context.Context, key string, members []cache.ScoredMember, limit int) ([]cache.ScoredMember, error) {
+	if len(members) < limit {
+		return members, nil
+	}
+
+	last := members[len(members)-1].Score
+	score := strconv.FormatFloat(last, 'f', -1, 64)
+	ranges, err := s.cache.GetSortedRanges(ctx, key, cache.ScoreRange{Min: score, Max: score})
+	if err != nil {
+		return nil, err
+	}
+
+	i := len(members)
+	for i > 0 && members[i-1].Score == last {
+		i--
+	}
+
+	return append(members[:i:i], ranges[0]...), nil
+}
+
+// trimCachedPage cuts the messages (sorted newest first) down to the limit. Fewer messages serve the page
+// only if there's nothing older to fetch from DB.
+func trimCachedPage(messages []models.Message, complete bool, limit int) ([]models.Message, bool, error) {
+	if len(messages) >= limit {
+		return messages[:limit], true, nil
+	}
+
+	if complete {
+		if messages == nil {
+			messages = make([]models.Message, 0)
+		}
+		return messages, true, nil
+	}
+
+	return nil, false, nil
+}
+
+// CachedMessagesVersion returns the version of the cached messages of the user, to be read before reading
+// the messages to cache from DB
+func (s *messageService) CachedMessagesVersion(ctx context.Context, username string) (int64, error) {
+	fields, err := s.cache.GetFields(ctx, username+cache.CACHE_KEY_SUFFIX+cache.VERSION_KEY_SUFFIX)
+	if err != nil {
+		return 0, err
+	}
+
+	value, ok := fields[CACHE_VERSION_FIELD]
+	if !ok {
+		return 0, nil
+	}
+	return strconv.ParseInt(value, 10, 64)
+}
+
+// CacheMessages adds the most recent messages of the user read from DB to the cache. Those are merged with
+// any message cached meanwhile rather than replacing them, so a concurrent send isn't lost.
+// complete tells the messages go back to the start of the history, so an empty history gets cached as well.
+// version is the one read before reading the messages: if they got invalidated since, they may be stale already,
+// so they're dropped again.
+func (s *messageService) CacheMessages(ctx context.Context, username string, messages []models.Message, complete bool, version int64) error {
+	members := make([]cache.ScoredMember, 0, len(messages)+1)
+	for i := range messages {
+		member, err := cachedMemberOf(&messages[i])
+		if err != nil {
+			return err
+		}
+		members = append(members, member)
+	}
+
+	if complete {
+		members = append(members, cache.ScoredMember{Member: HISTORY_START_MEMBER, Score: 0})
+	}
+
+	if len(members) == 0 {
+		return nil
+	}
+
+	key := username + cache.CACHE_KEY_SUFFIX
+	if err := s.cache.AddToSortedSet(ctx, key, members, CACHED_MSGS_LIMIT); err != nil {
+		return err
+	}
+
+	current, err := s.CachedMessagesVersion(ctx, username)
+	if err != nil || current != version {
+		return errors.Join(err, s.cache.Delete(ctx, key))
+	}
+	return nil
+}
+
+// CacheMessage atomically adds a new message to the cached messages of the user, dropping the oldest one
+// once the cache is full
+func (s *messageService) CacheMessage(ctx context.Context, username string, message *models.Message) error {
+	member, err := cachedMemberOf(message)
+	if err != nil {
+		return err
+	}
+
+	return s.cache.AddToSortedSet(ctx, username+cache.CACHE_KEY_SUFFIX, []cache.ScoredMember{member}, CACHED_MSGS_LIMIT)
+}
+
+// InvalidateCachedMessages drops the cached messages of the user, so they get fetched again from DB on the next read.
+// The version is bumped first, so a rebuild that read them from DB beforehand drops them again once it caches them.
+func (s *messageService) InvalidateCachedMessages(ctx context.Context, username string) error {
+	if err := s.cache.IncrField(ctx, username+cache.CACHE_KEY_SUFFIX+cache.VERSION_KEY_SUFFIX, CACHE_VERSION_FIELD); err != nil {
+		return err
+	}
+	return s.cache.Delete(ctx, username+cache.CACHE_KEY_SUFFIX)
+}
+
+// AcquireCacheRebuild leases the rebuild of the cached messages of the user, unless another replica
+// holds it already (false). The returned token releases the lease.
+func (s *messageService) AcquireCacheRebuild(ctx context.Context, username string) (string, bool, error) {
+	return s.cache.AcquireLease(ctx, username+cache.CACHE_KEY_SUFFIX+cache.LEASE_KEY_SUFFIX, CACHE_REBUILD_LEASE_TTL)
+}
+
+func (s *messageService) ReleaseCacheRebuild(ctx context.Context, username, token string) error {
+	return s.cache.ReleaseLease(ctx, username+cache.CACHE_KEY_SUFFIX+cache.LEASE_KEY_SUFFIX, token)
+}
+
+func cachedMemberOf(message *models.Message) (cache.ScoredMember, error) {
+	jsonData, err := json.Marshal(message)
+	if err != nil {
+		return cache.ScoredMember{}, err
+	}
+
+	return cache.ScoredMember{Member: string(jsonData), Score: float64(message.Timestamp.UnixMilli())}, nil
+}
+
+// decodeCachedMessages decodes the cached messages & tells whether the start of the history is among them
+func decodeCachedMessages(members []cache.ScoredMember) ([]models.Message, bool, error) {
+	var messages []models.Message
+	complete := false
+	for _, member := range members {
+		if member.Member == HISTORY_START_MEMBER {
+			complete = true
+			continue
+		}
+
+		var message models.Message
+		if err := json.Unmarshal([]byte(member.Member), &message); err != nil {
+			return nil, false, err
+		}
+		messages = append(messages, message)
+	}
+
+	return messages, complete, nil
+}
+
+// sortMessages sorts the messages newest first in clustering order, since the sorted set orders
+// messages sharing the same millisecond by their JSON
+func sortMessages(messages []models.Message) {
+	sort.SliceStable(messages, func(i, j int) bool {
+		return models.KeyOf(&messages[i]).Precedes(models.KeyOf(&messages[j]))
+	})
+}
+
+func scoreOf(timestamp time.Time) string {
+	return strconv.FormatInt(timestamp.UnixMilli(), 10)
+}
@@ -0,0 +1,171 @@
+package services
+
+import (
+	"chat-system/internal/metrics"
+	"chat-system/internal/models"
+	"testing"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/suite"
+)
+
+// MessageCacheTestSuite covers serving pages from the cached messages, which don't need a DB
+type MessageCacheTestSuite struct {
+	suite.Suite
+	service *messageService
+	// Newest first in clustering order
+	messages []models.Message
+}
+
+func TestMessageCacheTestSuite(t *testing.T) {
+	suite.Run(t, new(MessageCacheTestSuite))
+}
+
+func (mcs *MessageCacheTestSuite) SetupTest() {
+	mcs.service = NewMessageService(nil, newTestCache())
+
+	// 3 messages per millisecond, so pages get cut in the middle of the same millisecond
+	base := time.Now()
+	mcs.messages = nil
+	for i := 0; i < 12; i++ {
+		id := gocql.UUIDFromTime(base.Add(time.Duration(i/3)*time.Millisecond + time.Duration(i%3)*100))
+		mcs.messages = append(mcs.messages, models.Message{ID: id, Timestamp: models.TimestampOf(id), Content: string(rune('a' + i))})
+	}
+	sortMessages(mcs.messages)
+}
+
+func (mcs *MessageCacheTestSuite) cacheAll() {
+	for i := len(mcs.messages) - 1; i >= 0; i-- {
+		mcs.Require().NoError(mcs.service.CacheMessage(testCtx, "User1", &mcs.messages[i]))
+	}
+}
+
+func (mcs *MessageCacheTestSuite) contents(messages []models.Message) []string {
+	var contents []string
+	for _, message := range messages {
+		contents = append(contents, message.Content)
+	}
+	return contents
+}
+
+func (mcs *MessageCacheTestSuite) Test_Recent_Page() {
+	mcs.cacheAll()
+
+	messages, hit, err := mcs.service.GetCachedMessages(testCtx, "User1", models.MessagesPage{Limit: 4})
+	mcs.NoError(err)
+	mcs.True(hit)
+	mcs.Equal(mcs.contents(mcs.messages[:4]), mcs.contents(messages))
+}
+
+func (mcs *MessageCacheTestSuite) Test_Before_Page() {
+	mcs.cacheAll()
+
+	bound := models.KeyOf(&mcs.messages[1])
+	messages, hit, err := mcs.service.GetCachedMessages(testCtx, "User1", models.MessagesPage{Before: &bound, Limit: 4})
+	mcs.NoError(err)
+	mcs.True(hit)
+	mcs.Equal(mcs.contents(mcs.messages[2:6]), mcs.contents(messages))
+
+	// Older messages may be missing from cache
+	_, hit, err = mcs.service.GetCachedMessages(testCtx, "User1", models.MessagesPage{Before: &bound, Limit: 20})
+	mcs.NoError(err)
+	mcs.False(hit)
+}
+
+func (mcs *MessageCacheTestSuite) Test_After_Page() {
+	mcs.cacheAll()
+
+	bound := models.KeyOf(&mcs.messages[7])
+	messages, hit, err := mcs.service.GetCachedMessages(testCtx, "User1", models.MessagesPage{After: &bound, Limit: 4})
+	mcs.NoError(err)
+	mcs.True(hit)
+	mcs.Equal(mcs.contents(mcs.messages[3:7]), mcs.contents(messages))
+
+	// The cache doesn't go back beyond its oldest message
+	oldest := models.KeyOf(&mcs.messages[len(mcs.messages)-1])
+	_, hit, err = mcs.service.GetCachedMessages(testCtx, "User1", models.MessagesPage{After: &oldest, Limit: 4})
+	mcs.NoError(err)
+	mcs.False(hit)
+}
+
+func (mcs *MessageCacheTestSuite) Test_Complete_History() {
+	err := mcs.service.CacheMessages(testCtx, "User1", mcs.messages, true, 0)
+	mcs.NoError(err)
+
+	bound := models.KeyOf(&mcs.messages[1])
+	messages, hit, err := mcs.service.GetCachedMessages(testCtx, "User1", models.MessagesPage{Before: &bound, Limit: 20})
+	mcs.NoError(err)
+	mcs.True(hit)
+	mcs.Equal(mcs.contents(mcs.messages[2:]), mcs.contents(messages))
+}
+
+func (mcs *MessageCacheTestSuite) Test_Empty_History() {
+	_, hit, err := mcs.service.GetCachedMessages(testCtx, "User1", models.MessagesPage{Limit: 4})
+	mcs.NoError(err)
+	mcs.False(hit)
+
+	err = mcs.service.CacheMessages(testCtx, "User1", nil, true, 0)
+	mcs.NoError(err)
+
+	messages, hit, err := mcs.service.GetCachedMessages(testCtx, "User1", models.MessagesPage{Limit: 4})
+	mcs.NoError(err)
+	mcs.True(hit)
+	mcs.Empty(messages)
+
+	mcs.NoError(mcs.service.InvalidateCachedMessages(testCtx, "User1"))
+	_, hit, err = mcs.service.GetCachedMessages(testCtx, "User1", models.MessagesPage{Limit: 4})
+	mcs.NoError(err)
+	mcs.False(hit)
+}
+
+func (mcs *MessageCacheTestSuite) Test_Rebuild_Racing_Edit_Not_Kept() {
+	stale := append([]models.Message(nil), mcs.messages...)
+	read, edited := make(chan struct{}), make(chan error)
+
+	// The rebuild reads the messages from DB before the edit, yet caches them after it's invalidated
+	go func() {
+		version, err := mcs.service.CachedMessagesVersion(testCtx, "User1")
+		close(read)
+		<-edited
+		if err == nil {
+			err = mcs.service.CacheMessages(testCtx, "User1", stale, true, version)
+		}
+		edited <- err
+	}()
+
+	<-read
+	mcs.messages[0].Content = "edited"
+	mcs.NoError(mcs.service.InvalidateCachedMessages(testCtx, "User1"))
+	edited <- nil
+	mcs.NoError(<-edited)
+
+	_, hit, err := mcs.service.GetCachedMessages(testCtx, "User1", models.MessagesPage{Limit: 4})
+	mcs.NoError(err)
+	mcs.False(hit, "Stale messages dropped again")
+
+	// The next rebuild reads the edited message
+	version, err := mcs.service.CachedMessagesVersion(testCtx, "User1")
+	mcs.NoError(err)
+	mcs.NoError(mcs.service.CacheMessages(testCtx, "User1", mcs.messages, true, version))
+
+	messages, hit, err := mcs.service.GetCachedMessages(testCtx, "User1", models.MessagesPage{Limit: 4})
+	mcs.NoError(err)
+	mcs.True(hit)
+	mcs.Equal("edited", messages[0].Content)
+}
+
+func (mcs *MessageCacheTestSuite) Test_Counts_Reads() {
+	hits := testutil.ToFloat64(metrics.CacheReads.WithLabelValues(metrics.CACHE_HIT))
+	misses := testutil.ToFloat64(metrics.CacheReads.WithLabelValues(metrics.CACHE_MISS))
+
+	_, _, err := mcs.service.GetCachedMessages(testCtx, "User1", models.MessagesPage{Limit: 4})
+	mcs.NoError(err)
+	mcs.cacheAll()
+	_, _, err = mcs.service.GetCachedMessages(testCtx, "User1", models.MessagesPage{Limit: 4})
+	mcs.NoError(err)
+
+	mcs.Equal(hits+1, testutil.ToFloat64(metrics.CacheReads.WithLabelValues(metrics.CACHE_HIT)))
+	mcs.Equal(misses+1, testutil.ToFloat64(metrics.CacheReads.WithLabelValues(metrics.CACHE_MISS)))
+}
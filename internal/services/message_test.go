@@ -2,6 +2,7 @@ package services
 
 import (
 	"chat-system/internal/models"
+	"fmt"
 	"testing"
 	"time"
 
@@ -11,17 +12,17 @@ import (
 
 type MessagesTestSuite struct {
 	suite.Suite
-	dbSession *gocql.Session
+	storage   *testStorage
 	tableName string
 	service   *messageService
 }
 
-func (mts *MessagesTestSuite) DBSession() *gocql.Session {
-	return mts.dbSession
+func (mts *MessagesTestSuite) Storage() *testStorage {
+	return mts.storage
 }
 
-func (mts *MessagesTestSuite) SetDBSession(session *gocql.Session) {
-	mts.dbSession = session
+func (mts *MessagesTestSuite) SetStorage(storage *testStorage) {
+	mts.storage = storage
 }
 
 func (mts *MessagesTestSuite) DBTable() string {
@@ -46,9 +47,9 @@ func TestMessagesTestSuite(t *testing.T) {
 
 func (mts *MessagesTestSuite) SetupSuite() {
 	mts.SetDBTable(MSGS_TEST_TABLE_NAME)
-	mts.SetDBSession(setupDatabase(mts))
+	setupDatabase(mts)
 
-	mts.SetService(NewMessageService(mts.DBSession(), KEYSPACE_TEST, mts.DBTable()))
+	mts.SetService(NewMessageService(mts.Storage().messages(), newTestCache()))
 }
 
 func (mts *MessagesTestSuite) TearDownSuite() {
@@ -64,7 +65,7 @@ func (mts *MessagesTestSuite) TestCreateMessage_Success() {
 		Content:   "test",
 	}
 
-	err := mts.Service().CreateMessage(msg)
+	err := mts.Service().CreateMessage(testCtx, msg)
 
 	mts.Nil(err)
 
@@ -74,7 +75,7 @@ func (mts *MessagesTestSuite) TestCreateMessage_Success() {
 func (mts *MessagesTestSuite) TestCreateMessage_ErrDB() {
 	errMsg := "Key may not be empty"
 
-	err := mts.Service().CreateMessage(&models.Message{})
+	err := mts.Service().CreateMessage(testCtx, &models.Message{})
 
 	mts.EqualError(err, errMsg)
 }
@@ -85,7 +86,7 @@ func (mts *MessagesTestSuite) TestGetMessages_Success() {
 	seedTable(mts)
 
 	testUsername := "user1"
-	actual, err := mts.Service().GetMessages(testUsername)
+	actual, err := mts.Service().GetMessages(testCtx, testUsername, models.MessagesPage{})
 
 	mts.Nil(err)
 	mts.NotEmpty(actual)
@@ -98,7 +99,7 @@ func (mts *MessagesTestSuite) TestGetMessages_Success() {
 func (mts *MessagesTestSuite) TestGetMessages_Empty() {
 	testUsername := "test-non-exist"
 
-	actual, err := mts.Service().GetMessages(testUsername)
+	actual, err := mts.Service().GetMessages(testCtx, testUsername, models.MessagesPage{})
 
 	mts.Nil(err)
 	mts.Empty(actual)
@@ -107,8 +108,159 @@ func (mts *MessagesTestSuite) TestGetMessages_Empty() {
 func (mts *MessagesTestSuite) TestGetMessages_ErrDB() {
 	errMsg := "Key may not be empty"
 
-	actual, err := mts.Service().GetMessages("")
+	actual, err := mts.Service().GetMessages(testCtx, "", models.MessagesPage{})
 
 	mts.EqualError(err, errMsg)
 	mts.Nil(actual)
 }
+
+func (mts *MessagesTestSuite) TestGetMessages_Pages_By_Bounds() {
+	cleanTable(mts)
+
+	var sent []*models.Message
+	for i := 0; i < 5; i++ {
+		msg := &models.Message{Sender: "user1", Recipient: "user2", Content: fmt.Sprint(i)}
+		nextMillisecond()
+		mts.Require().Nil(mts.Service().CreateMessage(testCtx, msg))
+		sent = append(sent, msg)
+	}
+
+	// Newest first
+	firstPage, err := mts.Service().GetMessages(testCtx, "user1", models.MessagesPage{Limit: 2})
+	mts.Nil(err)
+	mts.Len(firstPage, 2)
+	mts.Equal("4", firstPage[0].Content)
+	mts.Equal("3", firstPage[1].Content)
+
+	bound := models.KeyOf(&firstPage[1])
+	secondPage, err := mts.Service().GetMessages(testCtx, "user1", models.MessagesPage{Before: &bound, Limit: 2})
+	mts.Nil(err)
+	mts.Len(secondPage, 2)
+	mts.Equal("2", secondPage[0].Content)
+	mts.Equal("1", secondPage[1].Content)
+
+	// Walking back towards the newer ones, still sorted newest first
+	bound = models.KeyFromID(sent[0].ID)
+	newer, err := mts.Service().GetMessages(testCtx, "user1", models.MessagesPage{After: &bound, Limit: 2})
+	mts.Nil(err)
+	mts.Len(newer, 2)
+	mts.Equal("2", newer[0].Content)
+	mts.Equal("1", newer[1].Content)
+
+	cleanTable(mts)
+}
+
+func (mts *MessagesTestSuite) TestGetMessages_Across_Buckets() {
+	cleanTable(mts)
+
+	// March is skipped
+	for i, sentAt := range []time.Time{
+		time.Date(2024, time.January, 10, 0, 0, 0, 0, time.UTC),
+		time.Date(2024, time.January, 20, 0, 0, 0, 0, time.UTC),
+		time.Date(2024, time.February, 10, 0, 0, 0, 0, time.UTC),
+		time.Date(2024, time.April, 1, 0, 0, 0, 0, time.UTC),
+	} {
+		mts.Require().Nil(seedMessage(mts, "user1", "user2", sentAt, fmt.Sprint(i)))
+	}
+
+	all, err := mts.Service().GetMessages(testCtx, "user1", models.MessagesPage{})
+	mts.Nil(err)
+	mts.Len(all, 4)
+
+	firstPage, err := mts.Service().GetMessages(testCtx, "user1", models.MessagesPage{Limit: 2})
+	mts.Nil(err)
+	mts.Len(firstPage, 2)
+	mts.Equal("3", firstPage[0].Content)
+	mts.Equal("2", firstPage[1].Content)
+
+	bound := models.KeyOf(&firstPage[1])
+	secondPage, err := mts.Service().GetMessages(testCtx, "user1", models.MessagesPage{Before: &bound, Limit: 2})
+	mts.Nil(err)
+	mts.Len(secondPage, 2)
+	mts.Equal("1", secondPage[0].Content)
+	mts.Equal("0", secondPage[1].Content)
+
+	bound = models.KeyOf(&secondPage[1])
+	newer, err := mts.Service().GetMessages(testCtx, "user1", models.MessagesPage{After: &bound, Limit: 2})
+	mts.Nil(err)
+	mts.Len(newer, 2)
+	mts.Equal("2", newer[0].Content)
+	mts.Equal("1", newer[1].Content)
+
+	cleanTable(mts)
+}
+
+func (mts *MessagesTestSuite) TestEditMessage_Updates_Both_Sides() {
+	cleanTable(mts)
+
+	msg := &models.Message{Sender: "user1", Recipient: "user2", Content: "helo"}
+	mts.Nil(mts.Service().CreateMessage(testCtx, msg))
+
+	stored, err := mts.Service().GetMessage(testCtx, "user1", msg.ID)
+	mts.Nil(err)
+	mts.NotNil(stored)
+
+	stored.Content = "hello"
+	mts.Nil(mts.Service().EditMessage(testCtx, stored))
+
+	for _, user := range []string{"user1", "user2"} {
+		edited, err := mts.Service().GetMessage(testCtx, user, msg.ID)
+
+		mts.Nil(err)
+		mts.Equal("hello", edited.Content)
+		mts.NotNil(edited.EditedAt)
+	}
+
+	conversations, err := NewConversationService(mts.Storage().conversations(), newTestCache()).GetConversations(testCtx, "user2")
+	mts.Nil(err)
+	mts.Equal("hello", conversations[0].LastMessagePreview)
+
+	cleanTable(mts)
+}
+
+func (mts *MessagesTestSuite) TestEditMessage_Keeps_Deleted_For_User() {
+	cleanTable(mts)
+
+	msg := &models.Message{Sender: "user1", Recipient: "user2", Content: "helo"}
+	mts.Nil(mts.Service().CreateMessage(testCtx, msg))
+	mts.Nil(mts.Service().DeleteMessageForUser(testCtx, "user2", msg))
+
+	stored, err := mts.Service().GetMessage(testCtx, "user1", msg.ID)
+	mts.Nil(err)
+
+	stored.Content = "hello"
+	mts.Nil(mts.Service().EditMessage(testCtx, stored))
+
+	deleted, err := mts.Service().GetMessage(testCtx, "user2", msg.ID)
+	mts.Nil(err)
+	mts.Nil(deleted)
+
+	cleanTable(mts)
+}
+
+func (mts *MessagesTestSuite) TestDeleteMessage_For_Everyone() {
+	cleanTable(mts)
+
+	msg := &models.Message{Sender: "user1", Recipient: "user2", Content: "oops"}
+	mts.Nil(mts.Service().CreateMessage(testCtx, msg))
+
+	stored, err := mts.Service().GetMessage(testCtx, "user1", msg.ID)
+	mts.Nil(err)
+	mts.Nil(mts.Service().DeleteMessage(testCtx, stored))
+
+	for _, user := range []string{"user1", "user2"} {
+		history, err := mts.Service().GetMessages(testCtx, user, models.MessagesPage{})
+
+		mts.Nil(err)
+		mts.Empty(history)
+	}
+
+	cleanTable(mts)
+}
+
+func (mts *MessagesTestSuite) TestGetMessage_Not_Found() {
+	actual, err := mts.Service().GetMessage(testCtx, "test-non-exist", gocql.TimeUUID())
+
+	mts.Nil(err)
+	mts.Nil(actual)
+}
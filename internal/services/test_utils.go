@@ -3,8 +3,13 @@
 package services
 
 import (
+	"chat-system/internal/api/cache"
 	"chat-system/internal/cassandra"
+	"chat-system/internal/models"
+	"chat-system/internal/repositories"
+	"context"
 	"fmt"
+	"os"
 	"testing"
 	"time"
 
@@ -12,69 +17,195 @@ import (
 )
 
 const (
-	KEYSPACE_TEST         = "chat_test"
-	REPLICA_COUNT         = 2
-	CLUSTER_ADDRS         = "127.0.0.1"
-	MSGS_TEST_TABLE_NAME  = "messages"
-	USERS_TEST_TABLE_NAME = "users"
+	KEYSPACE_TEST                     = "chat_test"
+	REPLICA_COUNT                     = 2
+	CLUSTER_ADDRS                     = "127.0.0.1"
+	MSGS_TEST_TABLE_NAME              = "user_messages"
+	MSG_BUCKETS_TEST_TABLE_NAME       = "user_message_buckets"
+	USERS_TEST_TABLE_NAME             = "users"
+	CONVERSATIONS_TEST_TABLE_NAME     = "conversations"
+	CONVERSATION_MSGS_TEST_TABLE_NAME = "conversation_messages"
+	GROUPS_TEST_TABLE_NAME            = "groups"
+	GROUP_MEMBERS_TEST_TABLE_NAME     = "group_members"
+	GROUP_MSGS_TEST_TABLE_NAME        = "group_messages"
+	CACHE_TEST_MAX_ENTRIES            = 1000
+	// Set `TEST_STORAGE` to this to test against Cassandra rather than in memory
+	TEST_STORAGE_CASSANDRA = "cassandra"
 )
 
+// Context of the calls made by the tests
+var testCtx = context.Background()
+
 type TestSuite interface {
 	T() *testing.T
 	FailNowf(failureMessage string, msg string, args ...interface{}) bool
-	// Get Current DB Session Obj
-	DBSession() *gocql.Session
-	// Set Current DB Session Obj
-	SetDBSession(session *gocql.Session)
+	// Get Current Storage Obj
+	Storage() *testStorage
+	// Set Current Storage Obj
+	SetStorage(storage *testStorage)
 	// Get DB Table Name
 	DBTable() string
 	// Set DB Table Name
 	SetDBTable(tableName string)
 }
 
-func setupDatabase(ts TestSuite) *gocql.Session {
+// newTestCache keeps the cache of the services under test in memory, so they don't need Redis
+func newTestCache() cache.Cache {
+	return cache.NewMemoryCache(CACHE_TEST_MAX_ENTRIES, 0)
+}
+
+// testStorage backs the services under test, in memory unless `TEST_STORAGE=cassandra`
+type testStorage struct {
+	// Only set when testing against Cassandra
+	session *gocql.Session
+	queries *repositories.CassandraQueries
+	memory  *repositories.MemoryStore
+}
+
+func (s *testStorage) users() repositories.UserRepository {
+	if s.session == nil {
+		return repositories.NewMemoryUserRepository(s.memory)
+	}
+
+	return repositories.NewCassandraUserRepository(s.queries)
+}
+
+func (s *testStorage) messages() repositories.MessageRepository {
+	if s.session == nil {
+		return repositories.NewMemoryMessageRepository(s.memory)
+	}
+
+	return repositories.NewCassandraMessageRepository(s.queries)
+}
+
+func (s *testStorage) conversations() repositories.ConversationRepository {
+	if s.session == nil {
+		return repositories.NewMemoryConversationRepository(s.memory)
+	}
+
+	return repositories.NewCassandraConversationRepository(s.queries)
+}
+
+func (s *testStorage) groups() repositories.GroupRepository {
+	if s.session == nil {
+		return repositories.NewMemoryGroupRepository(s.memory)
+	}
+
+	return repositories.NewCassandraGroupRepository(s.queries)
+}
+
+func setupDatabase(ts TestSuite) *testStorage {
+	if os.Getenv("TEST_STORAGE") != TEST_STORAGE_CASSANDRA {
+		ts.T().Log("testing against in-memory storage")
+		storage := &testStorage{memory: repositories.NewMemoryStore()}
+		ts.SetStorage(storage)
+		return storage
+	}
+
 	// Establish Conn
 	ts.T().Log("setting up test database")
 
-	dbSession, err := cassandra.Init(KEYSPACE_TEST, REPLICA_COUNT, CLUSTER_ADDRS)
+	config := cassandra.DefaultConfig(CLUSTER_ADDRS)
+	config.ReplicationFactor = REPLICA_COUNT
+
+	dbSession, err := cassandra.Init(KEYSPACE_TEST, config)
 	if err != nil {
 		ts.FailNowf("unable to connect to test database", err.Error())
 	}
 	ts.T().Log("connected to test database")
 
+	// Only the tables of the suite get created, so the statements aren't prepared up front
+	queries, err := repositories.NewCassandraQueries(dbSession, repositories.CassandraTables{
+		Keyspace:             KEYSPACE_TEST,
+		Users:                USERS_TEST_TABLE_NAME,
+		Messages:             MSGS_TEST_TABLE_NAME,
+		MessageBuckets:       MSG_BUCKETS_TEST_TABLE_NAME,
+		Conversations:        CONVERSATIONS_TEST_TABLE_NAME,
+		ConversationMessages: CONVERSATION_MSGS_TEST_TABLE_NAME,
+		Groups:               GROUPS_TEST_TABLE_NAME,
+		GroupMembers:         GROUP_MEMBERS_TEST_TABLE_NAME,
+		GroupMessages:        GROUP_MSGS_TEST_TABLE_NAME,
+	})
+	if err != nil {
+		ts.FailNowf("unable to build test queries", err.Error())
+	}
+
 	// Configure test suite
-	ts.SetDBSession(dbSession)
+	storage := &testStorage{session: dbSession, queries: queries}
+	ts.SetStorage(storage)
 
-	// Create test table
-	err = ts.DBSession().Query(getCreateTestTableStmt(ts)).Exec()
-	if err != nil {
-		ts.FailNowf("unable to create table", err.Error())
+	// Create test tables
+	for _, tableName := range getTestTables(ts) {
+		err = dbSession.Query(getCreateTestTableStmt(tableName)).Exec()
+		if err != nil {
+			ts.FailNowf("unable to create table", err.Error())
+		}
+		ts.T().Logf("created '%s' table in test database", tableName)
+	}
+	return storage
+}
+
+// getTestTables lists the suite's table along with the ones written to alongside it
+func getTestTables(ts TestSuite) []string {
+	switch ts.DBTable() {
+	case MSGS_TEST_TABLE_NAME, CONVERSATIONS_TEST_TABLE_NAME:
+		return []string{
+			MSGS_TEST_TABLE_NAME,
+			MSG_BUCKETS_TEST_TABLE_NAME,
+			CONVERSATIONS_TEST_TABLE_NAME,
+			CONVERSATION_MSGS_TEST_TABLE_NAME,
+		}
+	case GROUPS_TEST_TABLE_NAME:
+		return []string{
+			GROUPS_TEST_TABLE_NAME,
+			GROUP_MEMBERS_TEST_TABLE_NAME,
+			GROUP_MSGS_TEST_TABLE_NAME,
+			MSGS_TEST_TABLE_NAME,
+			MSG_BUCKETS_TEST_TABLE_NAME,
+		}
+	default:
+		return []string{ts.DBTable()}
 	}
-	ts.T().Logf("created '%s' table in test database", ts.DBTable())
-	return dbSession
 }
 
-func getCreateTestTableStmt(ts TestSuite) string {
+func getCreateTestTableStmt(tableName string) string {
 	var createTableStmt string
 
-	switch ts.DBTable() {
+	switch tableName {
 	case MSGS_TEST_TABLE_NAME:
 		createTableStmt = fmt.Sprintf(
 			`
 				CREATE TABLE IF NOT EXISTS %s.%s
 				(
 					user TEXT,
+					bucket INT,
 					timestamp TIMESTAMP,
 					id UUID,
 					sender TEXT,
 					recipient TEXT,
 					content TEXT,
-					PRIMARY KEY (user, timestamp, id)
+					group_id UUID,
+					edited_at TIMESTAMP,
+					PRIMARY KEY ((user, bucket), timestamp, id)
 				)
 				WITH CLUSTERING ORDER BY (timestamp DESC)
 			`,
 			KEYSPACE_TEST,
-			ts.DBTable(),
+			tableName,
+		)
+	case MSG_BUCKETS_TEST_TABLE_NAME:
+		createTableStmt = fmt.Sprintf(
+			`
+				CREATE TABLE IF NOT EXISTS %s.%s
+				(
+					user TEXT,
+					bucket INT,
+					PRIMARY KEY (user, bucket)
+				)
+				WITH CLUSTERING ORDER BY (bucket DESC)
+			`,
+			KEYSPACE_TEST,
+			tableName,
 		)
 	case USERS_TEST_TABLE_NAME:
 		createTableStmt = fmt.Sprintf(
@@ -87,7 +218,93 @@ func getCreateTestTableStmt(ts TestSuite) string {
 
 			`,
 			KEYSPACE_TEST,
-			ts.DBTable(),
+			tableName,
+		)
+	case CONVERSATION_MSGS_TEST_TABLE_NAME:
+		createTableStmt = fmt.Sprintf(
+			`
+				CREATE TABLE IF NOT EXISTS %s.%s
+				(
+					user_a TEXT,
+					user_b TEXT,
+					timestamp TIMESTAMP,
+					id UUID,
+					sender TEXT,
+					recipient TEXT,
+					content TEXT,
+					edited_at TIMESTAMP,
+					PRIMARY KEY ((user_a, user_b), timestamp, id)
+				)
+				WITH CLUSTERING ORDER BY (timestamp DESC)
+			`,
+			KEYSPACE_TEST,
+			tableName,
+		)
+	case GROUPS_TEST_TABLE_NAME:
+		createTableStmt = fmt.Sprintf(
+			`
+				CREATE TABLE IF NOT EXISTS %s.%s
+				(
+					id UUID PRIMARY KEY,
+					name TEXT,
+					owner TEXT,
+					created_at TIMESTAMP
+				)
+			`,
+			KEYSPACE_TEST,
+			tableName,
+		)
+	case GROUP_MEMBERS_TEST_TABLE_NAME:
+		createTableStmt = fmt.Sprintf(
+			`
+				CREATE TABLE IF NOT EXISTS %s.%s
+				(
+					group_id UUID,
+					username TEXT,
+					role TEXT,
+					joined_at TIMESTAMP,
+					PRIMARY KEY (group_id, username)
+				)
+			`,
+			KEYSPACE_TEST,
+			tableName,
+		)
+	case GROUP_MSGS_TEST_TABLE_NAME:
+		createTableStmt = fmt.Sprintf(
+			`
+				CREATE TABLE IF NOT EXISTS %s.%s
+				(
+					group_id UUID,
+					timestamp TIMESTAMP,
+					id UUID,
+					sender TEXT,
+					content TEXT,
+					edited_at TIMESTAMP,
+					PRIMARY KEY (group_id, timestamp, id)
+				)
+				WITH CLUSTERING ORDER BY (timestamp DESC)
+			`,
+			KEYSPACE_TEST,
+			tableName,
+		)
+	case CONVERSATIONS_TEST_TABLE_NAME:
+		createTableStmt = fmt.Sprintf(
+			`
+				CREATE TABLE IF NOT EXISTS %s.%s
+				(
+					user TEXT,
+					peer TEXT,
+					last_message_id UUID,
+					last_message_sender TEXT,
+					last_message_preview TEXT,
+					last_message_at TIMESTAMP,
+					last_read_id UUID,
+					peer_last_read_id UUID,
+					PRIMARY KEY (user, peer)
+				)
+			`,
+			KEYSPACE_TEST,
+			tableName,
 		)
 	}
 
@@ -98,106 +315,99 @@ func seedTable(ts TestSuite) {
 	ts.T().Logf("seeding test table for test '%s'", ts.T().Name())
 
 	userPrefix := "user"
-	var errTable, errRecord error
+	var errRecord error
 	for i := 1; i <= 2; i++ {
 		userSuffix := fmt.Sprint(i)
 		switch ts.DBTable() {
 		case MSGS_TEST_TABLE_NAME:
-			errRecord = ts.DBSession().Query(
-				getSeedStmt(ts),
-				userPrefix+userSuffix,
-				time.Now(),
-				gocql.TimeUUID(),
-				userPrefix+userSuffix,
-				userPrefix+fmt.Sprint(i+1),
-				"test content",
-			).Exec()
+			errRecord = seedMessage(ts, userPrefix+userSuffix, userPrefix+fmt.Sprint(i+1), time.Now(), "test content")
 
 		case USERS_TEST_TABLE_NAME:
-			errRecord = ts.DBSession().Query(
-				getSeedStmt(ts),
-				userPrefix+userSuffix,
-				"",
-			).Exec()
+			errRecord = ts.Storage().users().CreateUser(testCtx, &models.User{Username: userPrefix + userSuffix})
 		}
 		if errRecord != nil {
 			ts.FailNowf(
 				"unable to seed one record for test table",
-				errTable.Error(),
+				errRecord.Error(),
 			)
 		}
 	}
 
-	if errTable != nil {
-		ts.FailNowf("unable to seed test table", errTable.Error())
-	}
-
 	ts.T().Logf("db seeded successfully for test '%s'", ts.T().Name())
 }
 
-func getSeedStmt(ts TestSuite) string {
-	switch ts.DBTable() {
-	case MSGS_TEST_TABLE_NAME:
-		return fmt.Sprintf(
-			`INSERT INTO %s.%s
-			(user, timestamp, id, sender, recipient, content)
-			VALUES (?, ?, ?, ?, ?, ?)`,
-			KEYSPACE_TEST,
-			ts.DBTable(),
-		)
-	case USERS_TEST_TABLE_NAME:
-		return fmt.Sprintf(
-			`INSERT INTO %s.%s
-			(username, password)
-			VALUES (?, ?)`,
-			KEYSPACE_TEST,
-			ts.DBTable(),
-		)
-	default:
-		return ""
-	}
+// seedMessage stores a message sent at that time, e.g in the past
+func seedMessage(ts TestSuite, sender, recipient string, timestamp time.Time, content string) error {
+	id := gocql.UUIDFromTime(timestamp)
+
+	return ts.Storage().messages().CreateMessage(testCtx, &models.Message{
+		ID:        id,
+		Sender:    sender,
+		Recipient: recipient,
+		Timestamp: models.TimestampOf(id),
+		Content:   content,
+	}, content)
+}
+
+// nextMillisecond lets the clock move past the current millisecond, since messages sent within the same one
+// are sorted by their IDs rather than newest first, same as the clustering order of Cassandra
+func nextMillisecond() {
+	time.Sleep(time.Millisecond)
 }
 
 func cleanTable(ts TestSuite) {
 	ts.T().Logf("cleaning test database after test '%s'", ts.T().Name())
 
-	query := fmt.Sprintf(
-		`TRUNCATE %s.%s`,
-		KEYSPACE_TEST,
-		ts.DBTable(),
-	)
+	if ts.Storage().session == nil {
+		ts.Storage().memory.Reset()
+		return
+	}
 
-	err := ts.DBSession().Query(query).Exec()
-	if err != nil {
-		ts.FailNowf("unable to clean table", err.Error())
+	for _, tableName := range getTestTables(ts) {
+		query := fmt.Sprintf(
+			`TRUNCATE %s.%s`,
+			KEYSPACE_TEST,
+			tableName,
+		)
+
+		err := ts.Storage().session.Query(query).Exec()
+		if err != nil {
+			ts.FailNowf("unable to clean table", err.Error())
+		}
 	}
 
 	ts.T().Logf("test database cleaned after test '%s'", ts.T().Name())
 }
 
 func tearDownDatabase(ts TestSuite) {
+	if ts.Storage().session == nil {
+		return
+	}
+
 	ts.T().Log("tearing down database")
 
-	query := fmt.Sprintf(
-		`DROP TABLE %s.%s`,
-		KEYSPACE_TEST,
-		ts.DBTable(),
-	)
+	for _, tableName := range getTestTables(ts) {
+		query := fmt.Sprintf(
+			`DROP TABLE IF EXISTS %s.%s`,
+			KEYSPACE_TEST,
+			tableName,
+		)
 
-	err := ts.DBSession().Query(query).Exec()
-	if err != nil {
-		ts.FailNowf("unable to drop table", err.Error())
+		err := ts.Storage().session.Query(query).Exec()
+		if err != nil {
+			ts.FailNowf("unable to drop table", err.Error())
+		}
 	}
 
-	query = fmt.Sprintf(
+	query := fmt.Sprintf(
 		`DROP KEYSPACE %s`,
 		KEYSPACE_TEST,
 	)
 
-	err = ts.DBSession().Query(query).Exec()
+	err := ts.Storage().session.Query(query).Exec()
 	if err != nil {
 		ts.FailNowf("unable to drop test database keyspace", err.Error())
 	}
 
-	ts.DBSession().Close()
+	ts.Storage().session.Close()
 }
@@ -0,0 +1,162 @@
+// The purpose of this package is to trace requests across the service with OpenTelemetry, down to their
+// Cassandra queries & Redis commands. Spans are exported to an OTLP collector (e.g Jaeger, Tempo) or stdout,
+// while the W3C trace context of the incoming requests is picked up so that traces carry on from the callers.
+
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	EXPORTER_NONE   = "none"
+	EXPORTER_OTLP   = "otlp"
+	EXPORTER_STDOUT = "stdout"
+
+	// OTLP over HTTP, e.g the default endpoint of a collector running next to the service
+	DEFAULT_OTLP_ENDPOINT = "http://localhost:4318"
+	DEFAULT_SAMPLE_RATIO  = 1.0
+
+	SERVICE_NAME = "chat-service"
+)
+
+// Path of the metrics scraped by Prometheus, which aren't traced
+const METRICS_PATH = "/metrics"
+
+// Time given to the spans left to be exported on shutdown
+const FLUSH_TIMEOUT = 5 * time.Second
+
+// Tracer starts the spans of the service. It's a no-op until Init sets an exporter up.
+var Tracer = otel.Tracer("chat-system")
+
+var provider *sdktrace.TracerProvider
+
+type Config struct {
+	Exporter string
+	// URL of the OTLP/HTTP collector, over TLS when https
+	OTLPEndpoint string
+	// Share of the new traces getting sampled, while the ones started by the callers follow their decision
+	SampleRatio float64
+}
+
+// ParseConfig reads the config of the traces out of the settings, i.e. `TRACING_EXPORTER` (none, otlp or stdout),
+// `TRACING_OTLP_ENDPOINT` & `TRACING_SAMPLE_RATIO`, falling back to the defaults
+func ParseConfig(get func(name string) string) (Config, error) {
+	config := Config{
+		Exporter:     EXPORTER_NONE,
+		OTLPEndpoint: DEFAULT_OTLP_ENDPOINT,
+		SampleRatio:  DEFAULT_SAMPLE_RATIO,
+	}
+
+	if exporter := get("TRACING_EXPORTER"); exporter != "" {
+		if exporter != EXPORTER_NONE && exporter != EXPORTER_OTLP && exporter != EXPORTER_STDOUT {
+			return config, fmt.Errorf("unknown tracing exporter %q", exporter)
+		}
+		config.Exporter = exporter
+	}
+
+	if endpoint := get("TRACING_OTLP_ENDPOINT"); endpoint != "" {
+		if u, err := url.Parse(endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return config, fmt.Errorf("invalid TRACING_OTLP_ENDPOINT: %q, expected an http(s) URL", endpoint)
+		}
+		config.OTLPEndpoint = endpoint
+	}
+
+	if value := get("TRACING_SAMPLE_RATIO"); value != "" {
+		ratio, err := strconv.ParseFloat(value, 64)
+		if err != nil || ratio < 0 || ratio > 1 {
+			return config, fmt.Errorf("invalid TRACING_SAMPLE_RATIO: %q, expected 0 to 1", value)
+		}
+		config.SampleRatio = ratio
+	}
+
+	return config, nil
+}
+
+// Init sets the exporter of the spans up, unless tracing is off. The trace context gets propagated either way.
+func Init(config Config) error {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch config.Exporter {
+	case EXPORTER_OTLP:
+		exporter, err = otlptracehttp.New(context.Background(), otlptracehttp.WithEndpointURL(config.OTLPEndpoint))
+	case EXPORTER_STDOUT:
+		exporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	default:
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create the %s span exporter: %w", config.Exporter, err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(semconv.ServiceName(SERVICE_NAME)))
+	if err != nil {
+		return fmt.Errorf("failed to describe the service: %w", err)
+	}
+
+	provider = sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(config.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	slog.Info("Tracing is exported", "exporter", config.Exporter)
+
+	return nil
+}
+
+// Close exports the spans left, once nothing gets traced anymore
+func Close() {
+	if provider == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), FLUSH_TIMEOUT)
+	defer cancel()
+
+	if err := provider.Shutdown(ctx); err != nil {
+		slog.Error("Failed to export the spans left", "error", err)
+	}
+}
+
+// Handler starts a span per incoming request, carrying on from the trace context of the caller if any.
+// Spans are named after the method until the router renames them after the matched route.
+// Scrapes of the metrics are left out, not to drown the traces worth looking at.
+func Handler(handler http.Handler) http.Handler {
+	return otelhttp.NewHandler(handler, "HTTP",
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			return r.Method
+		}),
+		otelhttp.WithFilter(func(r *http.Request) bool {
+			return r.URL.Path != METRICS_PATH
+		}),
+	)
+}
+
+// End ends the span, marking it as failed along with the error if any
+func End(span trace.Span, err error, options ...trace.SpanEndOption) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End(options...)
+}
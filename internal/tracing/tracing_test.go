@@ -0,0 +1,85 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+type TracingTestSuite struct {
+	suite.Suite
+}
+
+func TestTracingTestSuite(t *testing.T) {
+	suite.Run(t, new(TracingTestSuite))
+}
+
+func settings(values map[string]string) func(name string) string {
+	return func(name string) string { return values[name] }
+}
+
+func (tts *TracingTestSuite) Test_Defaults() {
+	config, err := ParseConfig(settings(nil))
+	tts.Require().NoError(err)
+
+	tts.Equal(Config{Exporter: EXPORTER_NONE, OTLPEndpoint: DEFAULT_OTLP_ENDPOINT, SampleRatio: 1}, config)
+}
+
+func (tts *TracingTestSuite) Test_OTLP() {
+	config, err := ParseConfig(settings(map[string]string{
+		"TRACING_EXPORTER":      "otlp",
+		"TRACING_OTLP_ENDPOINT": "https://collector:4318",
+		"TRACING_SAMPLE_RATIO":  "0.25",
+	}))
+	tts.Require().NoError(err)
+
+	tts.Equal(Config{Exporter: EXPORTER_OTLP, OTLPEndpoint: "https://collector:4318", SampleRatio: 0.25}, config)
+}
+
+func (tts *TracingTestSuite) Test_Invalid() {
+	for name, value := range map[string]string{
+		"TRACING_EXPORTER":      "jaeger",
+		"TRACING_OTLP_ENDPOINT": "collector:4318",
+		"TRACING_SAMPLE_RATIO":  "1.5",
+	} {
+		_, err := ParseConfig(settings(map[string]string{name: value}))
+		tts.Error(err, name)
+	}
+}
+
+func (tts *TracingTestSuite) Test_Off_By_Default() {
+	tts.Require().NoError(Init(Config{Exporter: EXPORTER_NONE}))
+
+	tts.Nil(provider)
+	Close()
+}
+
+func (tts *TracingTestSuite) Test_Stdout() {
+	tts.Require().NoError(Init(Config{Exporter: EXPORTER_STDOUT, SampleRatio: 1}))
+	defer func() { provider = nil }()
+
+	tts.NotNil(provider)
+	Close()
+}
+
+func (tts *TracingTestSuite) Test_End_Records_Error() {
+	recorder := tracetest.NewSpanRecorder()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+
+	_, span := tracer.Start(context.Background(), "failed")
+	End(span, errors.New("timeout"))
+	_, span = tracer.Start(context.Background(), "succeeded")
+	End(span, nil)
+
+	spans := recorder.Ended()
+	tts.Require().Len(spans, 2)
+	tts.Equal(codes.Error, spans[0].Status().Code)
+	tts.Equal("timeout", spans[0].Status().Description)
+	tts.Len(spans[0].Events(), 1)
+	tts.Equal(codes.Unset, spans[1].Status().Code)
+}
@@ -0,0 +1,274 @@
+// Code generated by mockery v2.43.2. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+
+	gocql "github.com/gocql/gocql"
+
+	mock "github.com/stretchr/testify/mock"
+
+	models "chat-system/internal/models"
+)
+
+// ConversationService is an autogenerated mock type for the ConversationService type
+type ConversationService struct {
+	mock.Mock
+}
+
+// CountUnread provides a mock function with given fields: ctx, username, peer, lastRead
+func (_m *ConversationService) CountUnread(ctx context.Context, username string, peer string, lastRead *models.Message) (int, error) {
+	ret := _m.Called(ctx, username, peer, lastRead)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CountUnread")
+	}
+
+	var r0 int
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, *models.Message) (int, error)); ok {
+		return rf(ctx, username, peer, lastRead)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, *models.Message) int); ok {
+		r0 = rf(ctx, username, peer, lastRead)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, *models.Message) error); ok {
+		r1 = rf(ctx, username, peer, lastRead)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetConversationMessage provides a mock function with given fields: ctx, username, peer, id
+func (_m *ConversationService) GetConversationMessage(ctx context.Context, username string, peer string, id gocql.UUID) (*models.Message, error) {
+	ret := _m.Called(ctx, username, peer, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetConversationMessage")
+	}
+
+	var r0 *models.Message
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, gocql.UUID) (*models.Message, error)); ok {
+		return rf(ctx, username, peer, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, gocql.UUID) *models.Message); ok {
+		r0 = rf(ctx, username, peer, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.Message)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, gocql.UUID) error); ok {
+		r1 = rf(ctx, username, peer, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetConversationMessages provides a mock function with given fields: ctx, username, peer, page
+func (_m *ConversationService) GetConversationMessages(ctx context.Context, username string, peer string, page models.MessagesPage) ([]models.Message, error) {
+	ret := _m.Called(ctx, username, peer, page)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetConversationMessages")
+	}
+
+	var r0 []models.Message
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, models.MessagesPage) ([]models.Message, error)); ok {
+		return rf(ctx, username, peer, page)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, models.MessagesPage) []models.Message); ok {
+		r0 = rf(ctx, username, peer, page)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.Message)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, models.MessagesPage) error); ok {
+		r1 = rf(ctx, username, peer, page)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetConversations provides a mock function with given fields: ctx, username
+func (_m *ConversationService) GetConversations(ctx context.Context, username string) ([]models.Conversation, error) {
+	ret := _m.Called(ctx, username)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetConversations")
+	}
+
+	var r0 []models.Conversation
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) ([]models.Conversation, error)); ok {
+		return rf(ctx, username)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) []models.Conversation); ok {
+		r0 = rf(ctx, username)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.Conversation)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, username)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetReadMarks provides a mock function with given fields: ctx, username
+func (_m *ConversationService) GetReadMarks(ctx context.Context, username string) (map[string]gocql.UUID, error) {
+	ret := _m.Called(ctx, username)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetReadMarks")
+	}
+
+	var r0 map[string]gocql.UUID
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (map[string]gocql.UUID, error)); ok {
+		return rf(ctx, username)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) map[string]gocql.UUID); ok {
+		r0 = rf(ctx, username)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(map[string]gocql.UUID)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, username)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetUnreadCounts provides a mock function with given fields: ctx, username
+func (_m *ConversationService) GetUnreadCounts(ctx context.Context, username string) (map[string]int64, error) {
+	ret := _m.Called(ctx, username)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetUnreadCounts")
+	}
+
+	var r0 map[string]int64
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (map[string]int64, error)); ok {
+		return rf(ctx, username)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) map[string]int64); ok {
+		r0 = rf(ctx, username)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(map[string]int64)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, username)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// IncrUnreadCount provides a mock function with given fields: ctx, username, peer
+func (_m *ConversationService) IncrUnreadCount(ctx context.Context, username string, peer string) error {
+	ret := _m.Called(ctx, username, peer)
+
+	if len(ret) == 0 {
+		panic("no return value specified for IncrUnreadCount")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, username, peer)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MarkAsRead provides a mock function with given fields: ctx, username, peer, lastRead
+func (_m *ConversationService) MarkAsRead(ctx context.Context, username string, peer string, lastRead *models.Message) error {
+	ret := _m.Called(ctx, username, peer, lastRead)
+
+	if len(ret) == 0 {
+		panic("no return value specified for MarkAsRead")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, *models.Message) error); ok {
+		r0 = rf(ctx, username, peer, lastRead)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RecountUnread provides a mock function with given fields: ctx, username, peer, lastRead
+func (_m *ConversationService) RecountUnread(ctx context.Context, username string, peer string, lastRead *models.Message) (int, error) {
+	ret := _m.Called(ctx, username, peer, lastRead)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RecountUnread")
+	}
+
+	var r0 int
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, *models.Message) (int, error)); ok {
+		return rf(ctx, username, peer, lastRead)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, *models.Message) int); ok {
+		r0 = rf(ctx, username, peer, lastRead)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, *models.Message) error); ok {
+		r1 = rf(ctx, username, peer, lastRead)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewConversationService creates a new instance of ConversationService. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewConversationService(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *ConversationService {
+	mock := &ConversationService{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,200 @@
+// Code generated by mockery v2.43.2. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+
+	gocql "github.com/gocql/gocql"
+
+	mock "github.com/stretchr/testify/mock"
+
+	models "chat-system/internal/models"
+)
+
+// GroupService is an autogenerated mock type for the GroupService type
+type GroupService struct {
+	mock.Mock
+}
+
+// AddMember provides a mock function with given fields: ctx, groupID, username, role
+func (_m *GroupService) AddMember(ctx context.Context, groupID gocql.UUID, username string, role string) error {
+	ret := _m.Called(ctx, groupID, username, role)
+
+	if len(ret) == 0 {
+		panic("no return value specified for AddMember")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, gocql.UUID, string, string) error); ok {
+		r0 = rf(ctx, groupID, username, role)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// CreateGroup provides a mock function with given fields: ctx, group, members
+func (_m *GroupService) CreateGroup(ctx context.Context, group *models.Group, members []string) error {
+	ret := _m.Called(ctx, group, members)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CreateGroup")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *models.Group, []string) error); ok {
+		r0 = rf(ctx, group, members)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// CreateGroupMessage provides a mock function with given fields: ctx, message, members
+func (_m *GroupService) CreateGroupMessage(ctx context.Context, message *models.Message, members []string) error {
+	ret := _m.Called(ctx, message, members)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CreateGroupMessage")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *models.Message, []string) error); ok {
+		r0 = rf(ctx, message, members)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DeleteGroupMessage provides a mock function with given fields: ctx, message, members
+func (_m *GroupService) DeleteGroupMessage(ctx context.Context, message *models.Message, members []string) error {
+	ret := _m.Called(ctx, message, members)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteGroupMessage")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *models.Message, []string) error); ok {
+		r0 = rf(ctx, message, members)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// EditGroupMessage provides a mock function with given fields: ctx, message, members
+func (_m *GroupService) EditGroupMessage(ctx context.Context, message *models.Message, members []string) error {
+	ret := _m.Called(ctx, message, members)
+
+	if len(ret) == 0 {
+		panic("no return value specified for EditGroupMessage")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *models.Message, []string) error); ok {
+		r0 = rf(ctx, message, members)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// GetGroupMessages provides a mock function with given fields: ctx, groupID, page
+func (_m *GroupService) GetGroupMessages(ctx context.Context, groupID gocql.UUID, page models.MessagesPage) ([]models.Message, error) {
+	ret := _m.Called(ctx, groupID, page)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetGroupMessages")
+	}
+
+	var r0 []models.Message
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, gocql.UUID, models.MessagesPage) ([]models.Message, error)); ok {
+		return rf(ctx, groupID, page)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, gocql.UUID, models.MessagesPage) []models.Message); ok {
+		r0 = rf(ctx, groupID, page)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.Message)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, gocql.UUID, models.MessagesPage) error); ok {
+		r1 = rf(ctx, groupID, page)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetMembers provides a mock function with given fields: ctx, groupID
+func (_m *GroupService) GetMembers(ctx context.Context, groupID gocql.UUID) ([]models.GroupMember, error) {
+	ret := _m.Called(ctx, groupID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetMembers")
+	}
+
+	var r0 []models.GroupMember
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, gocql.UUID) ([]models.GroupMember, error)); ok {
+		return rf(ctx, groupID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, gocql.UUID) []models.GroupMember); ok {
+		r0 = rf(ctx, groupID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.GroupMember)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, gocql.UUID) error); ok {
+		r1 = rf(ctx, groupID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RemoveMember provides a mock function with given fields: ctx, groupID, username
+func (_m *GroupService) RemoveMember(ctx context.Context, groupID gocql.UUID, username string) error {
+	ret := _m.Called(ctx, groupID, username)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RemoveMember")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, gocql.UUID, string) error); ok {
+		r0 = rf(ctx, groupID, username)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// NewGroupService creates a new instance of GroupService. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewGroupService(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *GroupService {
+	mock := &GroupService{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -3,9 +3,13 @@
 package mocks
 
 import (
-	models "chat-system/internal/models"
+	context "context"
+
+	gocql "github.com/gocql/gocql"
 
 	mock "github.com/stretchr/testify/mock"
+
+	models "chat-system/internal/models"
 )
 
 // MessageService is an autogenerated mock type for the MessageService type
@@ -13,17 +17,170 @@ type MessageService struct {
 	mock.Mock
 }
 
-// CreateMessage provides a mock function with given fields: message
-func (_m *MessageService) CreateMessage(message *models.Message) error {
-	ret := _m.Called(message)
+// AcquireCacheRebuild provides a mock function with given fields: ctx, username
+func (_m *MessageService) AcquireCacheRebuild(ctx context.Context, username string) (string, bool, error) {
+	ret := _m.Called(ctx, username)
+
+	if len(ret) == 0 {
+		panic("no return value specified for AcquireCacheRebuild")
+	}
+
+	var r0 string
+	var r1 bool
+	var r2 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (string, bool, error)); ok {
+		return rf(ctx, username)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) string); ok {
+		r0 = rf(ctx, username)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) bool); ok {
+		r1 = rf(ctx, username)
+	} else {
+		r1 = ret.Get(1).(bool)
+	}
+
+	if rf, ok := ret.Get(2).(func(context.Context, string) error); ok {
+		r2 = rf(ctx, username)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// CacheMessage provides a mock function with given fields: ctx, username, message
+func (_m *MessageService) CacheMessage(ctx context.Context, username string, message *models.Message) error {
+	ret := _m.Called(ctx, username, message)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CacheMessage")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, *models.Message) error); ok {
+		r0 = rf(ctx, username, message)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// CacheMessages provides a mock function with given fields: ctx, username, messages, complete, version
+func (_m *MessageService) CacheMessages(ctx context.Context, username string, messages []models.Message, complete bool, version int64) error {
+	ret := _m.Called(ctx, username, messages, complete, version)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CacheMessages")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, []models.Message, bool, int64) error); ok {
+		r0 = rf(ctx, username, messages, complete, version)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// CachedMessagesVersion provides a mock function with given fields: ctx, username
+func (_m *MessageService) CachedMessagesVersion(ctx context.Context, username string) (int64, error) {
+	ret := _m.Called(ctx, username)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CachedMessagesVersion")
+	}
+
+	var r0 int64
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (int64, error)); ok {
+		return rf(ctx, username)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) int64); ok {
+		r0 = rf(ctx, username)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, username)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CreateMessage provides a mock function with given fields: ctx, message
+func (_m *MessageService) CreateMessage(ctx context.Context, message *models.Message) error {
+	ret := _m.Called(ctx, message)
 
 	if len(ret) == 0 {
 		panic("no return value specified for CreateMessage")
 	}
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(*models.Message) error); ok {
-		r0 = rf(message)
+	if rf, ok := ret.Get(0).(func(context.Context, *models.Message) error); ok {
+		r0 = rf(ctx, message)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DeleteMessage provides a mock function with given fields: ctx, message
+func (_m *MessageService) DeleteMessage(ctx context.Context, message *models.Message) error {
+	ret := _m.Called(ctx, message)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteMessage")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *models.Message) error); ok {
+		r0 = rf(ctx, message)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DeleteMessageForUser provides a mock function with given fields: ctx, username, message
+func (_m *MessageService) DeleteMessageForUser(ctx context.Context, username string, message *models.Message) error {
+	ret := _m.Called(ctx, username, message)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteMessageForUser")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, *models.Message) error); ok {
+		r0 = rf(ctx, username, message)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// EditMessage provides a mock function with given fields: ctx, message
+func (_m *MessageService) EditMessage(ctx context.Context, message *models.Message) error {
+	ret := _m.Called(ctx, message)
+
+	if len(ret) == 0 {
+		panic("no return value specified for EditMessage")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *models.Message) error); ok {
+		r0 = rf(ctx, message)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -31,29 +188,66 @@ func (_m *MessageService) CreateMessage(message *models.Message) error {
 	return r0
 }
 
-// GetFromCache provides a mock function with given fields: cacheKey
-func (_m *MessageService) GetFromCache(cacheKey string) ([]models.Message, error) {
-	ret := _m.Called(cacheKey)
+// GetCachedMessages provides a mock function with given fields: ctx, username, page
+func (_m *MessageService) GetCachedMessages(ctx context.Context, username string, page models.MessagesPage) ([]models.Message, bool, error) {
+	ret := _m.Called(ctx, username, page)
 
 	if len(ret) == 0 {
-		panic("no return value specified for GetFromCache")
+		panic("no return value specified for GetCachedMessages")
 	}
 
 	var r0 []models.Message
-	var r1 error
-	if rf, ok := ret.Get(0).(func(string) ([]models.Message, error)); ok {
-		return rf(cacheKey)
+	var r1 bool
+	var r2 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, models.MessagesPage) ([]models.Message, bool, error)); ok {
+		return rf(ctx, username, page)
 	}
-	if rf, ok := ret.Get(0).(func(string) []models.Message); ok {
-		r0 = rf(cacheKey)
+	if rf, ok := ret.Get(0).(func(context.Context, string, models.MessagesPage) []models.Message); ok {
+		r0 = rf(ctx, username, page)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).([]models.Message)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(string) error); ok {
-		r1 = rf(cacheKey)
+	if rf, ok := ret.Get(1).(func(context.Context, string, models.MessagesPage) bool); ok {
+		r1 = rf(ctx, username, page)
+	} else {
+		r1 = ret.Get(1).(bool)
+	}
+
+	if rf, ok := ret.Get(2).(func(context.Context, string, models.MessagesPage) error); ok {
+		r2 = rf(ctx, username, page)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// GetMessage provides a mock function with given fields: ctx, username, id
+func (_m *MessageService) GetMessage(ctx context.Context, username string, id gocql.UUID) (*models.Message, error) {
+	ret := _m.Called(ctx, username, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetMessage")
+	}
+
+	var r0 *models.Message
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, gocql.UUID) (*models.Message, error)); ok {
+		return rf(ctx, username, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, gocql.UUID) *models.Message); ok {
+		r0 = rf(ctx, username, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.Message)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, gocql.UUID) error); ok {
+		r1 = rf(ctx, username, id)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -61,9 +255,9 @@ func (_m *MessageService) GetFromCache(cacheKey string) ([]models.Message, error
 	return r0, r1
 }
 
-// GetMessages provides a mock function with given fields: username
-func (_m *MessageService) GetMessages(username string) ([]models.Message, error) {
-	ret := _m.Called(username)
+// GetMessages provides a mock function with given fields: ctx, username, page
+func (_m *MessageService) GetMessages(ctx context.Context, username string, page models.MessagesPage) ([]models.Message, error) {
+	ret := _m.Called(ctx, username, page)
 
 	if len(ret) == 0 {
 		panic("no return value specified for GetMessages")
@@ -71,19 +265,19 @@ func (_m *MessageService) GetMessages(username string) ([]models.Message, error)
 
 	var r0 []models.Message
 	var r1 error
-	if rf, ok := ret.Get(0).(func(string) ([]models.Message, error)); ok {
-		return rf(username)
+	if rf, ok := ret.Get(0).(func(context.Context, string, models.MessagesPage) ([]models.Message, error)); ok {
+		return rf(ctx, username, page)
 	}
-	if rf, ok := ret.Get(0).(func(string) []models.Message); ok {
-		r0 = rf(username)
+	if rf, ok := ret.Get(0).(func(context.Context, string, models.MessagesPage) []models.Message); ok {
+		r0 = rf(ctx, username, page)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).([]models.Message)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(string) error); ok {
-		r1 = rf(username)
+	if rf, ok := ret.Get(1).(func(context.Context, string, models.MessagesPage) error); ok {
+		r1 = rf(ctx, username, page)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -91,17 +285,17 @@ func (_m *MessageService) GetMessages(username string) ([]models.Message, error)
 	return r0, r1
 }
 
-// SetMessagesToCache provides a mock function with given fields: cacheKey, messages
-func (_m *MessageService) SetMessagesToCache(cacheKey string, messages []models.Message) error {
-	ret := _m.Called(cacheKey, messages)
+// InvalidateCachedMessages provides a mock function with given fields: ctx, username
+func (_m *MessageService) InvalidateCachedMessages(ctx context.Context, username string) error {
+	ret := _m.Called(ctx, username)
 
 	if len(ret) == 0 {
-		panic("no return value specified for SetMessagesToCache")
+		panic("no return value specified for InvalidateCachedMessages")
 	}
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(string, []models.Message) error); ok {
-		r0 = rf(cacheKey, messages)
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, username)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -109,17 +303,17 @@ func (_m *MessageService) SetMessagesToCache(cacheKey string, messages []models.
 	return r0
 }
 
-// UpdateCachedMsgsForUser provides a mock function with given fields: cacheKey, msg
-func (_m *MessageService) UpdateCachedMsgsForUser(cacheKey string, msg *models.Message) error {
-	ret := _m.Called(cacheKey, msg)
+// ReleaseCacheRebuild provides a mock function with given fields: ctx, username, token
+func (_m *MessageService) ReleaseCacheRebuild(ctx context.Context, username string, token string) error {
+	ret := _m.Called(ctx, username, token)
 
 	if len(ret) == 0 {
-		panic("no return value specified for UpdateCachedMsgsForUser")
+		panic("no return value specified for ReleaseCacheRebuild")
 	}
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(string, *models.Message) error); ok {
-		r0 = rf(cacheKey, msg)
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, username, token)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -4,6 +4,7 @@ package mocks
 
 import (
 	models "chat-system/internal/models"
+	context "context"
 
 	mock "github.com/stretchr/testify/mock"
 )
@@ -13,9 +14,9 @@ type UserService struct {
 	mock.Mock
 }
 
-// CreateUser provides a mock function with given fields: userInput
-func (_m *UserService) CreateUser(userInput *models.RegisterInput) (*models.User, error) {
-	ret := _m.Called(userInput)
+// CreateUser provides a mock function with given fields: ctx, userInput
+func (_m *UserService) CreateUser(ctx context.Context, userInput *models.RegisterInput) (*models.User, error) {
+	ret := _m.Called(ctx, userInput)
 
 	if len(ret) == 0 {
 		panic("no return value specified for CreateUser")
@@ -23,19 +24,19 @@ func (_m *UserService) CreateUser(userInput *models.RegisterInput) (*models.User
 
 	var r0 *models.User
 	var r1 error
-	if rf, ok := ret.Get(0).(func(*models.RegisterInput) (*models.User, error)); ok {
-		return rf(userInput)
+	if rf, ok := ret.Get(0).(func(context.Context, *models.RegisterInput) (*models.User, error)); ok {
+		return rf(ctx, userInput)
 	}
-	if rf, ok := ret.Get(0).(func(*models.RegisterInput) *models.User); ok {
-		r0 = rf(userInput)
+	if rf, ok := ret.Get(0).(func(context.Context, *models.RegisterInput) *models.User); ok {
+		r0 = rf(ctx, userInput)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*models.User)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(*models.RegisterInput) error); ok {
-		r1 = rf(userInput)
+	if rf, ok := ret.Get(1).(func(context.Context, *models.RegisterInput) error); ok {
+		r1 = rf(ctx, userInput)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -43,9 +44,9 @@ func (_m *UserService) CreateUser(userInput *models.RegisterInput) (*models.User
 	return r0, r1
 }
 
-// GetUserByCreds provides a mock function with given fields: credentials
-func (_m *UserService) GetUserByCreds(credentials models.LoginInput) (*models.User, error) {
-	ret := _m.Called(credentials)
+// GetUserByCreds provides a mock function with given fields: ctx, credentials
+func (_m *UserService) GetUserByCreds(ctx context.Context, credentials models.LoginInput) (*models.User, error) {
+	ret := _m.Called(ctx, credentials)
 
 	if len(ret) == 0 {
 		panic("no return value specified for GetUserByCreds")
@@ -53,19 +54,19 @@ func (_m *UserService) GetUserByCreds(credentials models.LoginInput) (*models.Us
 
 	var r0 *models.User
 	var r1 error
-	if rf, ok := ret.Get(0).(func(models.LoginInput) (*models.User, error)); ok {
-		return rf(credentials)
+	if rf, ok := ret.Get(0).(func(context.Context, models.LoginInput) (*models.User, error)); ok {
+		return rf(ctx, credentials)
 	}
-	if rf, ok := ret.Get(0).(func(models.LoginInput) *models.User); ok {
-		r0 = rf(credentials)
+	if rf, ok := ret.Get(0).(func(context.Context, models.LoginInput) *models.User); ok {
+		r0 = rf(ctx, credentials)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*models.User)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(models.LoginInput) error); ok {
-		r1 = rf(credentials)
+	if rf, ok := ret.Get(1).(func(context.Context, models.LoginInput) error); ok {
+		r1 = rf(ctx, credentials)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -73,9 +74,9 @@ func (_m *UserService) GetUserByCreds(credentials models.LoginInput) (*models.Us
 	return r0, r1
 }
 
-// UserExists provides a mock function with given fields: username
-func (_m *UserService) UserExists(username string) (bool, error) {
-	ret := _m.Called(username)
+// UserExists provides a mock function with given fields: ctx, username
+func (_m *UserService) UserExists(ctx context.Context, username string) (bool, error) {
+	ret := _m.Called(ctx, username)
 
 	if len(ret) == 0 {
 		panic("no return value specified for UserExists")
@@ -83,17 +84,17 @@ func (_m *UserService) UserExists(username string) (bool, error) {
 
 	var r0 bool
 	var r1 error
-	if rf, ok := ret.Get(0).(func(string) (bool, error)); ok {
-		return rf(username)
+	if rf, ok := ret.Get(0).(func(context.Context, string) (bool, error)); ok {
+		return rf(ctx, username)
 	}
-	if rf, ok := ret.Get(0).(func(string) bool); ok {
-		r0 = rf(username)
+	if rf, ok := ret.Get(0).(func(context.Context, string) bool); ok {
+		r0 = rf(ctx, username)
 	} else {
 		r0 = ret.Get(0).(bool)
 	}
 
-	if rf, ok := ret.Get(1).(func(string) error); ok {
-		r1 = rf(username)
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, username)
 	} else {
 		r1 = ret.Error(1)
 	}